@@ -0,0 +1,146 @@
+// Command kafka-ingest is internal/kafkaclient's counterpart to
+// cmd/minitrue-server's "ingestion" mode: instead of subscribing to an MQTT
+// topic, it joins a Kafka consumer group and feeds the same
+// {device_id, metric_name, timestamp, value} records into
+// ingestion.Service's hash-ring-aware write path. -mode=produce turns it
+// into a Kafka analog of cmd/publisher's -sim simulator, for parity between
+// the two ingest channels.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/minitrue/internal/cluster"
+	"github.com/minitrue/internal/ingestion"
+	"github.com/minitrue/internal/kafkaclient"
+	"github.com/minitrue/internal/storage"
+	"github.com/minitrue/pkg/models"
+	"github.com/minitrue/pkg/storage/backend"
+)
+
+func main() {
+	mode := flag.String("mode", "consume", "mode: consume | produce")
+	brokers := flag.String("brokers", "localhost:9092", "comma-separated Kafka broker addresses")
+	topic := flag.String("topic", "iot.sensors", "Kafka topic to consume from or produce to")
+	group := flag.String("group", "minitrue-ingest", "consumer group ID (shared across nodes to split partitions)")
+	nodeID := flag.String("node_id", "ing1", "node identifier (must be unique); also used as the Kafka client ID")
+	dataDir := flag.String("data_dir", "data", "directory for storing data files (-mode=consume only)")
+	tcpPort := flag.Int("tcp_port", 9100, "TCP port for internode communication (-mode=consume only)")
+	seedNodes := flag.String("seeds", "", "comma-separated list of seed node addresses (-mode=consume only)")
+	flag.Parse()
+
+	brokerList := strings.Split(*brokers, ",")
+
+	kc, err := kafkaclient.New(kafkaclient.Options{
+		Brokers:  brokerList,
+		Topic:    *topic,
+		GroupID:  *group,
+		ClientID: *nodeID,
+	})
+	if err != nil {
+		log.Fatalf("kafka connect: %v", err)
+	}
+	defer kc.Close()
+
+	switch *mode {
+	case "produce":
+		runProduce(kc)
+	case "consume":
+		runConsume(kc, *nodeID, *dataDir, *tcpPort, *seedNodes, *topic, *group)
+	default:
+		log.Fatalf("Unknown -mode: %s (must be: consume or produce)", *mode)
+	}
+}
+
+// runProduce simulates sensors the same way cmd/publisher's -sim branch
+// does, publishing to Kafka instead of MQTT.
+func runProduce(kc *kafkaclient.Client) {
+	devices := []string{"sensor_1", "sensor_2", "sensor_3"}
+	for {
+		did := devices[rand.Intn(len(devices))]
+		msg := map[string]interface{}{
+			"device_id":   did,
+			"metric_name": "temperature",
+			"timestamp":   time.Now().Unix(),
+			"value":       20.0 + rand.Float64()*10.0,
+		}
+		b, _ := json.Marshal(msg)
+		if err := kc.Publish(b); err != nil {
+			log.Printf("publish err: %v", err)
+		} else {
+			log.Printf("published simulated %s -> %s", did, string(b))
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// runConsume wires a minimal cluster membership and local storage, the same
+// pieces cmd/minitrue-server's "ingestion" mode wires, and feeds every
+// record a Kafka consumer group claim delivers into ingestion.Service's
+// hash-ring write path. The mqtt client passed to ingestion.New is nil:
+// Start (which subscribes to an MQTT topic) is never called here, since
+// Kafka drives record delivery instead.
+func runConsume(kc *kafkaclient.Client, nodeID, dataDir string, tcpPort int, seedNodes, topic, group string) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		log.Fatalf("Failed to create data directory: %v", err)
+	}
+	if err := cluster.InitHintStore(dataDir); err != nil {
+		log.Fatalf("Failed to initialize hinted-handoff store: %v", err)
+	}
+
+	storageBe := backend.NewDisk(dataDir)
+	segmentKey := fmt.Sprintf("%s.parq", nodeID)
+	walDir := filepath.Join(dataDir, fmt.Sprintf("%s.parq.wal", nodeID))
+	store := storage.NewUnifiedStorage(storageBe, segmentKey, walDir)
+	defer store.Close()
+
+	localNode := &models.NodeInfo{
+		ID:      nodeID,
+		Address: fmt.Sprintf("localhost:%d", tcpPort),
+		Status:  "active",
+	}
+	var seedNodesList []string
+	if seedNodes != "" {
+		seedNodesList = strings.Split(seedNodes, ",")
+	}
+
+	clusterMgr := cluster.GetClusterManager()
+	if err := clusterMgr.Initialize(localNode, tcpPort, seedNodesList); err != nil {
+		log.Fatalf("Failed to initialize cluster manager: %v", err)
+	}
+	defer clusterMgr.Stop()
+
+	ing := ingestion.New(nil, store, nodeID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		log.Printf("[%s] Shutting down...", nodeID)
+		cancel()
+	}()
+
+	log.Printf("[%s] kafka-ingest consuming topic=%s group=%s", nodeID, topic, group)
+	if err := kc.Consume(ctx, func(payload []byte) error {
+		var p ingestion.DataPoint
+		if err := json.Unmarshal(payload, &p); err != nil {
+			log.Printf("[%s][kafka-ingest] failed to parse json: %v payload=%s", nodeID, err, string(payload))
+			return nil
+		}
+		return ing.IngestDataPoint(p)
+	}); err != nil && ctx.Err() == nil {
+		log.Fatalf("kafka consume: %v", err)
+	}
+}
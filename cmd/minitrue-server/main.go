@@ -1,11 +1,11 @@
 package main
 
 import (
+	"crypto/sha256"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strconv"
@@ -18,7 +18,9 @@ import (
 	"github.com/minitrue/internal/mqttclient"
 	"github.com/minitrue/internal/query"
 	"github.com/minitrue/internal/storage"
+	"github.com/minitrue/internal/transport/secure"
 	"github.com/minitrue/pkg/models"
+	"github.com/minitrue/pkg/storage/backend"
 )
 
 func main() {
@@ -29,53 +31,55 @@ func main() {
 	broker := flag.String("broker", "tcp://localhost:1883", "MQTT broker URL")
 	dataDir := flag.String("data_dir", "data", "directory for storing data files")
 	seedNodes := flag.String("seeds", "", "comma-separated list of seed node addresses (e.g., localhost:9001,localhost:9002)")
+	joinAddrs := flag.String("join", "", "comma-separated list of seed node addresses to join (alias of -seeds)")
+	suspectTimeout := flag.Duration("suspect-timeout", 10*time.Second, "how long a node can go without a heartbeat before discovery marks it down")
+	replicationFactor := flag.Int("replication-factor", 2, "number of replicas (primary included) each write fans out to; must match across every node in the deployment")
+	raftBootstrap := flag.Bool("raft-bootstrap", false, "bootstrap this node as the initial cluster-state leader")
+	raftLeader := flag.String("raft-leader", "", "address of the current cluster-state leader (for nodes joining an existing cluster)")
+	mqttUsername := flag.String("mqtt-username", "", "username for MQTT broker authentication")
+	mqttPassword := flag.String("mqtt-password", "", "password for MQTT broker authentication")
+	authConfigPath := flag.String("auth-config", "", "path to YAML auth pipeline config (client tokens, topic ACLs, rate limits)")
+	clusterKeyPath := flag.String("cluster-key", "", fmt.Sprintf("path to the shared cluster token file gating internode auth (falls back to %s)", secure.ClusterKeyEnvVar))
+	tlsMode := flag.String("tls-mode", "off", "internode transport security: off | required")
+	gossipKeyPath := flag.String("gossip-key", "", fmt.Sprintf("path to the shared key file enabling AES-GCM gossip encryption (falls back to %s); with neither set, gossip stays plaintext", secure.ClusterKeyEnvVar))
+	gossipKeyID := flag.String("gossip-key-id", "default", "ID to register -gossip-key's key under, for BroadcastKeyRotation")
+	gossipRequireAuth := flag.Bool("gossip-require-auth", false, "reject gossip payloads that don't decrypt under a known key instead of treating them as plaintext; only safe once every node has -gossip-key installed")
+	ringLoadFactor := flag.Float64("ring-load-factor", 0, "use a capacity-aware BoundedRing for primary placement with this load factor (>=1; 0 keeps the plain, uncapped hash ring); must match across every node in the deployment")
+	storageBackend := flag.String("storage-backend", "disk", "where segment files live: disk | s3")
+	s3Endpoint := flag.String("s3-endpoint", "", "S3-compatible endpoint (host:port) for -storage-backend=s3")
+	s3AccessKey := flag.String("s3-access-key", "", "access key for -storage-backend=s3")
+	s3SecretKey := flag.String("s3-secret-key", "", "secret key for -storage-backend=s3")
+	s3Bucket := flag.String("s3-bucket", "", "bucket name for -storage-backend=s3")
+	s3Prefix := flag.String("s3-prefix", "", "object key prefix for -storage-backend=s3 (defaults to bucket root)")
+	s3UseSSL := flag.Bool("s3-use-ssl", true, "use TLS when talking to -storage-backend=s3")
 	flag.Parse()
 
-	// Store original command line arguments for restart
-	originalArgs := os.Args
-	executable, err := os.Executable()
-	if err != nil {
-		// Fallback to os.Args[0] if Executable() fails
-		executable = os.Args[0]
-	}
-
-	// Create restart function
-	restartFn := func() {
-		log.Printf("[Restart] Restarting server...")
-
-		var cmd *exec.Cmd
-
-		// Check if we're running via "go run" (temp binary in go-build* directories)
-		// This works for Linux (/tmp/go-build*), macOS (/var/folders/.../go-build*), Windows
-		execPath := strings.ToLower(executable)
-		if strings.Contains(execPath, "go-build") ||
-			strings.Contains(execPath, filepath.Join("tmp", "go-build")) ||
-			strings.Contains(execPath, filepath.Join("var", "folders")) {
-			// Running via go run - use go run with source file
-			args := []string{"run", "cmd/minitrue-server/main.go"}
-			args = append(args, originalArgs[1:]...)
-			cmd = exec.Command("go", args...)
-		} else {
-			// Running compiled binary - use executable directly
-			cmd = exec.Command(executable, originalArgs[1:]...)
+	switch *tlsMode {
+	case "off":
+		// No secure transport - every internode connection stays plaintext,
+		// same as before this flag existed.
+	case "required":
+		token, err := secure.LoadClusterToken(*clusterKeyPath)
+		if err != nil {
+			log.Fatalf("-tls-mode=required: %v", err)
 		}
+		cluster.InitSecureTransport(token)
+	default:
+		log.Fatalf("Unknown -tls-mode: %s (must be: off or required)", *tlsMode)
+	}
 
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = os.Stdin
-
-		// Start the new process
-		if err := cmd.Start(); err != nil {
-			log.Printf("[Restart] Failed to restart: %v", err)
-			return
+	if *gossipKeyPath != "" || os.Getenv(secure.ClusterKeyEnvVar) != "" {
+		rawKey, err := secure.LoadClusterToken(*gossipKeyPath)
+		if err != nil {
+			log.Fatalf("-gossip-key: %v", err)
+		}
+		// network.Keyring requires an exact 32-byte key; hash whatever-length
+		// secret the operator supplied down to one rather than asking them to
+		// provision AES-256 key material byte-for-byte.
+		gossipKey := sha256.Sum256(rawKey)
+		if _, err := cluster.InitGossipKeyring(*gossipKeyID, gossipKey[:], *gossipRequireAuth); err != nil {
+			log.Fatalf("-gossip-key: %v", err)
 		}
-
-		// Give it a moment to start
-		time.Sleep(200 * time.Millisecond)
-
-		// Exit current process
-		log.Printf("[Restart] Exiting current process...")
-		os.Exit(0)
 	}
 
 	// Auto-assign ports based on node ID if not specified
@@ -95,8 +99,19 @@ func main() {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
 
-	storageFile := filepath.Join(*dataDir, fmt.Sprintf("%s.parq", *nodeID))
-	store := storage.NewUnifiedStorage(storageFile)
+	if err := cluster.InitHintStore(*dataDir); err != nil {
+		log.Fatalf("Failed to initialize hinted-handoff store: %v", err)
+	}
+	cluster.SetReplicationFactor(*replicationFactor)
+	cluster.SetRingLoadFactor(*ringLoadFactor)
+
+	storageBe, err := newStorageBackend(*storageBackend, *dataDir, *s3Endpoint, *s3AccessKey, *s3SecretKey, *s3Bucket, *s3Prefix, *s3UseSSL)
+	if err != nil {
+		log.Fatalf("Failed to initialize -storage-backend=%s: %v", *storageBackend, err)
+	}
+	segmentKey := fmt.Sprintf("%s.parq", *nodeID)
+	walDir := filepath.Join(*dataDir, fmt.Sprintf("%s.parq.wal", *nodeID))
+	store := storage.NewUnifiedStorage(storageBe, segmentKey, walDir)
 	defer store.Close()
 
 	// Initialize cluster manager (gossip protocol + TCP server)
@@ -110,20 +125,40 @@ func main() {
 
 	seedNodesList := []string{}
 	if *seedNodes != "" {
-		seedNodesList = strings.Split(*seedNodes, ",")
+		seedNodesList = append(seedNodesList, strings.Split(*seedNodes, ",")...)
+	}
+	if *joinAddrs != "" {
+		seedNodesList = append(seedNodesList, strings.Split(*joinAddrs, ",")...)
 	}
 
 	clusterMgr := cluster.GetClusterManager()
-	if err := clusterMgr.Initialize(localNode, actualTCPPort, seedNodesList); err != nil {
+	if err := clusterMgr.InitializeWithSuspectTimeout(localNode, actualTCPPort, seedNodesList, *suspectTimeout); err != nil {
 		log.Fatalf("Failed to initialize cluster manager: %v", err)
 	}
 	defer clusterMgr.Stop()
 
 	log.Printf("[%s] Cluster manager initialized (TCP server on port %d)", *nodeID, actualTCPPort)
 
+	if err := cluster.GetFSM().InitPersistence(*dataDir); err != nil {
+		log.Fatalf("Failed to initialize cluster FSM persistence: %v", err)
+	}
+
+	if *raftBootstrap {
+		cluster.GetFSM().Bootstrap(*nodeID)
+		log.Printf("[%s] Bootstrapped as cluster-state leader", *nodeID)
+	} else if *raftLeader != "" {
+		if err := cluster.GetFSM().Join(*nodeID, *raftLeader); err != nil {
+			log.Printf("[%s] Failed to join cluster leader at %s: %v", *nodeID, *raftLeader, err)
+		} else {
+			log.Printf("[%s] Joined cluster via leader at %s", *nodeID, *raftLeader)
+		}
+	}
+
 	mqttOpts := mqttclient.Options{
 		BrokerURL: *broker,
 		ClientID:  fmt.Sprintf("minitrue-%s-%d", *nodeID, time.Now().UnixNano()),
+		Username:  *mqttUsername,
+		Password:  *mqttPassword,
 	}
 	mqttc, err := mqttclient.New(mqttOpts)
 	if err != nil {
@@ -131,21 +166,31 @@ func main() {
 	}
 	defer mqttc.Close()
 
+	auth := mqttclient.NewAuthPipeline()
+	if *authConfigPath != "" {
+		authCfg, err := mqttclient.LoadAuthConfig(*authConfigPath)
+		if err != nil {
+			log.Fatalf("Failed to load auth config: %v", err)
+		}
+		auth = authCfg.BuildPipeline()
+		log.Printf("[%s] Loaded auth pipeline from %s (%d device(s) configured)", *nodeID, *authConfigPath, len(authCfg.Devices))
+	}
+
 	switch *mode {
 	case "ingestion":
-		ing := ingestion.New(mqttc, store, *nodeID)
+		ing := ingestion.NewWithAuth(mqttc, store, *nodeID, auth)
 		ing.Start()
 		log.Printf("[%s] Ingestion service started", *nodeID)
 	case "query":
-		q := query.NewWithRestart(mqttc, store, *nodeID, restartFn)
+		q := query.NewWithNodeID(mqttc, store, *nodeID)
 		go q.StartHTTP(actualHTTPPort)
 		log.Printf("[%s] Query HTTP server running on :%d", *nodeID, actualHTTPPort)
 	case "all":
-		ing := ingestion.New(mqttc, store, *nodeID)
+		ing := ingestion.NewWithAuth(mqttc, store, *nodeID, auth)
 		ing.Start()
 		log.Printf("[%s] Ingestion service started", *nodeID)
 
-		q := query.NewWithRestart(mqttc, store, *nodeID, restartFn)
+		q := query.NewWithNodeID(mqttc, store, *nodeID)
 		go q.StartHTTP(actualHTTPPort)
 		log.Printf("[%s] Query HTTP server running on :%d", *nodeID, actualHTTPPort)
 	default:
@@ -187,3 +232,19 @@ func getDefaultHTTPPort(nodeID string) int {
 	// Default fallback
 	return 8080
 }
+
+// newStorageBackend builds the Backend that segment files are read from and
+// written to, per -storage-backend.
+func newStorageBackend(kind, dataDir, s3Endpoint, s3AccessKey, s3SecretKey, s3Bucket, s3Prefix string, s3UseSSL bool) (backend.Backend, error) {
+	switch kind {
+	case "disk":
+		return backend.NewDisk(dataDir), nil
+	case "s3":
+		if s3Endpoint == "" || s3Bucket == "" {
+			return nil, fmt.Errorf("-storage-backend=s3 requires -s3-endpoint and -s3-bucket")
+		}
+		return backend.NewS3(s3Endpoint, s3AccessKey, s3SecretKey, s3Bucket, s3Prefix, s3UseSSL)
+	default:
+		return nil, fmt.Errorf("unknown -storage-backend %q (must be: disk or s3)", kind)
+	}
+}
@@ -0,0 +1,27 @@
+package cluster
+
+// indexSummaryHandler serves an "index_summary" RPC (see
+// message_handler.go): given a cluster.ClusterConfig payload naming the
+// shard keys the requester cares about, it returns this node's
+// cluster.IndexSummary for just those shards, the same registered-callback
+// pattern rangeReconcileHandler and merkleBlockHandler use to avoid this
+// package importing storage directly.
+var indexSummaryHandler func(payload []byte) ([]byte, error)
+
+// SetIndexSummaryHandler registers the callback invoked when this node
+// receives an index-summary RPC over the internode TCP transport.
+func SetIndexSummaryHandler(handler func(payload []byte) ([]byte, error)) {
+	indexSummaryHandler = handler
+}
+
+// indexRequestHandler serves an "index_request" RPC: given a
+// cluster.IndexRequest naming one shard's mismatched hour buckets, it
+// returns a cluster.RecordResponse with that shard's records for those
+// hours.
+var indexRequestHandler func(payload []byte) ([]byte, error)
+
+// SetIndexRequestHandler registers the callback invoked when this node
+// receives an index-request RPC over the internode TCP transport.
+func SetIndexRequestHandler(handler func(payload []byte) ([]byte, error)) {
+	indexRequestHandler = handler
+}
@@ -0,0 +1,1003 @@
+// Package client implements the internode query RPC client: a per-node
+// pool of persistent, length-prefixed TCP connections (the same wire
+// format and port the gossip/replication traffic already uses, see
+// internal/cluster/message_handler.go) modeled on rqlite's approach to
+// node-to-node forwarding. It replaces opening a fresh HTTP request per
+// fan-out query with a small number of reused connections per peer, and
+// lets the caller register a local storage.Storage so requests destined
+// for the current node skip the network entirely.
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/minitrue/internal/cluster"
+	"github.com/minitrue/internal/storage"
+	"github.com/minitrue/pkg/models"
+	pkgcluster "github.com/minitrue/pkg/cluster"
+)
+
+// initialPoolSize and maxPoolSize bound each peer's connection pool: a few
+// connections are enough to pipeline fan-out queries to one node without
+// opening a new TCP connection (and handshake) per request, while max keeps
+// a single hot node from accumulating unbounded sockets under load.
+const (
+	initialPoolSize = 4
+	maxPoolSize     = 64
+)
+
+// maxResponseSize caps a single query RPC response, independent of
+// network.Server's 10 MiB request-side cap.
+const maxResponseSize = 10 * 1024 * 1024
+
+// QueryRequest mirrors query.QueryRequest's fields and JSON tags; it's
+// redeclared here rather than imported to avoid a cluster/client <-> query
+// import cycle (query.Service is this package's caller). The tags must
+// stay in sync with query.QueryRequest since both ends decode the same
+// wire payload.
+type QueryRequest struct {
+	DeviceID   string `json:"device_id"`
+	MetricName string `json:"metric_name"`
+	Operation  string `json:"operation"`
+	StartTime  int64  `json:"start_time"`
+	EndTime    int64  `json:"end_time"`
+}
+
+type samplesResponse struct {
+	Samples []float64 `json:"samples"`
+	Error   string    `json:"error,omitempty"`
+}
+
+type aggregatedResponse struct {
+	Stats storage.QueryStats `json:"stats"`
+	Error string             `json:"error,omitempty"`
+}
+
+// Client fans query RPCs out to other nodes over pooled TCP connections,
+// or straight into a local storage.Storage when SetLocal has registered
+// one for the target node.
+type Client struct {
+	dialTimeout time.Duration
+	connWrap    func(net.Conn) (net.Conn, error)
+
+	mu    sync.RWMutex
+	pools map[string]*connPool
+	local map[string]storage.Storage
+}
+
+// New builds a Client whose connections use dialTimeout and per-call read
+// deadline. If cluster.InitSecureTransport has been called, every
+// connection this Client dials performs that secure handshake before
+// carrying any query traffic - see cluster.GetConnWrap.
+func New(dialTimeout time.Duration) *Client {
+	c := &Client{
+		dialTimeout: dialTimeout,
+		connWrap:    cluster.GetConnWrap(),
+		pools:       make(map[string]*connPool),
+		local:       make(map[string]storage.Storage),
+	}
+	cluster.RegisterNodeEventListener(c.onNodeEvent)
+	return c
+}
+
+// SetLocal registers store as the in-process target for nodeID, so
+// QuerySamples/QueryAggregated calls for that node bypass the network and
+// call store directly. Callers register their own node here.
+func (c *Client) SetLocal(nodeID string, store storage.Storage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.local[nodeID] = store
+}
+
+func (c *Client) onNodeEvent(nodeID string, add bool) {
+	if add {
+		return
+	}
+	c.EvictNode(nodeID)
+}
+
+// EvictNode closes and discards nodeID's pooled connections. Safe to call
+// for a node with no pool.
+func (c *Client) EvictNode(nodeID string) {
+	c.mu.Lock()
+	pool := c.pools[nodeID]
+	delete(c.pools, nodeID)
+	c.mu.Unlock()
+
+	if pool != nil {
+		pool.closeAll()
+	}
+}
+
+// QuerySamples returns raw samples for req from nodeID.
+func (c *Client) QuerySamples(nodeID string, req QueryRequest) ([]float64, error) {
+	if store, ok := c.localStore(nodeID); ok {
+		return store.Query(req.DeviceID, req.MetricName, req.StartTime, req.EndTime)
+	}
+
+	var resp samplesResponse
+	if err := c.call(nodeID, "query_samples", req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Samples, nil
+}
+
+// QueryAggregated returns aggregated stats for req from nodeID.
+func (c *Client) QueryAggregated(nodeID string, req QueryRequest) (storage.QueryStats, error) {
+	if store, ok := c.localStore(nodeID); ok {
+		return store.QueryAggregated(req.DeviceID, req.MetricName, req.StartTime, req.EndTime)
+	}
+
+	var resp aggregatedResponse
+	if err := c.call(nodeID, "query_aggregated", req, &resp); err != nil {
+		return storage.QueryStats{}, err
+	}
+	if resp.Error != "" {
+		return storage.QueryStats{}, errors.New(resp.Error)
+	}
+	return resp.Stats, nil
+}
+
+// aggregatedCallResult is the asynchronous outcome of one query_aggregated
+// round-trip, used by QueryAggregatedCtx to race the read against ctx.
+type aggregatedCallResult struct {
+	resp aggregatedResponse
+	err  error
+}
+
+// QueryAggregatedCtx is QueryAggregated with a context: if ctx is done
+// before nodeID responds, the underlying pooled connection is discarded
+// rather than returned and ctx.Err() is returned, the same early-exit
+// contract QueryAggregatedBuckets uses for streaming calls. It backs the
+// query package's hedged and quorum ReadPolicy modes, where a request that
+// already lost the race needs to stop holding a connection open rather
+// than run to completion unattended.
+func (c *Client) QueryAggregatedCtx(ctx context.Context, nodeID string, req QueryRequest) (storage.QueryStats, error) {
+	if store, ok := c.localStore(nodeID); ok {
+		return store.QueryAggregated(req.DeviceID, req.MetricName, req.StartTime, req.EndTime)
+	}
+
+	pool, err := c.poolFor(nodeID)
+	if err != nil {
+		return storage.QueryStats{}, err
+	}
+	conn, err := pool.get()
+	if err != nil {
+		return storage.QueryStats{}, err
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		pool.put(conn)
+		return storage.QueryStats{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data, err := json.Marshal(models.InternalMessage{Type: "query_aggregated", Payload: json.RawMessage(payload)})
+	if err != nil {
+		pool.put(conn)
+		return storage.QueryStats{}, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	if err := writeMessage(conn, data); err != nil {
+		pool.discard(conn)
+		return storage.QueryStats{}, fmt.Errorf("failed to send request to %s: %w", nodeID, err)
+	}
+
+	done := make(chan aggregatedCallResult, 1)
+	go func() {
+		respData, err := readMessage(conn, maxResponseSize)
+		if err != nil {
+			done <- aggregatedCallResult{err: fmt.Errorf("failed to read response from %s: %w", nodeID, err)}
+			return
+		}
+		var resp aggregatedResponse
+		if err := json.Unmarshal(respData, &resp); err != nil {
+			done <- aggregatedCallResult{err: err}
+			return
+		}
+		done <- aggregatedCallResult{resp: resp}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			pool.discard(conn)
+			return storage.QueryStats{}, r.err
+		}
+		if r.resp.Error != "" {
+			pool.put(conn)
+			return storage.QueryStats{}, errors.New(r.resp.Error)
+		}
+		pool.put(conn)
+		return r.resp.Stats, nil
+	case <-ctx.Done():
+		pool.discard(conn)
+		return storage.QueryStats{}, ctx.Err()
+	}
+}
+
+// bucketRequest is QueryAggregatedBuckets' wire request: a QueryRequest
+// plus the bucket count, since the rest of the RPC surface only ever
+// sends QueryRequest as-is.
+type bucketRequest struct {
+	QueryRequest
+	Buckets int `json:"buckets"`
+}
+
+// bucketChunk is one frame of a "query_aggregated_stream" response. A
+// connection carries one bucketChunk per TimeBucket, followed by a final
+// chunk with Done set (or Error set, on failure) instead of a single
+// reply - see message_handler.go's streaming case.
+type bucketChunk struct {
+	Bucket storage.TimeBucket `json:"bucket"`
+	Done   bool               `json:"done,omitempty"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// QueryAggregatedBuckets streams per-time-bucket aggregated stats from
+// nodeID, calling onBucket as each bucket chunk arrives on the wire so a
+// coordinator merging many nodes' results can start combining before any
+// single node's scan finishes. ctx bounds the whole call: once it's done,
+// the underlying connection is torn down and ctx.Err() is returned even if
+// the remote node never sends a final chunk, so one slow replica can't hold
+// up the others.
+func (c *Client) QueryAggregatedBuckets(ctx context.Context, nodeID string, req QueryRequest, numBuckets int, onBucket func(storage.TimeBucket)) error {
+	if store, ok := c.localStore(nodeID); ok {
+		return store.QueryAggregatedBuckets(req.DeviceID, req.MetricName, req.StartTime, req.EndTime, numBuckets, func(b storage.TimeBucket) error {
+			onBucket(b)
+			return ctx.Err()
+		})
+	}
+
+	pool, err := c.poolFor(nodeID)
+	if err != nil {
+		return err
+	}
+	conn, err := pool.get()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(bucketRequest{QueryRequest: req, Buckets: numBuckets})
+	if err != nil {
+		pool.put(conn)
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data, err := json.Marshal(models.InternalMessage{Type: "query_aggregated_stream", Payload: json.RawMessage(payload)})
+	if err != nil {
+		pool.put(conn)
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	if err := writeMessage(conn, data); err != nil {
+		pool.discard(conn)
+		return fmt.Errorf("failed to send request to %s: %w", nodeID, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for {
+			frameData, err := readMessage(conn, maxResponseSize)
+			if err != nil {
+				done <- fmt.Errorf("failed to read chunk from %s: %w", nodeID, err)
+				return
+			}
+			var chunk bucketChunk
+			if err := json.Unmarshal(frameData, &chunk); err != nil {
+				done <- err
+				return
+			}
+			if chunk.Error != "" {
+				done <- errors.New(chunk.Error)
+				return
+			}
+			if chunk.Done {
+				done <- nil
+				return
+			}
+			onBucket(chunk.Bucket)
+		}
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			pool.discard(conn)
+			return err
+		}
+		pool.put(conn)
+		return nil
+	case <-ctx.Done():
+		pool.discard(conn)
+		return ctx.Err()
+	}
+}
+
+// walCatchupRequest is FetchWALSince's wire request.
+type walCatchupRequest struct {
+	AfterReqNum int64 `json:"after_req_num"`
+}
+
+// walCatchupChunk is one frame of a "wal_catchup" response - one per WAL
+// record, followed by a final chunk with Done (or Error) set, the same
+// shape bucketChunk uses for streaming aggregation.
+type walCatchupChunk struct {
+	Record storage.WALRecord `json:"record"`
+	Done   bool              `json:"done,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// FetchWALSince streams every WAL record nodeID has with a request number
+// greater than afterReqNum, calling onRecord as each arrives. It's used by a
+// node that has just (re)joined the gossip ring to catch up on writes and
+// deletes it missed while it was down, without waiting on the next ingest
+// to land. ctx bounds the whole call the same way QueryAggregatedBuckets
+// does - a peer that never finishes doesn't hold up the caller forever.
+func (c *Client) FetchWALSince(ctx context.Context, nodeID string, afterReqNum int64, onRecord func(storage.WALRecord)) error {
+	if store, ok := c.localStore(nodeID); ok {
+		records, err := store.WALRecordsSince(afterReqNum)
+		if err != nil {
+			return err
+		}
+		for _, rec := range records {
+			onRecord(rec)
+		}
+		return nil
+	}
+
+	pool, err := c.poolFor(nodeID)
+	if err != nil {
+		return err
+	}
+	conn, err := pool.get()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(walCatchupRequest{AfterReqNum: afterReqNum})
+	if err != nil {
+		pool.put(conn)
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data, err := json.Marshal(models.InternalMessage{Type: "wal_catchup", Payload: json.RawMessage(payload)})
+	if err != nil {
+		pool.put(conn)
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	if err := writeMessage(conn, data); err != nil {
+		pool.discard(conn)
+		return fmt.Errorf("failed to send request to %s: %w", nodeID, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for {
+			frameData, err := readMessage(conn, maxResponseSize)
+			if err != nil {
+				done <- fmt.Errorf("failed to read chunk from %s: %w", nodeID, err)
+				return
+			}
+			var chunk walCatchupChunk
+			if err := json.Unmarshal(frameData, &chunk); err != nil {
+				done <- err
+				return
+			}
+			if chunk.Error != "" {
+				done <- errors.New(chunk.Error)
+				return
+			}
+			if chunk.Done {
+				done <- nil
+				return
+			}
+			onRecord(chunk.Record)
+		}
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			pool.discard(conn)
+			return err
+		}
+		pool.put(conn)
+		return nil
+	case <-ctx.Done():
+		pool.discard(conn)
+		return ctx.Err()
+	}
+}
+
+// merkleBlockResponse is FetchMerkleBlock's wire response.
+type merkleBlockResponse struct {
+	Block *pkgcluster.MerkleBlock `json:"block,omitempty"`
+	Error string                  `json:"error,omitempty"`
+}
+
+// FetchMerkleBlock asks nodeID for an SPV-style proof of just the leaves in
+// its local tree matching predicate (e.g. a hash-ring key range, or a
+// device-ID prefix), instead of pulling its whole dataset through
+// NeedSync/GenerateSyncPlan. There is no local-store bypass here: unlike
+// QuerySamples/QueryAggregated, no provider has registered an in-process
+// tree for this node to read directly, so the request always goes over the
+// network, even for nodeID == this node.
+func (c *Client) FetchMerkleBlock(nodeID string, predicate pkgcluster.MerkleBlockPredicate) (*pkgcluster.MerkleBlock, error) {
+	pool, err := c.poolFor(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pool.get()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(predicate)
+	if err != nil {
+		pool.put(conn)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data, err := json.Marshal(models.InternalMessage{Type: "merkle_block", Payload: json.RawMessage(payload)})
+	if err != nil {
+		pool.put(conn)
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(c.dialTimeout))
+	if err := writeMessage(conn, data); err != nil {
+		pool.discard(conn)
+		return nil, fmt.Errorf("failed to send request to %s: %w", nodeID, err)
+	}
+
+	respData, err := readMessage(conn, maxResponseSize)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		pool.discard(conn)
+		return nil, fmt.Errorf("failed to read response from %s: %w", nodeID, err)
+	}
+	pool.put(conn)
+
+	var resp merkleBlockResponse
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Block, nil
+}
+
+// rangeCompareRequest is a single "merkle_reconcile" wire request: "how do
+// your leaves in [Low,High) compare against Hash".
+type rangeCompareRequest struct {
+	Low  string `json:"low"`
+	High string `json:"high"`
+	Hash string `json:"hash"`
+}
+
+// rangeCompareResponse is rangeCompareRequest's wire response, mirroring
+// pkgcluster.RangeCompareResult plus an out-of-band Error.
+type rangeCompareResponse struct {
+	Equal     bool     `json:"equal,omitempty"`
+	Terminal  bool     `json:"terminal,omitempty"`
+	Leaves    []string `json:"leaves,omitempty"`
+	Mid       string   `json:"mid,omitempty"`
+	LeftHash  string   `json:"left_hash,omitempty"`
+	RightHash string   `json:"right_hash,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// remoteRangePeer implements pkgcluster.RangePeer by issuing one
+// "merkle_reconcile" RPC per CompareRange call against a single node - the
+// adapter ReconcileRange drives as it recurses into mismatching halves.
+type remoteRangePeer struct {
+	client *Client
+	nodeID string
+}
+
+func (p *remoteRangePeer) CompareRange(low, high, hash string) (pkgcluster.RangeCompareResult, error) {
+	pool, err := p.client.poolFor(p.nodeID)
+	if err != nil {
+		return pkgcluster.RangeCompareResult{}, err
+	}
+
+	conn, err := pool.get()
+	if err != nil {
+		return pkgcluster.RangeCompareResult{}, err
+	}
+
+	payload, err := json.Marshal(rangeCompareRequest{Low: low, High: high, Hash: hash})
+	if err != nil {
+		pool.put(conn)
+		return pkgcluster.RangeCompareResult{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data, err := json.Marshal(models.InternalMessage{Type: "merkle_reconcile", Payload: json.RawMessage(payload)})
+	if err != nil {
+		pool.put(conn)
+		return pkgcluster.RangeCompareResult{}, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(p.client.dialTimeout))
+	if err := writeMessage(conn, data); err != nil {
+		pool.discard(conn)
+		return pkgcluster.RangeCompareResult{}, fmt.Errorf("failed to send request to %s: %w", p.nodeID, err)
+	}
+
+	respData, err := readMessage(conn, maxResponseSize)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		pool.discard(conn)
+		return pkgcluster.RangeCompareResult{}, fmt.Errorf("failed to read response from %s: %w", p.nodeID, err)
+	}
+	pool.put(conn)
+
+	var resp rangeCompareResponse
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		return pkgcluster.RangeCompareResult{}, err
+	}
+	if resp.Error != "" {
+		return pkgcluster.RangeCompareResult{}, errors.New(resp.Error)
+	}
+	return pkgcluster.RangeCompareResult{
+		Equal:     resp.Equal,
+		Terminal:  resp.Terminal,
+		Leaves:    resp.Leaves,
+		Mid:       resp.Mid,
+		LeftHash:  resp.LeftHash,
+		RightHash: resp.RightHash,
+	}, nil
+}
+
+// ReconcileRange drives pkgcluster.ReconcileRange against nodeID over the
+// network: local's leaves in [low,high) are compared against nodeID's,
+// recursing only into mismatching halves, so resyncing the shard range a
+// hash-ring rebalance just assigned to this node costs O(differences *
+// log N) round trips instead of a full CompareTrees.
+func (c *Client) ReconcileRange(nodeID string, local *pkgcluster.MerkleTree, low, high string) ([]pkgcluster.RangeDiff, error) {
+	return pkgcluster.ReconcileRange(local, &remoteRangePeer{client: c, nodeID: nodeID}, low, high)
+}
+
+// rangeProofRequest is a single "merkle_range_proof" wire request: "give me
+// a RangeProof for [Low,High) plus the root hash it was built against".
+type rangeProofRequest struct {
+	Low  string `json:"low"`
+	High string `json:"high"`
+}
+
+// rangeProofResponse is rangeProofRequest's wire response. Proof's element
+// type (pkgcluster.RangeProof's Tokens) is unexported, but its fields are
+// all exported, so json still round-trips it without this package needing
+// to name that type.
+type rangeProofResponse struct {
+	RootHash string                 `json:"root_hash"`
+	Proof    *pkgcluster.RangeProof `json:"proof"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// FetchRangeProof asks nodeID for a pkgcluster.RangeProof covering
+// [low, high) plus the root hash it was built against, in a single
+// "merkle_range_proof" round trip.
+func (c *Client) FetchRangeProof(nodeID, low, high string) (*pkgcluster.RangeProof, string, error) {
+	pool, err := c.poolFor(nodeID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	conn, err := pool.get()
+	if err != nil {
+		return nil, "", err
+	}
+
+	payload, err := json.Marshal(rangeProofRequest{Low: low, High: high})
+	if err != nil {
+		pool.put(conn)
+		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data, err := json.Marshal(models.InternalMessage{Type: "merkle_range_proof", Payload: json.RawMessage(payload)})
+	if err != nil {
+		pool.put(conn)
+		return nil, "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(c.dialTimeout))
+	if err := writeMessage(conn, data); err != nil {
+		pool.discard(conn)
+		return nil, "", fmt.Errorf("failed to send request to %s: %w", nodeID, err)
+	}
+
+	respData, err := readMessage(conn, maxResponseSize)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		pool.discard(conn)
+		return nil, "", fmt.Errorf("failed to read response from %s: %w", nodeID, err)
+	}
+	pool.put(conn)
+
+	var resp rangeProofResponse
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		return nil, "", err
+	}
+	if resp.Error != "" {
+		return nil, "", errors.New(resp.Error)
+	}
+	return resp.Proof, resp.RootHash, nil
+}
+
+// VerifyRangeSync fetches nodeID's RangeProof for [low, high), cryptographically
+// verifies it against the root hash it claims to be built from, and reports
+// any leaves local and nodeID disagree on within that range - the
+// single-round-trip counterpart to ReconcileRange's recursive CompareRange
+// walk, built on pkgcluster.DiffRangeProof instead.
+func (c *Client) VerifyRangeSync(nodeID string, local *pkgcluster.MerkleTree, low, high string) ([]pkgcluster.RangeDiff, error) {
+	proof, rootHash, err := c.FetchRangeProof(nodeID, low, high)
+	if err != nil {
+		return nil, err
+	}
+	return pkgcluster.DiffRangeProof(local, low, high, rootHash, proof)
+}
+
+// syncRootRequest/syncRootResponse and syncChildrenRequest/syncChildrenResponse
+// are the wire shapes for "merkle_sync_root"/"merkle_sync_children",
+// scoped to a single shard key so a verification walk stays cheap instead
+// of comparing a peer's entire dataset.
+type syncRootRequest struct {
+	ShardKey string `json:"shard_key"`
+}
+
+type syncRootResponse struct {
+	RootHash string `json:"root_hash"`
+	Error    string `json:"error,omitempty"`
+}
+
+type syncChildrenRequest struct {
+	ShardKey string `json:"shard_key"`
+	NodeHash string `json:"node_hash"`
+}
+
+type syncChildrenResponse struct {
+	LeftHash  string `json:"left_hash,omitempty"`
+	RightHash string `json:"right_hash,omitempty"`
+	IsLeaf    bool   `json:"is_leaf,omitempty"`
+	Data      string `json:"data,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// remoteSyncPeer implements pkgcluster.SyncPeer for a single shard key by
+// issuing one "merkle_sync_root"/"merkle_sync_children" RPC per call against
+// a single node - the adapter pkgcluster.Reconcile drives as it walks.
+type remoteSyncPeer struct {
+	client   *Client
+	nodeID   string
+	shardKey string
+}
+
+func (p *remoteSyncPeer) RequestRoot() (string, error) {
+	pool, err := p.client.poolFor(p.nodeID)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := pool.get()
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(syncRootRequest{ShardKey: p.shardKey})
+	if err != nil {
+		pool.put(conn)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data, err := json.Marshal(models.InternalMessage{Type: "merkle_sync_root", Payload: json.RawMessage(payload)})
+	if err != nil {
+		pool.put(conn)
+		return "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(p.client.dialTimeout))
+	if err := writeMessage(conn, data); err != nil {
+		pool.discard(conn)
+		return "", fmt.Errorf("failed to send request to %s: %w", p.nodeID, err)
+	}
+
+	respData, err := readMessage(conn, maxResponseSize)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		pool.discard(conn)
+		return "", fmt.Errorf("failed to read response from %s: %w", p.nodeID, err)
+	}
+	pool.put(conn)
+
+	var resp syncRootResponse
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		return "", err
+	}
+	if resp.Error != "" {
+		return "", errors.New(resp.Error)
+	}
+	return resp.RootHash, nil
+}
+
+func (p *remoteSyncPeer) RequestChildren(nodeHash string) (leftHash, rightHash string, isLeaf bool, data string, err error) {
+	pool, err := p.client.poolFor(p.nodeID)
+	if err != nil {
+		return "", "", false, "", err
+	}
+
+	conn, err := pool.get()
+	if err != nil {
+		return "", "", false, "", err
+	}
+
+	payload, err := json.Marshal(syncChildrenRequest{ShardKey: p.shardKey, NodeHash: nodeHash})
+	if err != nil {
+		pool.put(conn)
+		return "", "", false, "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data2, err := json.Marshal(models.InternalMessage{Type: "merkle_sync_children", Payload: json.RawMessage(payload)})
+	if err != nil {
+		pool.put(conn)
+		return "", "", false, "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(p.client.dialTimeout))
+	if err := writeMessage(conn, data2); err != nil {
+		pool.discard(conn)
+		return "", "", false, "", fmt.Errorf("failed to send request to %s: %w", p.nodeID, err)
+	}
+
+	respData, err := readMessage(conn, maxResponseSize)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		pool.discard(conn)
+		return "", "", false, "", fmt.Errorf("failed to read response from %s: %w", p.nodeID, err)
+	}
+	pool.put(conn)
+
+	var resp syncChildrenResponse
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		return "", "", false, "", err
+	}
+	if resp.Error != "" {
+		return "", "", false, "", errors.New(resp.Error)
+	}
+	return resp.LeftHash, resp.RightHash, resp.IsLeaf, resp.Data, nil
+}
+
+// VerifySync walks local (a shard-scoped MerkleTree) against nodeID's view
+// of the same shardKey and reports any leaves the two sides disagree on,
+// for use as a cheap post-merge check after anti-entropy pulls records for
+// a shard: a clean Reconcile confirms the merge actually closed the gap
+// instead of just trusting the hour-bucket hash it was triggered by.
+func (c *Client) VerifySync(nodeID, shardKey string, local *pkgcluster.MerkleTree) (missing, extra, conflicting []string, err error) {
+	return pkgcluster.Reconcile(local, &remoteSyncPeer{client: c, nodeID: nodeID, shardKey: shardKey})
+}
+
+// indexSummaryResponse is FetchIndexSummary's wire response, msgpack-encoded
+// like the rest of index exchange (see MarshalMsgpack).
+type indexSummaryResponse struct {
+	Summaries []pkgcluster.ShardSummary `codec:"summaries"`
+	Error     string                    `codec:"error"`
+}
+
+// FetchIndexSummary asks nodeID for its current pkgcluster.ShardSummary of
+// each shard key in shardKeys - the "index_summary" half of periodic
+// anti-entropy index exchange (see pkg/cluster/anti_entropy.go). The caller
+// diffs the result against its own summaries with pkgcluster.DiffShardSummary
+// to find which hour buckets, if any, need a FetchRecords follow-up.
+func (c *Client) FetchIndexSummary(nodeID string, shardKeys []string) ([]pkgcluster.ShardSummary, error) {
+	pool, err := c.poolFor(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pool.get()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := pkgcluster.MarshalMsgpack(pkgcluster.ClusterConfig{ShardKeys: shardKeys})
+	if err != nil {
+		pool.put(conn)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	// The index-summary/index-request payload is msgpack, not JSON like
+	// every other RPC's - base64 it into a JSON string so it can still ride
+	// inside the envelope message_handler.go dispatches on.
+	data, err := json.Marshal(models.InternalMessage{Type: "index_summary", Payload: base64.StdEncoding.EncodeToString(payload)})
+	if err != nil {
+		pool.put(conn)
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(c.dialTimeout))
+	if err := writeMessage(conn, data); err != nil {
+		pool.discard(conn)
+		return nil, fmt.Errorf("failed to send request to %s: %w", nodeID, err)
+	}
+
+	respData, err := readMessage(conn, maxResponseSize)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		pool.discard(conn)
+		return nil, fmt.Errorf("failed to read response from %s: %w", nodeID, err)
+	}
+	pool.put(conn)
+
+	var resp indexSummaryResponse
+	if err := pkgcluster.UnmarshalMsgpack(respData, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Summaries, nil
+}
+
+// recordResponseWire is FetchRecords's wire response, msgpack-encoded like
+// the rest of index exchange (see MarshalMsgpack).
+type recordResponseWire struct {
+	Records []pkgcluster.IndexRecord `codec:"records"`
+	Error   string                   `codec:"error"`
+}
+
+// FetchRecords asks nodeID for every record it holds for shardKey within
+// hours - the "index_request"/RecordResponse half of anti-entropy index
+// exchange. The caller merges the result via storage.Storage.PersistReplica,
+// the same path live replication and hinted-handoff replay already use.
+func (c *Client) FetchRecords(nodeID, shardKey string, hours []int64) ([]pkgcluster.IndexRecord, error) {
+	pool, err := c.poolFor(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pool.get()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := pkgcluster.MarshalMsgpack(pkgcluster.IndexRequest{ShardKey: shardKey, Hours: hours})
+	if err != nil {
+		pool.put(conn)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data, err := json.Marshal(models.InternalMessage{Type: "index_request", Payload: base64.StdEncoding.EncodeToString(payload)})
+	if err != nil {
+		pool.put(conn)
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(c.dialTimeout))
+	if err := writeMessage(conn, data); err != nil {
+		pool.discard(conn)
+		return nil, fmt.Errorf("failed to send request to %s: %w", nodeID, err)
+	}
+
+	respData, err := readMessage(conn, maxResponseSize)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		pool.discard(conn)
+		return nil, fmt.Errorf("failed to read response from %s: %w", nodeID, err)
+	}
+	pool.put(conn)
+
+	var resp recordResponseWire
+	if err := pkgcluster.UnmarshalMsgpack(respData, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Records, nil
+}
+
+func (c *Client) localStore(nodeID string) (storage.Storage, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	store, ok := c.local[nodeID]
+	return store, ok
+}
+
+func (c *Client) call(nodeID, msgType string, req QueryRequest, respVal interface{}) error {
+	pool, err := c.poolFor(nodeID)
+	if err != nil {
+		return err
+	}
+
+	conn, err := pool.get()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		pool.put(conn)
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	data, err := json.Marshal(models.InternalMessage{Type: msgType, Payload: json.RawMessage(payload)})
+	if err != nil {
+		pool.put(conn)
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(c.dialTimeout))
+	if err := writeMessage(conn, data); err != nil {
+		pool.discard(conn)
+		return fmt.Errorf("failed to send request to %s: %w", nodeID, err)
+	}
+
+	respData, err := readMessage(conn, maxResponseSize)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		pool.discard(conn)
+		return fmt.Errorf("failed to read response from %s: %w", nodeID, err)
+	}
+
+	pool.put(conn)
+	return json.Unmarshal(respData, respVal)
+}
+
+func (c *Client) poolFor(nodeID string) (*connPool, error) {
+	c.mu.RLock()
+	pool, ok := c.pools[nodeID]
+	c.mu.RUnlock()
+	if ok {
+		return pool, nil
+	}
+
+	addr, ok := cluster.GetNodeAddress(nodeID)
+	if !ok {
+		return nil, fmt.Errorf("no known address for node %s", nodeID)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pool, ok := c.pools[nodeID]; ok {
+		return pool, nil
+	}
+	pool = newConnPool(addr, initialPoolSize, maxPoolSize, c.dialTimeout, c.connWrap)
+	c.pools[nodeID] = pool
+	return pool, nil
+}
+
+// writeMessage writes data to conn as [4-byte length][data], the same
+// wire format network.Client/Server use.
+func writeMessage(conn net.Conn, data []byte) error {
+	lengthBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBytes, uint32(len(data)))
+	if _, err := conn.Write(lengthBytes); err != nil {
+		return err
+	}
+	_, err := conn.Write(data)
+	return err
+}
+
+// readMessage reads one [4-byte length][data] message from conn, rejecting
+// a declared length over maxSize.
+func readMessage(conn net.Conn, maxSize uint32) ([]byte, error) {
+	lengthBytes := make([]byte, 4)
+	if _, err := io.ReadFull(conn, lengthBytes); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(lengthBytes)
+	if length > maxSize {
+		return nil, fmt.Errorf("message size %d exceeds limit %d", length, maxSize)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
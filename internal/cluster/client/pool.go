@@ -0,0 +1,119 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// connPool is a bounded pool of persistent TCP connections to one peer.
+// initial connections are dialed eagerly so the first few queries to a
+// node don't pay a connect round trip; it grows lazily up to max under
+// concurrent load and refuses further connections past that, rather than
+// letting one hot peer accumulate unbounded sockets.
+type connPool struct {
+	address     string
+	dialTimeout time.Duration
+	max         int
+	connWrap    func(net.Conn) (net.Conn, error)
+
+	mu   sync.Mutex
+	idle []net.Conn
+	open int
+}
+
+// newConnPool builds a pool dialing address. connWrap, if non-nil, is
+// applied to every freshly dialed connection - initial and lazy alike -
+// before it's handed out, so a secure transport's handshake (see
+// internal/cluster.GetConnWrap) runs once per connection rather than once
+// per request.
+func newConnPool(address string, initial, max int, dialTimeout time.Duration, connWrap func(net.Conn) (net.Conn, error)) *connPool {
+	p := &connPool{
+		address:     address,
+		dialTimeout: dialTimeout,
+		max:         max,
+		connWrap:    connWrap,
+	}
+	for i := 0; i < initial; i++ {
+		conn, err := p.dial()
+		if err != nil {
+			break
+		}
+		p.idle = append(p.idle, conn)
+		p.open++
+	}
+	return p
+}
+
+func (p *connPool) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", p.address, p.dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if p.connWrap == nil {
+		return conn, nil
+	}
+	wrapped, err := p.connWrap(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("securing connection to %s: %w", p.address, err)
+	}
+	return wrapped, nil
+}
+
+// get returns an idle connection if one is available, otherwise dials a
+// new one as long as the pool is under max. The caller must return the
+// connection via put (reusable) or discard (broken).
+func (p *connPool) get() (net.Conn, error) {
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		conn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return conn, nil
+	}
+	if p.open >= p.max {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("connection pool for %s exhausted (max %d)", p.address, p.max)
+	}
+	p.open++
+	p.mu.Unlock()
+
+	conn, err := p.dial()
+	if err != nil {
+		p.mu.Lock()
+		p.open--
+		p.mu.Unlock()
+		return nil, fmt.Errorf("failed to connect to %s: %w", p.address, err)
+	}
+	return conn, nil
+}
+
+// put returns a still-good connection to the idle list for reuse.
+func (p *connPool) put(conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idle = append(p.idle, conn)
+}
+
+// discard closes a connection that failed mid-use and frees its slot so a
+// future get can dial a replacement.
+func (p *connPool) discard(conn net.Conn) {
+	conn.Close()
+	p.mu.Lock()
+	p.open--
+	p.mu.Unlock()
+}
+
+// closeAll closes every idle connection and resets the pool, used when the
+// peer has left the cluster (see Client.EvictNode).
+func (p *connPool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range p.idle {
+		conn.Close()
+	}
+	p.idle = nil
+	p.open = 0
+}
@@ -4,14 +4,105 @@ import (
 	"github.com/minitrue/pkg/cluster"
 )
 
+// HashRing is the placement interface the rest of this package, plus
+// internal/ingestion and internal/query, consult for primary/replica
+// assignment. cluster.ConsistentHashRing (the longstanding default)
+// satisfies it directly; boundedHashRing adapts pkg/cluster.BoundedRing for
+// deployments started with -ring-load-factor (see SetRingLoadFactor).
+type HashRing interface {
+	AddNode(nodeID string)
+	RemoveNode(nodeID string)
+	GetNode(key string) (string, error)
+	GetNodes(key string, count int) ([]string, error)
+	GetReplicas(key string, count int) ([]string, error)
+	GetAllNodes() []string
+}
+
 var (
-	hashRing *cluster.ConsistentHashRing
+	hashRing HashRing
+
+	// ringLoadFactor is the BoundedRing load factor newRing uses once set;
+	// 0 (the default) means every newRing call builds a plain, uncapped
+	// ConsistentHashRing instead.
+	ringLoadFactor float64
 )
 
+// SetRingLoadFactor switches to a capacity-aware BoundedRing with the given
+// load factor (see pkg/cluster.NewBoundedRing) for the current ring plus
+// every one newRing (re)builds from here on - including the one
+// Initialize/Restore reset to on membership changes. Call before
+// InitializeWithSuspectTimeout and before any node is added to the ring; a
+// load factor <= 0 restores the plain ConsistentHashRing behavior.
+func SetRingLoadFactor(c float64) {
+	ringLoadFactor = c
+	hashRing = newRing(150)
+}
+
+// newRing builds a fresh HashRing of whatever kind SetRingLoadFactor last
+// selected, with virtualNodes virtual nodes per member.
+func newRing(virtualNodes int) HashRing {
+	if ringLoadFactor > 0 {
+		return newBoundedHashRing(virtualNodes, ringLoadFactor)
+	}
+	return cluster.NewConsistentHashRing(virtualNodes)
+}
+
+// boundedHashRing adapts pkg/cluster.BoundedRing to HashRing, discarding the
+// []KeyMove slice AddNode/RemoveNode return - the same as ConsistentHashRing,
+// nothing here currently reacts to a rebalance by streaming data to
+// KeyMove.To. BoundedRing.Skips/Moves still expose its counters directly to
+// callers that hold onto the *cluster.BoundedRing themselves.
+type boundedHashRing struct {
+	br *cluster.BoundedRing
+}
+
+func newBoundedHashRing(virtualNodes int, loadFactor float64) *boundedHashRing {
+	return &boundedHashRing{br: cluster.NewBoundedRing(virtualNodes, loadFactor)}
+}
+
+func (b *boundedHashRing) AddNode(nodeID string)    { b.br.AddNode(nodeID) }
+func (b *boundedHashRing) RemoveNode(nodeID string) { b.br.RemoveNode(nodeID) }
+func (b *boundedHashRing) GetNode(key string) (string, error) {
+	return b.br.GetNode(key)
+}
+func (b *boundedHashRing) GetNodes(key string, count int) ([]string, error) {
+	return b.br.GetNodes(key, count)
+}
+func (b *boundedHashRing) GetReplicas(key string, count int) ([]string, error) {
+	return b.br.GetReplicas(key, count)
+}
+func (b *boundedHashRing) GetAllNodes() []string {
+	return b.br.GetAllNodes()
+}
+
+// replicationFactor is how many replicas (primary included) a write fans out
+// to and read-repair compares across. Every node in a deployment is expected
+// to be started with the same -replication-factor flag (see
+// cmd/minitrue-server), the same way discovery.Config's own ReplicationFactor
+// knob is configured independently per node today.
+var replicationFactor = 2
+
 func init() {
-	hashRing = cluster.NewConsistentHashRing(150)
+	hashRing = newRing(150)
+}
+
+// ReplicationFactor returns the configured replication factor.
+func ReplicationFactor() int {
+	return replicationFactor
+}
+
+// SetReplicationFactor overrides the replication factor, clamping below 1 up
+// to 1 (a replication factor of zero would mean nothing is ever stored).
+func SetReplicationFactor(n int) {
+	if n < 1 {
+		n = 1
+	}
+	replicationFactor = n
 }
 
+// GetPrimaryNode returns the primary node for deviceID according to the
+// ring maintained by the cluster FSM (see fsm.go): every node that has
+// applied the same replicated command log resolves to the same answer.
 func GetPrimaryNode(deviceID string) string {
 	node, err := hashRing.GetNode(deviceID)
 	if err != nil {
@@ -20,6 +111,9 @@ func GetPrimaryNode(deviceID string) string {
 	return node
 }
 
+// GetNodesForKey returns the primary plus replicationFactor-1 successor
+// nodes for key, read from the FSM-applied ring so placement is identical
+// on every node regardless of which one received the write.
 func GetNodesForKey(key string, replicationFactor int) []string {
 	nodes, err := hashRing.GetNodes(key, replicationFactor)
 	if err != nil || len(nodes) == 0 {
@@ -28,11 +122,11 @@ func GetNodesForKey(key string, replicationFactor int) []string {
 	return nodes
 }
 
-func GetHashRing() *cluster.ConsistentHashRing {
+func GetHashRing() HashRing {
 	return hashRing
 }
 
-func SetHashRing(ring *cluster.ConsistentHashRing) {
+func SetHashRing(ring HashRing) {
 	hashRing = ring
 }
 
@@ -0,0 +1,40 @@
+package cluster
+
+import "testing"
+
+func TestSetRingLoadFactor_SelectsBoundedRing(t *testing.T) {
+	defer SetRingLoadFactor(0)
+
+	SetRingLoadFactor(1.25)
+	if _, ok := GetHashRing().(*boundedHashRing); !ok {
+		t.Fatalf("GetHashRing() = %T, want *boundedHashRing", GetHashRing())
+	}
+
+	SetRingLoadFactor(0)
+	if _, ok := GetHashRing().(*boundedHashRing); ok {
+		t.Fatal("expected SetRingLoadFactor(0) to restore the plain ConsistentHashRing")
+	}
+}
+
+func TestBoundedHashRing_SatisfiesHashRing(t *testing.T) {
+	ring := newBoundedHashRing(150, 1.25)
+	ring.AddNode("node-1")
+	ring.AddNode("node-2")
+
+	if len(ring.GetAllNodes()) != 2 {
+		t.Fatalf("GetAllNodes() = %v, want 2 nodes", ring.GetAllNodes())
+	}
+
+	nodes, err := ring.GetNodes("device-001", 2)
+	if err != nil {
+		t.Fatalf("GetNodes failed: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("GetNodes returned %d nodes, want 2", len(nodes))
+	}
+
+	ring.RemoveNode("node-2")
+	if len(ring.GetAllNodes()) != 1 {
+		t.Fatalf("GetAllNodes() after RemoveNode = %v, want 1 node", ring.GetAllNodes())
+	}
+}
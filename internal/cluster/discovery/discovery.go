@@ -0,0 +1,128 @@
+// Package discovery runs cluster membership discovery for one node: it
+// advertises this node's NodeInfo, exchanges heartbeats with peers, and
+// notifies a Delegate of join/leave/update events. A production deployment
+// might reach for hashicorp/memberlist or serf here; this repo hand-rolls a
+// scoped-down version of the same idea on top of the existing TCP-based
+// pkg/cluster.GossipProtocol instead, consistent with how it already
+// hand-rolls its consistent hash ring and Merkle tree rather than vendoring
+// full implementations of those ideas.
+package discovery
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/minitrue/pkg/cluster"
+	minlog "github.com/minitrue/pkg/log"
+	"github.com/minitrue/pkg/models"
+	"github.com/minitrue/pkg/network"
+)
+
+// Delegate receives membership change events as gossip state evolves.
+type Delegate interface {
+	NotifyJoin(node *models.NodeInfo)
+	NotifyLeave(node *models.NodeInfo)
+	NotifyUpdate(node *models.NodeInfo)
+}
+
+// Config configures a Discovery instance.
+type Config struct {
+	LocalNode         *models.NodeInfo
+	GossipInterval    time.Duration
+	SuspectTimeout    time.Duration
+	ReplicationFactor int
+}
+
+// Discovery advertises LocalNode over the gossip transport and diffs
+// successive membership snapshots to drive a Delegate.
+type Discovery struct {
+	gossip   *cluster.GossipProtocol
+	delegate Delegate
+	known    map[string]string // nodeID -> last observed status
+}
+
+// New builds a Discovery that gossips over client and calls delegate on
+// membership changes once Start and Poll are running.
+func New(cfg Config, client *network.Client, delegate Delegate) *Discovery {
+	gp := cluster.NewGossipProtocol(cfg.LocalNode, cfg.GossipInterval, client, cfg.ReplicationFactor, minlog.Nop())
+	if cfg.SuspectTimeout > 0 {
+		gp.SetSuspectTimeout(cfg.SuspectTimeout)
+	}
+	return &Discovery{
+		gossip:   gp,
+		delegate: delegate,
+		known:    make(map[string]string),
+	}
+}
+
+// Gossip returns the underlying GossipProtocol, needed by callers that wire
+// it into the internode message handler.
+func (d *Discovery) Gossip() *cluster.GossipProtocol { return d.gossip }
+
+// Start begins gossiping and failure detection.
+func (d *Discovery) Start() { d.gossip.Start() }
+
+// Stop halts gossiping and failure detection.
+func (d *Discovery) Stop() { d.gossip.Stop() }
+
+// Join seeds this node's membership view from each address, e.g. the
+// comma-split value of a "-join host:port,host:port" flag. Each address is
+// contacted concurrently so a slow or unreachable seed doesn't block the
+// others.
+func (d *Discovery) Join(addrs []string) {
+	for _, addr := range addrs {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		go func(addr string) {
+			if err := d.gossip.AddSeedNode(addr); err != nil {
+				log.Printf("[Discovery] Failed to join seed %s: %v", addr, err)
+			} else {
+				log.Printf("[Discovery] Joined via seed %s", addr)
+			}
+		}(addr)
+	}
+}
+
+// Members returns the currently active cluster membership.
+func (d *Discovery) Members() []*models.NodeInfo {
+	return d.gossip.GetActiveNodes()
+}
+
+// Poll compares the gossip protocol's current state against what was last
+// observed and fires the matching Delegate callback for each change.
+// Callers typically invoke this on the same interval they already use to
+// reconcile other cluster-derived state.
+func (d *Discovery) Poll() {
+	if d.delegate == nil {
+		return
+	}
+
+	state := d.gossip.GetClusterState()
+	seen := make(map[string]bool, len(state.Nodes))
+
+	for id, node := range state.Nodes {
+		seen[id] = true
+		prevStatus, known := d.known[id]
+		switch {
+		case !known:
+			d.known[id] = node.Status
+			d.delegate.NotifyJoin(node)
+		case prevStatus != node.Status:
+			d.known[id] = node.Status
+			if node.Status == "down" {
+				d.delegate.NotifyLeave(node)
+			} else {
+				d.delegate.NotifyUpdate(node)
+			}
+		}
+	}
+
+	for id := range d.known {
+		if !seen[id] {
+			delete(d.known, id)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package cluster
+
+import (
+	"log"
+
+	"github.com/minitrue/internal/cluster/discovery"
+	"github.com/minitrue/pkg/models"
+)
+
+// hashRingDelegate applies discovery join/leave events to the package's
+// shared ConsistentHashRing, the same ring ingestion and query consult to
+// place keys.
+type hashRingDelegate struct{}
+
+func (hashRingDelegate) NotifyJoin(node *models.NodeInfo) {
+	AddNode(node.ID)
+	log.Printf("[Cluster] Discovery: node %s joined, added to hash ring", node.ID)
+}
+
+func (hashRingDelegate) NotifyLeave(node *models.NodeInfo) {
+	RemoveNode(node.ID)
+	log.Printf("[Cluster] Discovery: node %s is down, removed from hash ring", node.ID)
+}
+
+func (hashRingDelegate) NotifyUpdate(node *models.NodeInfo) {
+	// Status changed (e.g. suspect -> active) but the node is still up, so
+	// no ring change is needed.
+}
+
+var _ discovery.Delegate = hashRingDelegate{}
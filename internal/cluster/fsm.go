@@ -0,0 +1,275 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/minitrue/pkg/cluster"
+)
+
+// CommandOp identifies a cluster-state mutation applied deterministically by
+// the FSM. Every node that applies the same sequence of commands in the same
+// order ends up with an identical ConsistentHashRing.
+type CommandOp string
+
+const (
+	OpAddNode                CommandOp = "AddNode"
+	OpRemoveNode              CommandOp = "RemoveNode"
+	OpSetStatus               CommandOp = "SetStatus"
+	OpUpdateReplicationFactor CommandOp = "UpdateReplicationFactor"
+)
+
+// Command is a single replicated log entry.
+type Command struct {
+	Index             uint64    `json:"index"`
+	Op                CommandOp `json:"op"`
+	NodeID            string    `json:"node_id,omitempty"`
+	Status            string    `json:"status,omitempty"`
+	ReplicationFactor int       `json:"replication_factor,omitempty"`
+}
+
+// ClusterFSM is the replicated state machine for cluster membership. It owns
+// the package-level hash ring: Apply rebuilds ring membership deterministically
+// from each command, so primary/replica assignment (GetNodesForKey) is
+// identical on every node that has applied the same log prefix. Writes on a
+// follower are forwarded to the leader instead of applied locally.
+type ClusterFSM struct {
+	mu                sync.Mutex
+	log               []Command
+	lastIndex         uint64
+	replicationFactor int
+	leaderID          string
+	isLeader          bool
+
+	// tree, when non-nil (see InitPersistence), gives every applied Command a
+	// durable, versioned home: Apply writes it under the new version as well
+	// as updating the live ring, so CommandAt can answer "what membership
+	// command took effect as of version V" - a cluster-history audit trail -
+	// after it's long gone from the in-memory log. tree's node content
+	// survives a restart via its FileNodeDB, but (like MutableTree itself)
+	// the version->root mapping this process built up does not, so a
+	// restarted node starts a fresh history rather than resuming the old
+	// one; see HistoryAvailable.
+	tree *cluster.MutableTree
+}
+
+var globalFSM = &ClusterFSM{
+	replicationFactor: 2,
+}
+
+// GetFSM returns the process-wide cluster FSM.
+func GetFSM() *ClusterFSM {
+	return globalFSM
+}
+
+// InitPersistence opens a durable NodeDB-backed MutableTree under
+// dataDir/fsm.nodedb and starts recording every future Apply into it as a
+// new tree version (the tree's version count, which matches the command's
+// log index only when no Apply or Restore preceded this call). Call before
+// Bootstrap/Join. Safe to call at most once; a second call is a no-op.
+func (f *ClusterFSM) InitPersistence(dataDir string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.tree != nil {
+		return nil
+	}
+
+	db, err := cluster.NewFileNodeDB(filepath.Join(dataDir, "fsm.nodedb"))
+	if err != nil {
+		return fmt.Errorf("failed to open FSM node db: %w", err)
+	}
+	f.tree = cluster.NewMutableTree(db)
+	return nil
+}
+
+// HistoryAvailable reports whether InitPersistence has been called, i.e.
+// whether CommandAt can answer anything at all.
+func (f *ClusterFSM) HistoryAvailable() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tree != nil
+}
+
+// CommandAt returns the command that was applied when the FSM's persisted
+// history reached version (see Apply), for auditing what changed and when.
+func (f *ClusterFSM) CommandAt(version int64) (Command, error) {
+	f.mu.Lock()
+	tree := f.tree
+	f.mu.Unlock()
+
+	if tree == nil {
+		return Command{}, fmt.Errorf("FSM persistence not initialized, call InitPersistence first")
+	}
+
+	snapshot, err := tree.GetImmutable(version)
+	if err != nil {
+		return Command{}, err
+	}
+	data, ok, err := snapshot.Get(commandKey(version))
+	if err != nil {
+		return Command{}, err
+	}
+	if !ok {
+		return Command{}, fmt.Errorf("no command recorded for version %d", version)
+	}
+
+	var cmd Command
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return Command{}, fmt.Errorf("failed to decode command at version %d: %w", version, err)
+	}
+	return cmd, nil
+}
+
+func commandKey(version int64) []byte {
+	return []byte(fmt.Sprintf("cmd:%d", version))
+}
+
+// persist writes cmd into tree under the version it's about to become and
+// saves that version, so CommandAt(version) can later answer what command
+// produced it. tree has its own locking, so this runs outside f.mu.
+func (f *ClusterFSM) persist(tree *cluster.MutableTree, cmd Command) error {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to marshal command %d: %w", cmd.Index, err)
+	}
+
+	nextVersion := tree.Version() + 1
+	if err := tree.Set(commandKey(nextVersion), data); err != nil {
+		return fmt.Errorf("failed to stage command %d at version %d: %w", cmd.Index, nextVersion, err)
+	}
+	if _, _, err := tree.SaveVersion(); err != nil {
+		return fmt.Errorf("failed to save version %d for command %d: %w", nextVersion, cmd.Index, err)
+	}
+	return nil
+}
+
+// Bootstrap marks the local node as the leader of a brand new cluster. Call
+// this on exactly one node when forming a cluster from scratch.
+func (f *ClusterFSM) Bootstrap(nodeID string) {
+	f.mu.Lock()
+	f.isLeader = true
+	f.leaderID = nodeID
+	f.mu.Unlock()
+
+	f.Apply(Command{Op: OpAddNode, NodeID: nodeID})
+}
+
+// Join records the leader's address for command forwarding and asks it to add
+// the local node to the replicated membership.
+func (f *ClusterFSM) Join(nodeID, leaderAddr string) error {
+	f.mu.Lock()
+	f.isLeader = false
+	f.mu.Unlock()
+
+	return f.Propose(Command{Op: OpAddNode, NodeID: nodeID}, leaderAddr)
+}
+
+// IsLeader reports whether the local node currently owns writes.
+func (f *ClusterFSM) IsLeader() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.isLeader
+}
+
+// ReplicationFactor returns the replication factor currently applied by the FSM.
+func (f *ClusterFSM) ReplicationFactor() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.replicationFactor
+}
+
+// Propose submits a command for replication. On the leader it is applied
+// immediately; on a follower it is forwarded to leaderAddr over the existing
+// internode TCP framing so every node converges on the same log.
+func (f *ClusterFSM) Propose(cmd Command, leaderAddr string) error {
+	f.mu.Lock()
+	isLeader := f.isLeader
+	f.mu.Unlock()
+
+	if isLeader {
+		f.Apply(cmd)
+		return nil
+	}
+	return f.forward(leaderAddr, cmd)
+}
+
+// forward sends cmd to leaderAddr's RPCServer (see rpc_command.go) and
+// blocks for the leader's ack, so a follower's Propose only returns once the
+// command has actually been applied rather than merely handed off.
+func (f *ClusterFSM) forward(leaderAddr string, cmd Command) error {
+	if leaderAddr == "" {
+		return fmt.Errorf("no known leader address to forward command to")
+	}
+
+	return forwardCommandRPC(leaderAddr, cmd)
+}
+
+// Apply deterministically rebuilds hash-ring membership from a single
+// command. It is safe to call directly when receiving a forwarded command as
+// the leader, or when replaying a snapshot via Restore.
+func (f *ClusterFSM) Apply(cmd Command) {
+	f.mu.Lock()
+	f.lastIndex++
+	cmd.Index = f.lastIndex
+	f.log = append(f.log, cmd)
+	tree := f.tree
+	f.mu.Unlock()
+
+	if tree != nil {
+		if err := f.persist(tree, cmd); err != nil {
+			log.Printf("[ClusterFSM] Failed to persist command %d: %v", cmd.Index, err)
+		}
+	}
+
+	ring := GetHashRing()
+	if ring == nil {
+		ring = newRing(150)
+		SetHashRing(ring)
+	}
+
+	switch cmd.Op {
+	case OpAddNode:
+		ring.AddNode(cmd.NodeID)
+	case OpRemoveNode:
+		ring.RemoveNode(cmd.NodeID)
+	case OpSetStatus:
+		if cmd.Status == "down" {
+			ring.RemoveNode(cmd.NodeID)
+		} else if cmd.Status == "active" {
+			ring.AddNode(cmd.NodeID)
+		}
+	case OpUpdateReplicationFactor:
+		f.mu.Lock()
+		f.replicationFactor = cmd.ReplicationFactor
+		f.mu.Unlock()
+	}
+}
+
+// Snapshot returns the full replicated command log, suitable for bootstrapping
+// a new follower or persisting the ring's history to disk.
+func (f *ClusterFSM) Snapshot() []Command {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := make([]Command, len(f.log))
+	copy(out, f.log)
+	return out
+}
+
+// Restore replays a command log from scratch, rebuilding the hash ring in the
+// same deterministic order it was originally applied.
+func (f *ClusterFSM) Restore(commands []Command) {
+	f.mu.Lock()
+	f.log = nil
+	f.lastIndex = 0
+	f.mu.Unlock()
+
+	SetHashRing(newRing(150))
+	for _, cmd := range commands {
+		f.Apply(cmd)
+	}
+}
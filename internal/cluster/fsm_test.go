@@ -0,0 +1,42 @@
+package cluster
+
+import "testing"
+
+func TestClusterFSM_CommandAtReturnsThePersistedCommand(t *testing.T) {
+	f := &ClusterFSM{replicationFactor: 2}
+	if err := f.InitPersistence(t.TempDir()); err != nil {
+		t.Fatalf("InitPersistence: %v", err)
+	}
+	if !f.HistoryAvailable() {
+		t.Fatal("expected HistoryAvailable to be true after InitPersistence")
+	}
+
+	f.Apply(Command{Op: OpAddNode, NodeID: "node-1"})
+	f.Apply(Command{Op: OpAddNode, NodeID: "node-2"})
+
+	cmd, err := f.CommandAt(2)
+	if err != nil {
+		t.Fatalf("CommandAt(2): %v", err)
+	}
+	if cmd.Op != OpAddNode || cmd.NodeID != "node-2" {
+		t.Errorf("CommandAt(2) = %+v, want AddNode node-2", cmd)
+	}
+
+	cmd, err = f.CommandAt(1)
+	if err != nil {
+		t.Fatalf("CommandAt(1): %v", err)
+	}
+	if cmd.NodeID != "node-1" {
+		t.Errorf("CommandAt(1) = %+v, want AddNode node-1", cmd)
+	}
+}
+
+func TestClusterFSM_CommandAtWithoutPersistenceErrors(t *testing.T) {
+	f := &ClusterFSM{replicationFactor: 2}
+	if f.HistoryAvailable() {
+		t.Fatal("expected HistoryAvailable to be false before InitPersistence")
+	}
+	if _, err := f.CommandAt(1); err == nil {
+		t.Error("expected CommandAt to error before InitPersistence")
+	}
+}
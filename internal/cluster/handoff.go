@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	minlog "github.com/minitrue/pkg/log"
+	"github.com/minitrue/pkg/models"
+	"github.com/minitrue/pkg/network"
+)
+
+// replicaWriteHandler applies an inbound replicated write locally. It is
+// registered by the ingestion service (which owns the storage.Storage
+// instance) so this package can forward writes without importing storage.
+var replicaWriteHandler func(payload []byte) error
+
+// SetReplicaWriteHandler registers the callback invoked when this node
+// receives a "replica_write" message, either live from a peer or replayed
+// from that peer's hinted-handoff queue.
+func SetReplicaWriteHandler(handler func(payload []byte) error) {
+	replicaWriteHandler = handler
+}
+
+var handoffClient = network.NewClient(3*time.Second, minlog.Nop())
+
+// GetNodeAddress resolves a node's internode TCP address from the gossip
+// layer, as tracked by the ClusterManager.
+func GetNodeAddress(nodeID string) (string, bool) {
+	cm := GetClusterManager()
+	gp := cm.GetGossipProtocol()
+	if gp == nil {
+		return "", false
+	}
+	node := gp.GetNodeByID(nodeID)
+	if node == nil {
+		return "", false
+	}
+	return node.Address, true
+}
+
+// PushReplicaWrite attempts to deliver payload (a JSON-encoded datapoint) to
+// targetNodeID over the internode TCP transport. If the target's address is
+// unknown or the send fails, the write is queued in the hint store instead of
+// being lost, and a background reconciler will replay it once the target is
+// reachable again.
+func PushReplicaWrite(targetNodeID string, payload []byte) error {
+	msg := models.InternalMessage{Type: "replica_write", Payload: json.RawMessage(payload)}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replica write: %w", err)
+	}
+
+	addr, ok := GetNodeAddress(targetNodeID)
+	sendErr := error(nil)
+	if !ok {
+		sendErr = fmt.Errorf("no known address for node %s", targetNodeID)
+	} else {
+		sendErr = handoffClient.Send(addr, data)
+	}
+
+	if sendErr == nil {
+		return nil
+	}
+
+	hs := GetHintStore()
+	if hs == nil {
+		return fmt.Errorf("delivery to %s failed and no hint store configured: %w", targetNodeID, sendErr)
+	}
+
+	if err := hs.Add(targetNodeID, payload); err != nil {
+		return fmt.Errorf("delivery to %s failed (%v) and hint could not be queued: %w", targetNodeID, sendErr, err)
+	}
+
+	log.Printf("[HintedHandoff] Queued hint for unreachable node %s: %v", targetNodeID, sendErr)
+	return nil
+}
+
+// StartHintReconciler polls cluster membership on the given interval and
+// streams any queued hints to targets that have become active again,
+// deleting them once acknowledged.
+func StartHintReconciler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			reconcileHints()
+		}
+	}()
+}
+
+func reconcileHints() {
+	hs := GetHintStore()
+	if hs == nil {
+		return
+	}
+
+	cm := GetClusterManager()
+	gp := cm.GetGossipProtocol()
+
+	for _, target := range hs.Targets() {
+		if gp != nil && !gp.IsNodeActive(target) {
+			continue
+		}
+
+		if err := DrainHintsFor(target); err != nil {
+			log.Printf("[HintedHandoff] Reconciliation for %s incomplete: %v", target, err)
+		} else {
+			log.Printf("[HintedHandoff] Drained all pending hints for %s", target)
+		}
+	}
+}
+
+// DrainHintsFor replays every hint queued for target and deletes them once
+// delivered, over the same transport PushReplicaWrite uses for a live write.
+// Besides the ticker-driven reconcileHints above, ClusterManager.syncHashRing
+// calls this directly - synchronously, before re-adding a node that just
+// came back from "down" to "active" - so the node's backlog is flushed
+// before it starts receiving new writes as part of the write set again.
+func DrainHintsFor(target string) error {
+	hs := GetHintStore()
+	if hs == nil {
+		return nil
+	}
+
+	return hs.Drain(target, func(payload []byte) error {
+		msg := models.InternalMessage{Type: "replica_write", Payload: json.RawMessage(payload)}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		addr, ok := GetNodeAddress(target)
+		if !ok {
+			return fmt.Errorf("no known address for node %s", target)
+		}
+		return handoffClient.Send(addr, data)
+	})
+}
@@ -0,0 +1,256 @@
+package cluster
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Hint is a write that could not be delivered to its target node at the time
+// it was generated, queued so a background reconciler can replay it once the
+// target is reachable again.
+type Hint struct {
+	Target    string          `json:"target"`
+	Payload   json.RawMessage `json:"payload"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// maxHintsPerTarget bounds how many writes can queue for one unreachable
+// target. Once a target's queue is full, Add drops the oldest hints to make
+// room for the newest, so a replica that stays down for a long time can't
+// grow its hint file without bound.
+const maxHintsPerTarget = 10000
+
+// HintStore persists pending hints per target node under dataDir/hints so
+// they survive a restart of the coordinator that generated them. Each
+// target's hints live in their own newline-delimited JSON file, bounded to
+// maxHintsPerTarget entries and replayed in order (oldest first) once the
+// target comes back.
+type HintStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+var (
+	globalHintStore     *HintStore
+	globalHintStoreOnce sync.Once
+)
+
+// InitHintStore creates the process-wide hint store rooted at dataDir/hints.
+// Safe to call multiple times; only the first call takes effect.
+func InitHintStore(dataDir string) error {
+	var err error
+	globalHintStoreOnce.Do(func() {
+		globalHintStore, err = newHintStore(filepath.Join(dataDir, "hints"))
+	})
+	return err
+}
+
+// GetHintStore returns the process-wide hint store, or nil if InitHintStore
+// hasn't been called yet.
+func GetHintStore() *HintStore {
+	return globalHintStore
+}
+
+func newHintStore(dir string) (*HintStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create hint store dir: %w", err)
+	}
+	return &HintStore{dir: dir}, nil
+}
+
+func (hs *HintStore) pathFor(target string) string {
+	return filepath.Join(hs.dir, fmt.Sprintf("hints-%s.jsonl", target))
+}
+
+// Add appends a hint for target, to be replayed once it is reachable again.
+// If target's queue is already at maxHintsPerTarget, the oldest hint(s) are
+// dropped to make room - this is the only path that can grow a hint file, so
+// it's also the only place the bound needs to be enforced.
+func (hs *HintStore) Add(target string, payload []byte) error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	hints, err := hs.readLocked(target)
+	if err != nil {
+		return fmt.Errorf("failed to read existing hints for %s: %w", target, err)
+	}
+
+	hints = append(hints, Hint{Target: target, Payload: payload, Timestamp: time.Now()})
+	if len(hints) > maxHintsPerTarget {
+		dropped := len(hints) - maxHintsPerTarget
+		log.Printf("[HintStore] Queue for %s exceeds %d, dropping %d oldest hint(s)", target, maxHintsPerTarget, dropped)
+		hints = hints[dropped:]
+	}
+
+	return hs.rewriteLocked(target, hints)
+}
+
+// Pending returns the number of hints currently queued for target, used to
+// expose a "pending hints per peer" metric.
+func (hs *HintStore) Pending(target string) int {
+	hints, err := hs.read(target)
+	if err != nil {
+		return 0
+	}
+	return len(hints)
+}
+
+// PendingByTarget returns the pending hint count for every target that has
+// at least one queued hint.
+func (hs *HintStore) PendingByTarget() map[string]int {
+	hs.mu.Lock()
+	entries, err := os.ReadDir(hs.dir)
+	hs.mu.Unlock()
+	if err != nil {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, e := range entries {
+		target := targetFromHintFilename(e.Name())
+		if target == "" {
+			continue
+		}
+		if n := hs.Pending(target); n > 0 {
+			counts[target] = n
+		}
+	}
+	return counts
+}
+
+func targetFromHintFilename(name string) string {
+	const prefix, suffix = "hints-", ".jsonl"
+	if len(name) <= len(prefix)+len(suffix) {
+		return ""
+	}
+	if name[:len(prefix)] != prefix || name[len(name)-len(suffix):] != suffix {
+		return ""
+	}
+	return name[len(prefix) : len(name)-len(suffix)]
+}
+
+func (hs *HintStore) read(target string) ([]Hint, error) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	return hs.readLocked(target)
+}
+
+// readLocked is read's body, callable from a method that already holds mu.
+func (hs *HintStore) readLocked(target string) ([]Hint, error) {
+	f, err := os.Open(hs.pathFor(target))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hints []Hint
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var h Hint
+		if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+			log.Printf("[HintStore] Skipping malformed hint for %s: %v", target, err)
+			continue
+		}
+		hints = append(hints, h)
+	}
+
+	return hints, scanner.Err()
+}
+
+// Drain replays every pending hint for target, in order, via send. On the
+// first failure it stops and leaves the remaining hints queued. On full
+// success the hint file is cleared - but only of the hints this call
+// actually sent: Add isn't blocked while send runs, so it re-reads under mu
+// right before clearing and keeps anything that was appended for target in
+// the meantime instead of unconditionally removing the file, which would
+// otherwise silently drop a hint that arrived mid-drain.
+func (hs *HintStore) Drain(target string, send func(payload []byte) error) error {
+	hints, err := hs.read(target)
+	if err != nil {
+		return fmt.Errorf("failed to read hints for %s: %w", target, err)
+	}
+	if len(hints) == 0 {
+		return nil
+	}
+
+	for i, h := range hints {
+		if err := send(h.Payload); err != nil {
+			// Re-queue the hints we didn't get to by rewriting the file
+			// without the ones we already delivered.
+			hs.rewrite(target, hints[i:])
+			return fmt.Errorf("failed to deliver hint %d/%d to %s: %w", i+1, len(hints), target, err)
+		}
+	}
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	current, err := hs.readLocked(target)
+	if err != nil {
+		return fmt.Errorf("failed to re-read hints for %s before clearing: %w", target, err)
+	}
+	if len(current) > len(hints) {
+		return hs.rewriteLocked(target, current[len(hints):])
+	}
+	if err := os.Remove(hs.pathFor(target)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear hints for %s: %w", target, err)
+	}
+	return nil
+}
+
+func (hs *HintStore) rewrite(target string, remaining []Hint) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	if err := hs.rewriteLocked(target, remaining); err != nil {
+		log.Printf("[HintStore] Failed to rewrite hints for %s: %v", target, err)
+	}
+}
+
+// rewriteLocked is rewrite's body, callable from a method that already holds
+// mu. An empty remaining still writes an empty file rather than removing it,
+// which is fine: the next read/Drain simply sees zero hints.
+func (hs *HintStore) rewriteLocked(target string, remaining []Hint) error {
+	f, err := os.Create(hs.pathFor(target))
+	if err != nil {
+		return fmt.Errorf("failed to create hint file for %s: %w", target, err)
+	}
+	defer f.Close()
+
+	for _, h := range remaining {
+		line, err := json.Marshal(h)
+		if err != nil {
+			continue
+		}
+		f.Write(append(line, '\n'))
+	}
+	return nil
+}
+
+// Targets lists every node ID that currently has at least one hint file,
+// regardless of whether it still has pending entries.
+func (hs *HintStore) Targets() []string {
+	hs.mu.Lock()
+	entries, err := os.ReadDir(hs.dir)
+	hs.mu.Unlock()
+	if err != nil {
+		return nil
+	}
+
+	var targets []string
+	for _, e := range entries {
+		if t := targetFromHintFilename(e.Name()); t != "" {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
@@ -0,0 +1,30 @@
+package cluster
+
+import "github.com/minitrue/pkg/network"
+
+// gossipKeyring is the process-wide gossip encryption keyring, set once at
+// startup by InitGossipKeyring. Nil means gossip traffic stays plaintext,
+// the behavior every deployment had before this existed.
+var gossipKeyring *network.Keyring
+
+// InitGossipKeyring enables encrypted, authenticated gossip traffic for the
+// rest of this process's lifetime: GossipProtocol's TCP full-sync sends
+// (handoffClient and the FSM's forwarding client are unaffected - this is
+// gossip-specific, unlike InitSecureTransport) and the ClusterManager
+// server that receives them both start using kr. Call before
+// InitializeWithSuspectTimeout so ClusterManager's own server and client
+// pick it up too.
+//
+// requireAuthenticated, if true, makes the server reject any gossip
+// payload it can't decrypt with a known key instead of treating it as
+// plaintext - only safe to flip once every node in the cluster has kr's
+// primary key installed, so roll a cluster out with it false, confirm via
+// GetGossipProtocol().BroadcastKeyRotation, then restart with it true.
+func InitGossipKeyring(primaryKeyID string, primaryKey []byte, requireAuthenticated bool) (*network.Keyring, error) {
+	kr, err := network.NewKeyring(primaryKeyID, primaryKey, requireAuthenticated)
+	if err != nil {
+		return nil, err
+	}
+	gossipKeyring = kr
+	return kr, nil
+}
@@ -0,0 +1,80 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+)
+
+// latencyEMAAlpha weights each new sample against the running average: a
+// higher value reacts faster to a node getting slower (or recovering) at
+// the cost of more noise from a single outlier request.
+const latencyEMAAlpha = 0.2
+
+// defaultHedgeDelay is handed out for a node with no tracked latency yet
+// (e.g. right after startup, before any query has completed against it).
+// hedgeDelayMultiplier converts a node's typical latency into a hedge
+// threshold generous enough that an ordinarily-slow-but-healthy response
+// isn't mistaken for a straggler worth hedging against. minHedgeDelay and
+// maxHedgeDelay bound the result so a node with a near-zero EMA doesn't
+// trigger the backup on every request, and a node with a stale, inflated
+// EMA doesn't hold a hedge off indefinitely.
+const (
+	defaultHedgeDelay    = 20 * time.Millisecond
+	hedgeDelayMultiplier = 2
+	minHedgeDelay        = 5 * time.Millisecond
+	maxHedgeDelay        = 500 * time.Millisecond
+)
+
+// latencyTracker keeps an exponential moving average of observed query
+// latency per node, used to self-tune the hedged ReadPolicy's backup
+// threshold (see query.distributedQueryHedged) to each node's own recent
+// behavior instead of one fixed delay for the whole cluster.
+type latencyTracker struct {
+	mu   sync.Mutex
+	emas map[string]time.Duration
+}
+
+var globalLatencyTracker = &latencyTracker{emas: make(map[string]time.Duration)}
+
+// RecordNodeLatency folds d into nodeID's latency EMA. Callers record only
+// successful query round-trips - a timeout or error says nothing useful
+// about how fast the node normally answers.
+func RecordNodeLatency(nodeID string, d time.Duration) {
+	globalLatencyTracker.record(nodeID, d)
+}
+
+func (lt *latencyTracker) record(nodeID string, d time.Duration) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	prev, ok := lt.emas[nodeID]
+	if !ok {
+		lt.emas[nodeID] = d
+		return
+	}
+	lt.emas[nodeID] = time.Duration(latencyEMAAlpha*float64(d) + (1-latencyEMAAlpha)*float64(prev))
+}
+
+// HedgeDelay returns how long a hedged read should wait for nodeID before
+// firing a backup request to the next replica.
+func HedgeDelay(nodeID string) time.Duration {
+	return globalLatencyTracker.hedgeDelay(nodeID)
+}
+
+func (lt *latencyTracker) hedgeDelay(nodeID string) time.Duration {
+	lt.mu.Lock()
+	ema, ok := lt.emas[nodeID]
+	lt.mu.Unlock()
+	if !ok {
+		return defaultHedgeDelay
+	}
+
+	d := ema * hedgeDelayMultiplier
+	if d < minHedgeDelay {
+		return minHedgeDelay
+	}
+	if d > maxHedgeDelay {
+		return maxHedgeDelay
+	}
+	return d
+}
@@ -6,15 +6,23 @@ import (
 	"sync"
 	"time"
 
+	"github.com/minitrue/internal/cluster/discovery"
 	"github.com/minitrue/pkg/cluster"
+	minlog "github.com/minitrue/pkg/log"
 	"github.com/minitrue/pkg/models"
 	"github.com/minitrue/pkg/network"
 )
 
+// defaultSuspectTimeout is the suspectTimeout Initialize passes on, for
+// call sites (e.g. cmd/kafka-ingest) that don't set the knob themselves.
+const defaultSuspectTimeout = 10 * time.Second
+
 type ClusterManager struct {
 	gossipProtocol *cluster.GossipProtocol
-	hashRing       *cluster.ConsistentHashRing
+	discovery      *discovery.Discovery
+	hashRing       HashRing
 	server         *network.Server
+	commandRPC     *network.RPCServer
 	mu             sync.RWMutex
 }
 
@@ -31,48 +39,74 @@ func GetClusterManager() *ClusterManager {
 }
 
 func (cm *ClusterManager) Initialize(localNode *models.NodeInfo, tcpPort int, seedNodes []string) error {
+	return cm.InitializeWithSuspectTimeout(localNode, tcpPort, seedNodes, defaultSuspectTimeout)
+}
+
+// InitializeWithSuspectTimeout is like Initialize but lets the caller tune
+// how long a node can go without a heartbeat before discovery marks it down
+// and removes it from the hash ring.
+func (cm *ClusterManager) InitializeWithSuspectTimeout(localNode *models.NodeInfo, tcpPort int, seedNodes []string, suspectTimeout time.Duration) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	cm.hashRing = GetHashRing()
 	if cm.hashRing == nil {
-		cm.hashRing = cluster.NewConsistentHashRing(150)
+		cm.hashRing = newRing(150)
 		SetHashRing(cm.hashRing)
 	}
 
 	cm.hashRing.AddNode(localNode.ID)
 
-	networkClient := network.NewClient(5 * time.Second)
+	networkClient := network.NewClient(5*time.Second, minlog.Nop())
+	if secureCfg != nil {
+		networkClient.SetConnWrap(connWrapClient(secureCfg))
+	}
+	if gossipKeyring != nil {
+		networkClient.SetKeyring(gossipKeyring)
+	}
 
-	cm.gossipProtocol = cluster.NewGossipProtocol(
-		localNode,
-		2*time.Second,
-		networkClient,
-		3,
-	)
+	cm.discovery = discovery.New(discovery.Config{
+		LocalNode:         localNode,
+		GossipInterval:    2 * time.Second,
+		SuspectTimeout:    suspectTimeout,
+		ReplicationFactor: 3,
+	}, networkClient, hashRingDelegate{})
+	cm.gossipProtocol = cm.discovery.Gossip()
+	if gossipKeyring != nil {
+		cm.gossipProtocol.SetKeyring(gossipKeyring)
+	}
 
-	cm.gossipProtocol.Start()
+	cm.discovery.Start()
 
 	messageHandler := NewMessageHandler(cm.gossipProtocol, cm.onNodeUpdate)
 
 	tcpAddress := fmt.Sprintf(":%d", tcpPort)
-	cm.server = network.NewServer(tcpAddress, messageHandler)
+	cm.server = network.NewServer(tcpAddress, messageHandler, minlog.Nop())
+	if secureCfg != nil {
+		cm.server.SetConnWrap(connWrapServer(secureCfg))
+	}
+	if gossipKeyring != nil {
+		cm.server.SetKeyring(gossipKeyring)
+	}
 
 	if err := cm.server.Start(); err != nil {
 		return fmt.Errorf("failed to start TCP server: %w", err)
 	}
 
-	for _, seedAddr := range seedNodes {
-		if seedAddr != "" {
-			go func(addr string) {
-				if err := cm.gossipProtocol.AddSeedNode(addr); err != nil {
-					log.Printf("[Cluster] Failed to connect to seed node %s: %v", addr, err)
-				} else {
-					log.Printf("[Cluster] Connected to seed node %s", addr)
-				}
-			}(seedAddr)
-		}
+	rpcAddress, err := commandRPCAddr(tcpAddress)
+	if err != nil {
+		return fmt.Errorf("failed to derive command RPC address: %w", err)
 	}
+	cm.commandRPC = network.NewRPCServer(rpcAddress, 0)
+	if secureCfg != nil {
+		cm.commandRPC.SetConnWrap(connWrapServer(secureCfg))
+	}
+	RegisterCommandRPCHandler(cm.commandRPC)
+	if err := cm.commandRPC.Start(); err != nil {
+		return fmt.Errorf("failed to start command RPC server: %w", err)
+	}
+
+	cm.discovery.Join(seedNodes)
 
 	go cm.syncHashRingLoop()
 
@@ -85,31 +119,57 @@ func (cm *ClusterManager) syncHashRingLoop() {
 
 	for range ticker.C {
 		cm.syncHashRing()
+
+		cm.mu.RLock()
+		d := cm.discovery
+		cm.mu.RUnlock()
+		if d != nil {
+			d.Poll()
+		}
 	}
 }
 
+// syncHashRing reconciles the hash ring against gossip's view of cluster
+// membership. It only takes cm.mu (RLock) to snapshot gossipProtocol and
+// hashRing - both otherwise-immutable-after-Initialize pointers, safe to use
+// unlocked afterwards since ConsistentHashRing guards its own state - rather
+// than holding it across DrainHintsFor, which can replay up to
+// maxHintsPerTarget hints over TCP sequentially. Every other exported method
+// (GetReplicas, GetMembers, GetGossipProtocol, GetHashRing,
+// GetNodeHTTPPort) only ever needs an RLock for the same snapshot-and-release
+// pattern, so a node rejoining with a deep hint queue no longer stalls them
+// for the length of the drain.
 func (cm *ClusterManager) syncHashRing() {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
+	cm.mu.RLock()
+	gossipProtocol := cm.gossipProtocol
+	hashRing := cm.hashRing
+	cm.mu.RUnlock()
 
-	if cm.gossipProtocol == nil || cm.hashRing == nil {
+	if gossipProtocol == nil || hashRing == nil {
 		return
 	}
 
-	clusterState := cm.gossipProtocol.GetClusterState()
-	
+	clusterState := gossipProtocol.GetClusterState()
+
 	ringNodes := make(map[string]bool)
-	for _, nodeID := range cm.hashRing.GetAllNodes() {
+	for _, nodeID := range hashRing.GetAllNodes() {
 		ringNodes[nodeID] = true
 	}
 
 	for nodeID, nodeInfo := range clusterState.Nodes {
 		if nodeInfo.Status == "active" && !ringNodes[nodeID] {
-			cm.hashRing.AddNode(nodeID)
+			// Flush anything queued for nodeID while it was down before it
+			// rejoins the write set, so a write landing right after it's
+			// re-added can't race ahead of hints still waiting to be
+			// delivered to it.
+			if err := DrainHintsFor(nodeID); err != nil {
+				log.Printf("[Cluster] Hint drain for %s incomplete, adding to ring anyway: %v", nodeID, err)
+			}
+			hashRing.AddNode(nodeID)
 			log.Printf("[Cluster] Synced: Added node %s to hash ring", nodeID)
 			ringNodes[nodeID] = true
 		} else if nodeInfo.Status == "down" && ringNodes[nodeID] {
-			cm.hashRing.RemoveNode(nodeID)
+			hashRing.RemoveNode(nodeID)
 			log.Printf("[Cluster] Synced: Removed node %s from hash ring (down)", nodeID)
 			delete(ringNodes, nodeID)
 		}
@@ -118,9 +178,9 @@ func (cm *ClusterManager) syncHashRing() {
 
 func (cm *ClusterManager) onNodeUpdate(nodeID string, add bool) {
 	cm.mu.Lock()
-	defer cm.mu.Unlock()
 
 	if cm.hashRing == nil {
+		cm.mu.Unlock()
 		return
 	}
 
@@ -131,6 +191,12 @@ func (cm *ClusterManager) onNodeUpdate(nodeID string, add bool) {
 		cm.hashRing.RemoveNode(nodeID)
 		log.Printf("[Cluster] Node %s removed from hash ring", nodeID)
 	}
+
+	cm.mu.Unlock()
+
+	// Notified outside the lock so a listener (e.g. the query client pool)
+	// is free to call back into ClusterManager without deadlocking.
+	notifyNodeEventListeners(nodeID, add)
 }
 
 func (cm *ClusterManager) GetGossipProtocol() *cluster.GossipProtocol {
@@ -139,18 +205,37 @@ func (cm *ClusterManager) GetGossipProtocol() *cluster.GossipProtocol {
 	return cm.gossipProtocol
 }
 
-func (cm *ClusterManager) GetHashRing() *cluster.ConsistentHashRing {
+func (cm *ClusterManager) GetHashRing() HashRing {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 	return cm.hashRing
 }
 
+// GetReplicas returns the primary plus ReplicationFactor()-1 successor nodes
+// for key: the full replica set a write should fan out to and read-repair
+// should compare across.
+func (cm *ClusterManager) GetReplicas(key string) ([]string, error) {
+	cm.mu.RLock()
+	ring := cm.hashRing
+	cm.mu.RUnlock()
+
+	if ring == nil {
+		return nil, fmt.Errorf("hash ring not initialized")
+	}
+	return ring.GetReplicas(key, ReplicationFactor())
+}
+
 func (cm *ClusterManager) Stop() error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	if cm.gossipProtocol != nil {
-		cm.gossipProtocol.Stop()
+	if cm.discovery != nil {
+		cm.discovery.Stop()
+	}
+	if cm.commandRPC != nil {
+		if err := cm.commandRPC.Stop(); err != nil {
+			return err
+		}
 	}
 	if cm.server != nil {
 		return cm.server.Stop()
@@ -158,6 +243,18 @@ func (cm *ClusterManager) Stop() error {
 	return nil
 }
 
+// GetMembers returns the cluster membership as currently known by
+// discovery, for the /cluster/members HTTP endpoint.
+func (cm *ClusterManager) GetMembers() []*models.NodeInfo {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if cm.discovery == nil {
+		return nil
+	}
+	return cm.discovery.Members()
+}
+
 func (cm *ClusterManager) GetNodeHTTPPort(nodeID string) (int, error) {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
@@ -173,4 +270,3 @@ func (cm *ClusterManager) GetNodeHTTPPort(nodeID string) (int, error) {
 
 	return node.HTTPPort, nil
 }
-
@@ -0,0 +1,14 @@
+package cluster
+
+// merkleBlockHandler serves a "merkle_block" RPC (see message_handler.go):
+// given a cluster.MerkleBlockPredicate, it returns the SPV-style
+// cluster.MerkleBlock proving just the matching leaves against the local
+// tree's root, the same registered-callback pattern queryHandler and
+// walCatchupHandler use to avoid this package importing storage directly.
+var merkleBlockHandler func(payload []byte) ([]byte, error)
+
+// SetMerkleBlockHandler registers the callback invoked when this node
+// receives a Merkle block RPC over the internode TCP transport.
+func SetMerkleBlockHandler(handler func(payload []byte) ([]byte, error)) {
+	merkleBlockHandler = handler
+}
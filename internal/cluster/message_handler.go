@@ -1,7 +1,10 @@
 package cluster
 
 import (
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net"
 
@@ -10,13 +13,13 @@ import (
 )
 
 type MessageHandler struct {
-	gossipProtocol *cluster.GossipProtocol
+	gossipProtocol  *cluster.GossipProtocol
 	hashRingUpdater func(nodeID string, add bool)
 }
 
 func NewMessageHandler(gossipProtocol *cluster.GossipProtocol, hashRingUpdater func(string, bool)) *MessageHandler {
 	return &MessageHandler{
-		gossipProtocol: gossipProtocol,
+		gossipProtocol:  gossipProtocol,
 		hashRingUpdater: hashRingUpdater,
 	}
 }
@@ -40,9 +43,174 @@ func (mh *MessageHandler) HandleMessage(data []byte, conn net.Conn) error {
 		}
 
 		mh.gossipProtocol.HandleGossipMessage(gossipMsg)
-		
+
 		mh.updateHashRingFromGossip(gossipMsg)
 
+	case "replica_write":
+		if replicaWriteHandler == nil {
+			log.Printf("[MessageHandler] Received replica_write but no handler registered")
+			return nil
+		}
+		payloadBytes, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		return replicaWriteHandler(payloadBytes)
+
+	case "query_samples", "query_aggregated":
+		if queryHandler == nil {
+			return writeLengthPrefixed(conn, []byte(`{"error":"no query handler registered"}`))
+		}
+		payloadBytes, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		resp, handlerErr := queryHandler(msg.Type, payloadBytes)
+		if handlerErr != nil {
+			errResp, _ := json.Marshal(map[string]string{"error": handlerErr.Error()})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		return writeLengthPrefixed(conn, resp)
+
+	case "query_aggregated_stream":
+		if queryStreamHandler == nil {
+			return writeLengthPrefixed(conn, []byte(`{"done":true,"error":"no query handler registered"}`))
+		}
+		payloadBytes, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		if err := queryStreamHandler(payloadBytes, func(frame []byte) error {
+			return writeLengthPrefixed(conn, frame)
+		}); err != nil {
+			errResp, _ := json.Marshal(map[string]interface{}{"done": true, "error": err.Error()})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		return nil
+
+	case "wal_catchup":
+		if walCatchupHandler == nil {
+			return writeLengthPrefixed(conn, []byte(`{"done":true,"error":"no wal catch-up handler registered"}`))
+		}
+		payloadBytes, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		if err := walCatchupHandler(payloadBytes, func(frame []byte) error {
+			return writeLengthPrefixed(conn, frame)
+		}); err != nil {
+			errResp, _ := json.Marshal(map[string]interface{}{"done": true, "error": err.Error()})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		return nil
+
+	case "merkle_block":
+		if merkleBlockHandler == nil {
+			return writeLengthPrefixed(conn, []byte(`{"error":"no merkle block handler registered"}`))
+		}
+		payloadBytes, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		resp, handlerErr := merkleBlockHandler(payloadBytes)
+		if handlerErr != nil {
+			errResp, _ := json.Marshal(map[string]string{"error": handlerErr.Error()})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		return writeLengthPrefixed(conn, resp)
+
+	case "merkle_reconcile":
+		if rangeReconcileHandler == nil {
+			return writeLengthPrefixed(conn, []byte(`{"error":"no range reconcile handler registered"}`))
+		}
+		payloadBytes, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		resp, handlerErr := rangeReconcileHandler(payloadBytes)
+		if handlerErr != nil {
+			errResp, _ := json.Marshal(map[string]string{"error": handlerErr.Error()})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		return writeLengthPrefixed(conn, resp)
+
+	case "merkle_range_proof":
+		if rangeProofHandler == nil {
+			return writeLengthPrefixed(conn, []byte(`{"error":"no range proof handler registered"}`))
+		}
+		payloadBytes, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		resp, handlerErr := rangeProofHandler(payloadBytes)
+		if handlerErr != nil {
+			errResp, _ := json.Marshal(map[string]string{"error": handlerErr.Error()})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		return writeLengthPrefixed(conn, resp)
+
+	case "merkle_sync_root":
+		if syncRootHandler == nil {
+			return writeLengthPrefixed(conn, []byte(`{"error":"no sync root handler registered"}`))
+		}
+		payloadBytes, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		resp, handlerErr := syncRootHandler(payloadBytes)
+		if handlerErr != nil {
+			errResp, _ := json.Marshal(map[string]string{"error": handlerErr.Error()})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		return writeLengthPrefixed(conn, resp)
+
+	case "merkle_sync_children":
+		if syncChildrenHandler == nil {
+			return writeLengthPrefixed(conn, []byte(`{"error":"no sync children handler registered"}`))
+		}
+		payloadBytes, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return err
+		}
+		resp, handlerErr := syncChildrenHandler(payloadBytes)
+		if handlerErr != nil {
+			errResp, _ := json.Marshal(map[string]string{"error": handlerErr.Error()})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		return writeLengthPrefixed(conn, resp)
+
+	case "index_summary":
+		if indexSummaryHandler == nil {
+			errResp, _ := cluster.MarshalMsgpack(map[string]string{"error": "no index summary handler registered"})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		payloadBytes, err := decodeMsgpackPayload(msg.Payload)
+		if err != nil {
+			return err
+		}
+		resp, handlerErr := indexSummaryHandler(payloadBytes)
+		if handlerErr != nil {
+			errResp, _ := cluster.MarshalMsgpack(map[string]string{"error": handlerErr.Error()})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		return writeLengthPrefixed(conn, resp)
+
+	case "index_request":
+		if indexRequestHandler == nil {
+			errResp, _ := cluster.MarshalMsgpack(map[string]string{"error": "no index request handler registered"})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		payloadBytes, err := decodeMsgpackPayload(msg.Payload)
+		if err != nil {
+			return err
+		}
+		resp, handlerErr := indexRequestHandler(payloadBytes)
+		if handlerErr != nil {
+			errResp, _ := cluster.MarshalMsgpack(map[string]string{"error": handlerErr.Error()})
+			return writeLengthPrefixed(conn, errResp)
+		}
+		return writeLengthPrefixed(conn, resp)
+
 	default:
 		log.Printf("[MessageHandler] Unknown message type: %s", msg.Type)
 	}
@@ -50,6 +218,32 @@ func (mh *MessageHandler) HandleMessage(data []byte, conn net.Conn) error {
 	return nil
 }
 
+// decodeMsgpackPayload recovers the raw msgpack bytes client.go's
+// FetchIndexSummary/FetchRecords base64'd into msg.Payload so they could
+// still ride inside this envelope's JSON Payload field alongside every other
+// (plain-JSON) RPC type.
+func decodeMsgpackPayload(payload interface{}) ([]byte, error) {
+	b64, ok := payload.(string)
+	if !ok {
+		return nil, fmt.Errorf("index payload must be a base64-encoded string, got %T", payload)
+	}
+	return base64.StdEncoding.DecodeString(b64)
+}
+
+// writeLengthPrefixed writes data back over conn as [4-byte length][data],
+// the same length-prefixed format network.Client/Server use for the
+// request side - so a query RPC reply looks, on the wire, just like
+// another message in this same connection's stream.
+func writeLengthPrefixed(conn net.Conn, data []byte) error {
+	lengthBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBytes, uint32(len(data)))
+	if _, err := conn.Write(lengthBytes); err != nil {
+		return err
+	}
+	_, err := conn.Write(data)
+	return err
+}
+
 func (mh *MessageHandler) updateHashRingFromGossip(msg models.GossipMessage) {
 	hashRing := GetHashRing()
 	if hashRing == nil {
@@ -79,4 +273,3 @@ func (mh *MessageHandler) updateHashRingFromGossip(msg models.GossipMessage) {
 		}
 	}
 }
-
@@ -0,0 +1,34 @@
+package cluster
+
+import "sync"
+
+// nodeEventListeners are notified whenever gossip-driven hash ring
+// membership changes (see ClusterManager.onNodeUpdate): add is true when a
+// node joins/becomes active, false when it's removed/marked down. The
+// internode query client pool (internal/cluster/client) uses this to evict
+// a departed node's pooled connections instead of polling membership
+// itself.
+var (
+	nodeEventListenersMu sync.Mutex
+	nodeEventListeners   []func(nodeID string, add bool)
+)
+
+// RegisterNodeEventListener adds fn to the set notified on every gossip
+// membership change. There is no corresponding unregister - listeners are
+// expected to live for the process lifetime, same as replicaWriteHandler.
+func RegisterNodeEventListener(fn func(nodeID string, add bool)) {
+	nodeEventListenersMu.Lock()
+	defer nodeEventListenersMu.Unlock()
+	nodeEventListeners = append(nodeEventListeners, fn)
+}
+
+func notifyNodeEventListeners(nodeID string, add bool) {
+	nodeEventListenersMu.Lock()
+	listeners := make([]func(string, bool), len(nodeEventListeners))
+	copy(listeners, nodeEventListeners)
+	nodeEventListenersMu.Unlock()
+
+	for _, fn := range listeners {
+		fn(nodeID, add)
+	}
+}
@@ -0,0 +1,29 @@
+package cluster
+
+// queryHandler executes an internode query RPC ("query_samples" or
+// "query_aggregated", see message_handler.go) against this node's local
+// storage.Storage and returns the JSON-encoded response payload. It is
+// registered by the query service (which owns storage.Storage) so this
+// package can serve query RPC without importing storage/query itself -
+// the same pattern replicaWriteHandler uses for replicated writes.
+var queryHandler func(msgType string, payload []byte) ([]byte, error)
+
+// SetQueryHandler registers the callback invoked when this node receives a
+// query RPC over the internode TCP transport.
+func SetQueryHandler(handler func(msgType string, payload []byte) ([]byte, error)) {
+	queryHandler = handler
+}
+
+// queryStreamHandler serves a "query_aggregated_stream" RPC (see
+// message_handler.go). Unlike queryHandler it doesn't return a single
+// encoded reply: it calls writeFrame once per partial result as the query
+// service produces them, so a wide time-range scan can start streaming
+// buckets back before it finishes. It is registered the same way
+// queryHandler is, by the query service that owns storage.Storage.
+var queryStreamHandler func(payload []byte, writeFrame func([]byte) error) error
+
+// SetQueryStreamHandler registers the callback invoked when this node
+// receives a streaming query RPC over the internode TCP transport.
+func SetQueryStreamHandler(handler func(payload []byte, writeFrame func([]byte) error) error) {
+	queryStreamHandler = handler
+}
@@ -0,0 +1,14 @@
+package cluster
+
+// rangeProofHandler serves a "merkle_range_proof" RPC (see
+// message_handler.go): given [low, high), it returns the local tree's
+// RangeProof for that span plus the root hash it was built against, the
+// same registered-callback pattern rangeReconcileHandler and
+// merkleBlockHandler use to avoid this package importing storage directly.
+var rangeProofHandler func(payload []byte) ([]byte, error)
+
+// SetRangeProofHandler registers the callback invoked when this node
+// receives a range-proof RPC over the internode TCP transport.
+func SetRangeProofHandler(handler func(payload []byte) ([]byte, error)) {
+	rangeProofHandler = handler
+}
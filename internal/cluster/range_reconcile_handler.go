@@ -0,0 +1,14 @@
+package cluster
+
+// rangeReconcileHandler serves a "merkle_reconcile" RPC (see
+// message_handler.go): given a single range-compare request, it returns the
+// local tree's cluster.RangeCompareResult for that range, the same
+// registered-callback pattern merkleBlockHandler and queryHandler use to
+// avoid this package importing storage directly.
+var rangeReconcileHandler func(payload []byte) ([]byte, error)
+
+// SetRangeReconcileHandler registers the callback invoked when this node
+// receives a range-reconciliation RPC over the internode TCP transport.
+func SetRangeReconcileHandler(handler func(payload []byte) ([]byte, error)) {
+	rangeReconcileHandler = handler
+}
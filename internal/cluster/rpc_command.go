@@ -0,0 +1,85 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/minitrue/pkg/network"
+)
+
+// commandRPCPortOffset is added to a node's internode TCP port to get the
+// port its RPCServer listens on for forwarded FSM commands - the first real
+// caller of pkg/network's Frame/RPCServer/RPCClient substrate (see
+// RegisterCommandRPCHandler and ClusterFSM.forward), rather than another
+// case in MessageHandler.HandleMessage's ad-hoc JSON switch.
+const commandRPCPortOffset = 500
+
+// commandMsgType is the Frame Type forwarded cluster commands are sent
+// under. It's the only RPC type registered so far; more subsystems can
+// register their own Type on the same RPCServer as they migrate off the
+// ad-hoc transport.
+const commandMsgType uint16 = 1
+
+// commandRPCCodec is fixed at JSON so a forwarded Command's wire shape
+// matches every other internode message in this package.
+var commandRPCCodec = network.JSONCodec
+
+// RegisterCommandRPCHandler registers the handler that applies a forwarded
+// Command on server: only the leader accepts one; a follower answers with
+// an error instead of applying it.
+func RegisterCommandRPCHandler(server *network.RPCServer) {
+	server.RegisterHandler(commandMsgType, func(payload []byte) ([]byte, error) {
+		var cmd Command
+		if err := commandRPCCodec.Unmarshal(payload, &cmd); err != nil {
+			return nil, fmt.Errorf("invalid forwarded command: %w", err)
+		}
+		if !GetFSM().IsLeader() {
+			return nil, fmt.Errorf("not the cluster-state leader")
+		}
+		GetFSM().Apply(cmd)
+		return commandRPCCodec.Marshal(struct{}{})
+	})
+}
+
+// commandRPCAddr derives the RPCServer address a forwarded command should
+// dial from tcpAddr (the same host:port a node advertises for its internode
+// TCP server), by shifting the port by commandRPCPortOffset - so a node only
+// needs to expose the one address it already knows from -raft-leader/gossip.
+func commandRPCAddr(tcpAddr string) (string, error) {
+	host, portStr, err := net.SplitHostPort(tcpAddr)
+	if err != nil {
+		return "", fmt.Errorf("invalid leader address %q: %w", tcpAddr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid leader port in %q: %w", tcpAddr, err)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port+commandRPCPortOffset)), nil
+}
+
+// forwardCommandRPCTimeout bounds how long a follower waits for the leader
+// to apply a forwarded command before giving up.
+const forwardCommandRPCTimeout = 10 * time.Second
+
+// forwardCommandRPC dials leaderAddr's RPCServer and sends cmd as a
+// commandMsgType request, blocking for the leader's ack (or error).
+func forwardCommandRPC(leaderAddr string, cmd Command) error {
+	rpcAddr, err := commandRPCAddr(leaderAddr)
+	if err != nil {
+		return err
+	}
+
+	rpcClient, err := network.NewRPCClientWithConnWrap(rpcAddr, commandRPCCodec, 0, GetConnWrap())
+	if err != nil {
+		return fmt.Errorf("failed to connect to leader's RPC server at %s: %w", rpcAddr, err)
+	}
+	defer rpcClient.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), forwardCommandRPCTimeout)
+	defer cancel()
+
+	return rpcClient.Call(ctx, commandMsgType, cmd, nil)
+}
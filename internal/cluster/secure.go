@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"net"
+
+	"github.com/minitrue/internal/transport/secure"
+)
+
+// secureCfg is the process-wide transport security configuration, set once
+// at startup by InitSecureTransport. Nil means every internode connection
+// stays plaintext, the behavior every deployment had before this existed.
+var secureCfg *secure.Config
+
+// InitSecureTransport enables encrypted, authenticated internode
+// connections for the rest of this process's lifetime: every connection
+// this package dials or accepts - gossip and hinted handoff (this file),
+// the FSM's leader-forwarding client (fsm.go), and the query RPC pool
+// (internal/cluster/client, via GetConnWrap) - performs the secure.Client/
+// secure.Server handshake gated by token before any cluster traffic
+// crosses it. Call before InitializeWithSuspectTimeout so the
+// ClusterManager's own server and client pick it up too.
+func InitSecureTransport(token []byte) {
+	cfg := &secure.Config{Token: token}
+	secureCfg = cfg
+
+	handoffClient.SetConnWrap(connWrapClient(cfg))
+}
+
+// GetConnWrap returns the client-side secure handshake hook for the
+// configured transport, or nil if InitSecureTransport hasn't been called.
+// internal/cluster/client's connection pool uses this to secure its own
+// dialed connections the same way ClusterManager secures its TCP server.
+func GetConnWrap() func(net.Conn) (net.Conn, error) {
+	if secureCfg == nil {
+		return nil
+	}
+	return connWrapClient(secureCfg)
+}
+
+func connWrapClient(cfg *secure.Config) func(net.Conn) (net.Conn, error) {
+	return func(conn net.Conn) (net.Conn, error) {
+		return secure.Client(conn, *cfg)
+	}
+}
+
+func connWrapServer(cfg *secure.Config) func(net.Conn) (net.Conn, error) {
+	return func(conn net.Conn) (net.Conn, error) {
+		return secure.Server(conn, *cfg)
+	}
+}
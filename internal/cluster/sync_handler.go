@@ -0,0 +1,22 @@
+package cluster
+
+// syncRootHandler and syncChildrenHandler serve the "merkle_sync_root" and
+// "merkle_sync_children" RPCs (see message_handler.go): together they let a
+// remote pkgcluster.Reconcile walk this node's local per-shard tree one
+// level at a time, the same registered-callback pattern merkleBlockHandler
+// and rangeReconcileHandler use to avoid this package importing storage
+// directly.
+var syncRootHandler func(payload []byte) ([]byte, error)
+var syncChildrenHandler func(payload []byte) ([]byte, error)
+
+// SetSyncRootHandler registers the callback invoked when this node receives
+// a Merkle sync root-hash RPC over the internode TCP transport.
+func SetSyncRootHandler(handler func(payload []byte) ([]byte, error)) {
+	syncRootHandler = handler
+}
+
+// SetSyncChildrenHandler registers the callback invoked when this node
+// receives a Merkle sync children RPC over the internode TCP transport.
+func SetSyncChildrenHandler(handler func(payload []byte) ([]byte, error)) {
+	syncChildrenHandler = handler
+}
@@ -0,0 +1,16 @@
+package cluster
+
+// walCatchupHandler serves a "wal_catchup" RPC (see message_handler.go): it
+// streams every local WAL record newer than the requested request number
+// back to the caller via writeFrame, the same streaming-callback shape
+// queryStreamHandler uses for "query_aggregated_stream". It is registered
+// by the query service that owns storage.Storage, the same pattern
+// queryHandler and replicaWriteHandler use to avoid this package importing
+// storage/query directly.
+var walCatchupHandler func(payload []byte, writeFrame func([]byte) error) error
+
+// SetWALCatchupHandler registers the callback invoked when this node
+// receives a WAL catch-up RPC over the internode TCP transport.
+func SetWALCatchupHandler(handler func(payload []byte, writeFrame func([]byte) error) error) {
+	walCatchupHandler = handler
+}
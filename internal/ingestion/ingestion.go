@@ -2,7 +2,9 @@ package ingestion
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/minitrue/internal/cluster"
 	"github.com/minitrue/internal/mqttclient"
@@ -11,6 +13,10 @@ import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+// hintReconcileInterval is how often the hinted-handoff reconciler polls
+// cluster membership for targets that have come back online.
+const hintReconcileInterval = 5 * time.Second
+
 // DataPoint represents a single measurement from a device
 // The combination of DeviceID and MetricName will be used to distribute primaries across nodes
 // to avoid one node being primary for all data of a device.
@@ -25,10 +31,25 @@ type Service struct {
 	mqtt   *mqttclient.Client
 	store  storage.Storage
 	nodeID string
+	auth   *mqttclient.AuthPipeline
 }
 
 func New(m *mqttclient.Client, s storage.Storage, nodeID string) *Service {
-	return &Service{mqtt: m, store: s, nodeID: nodeID}
+	return NewWithAuth(m, s, nodeID, mqttclient.NewAuthPipeline())
+}
+
+// NewWithAuth is like New but evaluates every inbound message through auth
+// before it reaches handle, rejecting anything that fails a filter (bad
+// token, topic/device_id mismatch, rate limit, ...).
+func NewWithAuth(m *mqttclient.Client, s storage.Storage, nodeID string, auth *mqttclient.AuthPipeline) *Service {
+	svc := &Service{mqtt: m, store: s, nodeID: nodeID, auth: auth}
+
+	// Let the cluster package forward replicated writes (live pushes and
+	// replayed hints alike) into this node's storage without importing it.
+	cluster.SetReplicaWriteHandler(svc.handleReplicaWrite)
+	cluster.StartHintReconciler(hintReconcileInterval)
+
+	return svc
 }
 
 func (s *Service) Start() {
@@ -41,73 +62,116 @@ func (s *Service) Start() {
 }
 
 func (s *Service) handle(client mqtt.Client, msg mqtt.Message) {
+	ctx := mqttclient.MessageContext{
+		Identity: mqttclient.IdentityFromTopic(msg.Topic()),
+		Topic:    msg.Topic(),
+		Payload:  msg.Payload(),
+	}
+	if err := s.auth.Evaluate(ctx); err != nil {
+		// Already logged with the rejecting filter's name by AuthPipeline.
+		return
+	}
+
 	var p DataPoint
 	if err := json.Unmarshal(msg.Payload(), &p); err != nil {
 		log.Printf("[%s][ingestion] failed to parse json: %v payload=%s", s.nodeID, err, string(msg.Payload()))
 		return
 	}
+
+	s.IngestDataPoint(p)
+	_ = client
+}
+
+// IngestDataPoint routes p through hash-ring placement: if this node is
+// primary for p's key it persists p and replicates it to the backup
+// node(s) (queuing a hint for anyone currently unreachable), otherwise it
+// does nothing and relies on the primary to have already done the same.
+// This is the write path both the MQTT handler above and any other ingest
+// transport (e.g. internal/kafkaclient's consumer group handler) funnel
+// records through, so every ingestor shares one placement and replication
+// implementation.
+func (s *Service) IngestDataPoint(p DataPoint) error {
 	if p.DeviceID == "" {
 		log.Printf("[%s][ingestion] missing device_id in payload", s.nodeID)
-		return
+		return fmt.Errorf("missing device_id")
 	}
 
 	key := p.DeviceID + ":" + p.MetricName
-	
+
 	hashRing := cluster.GetHashRing()
 	if hashRing == nil {
 		log.Printf("[%s][ingestion] Hash ring nil, storing locally", s.nodeID)
 		if err := s.store.PersistPrimary(p); err != nil {
 			log.Printf("[%s][ingestion] PersistPrimary error: %v", s.nodeID, err)
-			return
+			return err
 		}
 		log.Printf("[%s][ingestion] PRIMARY stored %s/%s = %.2f (hash ring nil)", s.nodeID, p.DeviceID, p.MetricName, p.Value)
-		return
+		return nil
 	}
-	
+
 	allNodes := hashRing.GetAllNodes()
 	if len(allNodes) == 0 {
 		log.Printf("[%s][ingestion] Hash ring empty, storing locally", s.nodeID)
 		if err := s.store.PersistPrimary(p); err != nil {
 			log.Printf("[%s][ingestion] PersistPrimary error: %v", s.nodeID, err)
-			return
+			return err
 		}
 		log.Printf("[%s][ingestion] PRIMARY stored %s/%s = %.2f (ring empty)", s.nodeID, p.DeviceID, p.MetricName, p.Value)
-		return
+		return nil
 	}
-	
-	nodes := cluster.GetNodesForKey(key, 2)
+
+	nodes := cluster.GetNodesForKey(key, cluster.ReplicationFactor())
 	if len(nodes) == 0 {
 		log.Printf("[%s][ingestion] GetNodesForKey returned empty, storing locally", s.nodeID)
 		if err := s.store.PersistPrimary(p); err != nil {
 			log.Printf("[%s][ingestion] PersistPrimary error: %v", s.nodeID, err)
-			return
+			return err
 		}
 		log.Printf("[%s][ingestion] PRIMARY stored %s/%s = %.2f (no nodes for key)", s.nodeID, p.DeviceID, p.MetricName, p.Value)
-		return
+		return nil
 	}
-	
+
 	primaryNode := nodes[0]
 
 	if primaryNode == s.nodeID {
 		if err := s.store.PersistPrimary(p); err != nil {
 			log.Printf("[%s][ingestion] PersistPrimary error: %v", s.nodeID, err)
-			return
+			return err
 		}
 		log.Printf("[%s][ingestion] PRIMARY stored %s/%s = %.2f", s.nodeID, p.DeviceID, p.MetricName, p.Value)
-		return
-	}
 
-	if len(nodes) > 1 {
-		replicaNode := nodes[1]
-		if replicaNode == s.nodeID {
-			if err := s.store.PersistReplica(p); err != nil {
-				log.Printf("[%s][ingestion] PersistReplica error: %v", s.nodeID, err)
-				return
+		// Explicitly replicate to the remaining nodes instead of relying on
+		// every node happening to see the same MQTT message: an unreachable
+		// replica gets its write queued as a hint and replayed once it is
+		// back, instead of silently losing it.
+		payload, err := json.Marshal(p)
+		if err != nil {
+			log.Printf("[%s][ingestion] Failed to marshal datapoint for replication: %v", s.nodeID, err)
+			return err
+		}
+		for _, replicaNode := range nodes[1:] {
+			if replicaNode == s.nodeID {
+				continue
+			}
+			if err := cluster.PushReplicaWrite(replicaNode, payload); err != nil {
+				log.Printf("[%s][ingestion] Failed to replicate %s/%s to %s: %v", s.nodeID, p.DeviceID, p.MetricName, replicaNode, err)
 			}
-			log.Printf("[%s][ingestion] REPLICA stored %s/%s = %.2f (primary=%s)", s.nodeID, p.DeviceID, p.MetricName, p.Value, primaryNode)
-			return
 		}
 	}
 
-	_ = client
-}
\ No newline at end of file
+	return nil
+}
+
+// handleReplicaWrite applies a write forwarded by the primary for this key,
+// whether delivered live or replayed from the primary's hint queue.
+func (s *Service) handleReplicaWrite(payload []byte) error {
+	var p DataPoint
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+	if err := s.store.PersistReplica(p); err != nil {
+		return err
+	}
+	log.Printf("[%s][ingestion] REPLICA stored %s/%s = %.2f (via hinted handoff push)", s.nodeID, p.DeviceID, p.MetricName, p.Value)
+	return nil
+}
@@ -0,0 +1,108 @@
+package kafkaclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/IBM/sarama"
+)
+
+// Client wraps a Sarama sync producer and consumer group under the small
+// surface the ingestor and the simulators actually use, the same way
+// mqttclient.Client wraps a paho client.
+type Client struct {
+	opts     Options
+	producer sarama.SyncProducer
+	group    sarama.ConsumerGroup
+}
+
+// New connects both a producer and a consumer group client to opts.Brokers.
+// Either half can be left unused - a simulator only calls Publish, an
+// ingestor only calls Consume - but both are dialed up front so a
+// misconfigured broker address fails at startup rather than on first use.
+func New(opts Options) (*Client, error) {
+	cfg := sarama.NewConfig()
+	cfg.ClientID = opts.ClientID
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+
+	producer, err := sarama.NewSyncProducer(opts.Brokers, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("kafka producer: %w", err)
+	}
+
+	group, err := sarama.NewConsumerGroup(opts.Brokers, opts.GroupID, cfg)
+	if err != nil {
+		producer.Close()
+		return nil, fmt.Errorf("kafka consumer group: %w", err)
+	}
+
+	return &Client{opts: opts, producer: producer, group: group}, nil
+}
+
+// Publish sends payload to the configured topic, for simulators publishing
+// records in parity with the MQTT arduino-pub simulator.
+func (c *Client) Publish(payload []byte) error {
+	_, _, err := c.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: c.opts.Topic,
+		Value: sarama.ByteEncoder(payload),
+	})
+	return err
+}
+
+// RecordHandler processes one record's raw payload. A record is marked
+// (and its offset later committed) only after handle returns nil, so a
+// crash mid-handle redelivers it. A non-nil error is logged and the record
+// is left unmarked, but consumption of its partition goes on: once a later
+// record there is marked, the committed offset moves past the failed one
+// and it is not redelivered.
+type RecordHandler func(payload []byte) error
+
+// Consume joins the client's consumer group and hands every record from
+// Topic to handle until ctx is done. Every Client sharing GroupID against
+// Topic gets a disjoint slice of its partitions, and Sarama rebalances that
+// split automatically as clients join or leave - the consumer group
+// semantics (offsets, rebalancing) this package is meant to expose, rather
+// than hiding them behind a single-consumer abstraction.
+func (c *Client) Consume(ctx context.Context, handle RecordHandler) error {
+	h := &consumerGroupHandler{handle: handle}
+	for {
+		if err := c.group.Consume(ctx, []string{c.opts.Topic}, h); err != nil {
+			return fmt.Errorf("kafka consume: %w", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// Close releases the producer and consumer group connections.
+func (c *Client) Close() error {
+	groupErr := c.group.Close()
+	producerErr := c.producer.Close()
+	if groupErr != nil {
+		return groupErr
+	}
+	return producerErr
+}
+
+// consumerGroupHandler adapts a RecordHandler to sarama.ConsumerGroupHandler.
+type consumerGroupHandler struct {
+	handle RecordHandler
+}
+
+func (h *consumerGroupHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
+func (h *consumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *consumerGroupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		if err := h.handle(msg.Value); err != nil {
+			log.Printf("[kafkaclient] handler error partition=%d offset=%d: %v", msg.Partition, msg.Offset, err)
+			continue
+		}
+		sess.MarkMessage(msg, "")
+	}
+	return nil
+}
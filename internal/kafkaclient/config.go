@@ -0,0 +1,24 @@
+package kafkaclient
+
+// Options configures a Client's connection to a Kafka cluster. It mirrors
+// mqttclient.Options' role for the MQTT side: everything a connection needs
+// to reach the broker(s) and identify itself, with nothing ingestion- or
+// simulator-specific mixed in.
+type Options struct {
+	// Brokers is the cluster's bootstrap address list, e.g.
+	// []string{"localhost:9092"}.
+	Brokers []string
+
+	// Topic is the topic records are produced to and consumed from.
+	Topic string
+
+	// GroupID is the consumer group Consume joins. Every minitrue node
+	// ingesting from the same topic should share a GroupID so the
+	// partitions - and therefore the ingest load - are split across them
+	// instead of each node reading every record.
+	GroupID string
+
+	// ClientID identifies this connection to the broker, the same role
+	// mqttclient.Options.ClientID plays for MQTT connections.
+	ClientID string
+}
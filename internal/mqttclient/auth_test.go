@@ -0,0 +1,118 @@
+package mqttclient
+
+import "testing"
+
+func TestClientAuthFilter(t *testing.T) {
+	f := &ClientAuthFilter{Tokens: map[string]string{"dev-1": "secret"}}
+
+	if err := f.Evaluate(MessageContext{Identity: "dev-2", Payload: []byte(`{}`)}); err != nil {
+		t.Errorf("expected identity with no configured token to pass, got %v", err)
+	}
+
+	if err := f.Evaluate(MessageContext{Identity: "dev-1", Payload: []byte(`{"token":"secret"}`)}); err != nil {
+		t.Errorf("expected matching token to pass, got %v", err)
+	}
+
+	if err := f.Evaluate(MessageContext{Identity: "dev-1", Payload: []byte(`{"token":"wrong"}`)}); err == nil {
+		t.Error("expected mismatched token to be rejected")
+	}
+
+	if err := f.Evaluate(MessageContext{Identity: "dev-1", Payload: []byte(`not json`)}); err == nil {
+		t.Error("expected unparseable payload to be rejected")
+	}
+}
+
+func TestTopicACL(t *testing.T) {
+	f := &TopicACL{AllowedPrefixes: map[string][]string{"dev-1": {"iot/sensors/dev-1"}}}
+
+	if err := f.Evaluate(MessageContext{Identity: "dev-2", Topic: "iot/sensors/dev-2"}); err != nil {
+		t.Errorf("expected identity with no configured prefixes to pass, got %v", err)
+	}
+
+	if err := f.Evaluate(MessageContext{Identity: "dev-1", Topic: "iot/sensors/dev-1/temp"}); err != nil {
+		t.Errorf("expected allowed prefix to pass, got %v", err)
+	}
+
+	if err := f.Evaluate(MessageContext{Identity: "dev-1", Topic: "iot/sensors/dev-2"}); err == nil {
+		t.Error("expected topic outside allowed prefixes to be rejected")
+	}
+}
+
+func TestPayloadValidator(t *testing.T) {
+	f := &PayloadValidator{}
+
+	if err := f.Evaluate(MessageContext{Identity: "dev-1", Payload: []byte(`{"device_id":"dev-1"}`)}); err != nil {
+		t.Errorf("expected matching device_id to pass, got %v", err)
+	}
+
+	if err := f.Evaluate(MessageContext{Identity: "dev-1", Payload: []byte(`{"device_id":"dev-2"}`)}); err == nil {
+		t.Error("expected spoofed device_id to be rejected")
+	}
+
+	if err := f.Evaluate(MessageContext{Identity: "dev-1", Payload: []byte(`{}`)}); err == nil {
+		t.Error("expected missing device_id to be rejected")
+	}
+
+	if err := f.Evaluate(MessageContext{Identity: "dev-1", Payload: []byte(`not json`)}); err == nil {
+		t.Error("expected unparseable payload to be rejected")
+	}
+}
+
+func TestRateLimiter_EnforcesCapacity(t *testing.T) {
+	f := NewRateLimiter(map[string]RateLimit{"dev-1": {Capacity: 2, RefillPerSec: 0}}, RateLimit{})
+
+	ctx := MessageContext{Identity: "dev-1"}
+	if err := f.Evaluate(ctx); err != nil {
+		t.Fatalf("expected first message within capacity to pass, got %v", err)
+	}
+	if err := f.Evaluate(ctx); err != nil {
+		t.Fatalf("expected second message within capacity to pass, got %v", err)
+	}
+	if err := f.Evaluate(ctx); err == nil {
+		t.Error("expected third message to exceed capacity and be rejected")
+	}
+}
+
+func TestRateLimiter_FallbackDisabledByDefault(t *testing.T) {
+	f := NewRateLimiter(nil, RateLimit{})
+	for i := 0; i < 5; i++ {
+		if err := f.Evaluate(MessageContext{Identity: "unlisted"}); err != nil {
+			t.Fatalf("expected a zero-value fallback to disable rate limiting, got %v", err)
+		}
+	}
+}
+
+func TestAuthPipeline_StopsAtFirstRejection(t *testing.T) {
+	p := NewAuthPipeline(
+		&PayloadValidator{},
+		&TopicACL{AllowedPrefixes: map[string][]string{"dev-1": {"iot/sensors/other"}}},
+	)
+
+	err := p.Evaluate(MessageContext{Identity: "dev-1", Topic: "iot/sensors/dev-1", Payload: []byte(`{"device_id":"dev-1"}`)})
+	if err == nil {
+		t.Fatal("expected pipeline to reject based on TopicACL")
+	}
+	if p.Rejected() != 1 || p.Accepted() != 0 {
+		t.Errorf("Rejected()=%d Accepted()=%d, want 1 and 0", p.Rejected(), p.Accepted())
+	}
+
+	if err := p.Evaluate(MessageContext{Identity: "dev-1", Topic: "iot/sensors/other", Payload: []byte(`{"device_id":"dev-1"}`)}); err != nil {
+		t.Fatalf("expected a message passing every filter to be accepted, got %v", err)
+	}
+	if p.Accepted() != 1 {
+		t.Errorf("Accepted() = %d, want 1", p.Accepted())
+	}
+}
+
+func TestIdentityFromTopic(t *testing.T) {
+	cases := map[string]string{
+		"iot/sensors/dev-1":      "dev-1",
+		"iot/sensors/dev-1/temp": "dev-1",
+		"other/topic":            "",
+	}
+	for topic, want := range cases {
+		if got := IdentityFromTopic(topic); got != want {
+			t.Errorf("IdentityFromTopic(%q) = %q, want %q", topic, got, want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package mqttclient
 
 import (
+	"crypto/tls"
 	"fmt"
 	"time"
 
@@ -10,6 +11,15 @@ import (
 type Options struct {
 	BrokerURL string
 	ClientID  string
+
+	// Username/Password authenticate this connection with the broker
+	// (MQTT CONNECT-level auth). Both are optional.
+	Username string
+	Password string
+
+	// TLSConfig, when set, is used for the broker connection and is the
+	// place to supply client certificates for mTLS auth.
+	TLSConfig *tls.Config
 }
 
 type Client struct {
@@ -22,6 +32,13 @@ func New(opts Options) (*Client, error) {
 	o.SetClientID(opts.ClientID)
 	o.SetConnectRetry(true)
 	o.SetConnectRetryInterval(2 * time.Second)
+	if opts.Username != "" {
+		o.SetUsername(opts.Username)
+		o.SetPassword(opts.Password)
+	}
+	if opts.TLSConfig != nil {
+		o.SetTLSConfig(opts.TLSConfig)
+	}
 	c := mqtt.NewClient(o)
 
 	token := c.Connect()
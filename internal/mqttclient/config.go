@@ -0,0 +1,112 @@
+package mqttclient
+
+import "fmt"
+
+// AuthConfig is the parsed form of the YAML auth pipeline config. See
+// LoadAuthConfig for the expected file shape.
+type AuthConfig struct {
+	Devices map[string]DeviceConfig
+	Default RateLimit
+}
+
+// DeviceConfig holds the per-device settings read from the "devices" map in
+// the config file.
+type DeviceConfig struct {
+	Token           string
+	AllowedPrefixes []string
+	RateLimit       RateLimit
+}
+
+// LoadAuthConfig reads a YAML file shaped like:
+//
+//	devices:
+//	  device-1:
+//	    token: "abc123"
+//	    allowed_prefixes:
+//	      - "iot/sensors/device-1"
+//	    rate_limit:
+//	      capacity: 20
+//	      refill_per_sec: 5
+//	default_rate_limit:
+//	  capacity: 100
+//	  refill_per_sec: 20
+func LoadAuthConfig(path string) (*AuthConfig, error) {
+	raw, err := parseYAML(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse auth config: %w", err)
+	}
+
+	cfg := &AuthConfig{Devices: make(map[string]DeviceConfig)}
+
+	if devicesRaw, ok := raw["devices"].(map[string]interface{}); ok {
+		for id, v := range devicesRaw {
+			devMap, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("devices.%s: expected a map", id)
+			}
+			cfg.Devices[id] = parseDeviceConfig(devMap)
+		}
+	}
+
+	if defRaw, ok := raw["default_rate_limit"].(map[string]interface{}); ok {
+		cfg.Default = parseRateLimit(defRaw)
+	}
+
+	return cfg, nil
+}
+
+func parseDeviceConfig(m map[string]interface{}) DeviceConfig {
+	var dc DeviceConfig
+	if token, ok := m["token"].(string); ok {
+		dc.Token = token
+	}
+	if list, ok := m["allowed_prefixes"].([]interface{}); ok {
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				dc.AllowedPrefixes = append(dc.AllowedPrefixes, s)
+			}
+		}
+	}
+	if rl, ok := m["rate_limit"].(map[string]interface{}); ok {
+		dc.RateLimit = parseRateLimit(rl)
+	}
+	return dc
+}
+
+func parseRateLimit(m map[string]interface{}) RateLimit {
+	var rl RateLimit
+	if c, ok := m["capacity"].(float64); ok {
+		rl.Capacity = c
+	}
+	if r, ok := m["refill_per_sec"].(float64); ok {
+		rl.RefillPerSec = r
+	}
+	return rl
+}
+
+// BuildPipeline assembles the standard AuthPipeline (ClientAuth -> TopicACL
+// -> PayloadValidator -> RateLimiter) from a loaded AuthConfig.
+func (cfg *AuthConfig) BuildPipeline() *AuthPipeline {
+	tokens := make(map[string]string)
+	prefixes := make(map[string][]string)
+	limits := make(map[string]RateLimit)
+
+	for id, dc := range cfg.Devices {
+		if dc.Token != "" {
+			tokens[id] = dc.Token
+		}
+		if len(dc.AllowedPrefixes) > 0 {
+			prefixes[id] = dc.AllowedPrefixes
+		}
+		if dc.RateLimit.Capacity > 0 {
+			limits[id] = dc.RateLimit
+		}
+	}
+
+	return NewAuthPipeline(
+		&ClientAuthFilter{Tokens: tokens},
+		&TopicACL{AllowedPrefixes: prefixes},
+		&PayloadValidator{},
+		NewRateLimiter(limits, cfg.Default),
+	)
+}
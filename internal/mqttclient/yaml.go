@@ -0,0 +1,146 @@
+package mqttclient
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseYAML reads a small indentation-based subset of YAML: nested maps,
+// string/number/bool scalars, and block lists of scalars. It does not
+// support flow style, anchors, or multi-document files. This is enough to
+// express the auth pipeline config below without pulling in a YAML library,
+// matching the rest of this repo's preference for small hand-rolled parsers
+// over third-party dependencies.
+func parseYAML(path string) (map[string]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []yamlLine
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		trimmed := strings.TrimRight(raw, " \t")
+		withoutIndent := strings.TrimLeft(trimmed, " ")
+		content := strings.TrimSpace(stripYAMLComment(withoutIndent))
+		if content == "" {
+			continue
+		}
+		indent := len(trimmed) - len(withoutIndent)
+		lines = append(lines, yamlLine{indent: indent, content: content})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	result, _, err := parseYAMLMap(lines, 0, 0)
+	return result, err
+}
+
+type yamlLine struct {
+	indent  int
+	content string
+}
+
+func stripYAMLComment(s string) string {
+	if idx := strings.Index(s, "#"); idx >= 0 {
+		return s[:idx]
+	}
+	return s
+}
+
+// parseYAMLMap parses a sequence of sibling "key: value" / "key:" lines
+// starting at lines[start] with the given indent, returning the map and the
+// index of the first line not consumed.
+func parseYAMLMap(lines []yamlLine, start, indent int) (map[string]interface{}, int, error) {
+	result := make(map[string]interface{})
+	i := start
+	for i < len(lines) {
+		line := lines[i]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, i, fmt.Errorf("unexpected indent at %q", line.content)
+		}
+		if strings.HasPrefix(line.content, "- ") {
+			break
+		}
+
+		key, value, ok := strings.Cut(line.content, ":")
+		if !ok {
+			return nil, i, fmt.Errorf("expected \"key: value\", got %q", line.content)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if value != "" {
+			result[key] = parseYAMLScalar(value)
+			i++
+			continue
+		}
+
+		// Nested block: either a map or a list, indented deeper than this key.
+		if i+1 < len(lines) && lines[i+1].indent > indent {
+			childIndent := lines[i+1].indent
+			if strings.HasPrefix(lines[i+1].content, "- ") {
+				list, next, err := parseYAMLList(lines, i+1, childIndent)
+				if err != nil {
+					return nil, i, err
+				}
+				result[key] = list
+				i = next
+				continue
+			}
+			m, next, err := parseYAMLMap(lines, i+1, childIndent)
+			if err != nil {
+				return nil, i, err
+			}
+			result[key] = m
+			i = next
+			continue
+		}
+
+		result[key] = nil
+		i++
+	}
+	return result, i, nil
+}
+
+func parseYAMLList(lines []yamlLine, start, indent int) ([]interface{}, int, error) {
+	var result []interface{}
+	i := start
+	for i < len(lines) {
+		line := lines[i]
+		if line.indent != indent || !strings.HasPrefix(line.content, "- ") {
+			break
+		}
+		item := strings.TrimSpace(strings.TrimPrefix(line.content, "-"))
+		result = append(result, parseYAMLScalar(item))
+		i++
+	}
+	return result, i, nil
+}
+
+func parseYAMLScalar(s string) interface{} {
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		return s[1 : len(s)-1]
+	}
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null", "~":
+		return nil
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n
+	}
+	return s
+}
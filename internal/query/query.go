@@ -1,19 +1,39 @@
 package query
 
 import (
-	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/minitrue/internal/cluster"
+	"github.com/minitrue/internal/cluster/client"
+	"github.com/minitrue/internal/models"
 	"github.com/minitrue/internal/mqttclient"
 	"github.com/minitrue/internal/storage"
 	"github.com/minitrue/internal/websocket"
+	pkgcluster "github.com/minitrue/pkg/cluster"
+)
+
+// streamNumBuckets is the number of equal-width time buckets each node
+// splits a /query-aggregated-stream range into. streamFlushInterval bounds
+// how often the coordinator re-emits its current merged state, and
+// streamQueryTimeout is the deadline past which a straggling node's partial
+// results are dropped rather than holding up the response.
+const (
+	streamNumBuckets    = 20
+	streamFlushInterval = 200 * time.Millisecond
+	streamQueryTimeout  = 30 * time.Second
+	walCatchupTimeout   = 30 * time.Second
 )
 
 type QueryRequest struct {
@@ -22,8 +42,31 @@ type QueryRequest struct {
 	Operation  string `json:"operation"`
 	StartTime  int64  `json:"start_time"`
 	EndTime    int64  `json:"end_time"`
+	// ReadPolicy picks how distributedQueryAggregated reconciles the
+	// replicas for this key; see the ReadPolicy* constants. The zero value
+	// behaves like ReadPolicyAll, so requests from before this field existed
+	// keep today's wait-for-everyone-then-repair behavior.
+	ReadPolicy string `json:"read_policy,omitempty"`
 }
 
+// ReadPolicy values for QueryRequest.ReadPolicy.
+const (
+	// ReadPolicyAll waits for every candidate replica and read-repairs any
+	// that came back with fewer samples than this node - the original,
+	// strongest-consistency behavior.
+	ReadPolicyAll = "all"
+	// ReadPolicyQuorum returns as soon as ceil(R/2)+1 of the R replicas
+	// (at most R) agree on the same QueryStats, canceling the rest.
+	ReadPolicyQuorum = "quorum"
+	// ReadPolicyOne queries only the first candidate replica and returns
+	// whatever it has, without fanning out or repairing.
+	ReadPolicyOne = "one"
+	// ReadPolicyHedged queries the primary and, if it hasn't answered
+	// within its self-tuned hedge delay (see cluster.HedgeDelay), also
+	// queries the next replica and takes whichever responds first.
+	ReadPolicyHedged = "hedged"
+)
+
 type QueryResult struct {
 	DeviceID   string  `json:"device_id"`
 	MetricName string  `json:"metric_name"`
@@ -33,6 +76,19 @@ type QueryResult struct {
 	Duration   int64   `json:"duration_ns"`
 }
 
+// toClientRequest converts a QueryRequest to client.QueryRequest, the
+// type redeclared in internal/cluster/client to avoid an import cycle
+// back into this package.
+func toClientRequest(qr QueryRequest) client.QueryRequest {
+	return client.QueryRequest{
+		DeviceID:   qr.DeviceID,
+		MetricName: qr.MetricName,
+		Operation:  qr.Operation,
+		StartTime:  qr.StartTime,
+		EndTime:    qr.EndTime,
+	}
+}
+
 func combineStats(stats []storage.QueryStats) storage.QueryStats {
 	if len(stats) == 0 {
 		return storage.QueryStats{}
@@ -52,23 +108,564 @@ func combineStats(stats []storage.QueryStats) storage.QueryStats {
 }
 
 type Service struct {
-	mqtt       *mqttclient.Client
-	store      storage.Storage
-	nodeID     string
-	httpClient *http.Client
-	wsHub      *websocket.Hub
-	restartFn  func() 
+	mqtt    *mqttclient.Client
+	store   storage.Storage
+	nodeID  string
+	qClient *client.Client
+	wsHub   *websocket.Hub
 }
 
 func New(m *mqttclient.Client, s storage.Storage) *Service {
 	return NewWithNodeID(m, s, "")
 }
 
-func NewWithNodeID(m *mqttclient.Client, s storage.Storage, nodeID string) *Service {
-	return NewWithRestart(m, s, nodeID, nil)
+// newQueryClient builds the internode query RPC client for this node and
+// registers s's own storage as the local bypass target, so distributedQuery
+// routes to it in-process instead of over the pool.
+func newQueryClient(nodeID string, s storage.Storage) *client.Client {
+	qClient := client.New(5 * time.Second)
+	qClient.SetLocal(nodeID, s)
+
+	cluster.SetQueryHandler(func(msgType string, payload []byte) ([]byte, error) {
+		var qr QueryRequest
+		if err := json.Unmarshal(payload, &qr); err != nil {
+			return nil, fmt.Errorf("invalid query request: %w", err)
+		}
+
+		switch msgType {
+		case "query_samples":
+			samples, err := s.Query(qr.DeviceID, qr.MetricName, qr.StartTime, qr.EndTime)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(struct {
+				Samples []float64 `json:"samples"`
+			}{samples})
+
+		case "query_aggregated":
+			stats, err := s.QueryAggregated(qr.DeviceID, qr.MetricName, qr.StartTime, qr.EndTime)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(struct {
+				Stats storage.QueryStats `json:"stats"`
+			}{stats})
+
+		default:
+			return nil, fmt.Errorf("unknown query message type %q", msgType)
+		}
+	})
+
+	cluster.SetQueryStreamHandler(func(payload []byte, writeFrame func([]byte) error) error {
+		var req bucketStreamRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return fmt.Errorf("invalid query request: %w", err)
+		}
+
+		err := s.QueryAggregatedBuckets(req.DeviceID, req.MetricName, req.StartTime, req.EndTime, req.Buckets, func(b storage.TimeBucket) error {
+			frame, err := json.Marshal(bucketStreamChunk{Bucket: b})
+			if err != nil {
+				return err
+			}
+			return writeFrame(frame)
+		})
+		if err != nil {
+			return err
+		}
+
+		done, _ := json.Marshal(bucketStreamChunk{Done: true})
+		return writeFrame(done)
+	})
+
+	cluster.SetWALCatchupHandler(func(payload []byte, writeFrame func([]byte) error) error {
+		var req walCatchupRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return fmt.Errorf("invalid wal catch-up request: %w", err)
+		}
+
+		records, err := s.WALRecordsSince(req.AfterReqNum)
+		if err != nil {
+			return err
+		}
+		for _, rec := range records {
+			frame, err := json.Marshal(walCatchupChunk{Record: rec})
+			if err != nil {
+				return err
+			}
+			if err := writeFrame(frame); err != nil {
+				return err
+			}
+		}
+
+		done, _ := json.Marshal(walCatchupChunk{Done: true})
+		return writeFrame(done)
+	})
+
+	cluster.SetMerkleBlockHandler(func(payload []byte) ([]byte, error) {
+		var predicate pkgcluster.MerkleBlockPredicate
+		if err := json.Unmarshal(payload, &predicate); err != nil {
+			return nil, fmt.Errorf("invalid merkle block request: %w", err)
+		}
+
+		records, err := s.WALRecordsSince(0)
+		if err != nil {
+			return nil, err
+		}
+		leaves := make([]string, len(records))
+		for i, rec := range records {
+			leaves[i] = walRecordLeaf(rec)
+		}
+
+		tree := pkgcluster.NewMerkleTree(leaves)
+		block, err := pkgcluster.BuildMerkleBlock(tree, predicate)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(struct {
+			Block *pkgcluster.MerkleBlock `json:"block"`
+		}{block})
+	})
+
+	cluster.SetRangeReconcileHandler(func(payload []byte) ([]byte, error) {
+		var req rangeCompareRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, fmt.Errorf("invalid range reconcile request: %w", err)
+		}
+
+		records, err := s.WALRecordsSince(0)
+		if err != nil {
+			return nil, err
+		}
+		leaves := make([]string, len(records))
+		for i, rec := range records {
+			leaves[i] = walRecordLeaf(rec)
+		}
+
+		tree := pkgcluster.NewMerkleTree(leaves)
+		result, err := pkgcluster.NewLocalRangePeer(tree).CompareRange(req.Low, req.High, req.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(struct {
+			Equal     bool     `json:"equal,omitempty"`
+			Terminal  bool     `json:"terminal,omitempty"`
+			Leaves    []string `json:"leaves,omitempty"`
+			Mid       string   `json:"mid,omitempty"`
+			LeftHash  string   `json:"left_hash,omitempty"`
+			RightHash string   `json:"right_hash,omitempty"`
+		}{result.Equal, result.Terminal, result.Leaves, result.Mid, result.LeftHash, result.RightHash})
+	})
+
+	cluster.SetRangeProofHandler(func(payload []byte) ([]byte, error) {
+		var req rangeProofRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, fmt.Errorf("invalid range proof request: %w", err)
+		}
+
+		records, err := s.WALRecordsSince(0)
+		if err != nil {
+			return nil, err
+		}
+		leaves := make([]string, len(records))
+		for i, rec := range records {
+			leaves[i] = walRecordLeaf(rec)
+		}
+
+		tree := pkgcluster.NewMerkleTree(leaves)
+		rp, err := tree.GetRangeProof(req.Low, req.High)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(struct {
+			RootHash string                 `json:"root_hash"`
+			Proof    *pkgcluster.RangeProof `json:"proof"`
+		}{tree.GetRootHash(), rp})
+	})
+
+	cluster.SetSyncRootHandler(func(payload []byte) ([]byte, error) {
+		var req syncRootRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, fmt.Errorf("invalid sync root request: %w", err)
+		}
+
+		tree, err := shardMerkleTree(s, req.ShardKey)
+		if err != nil {
+			return nil, err
+		}
+		rootHash, err := pkgcluster.NewLocalSyncSession(tree).RequestRoot()
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(syncRootResponse{RootHash: rootHash})
+	})
+
+	cluster.SetSyncChildrenHandler(func(payload []byte) ([]byte, error) {
+		var req syncChildrenRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, fmt.Errorf("invalid sync children request: %w", err)
+		}
+
+		tree, err := shardMerkleTree(s, req.ShardKey)
+		if err != nil {
+			return nil, err
+		}
+		leftHash, rightHash, isLeaf, data, err := pkgcluster.NewLocalSyncSession(tree).RequestChildren(req.NodeHash)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(syncChildrenResponse{LeftHash: leftHash, RightHash: rightHash, IsLeaf: isLeaf, Data: data})
+	})
+
+	cluster.SetIndexSummaryHandler(func(payload []byte) ([]byte, error) {
+		var cfg pkgcluster.ClusterConfig
+		if err := pkgcluster.UnmarshalMsgpack(payload, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid cluster config: %w", err)
+		}
+
+		// Sourced from AllRecords (the on-disk, footer-backed segment), not
+		// WALRecordsSince: the WAL only keeps walRetention worth of history,
+		// and anti-entropy exists specifically to reconcile partitions longer
+		// than that.
+		records, err := s.AllRecords()
+		if err != nil {
+			return nil, err
+		}
+		all := pkgcluster.BuildShardSummaries(recordsToIndexRecords(records))
+
+		wanted := make(map[string]bool, len(cfg.ShardKeys))
+		for _, key := range cfg.ShardKeys {
+			wanted[key] = true
+		}
+		summaries := make([]pkgcluster.ShardSummary, 0, len(wanted))
+		for key, summary := range all {
+			if wanted[key] {
+				summaries = append(summaries, summary)
+			}
+		}
+
+		return pkgcluster.MarshalMsgpack(pkgcluster.IndexSummary{Summaries: summaries})
+	})
+
+	cluster.SetIndexRequestHandler(func(payload []byte) ([]byte, error) {
+		var req pkgcluster.IndexRequest
+		if err := pkgcluster.UnmarshalMsgpack(payload, &req); err != nil {
+			return nil, fmt.Errorf("invalid index request: %w", err)
+		}
+
+		wantHour := make(map[int64]bool, len(req.Hours))
+		for _, h := range req.Hours {
+			wantHour[h] = true
+		}
+
+		deviceID, metricName := splitShardKey(req.ShardKey)
+		records, err := s.QueryRaw(deviceID, metricName, 0, math.MaxInt64)
+		if err != nil {
+			return nil, err
+		}
+
+		var matched []pkgcluster.IndexRecord
+		for _, r := range records {
+			if wantHour[pkgcluster.HourBucket(r.Timestamp)] {
+				matched = append(matched, pkgcluster.IndexRecord{
+					DeviceID: r.DeviceID, MetricName: r.MetricName, Timestamp: r.Timestamp, Value: r.Value,
+				})
+			}
+		}
+
+		return pkgcluster.MarshalMsgpack(pkgcluster.RecordResponse{ShardKey: req.ShardKey, Records: matched})
+	})
+
+	// The first time gossip reports another active node, pull its WAL from
+	// the beginning so this node catches up on writes/deletes it missed
+	// while it was down (or never had, on a first join) instead of waiting
+	// for the next ingest to reconcile it. Only the first peer triggers
+	// this - one full catch-up is enough to get caught up with the cluster.
+	var catchUpOnce sync.Once
+	cluster.RegisterNodeEventListener(func(peerNodeID string, add bool) {
+		if !add || peerNodeID == nodeID {
+			return
+		}
+		catchUpOnce.Do(func() {
+			go catchUpFromPeer(qClient, nodeID, s, peerNodeID)
+		})
+	})
+
+	startAntiEntropyLoop(qClient, nodeID, s)
+
+	return qClient
+}
+
+// bucketStreamRequest, bucketStreamChunk, walCatchupRequest and
+// walCatchupChunk mirror their client package counterparts' wire shape;
+// they're redeclared rather than exported from cluster/client for the same
+// import-cycle reason as QueryRequest.
+type bucketStreamRequest struct {
+	QueryRequest
+	Buckets int `json:"buckets"`
+}
+
+type bucketStreamChunk struct {
+	Bucket storage.TimeBucket `json:"bucket"`
+	Done   bool               `json:"done,omitempty"`
+	Error  string             `json:"error,omitempty"`
+}
+
+type walCatchupRequest struct {
+	AfterReqNum int64 `json:"after_req_num"`
+}
+
+type walCatchupChunk struct {
+	Record storage.WALRecord `json:"record"`
+	Done   bool              `json:"done,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// rangeCompareRequest mirrors client package's wire shape for a single
+// "merkle_reconcile" request; redeclared rather than exported from
+// cluster/client for the same import-cycle reason as QueryRequest.
+type rangeCompareRequest struct {
+	Low  string `json:"low"`
+	High string `json:"high"`
+	Hash string `json:"hash"`
+}
+
+// rangeProofRequest mirrors client package's wire shape for a single
+// "merkle_range_proof" request; redeclared rather than exported from
+// cluster/client for the same import-cycle reason as QueryRequest.
+type rangeProofRequest struct {
+	Low  string `json:"low"`
+	High string `json:"high"`
+}
+
+// syncRootRequest/syncRootResponse and syncChildrenRequest/syncChildrenResponse
+// mirror the client package's wire shapes for "merkle_sync_root"/
+// "merkle_sync_children"; redeclared rather than exported from
+// cluster/client for the same import-cycle reason as QueryRequest.
+type syncRootRequest struct {
+	ShardKey string `json:"shard_key"`
+}
+
+type syncRootResponse struct {
+	RootHash string `json:"root_hash"`
+}
+
+type syncChildrenRequest struct {
+	ShardKey string `json:"shard_key"`
+	NodeHash string `json:"node_hash"`
+}
+
+type syncChildrenResponse struct {
+	LeftHash  string `json:"left_hash,omitempty"`
+	RightHash string `json:"right_hash,omitempty"`
+	IsLeaf    bool   `json:"is_leaf,omitempty"`
+	Data      string `json:"data,omitempty"`
+}
+
+// shardMerkleTree builds a MerkleTree over just shardKey's records, the
+// scope a merkle_sync_root/merkle_sync_children request and
+// runAntiEntropy's post-merge verification both operate on instead of the
+// whole node's dataset.
+func shardMerkleTree(s storage.Storage, shardKey string) (*pkgcluster.MerkleTree, error) {
+	deviceID, metricName := splitShardKey(shardKey)
+
+	records, err := s.AllRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	var leaves []string
+	for _, r := range records {
+		if r.DeviceID == deviceID && r.MetricName == metricName {
+			leaves = append(leaves, recordLeaf(r))
+		}
+	}
+	return pkgcluster.NewMerkleTree(leaves), nil
+}
+
+// recordLeaf renders a models.Record as a MerkleTree leaf for shardMerkleTree.
+func recordLeaf(r models.Record) string {
+	return fmt.Sprintf("%s:%s:%d:%.6f", r.DeviceID, r.MetricName, r.Timestamp, r.Value)
+}
+
+// walRecordLeaf renders rec as a MerkleTree leaf: device ID first so a
+// cluster.MerkleBlockPredicate prefix match (e.g. "give me proof for just
+// device D") lines up with the leaf string's start.
+func walRecordLeaf(rec storage.WALRecord) string {
+	return fmt.Sprintf("%s:%s:%d:%.6f:%s", rec.DeviceID, rec.MetricName, rec.Timestamp, rec.Value, rec.Op)
+}
+
+// splitShardKey reverses pkgcluster.ShardKey. Like the hash-ring key
+// internal/ingestion builds the same way, this assumes device_id doesn't
+// itself contain ":" - a pre-existing assumption this just has to live with,
+// not one anti-entropy introduces.
+func splitShardKey(key string) (deviceID, metricName string) {
+	parts := strings.SplitN(key, ":", 2)
+	if len(parts) != 2 {
+		return key, ""
+	}
+	return parts[0], parts[1]
+}
+
+// walRecordsToIndexRecords drops WAL tombstones (Op == "delete") and
+// reshapes the rest into the DeviceID/MetricName/Timestamp/Value-only
+// pkgcluster.IndexRecord BuildShardSummaries groups by shard and hour.
+func walRecordsToIndexRecords(records []storage.WALRecord) []pkgcluster.IndexRecord {
+	out := make([]pkgcluster.IndexRecord, 0, len(records))
+	for _, r := range records {
+		if r.Op == "delete" {
+			continue
+		}
+		out = append(out, pkgcluster.IndexRecord{
+			DeviceID: r.DeviceID, MetricName: r.MetricName, Timestamp: r.Timestamp, Value: r.Value,
+		})
+	}
+	return out
+}
+
+// recordsToIndexRecords reshapes AllRecords' output into the
+// DeviceID/MetricName/Timestamp/Value-only pkgcluster.IndexRecord
+// BuildShardSummaries groups by shard and hour. Unlike
+// walRecordsToIndexRecords, there are no tombstones to drop: Delete rewrites
+// a deleted series' data out of the on-disk segment rather than leaving one
+// behind.
+func recordsToIndexRecords(records []models.Record) []pkgcluster.IndexRecord {
+	out := make([]pkgcluster.IndexRecord, 0, len(records))
+	for _, r := range records {
+		out = append(out, pkgcluster.IndexRecord{
+			DeviceID: r.DeviceID, MetricName: r.MetricName, Timestamp: r.Timestamp, Value: r.Value,
+		})
+	}
+	return out
+}
+
+// antiEntropyInterval is how often startAntiEntropyLoop re-exchanges index
+// summaries with every active peer. This is independent of (and slower
+// than) the write-time replication path and hinted handoff: it's what
+// recovers data after a partition lasts long enough that a replica missed
+// writes its hints never got queued for, e.g. because it wasn't even a
+// replica for the affected keys at write time.
+const antiEntropyInterval = 5 * time.Minute
+
+// startAntiEntropyLoop runs runAntiEntropy on antiEntropyInterval until the
+// process exits.
+func startAntiEntropyLoop(qClient *client.Client, nodeID string, s storage.Storage) {
+	go func() {
+		ticker := time.NewTicker(antiEntropyInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runAntiEntropy(qClient, nodeID, s)
+		}
+	}()
+}
+
+// runAntiEntropy is a Syncthing-style index exchange round: summarize every
+// shard this node holds records for by hour bucket, ask each active peer for
+// its own summary of those same shards, and pull + merge the records behind
+// any hour bucket whose hash disagrees. Errors talking to one peer are
+// logged and skipped rather than aborting the round for the rest.
+func runAntiEntropy(qClient *client.Client, nodeID string, s storage.Storage) {
+	records, err := s.AllRecords()
+	if err != nil {
+		log.Printf("[%s][anti-entropy] Failed to read local records: %v", nodeID, err)
+		return
+	}
+	localSummaries := pkgcluster.BuildShardSummaries(recordsToIndexRecords(records))
+	if len(localSummaries) == 0 {
+		return
+	}
+	shardKeys := make([]string, 0, len(localSummaries))
+	for key := range localSummaries {
+		shardKeys = append(shardKeys, key)
+	}
+
+	for _, member := range cluster.GetClusterManager().GetMembers() {
+		if member.ID == nodeID || member.Status != "active" {
+			continue
+		}
+
+		peerSummaries, err := qClient.FetchIndexSummary(member.ID, shardKeys)
+		if err != nil {
+			log.Printf("[%s][anti-entropy] Failed to fetch index summary from %s: %v", nodeID, member.ID, err)
+			continue
+		}
+
+		for _, peerSummary := range peerSummaries {
+			local, ok := localSummaries[peerSummary.ShardKey]
+			if !ok {
+				continue
+			}
+			hours := pkgcluster.DiffShardSummary(local, peerSummary)
+			if len(hours) == 0 {
+				continue
+			}
+
+			remoteRecords, err := qClient.FetchRecords(member.ID, peerSummary.ShardKey, hours)
+			if err != nil {
+				log.Printf("[%s][anti-entropy] Failed to fetch records for %s from %s: %v", nodeID, peerSummary.ShardKey, member.ID, err)
+				continue
+			}
+			for _, r := range remoteRecords {
+				if err := s.PersistReplica(r); err != nil {
+					log.Printf("[%s][anti-entropy] Failed to merge record for %s: %v", nodeID, peerSummary.ShardKey, err)
+				}
+			}
+			if len(remoteRecords) > 0 {
+				log.Printf("[%s][anti-entropy] Merged %d record(s) for %s from %s (hour buckets: %v)",
+					nodeID, len(remoteRecords), peerSummary.ShardKey, member.ID, hours)
+
+				if tree, err := shardMerkleTree(s, peerSummary.ShardKey); err != nil {
+					log.Printf("[%s][anti-entropy] Failed to build verification tree for %s: %v", nodeID, peerSummary.ShardKey, err)
+				} else if missing, extra, conflicting, err := qClient.VerifySync(member.ID, peerSummary.ShardKey, tree); err != nil {
+					log.Printf("[%s][anti-entropy] Post-merge verification against %s for %s failed: %v", nodeID, member.ID, peerSummary.ShardKey, err)
+				} else if len(missing) > 0 || len(extra) > 0 || len(conflicting) > 0 {
+					log.Printf("[%s][anti-entropy] Post-merge verification against %s for %s still disagrees: %d missing, %d extra, %d conflicting",
+						nodeID, member.ID, peerSummary.ShardKey, len(missing), len(extra), len(conflicting))
+				}
+			}
+		}
+	}
+}
+
+// catchUpFromPeer pulls peerNodeID's whole WAL and replays each record into
+// s, logging rather than failing the caller on error since it runs in the
+// background off newQueryClient.
+func catchUpFromPeer(qClient *client.Client, nodeID string, s storage.Storage, peerNodeID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), walCatchupTimeout)
+	defer cancel()
+
+	applied := 0
+	err := qClient.FetchWALSince(ctx, peerNodeID, 0, func(rec storage.WALRecord) {
+		var applyErr error
+		switch {
+		case rec.Op == "delete":
+			applyErr = s.Delete(rec.DeviceID, rec.MetricName)
+		case rec.Role == "replica":
+			applyErr = s.PersistReplica(rec)
+		default:
+			applyErr = s.PersistPrimary(rec)
+		}
+		if applyErr != nil {
+			log.Printf("[Query] Failed to apply WAL record from %s during catch-up: %v", peerNodeID, applyErr)
+			return
+		}
+		applied++
+	})
+	if err != nil {
+		log.Printf("[Query] WAL catch-up from %s failed: %v", peerNodeID, err)
+		return
+	}
+
+	log.Printf("[%s] Caught up %d WAL record(s) from peer %s", nodeID, applied, peerNodeID)
 }
 
-func NewWithRestart(m *mqttclient.Client, s storage.Storage, nodeID string, restartFn func()) *Service {
+func NewWithNodeID(m *mqttclient.Client, s storage.Storage, nodeID string) *Service {
+	qClient := newQueryClient(nodeID, s)
+
 	wsOpts := mqttclient.Options{
 		BrokerURL: "tcp://localhost:1883",
 		ClientID:  fmt.Sprintf("minitrue-ws-%s-%d", nodeID, time.Now().UnixNano()),
@@ -77,29 +674,23 @@ func NewWithRestart(m *mqttclient.Client, s storage.Storage, nodeID string, rest
 	if err != nil {
 		log.Printf("[WebSocket] Failed to create MQTT client: %v", err)
 		return &Service{
-			mqtt:   m,
-			store:  s,
-			nodeID: nodeID,
-			httpClient: &http.Client{
-				Timeout: 5 * time.Second,
-			},
-			wsHub:     nil,
-			restartFn: restartFn,
+			mqtt:    m,
+			store:   s,
+			nodeID:  nodeID,
+			qClient: qClient,
+			wsHub:   nil,
 		}
 	}
 
-	hub := websocket.NewHub(wsMqttClient)
+	hub := websocket.NewHub(wsMqttClient, s)
 	go hub.Run()
 
 	return &Service{
-		mqtt:   m,
-		store:  s,
-		nodeID: nodeID,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Second,
-		},
-		wsHub:     hub,
-		restartFn: restartFn,
+		mqtt:    m,
+		store:   s,
+		nodeID:  nodeID,
+		qClient: qClient,
+		wsHub:   hub,
 	}
 }
 
@@ -107,7 +698,14 @@ func (s *Service) StartHTTP(port int) {
 	http.HandleFunc("/query", s.handleQuery)
 	http.HandleFunc("/query-samples", s.handleQuerySamples)
 	http.HandleFunc("/query-aggregated", s.handleQueryAggregated)
+	http.HandleFunc("/query-aggregated-stream", s.handleQueryAggregatedStream)
+	http.HandleFunc("/queryql", s.handleQueryQL)
 	http.HandleFunc("/delete", s.handleDelete)
+	http.HandleFunc("/cluster/members", s.handleClusterMembers)
+	http.HandleFunc("/cluster/fsm-history", s.handleClusterFSMHistory)
+	http.HandleFunc("/cluster/proof", s.handleClusterProof)
+	http.HandleFunc("/cluster/reconcile-range", s.handleClusterReconcileRange)
+	http.HandleFunc("/cluster/range-proof", s.handleClusterRangeProof)
 
 	if s.wsHub != nil {
 		http.HandleFunc("/ws", s.handleWebSocket)
@@ -123,6 +721,173 @@ func (s *Service) StartHTTP(port int) {
 	}
 }
 
+func (s *Service) handleClusterMembers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	members := cluster.GetClusterManager().GetMembers()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"members": members,
+		"count":   len(members),
+	})
+}
+
+// handleClusterFSMHistory looks up the cluster-membership command that was
+// applied as of ?version=N, from the FSM's persisted version history (see
+// ClusterFSM.InitPersistence). Requires the server to have been started
+// with a data dir the FSM could open a node db under.
+func (s *Service) handleClusterFSMHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	fsm := cluster.GetFSM()
+	if !fsm.HistoryAvailable() {
+		http.Error(w, "FSM history not available on this node", http.StatusServiceUnavailable)
+		return
+	}
+
+	versionStr := r.URL.Query().Get("version")
+	version, err := strconv.ParseInt(versionStr, 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid version %q: %v", versionStr, err), http.StatusBadRequest)
+		return
+	}
+
+	cmd, err := fsm.CommandAt(version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(cmd)
+}
+
+// handleClusterProof answers "is this device/metric shard part of what this
+// node holds" with a cryptographic proof instead of a plain yes/no: it
+// builds a pkgcluster.SparseMerkleTree over every shard key's digest (the
+// same per-shard summaries anti-entropy already computes from AllRecords)
+// and returns a Prove result the caller can check with
+// pkgcluster.VerifyInclusion/VerifyExclusion against root_hash.
+func (s *Service) handleClusterProof(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	device := r.URL.Query().Get("device")
+	metric := r.URL.Query().Get("metric")
+	if device == "" || metric == "" {
+		http.Error(w, "device and metric query params are required", http.StatusBadRequest)
+		return
+	}
+
+	records, err := s.store.AllRecords()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	summaries := pkgcluster.BuildShardSummaries(recordsToIndexRecords(records))
+
+	tree := pkgcluster.NewSparseMerkleTree()
+	for key, summary := range summaries {
+		tree.Put([]byte(key), shardSummaryDigest(summary))
+	}
+
+	key := []byte(pkgcluster.ShardKey(device, metric))
+	value, proof, found := tree.Prove(key)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"root_hash": fmt.Sprintf("%x", tree.GetRootHash()),
+		"found":     found,
+		"value":     fmt.Sprintf("%x", value),
+		"proof":     proof,
+	})
+}
+
+// shardSummaryDigest folds a ShardSummary down to a single hash, the value
+// handleClusterProof stores each shard key against in the sparse tree.
+func shardSummaryDigest(summary pkgcluster.ShardSummary) []byte {
+	data, _ := pkgcluster.MarshalMsgpack(summary)
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// handleClusterReconcileRange drives Client.ReconcileRange against ?node
+// for a single shard's leaves in [?low, ?high) (both default to the empty
+// string/"\xff" span, i.e. the whole shard), so an operator - or a future
+// rebalance hook reacting to a BoundedRing KeyMove - can resync just the
+// range that actually diverged instead of a full CompareTrees pass.
+func (s *Service) handleClusterReconcileRange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	nodeID := r.URL.Query().Get("node")
+	device := r.URL.Query().Get("device")
+	metric := r.URL.Query().Get("metric")
+	if nodeID == "" || device == "" || metric == "" {
+		http.Error(w, "node, device and metric query params are required", http.StatusBadRequest)
+		return
+	}
+	low := r.URL.Query().Get("low")
+	high := r.URL.Query().Get("high")
+	if high == "" {
+		high = "\xff"
+	}
+
+	tree, err := shardMerkleTree(s.store, pkgcluster.ShardKey(device, metric))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	diffs, err := s.qClient.ReconcileRange(nodeID, tree, low, high)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"diffs": diffs,
+		"count": len(diffs),
+	})
+}
+
+// handleClusterRangeProof drives Client.VerifyRangeSync against ?node for a
+// single shard's leaves in [?low, ?high) - the single-round-trip
+// GetRangeProof/VerifyRangeProof counterpart to handleClusterReconcileRange's
+// recursive CompareRange walk.
+func (s *Service) handleClusterRangeProof(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	nodeID := r.URL.Query().Get("node")
+	device := r.URL.Query().Get("device")
+	metric := r.URL.Query().Get("metric")
+	if nodeID == "" || device == "" || metric == "" {
+		http.Error(w, "node, device and metric query params are required", http.StatusBadRequest)
+		return
+	}
+	low := r.URL.Query().Get("low")
+	high := r.URL.Query().Get("high")
+	if high == "" {
+		high = "\xff"
+	}
+
+	tree, err := shardMerkleTree(s.store, pkgcluster.ShardKey(device, metric))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	diffs, err := s.qClient.VerifyRangeSync(nodeID, tree, low, high)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"diffs": diffs,
+		"count": len(diffs),
+	})
+}
+
 func (s *Service) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	if s.wsHub == nil {
 		http.Error(w, "WebSocket not available", http.StatusServiceUnavailable)
@@ -236,41 +1001,25 @@ func (s *Service) distributedQuery(qr QueryRequest) ([]float64, error) {
 
 	// Use same keying as ingestion to target the right nodes
 	key := qr.DeviceID + ":" + qr.MetricName
-	selectedNodes := cluster.GetNodesForKey(key, 2)
+	selectedNodes := cluster.GetNodesForKey(key, cluster.ReplicationFactor())
 	if len(selectedNodes) == 0 {
 		return nil, fmt.Errorf("no nodes in cluster")
 	}
 
 	log.Printf("[Query] Querying %d nodes for device=%s metric=%s", len(selectedNodes), qr.DeviceID, qr.MetricName)
 
-	// Query nodes concurrently
+	// Query every candidate node concurrently through s.qClient, which
+	// routes the local node straight into storage.Storage (see SetLocal in
+	// newQueryClient) and every other node over its pooled TCP connection.
 	var wg sync.WaitGroup
-	resultChan := make(chan []float64, len(selectedNodes)+1)
-	errorChan := make(chan error, len(selectedNodes)+1)
-
-	// Query local node first
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		samples, err := s.store.Query(qr.DeviceID, qr.MetricName, qr.StartTime, qr.EndTime)
-		if err != nil {
-			log.Printf("[Query] Local query error: %v", err)
-			errorChan <- err
-			return
-		}
-		if len(samples) > 0 {
-			resultChan <- samples
-		}
-	}()
+	resultChan := make(chan []float64, len(selectedNodes))
+	errorChan := make(chan error, len(selectedNodes))
 
 	for _, nodeID := range selectedNodes {
-		if s.nodeID != "" && nodeID == s.nodeID {
-			continue
-		}
 		wg.Add(1)
 		go func(nID string) {
 			defer wg.Done()
-			samples, err := s.queryRemoteNode(nID, qr)
+			samples, err := s.qClient.QuerySamples(nID, toClientRequest(qr))
 			if err != nil {
 				log.Printf("[Query] Failed to query node %s: %v", nID, err)
 				errorChan <- err
@@ -299,54 +1048,60 @@ func (s *Service) distributedQuery(qr QueryRequest) ([]float64, error) {
 	return allSamples, nil
 }
 
+// distributedQueryAggregated resolves qr's candidate replicas once and
+// dispatches to the strategy named by qr.ReadPolicy.
 func (s *Service) distributedQueryAggregated(qr QueryRequest) (storage.QueryStats, error) {
+	switch qr.ReadPolicy {
+	case ReadPolicyOne:
+		return s.distributedQueryOne(qr)
+	case ReadPolicyHedged:
+		return s.distributedQueryHedged(qr)
+	case ReadPolicyQuorum:
+		return s.distributedQueryQuorum(qr)
+	default:
+		return s.distributedQueryAll(qr)
+	}
+}
+
+// distributedQueryAll is ReadPolicyAll: wait for every candidate replica,
+// combine whatever came back, and read-repair any replica that answered
+// with fewer samples than this node holds.
+func (s *Service) distributedQueryAll(qr QueryRequest) (storage.QueryStats, error) {
 	hashRing := cluster.GetHashRing()
 	if hashRing == nil {
 		return storage.QueryStats{}, fmt.Errorf("hash ring not initialized")
 	}
 
 	key := qr.DeviceID + ":" + qr.MetricName
-	selectedNodes := cluster.GetNodesForKey(key, 2)
+	selectedNodes := cluster.GetNodesForKey(key, cluster.ReplicationFactor())
 	if len(selectedNodes) == 0 {
 		return storage.QueryStats{}, fmt.Errorf("no nodes in cluster")
 	}
 
 	log.Printf("[Query] Querying %d nodes for device=%s metric=%s", len(selectedNodes), qr.DeviceID, qr.MetricName)
 
-	var wg sync.WaitGroup
-	resultChan := make(chan storage.QueryStats, len(selectedNodes)+1)
-	errorChan := make(chan error, len(selectedNodes)+1)
+	type nodeStats struct {
+		nodeID string
+		stats  storage.QueryStats
+	}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		stats, err := s.store.QueryAggregated(qr.DeviceID, qr.MetricName, qr.StartTime, qr.EndTime)
-		if err != nil {
-			log.Printf("[Query] Local query error: %v", err)
-			errorChan <- err
-			return
-		}
-		if stats.Count > 0 {
-			resultChan <- stats
-		}
-	}()
+	var wg sync.WaitGroup
+	resultChan := make(chan nodeStats, len(selectedNodes))
+	errorChan := make(chan error, len(selectedNodes))
 
 	for _, nodeID := range selectedNodes {
-		if s.nodeID != "" && nodeID == s.nodeID {
-			continue
-		}
 		wg.Add(1)
 		go func(nID string) {
 			defer wg.Done()
-			stats, err := s.queryRemoteNodeAggregated(nID, qr)
+			start := time.Now()
+			stats, err := s.qClient.QueryAggregated(nID, toClientRequest(qr))
 			if err != nil {
 				log.Printf("[Query] Failed to query node %s: %v", nID, err)
 				errorChan <- err
 				return
 			}
-			if stats.Count > 0 {
-				resultChan <- stats
-			}
+			cluster.RecordNodeLatency(nID, time.Since(start))
+			resultChan <- nodeStats{nodeID: nID, stats: stats}
 		}(nodeID)
 	}
 
@@ -354,9 +1109,13 @@ func (s *Service) distributedQueryAggregated(qr QueryRequest) (storage.QueryStat
 	close(resultChan)
 	close(errorChan)
 
+	statsByNode := make(map[string]storage.QueryStats)
 	allStats := make([]storage.QueryStats, 0)
-	for stats := range resultChan {
-		allStats = append(allStats, stats)
+	for ns := range resultChan {
+		statsByNode[ns.nodeID] = ns.stats
+		if ns.stats.Count > 0 {
+			allStats = append(allStats, ns.stats)
+		}
 	}
 
 	combined := combineStats(allStats)
@@ -365,108 +1124,232 @@ func (s *Service) distributedQueryAggregated(qr QueryRequest) (storage.QueryStat
 		return storage.QueryStats{}, fmt.Errorf("all queries failed")
 	}
 
+	go s.readRepair(qr, statsByNode)
+
 	log.Printf("[Query] Aggregated stats from %d candidate nodes", len(selectedNodes))
 	return combined, nil
 }
 
-func (s *Service) queryRemoteNode(nodeID string, qr QueryRequest) ([]float64, error) {
-	clusterMgr := cluster.GetClusterManager()
-	if clusterMgr == nil {
-		return nil, fmt.Errorf("cluster manager not initialized")
+// distributedQueryOne is ReadPolicyOne: query only the first candidate
+// replica and return whatever it has, with no fan-out and no repair.
+func (s *Service) distributedQueryOne(qr QueryRequest) (storage.QueryStats, error) {
+	hashRing := cluster.GetHashRing()
+	if hashRing == nil {
+		return storage.QueryStats{}, fmt.Errorf("hash ring not initialized")
+	}
+
+	key := qr.DeviceID + ":" + qr.MetricName
+	selectedNodes := cluster.GetNodesForKey(key, cluster.ReplicationFactor())
+	if len(selectedNodes) == 0 {
+		return storage.QueryStats{}, fmt.Errorf("no nodes in cluster")
 	}
 
-	nodePort, err := clusterMgr.GetNodeHTTPPort(nodeID)
+	nodeID := selectedNodes[0]
+	start := time.Now()
+	stats, err := s.qClient.QueryAggregated(nodeID, toClientRequest(qr))
 	if err != nil {
-		nodePort = s.getNodePort(nodeID)
-		if nodePort == 0 {
-			return nil, fmt.Errorf("unknown node port for %s: %w", nodeID, err)
-		}
+		return storage.QueryStats{}, fmt.Errorf("node %s query failed: %w", nodeID, err)
 	}
+	cluster.RecordNodeLatency(nodeID, time.Since(start))
+	return stats, nil
+}
 
-	url := fmt.Sprintf("http://localhost:%d/query-samples", nodePort)
+// nodeQueryResult is one node's outcome from a ReadPolicyHedged or
+// ReadPolicyQuorum fan-out.
+type nodeQueryResult struct {
+	nodeID string
+	stats  storage.QueryStats
+	err    error
+}
 
-	reqBody, err := json.Marshal(qr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+// distributedQueryHedged is ReadPolicyHedged: query the primary, and if it
+// hasn't answered within cluster.HedgeDelay(primary), also fire the next
+// replica and take whichever responds first. The loser, if any, is
+// abandoned: this function's ctx is canceled on return, which tears down
+// its connection.
+func (s *Service) distributedQueryHedged(qr QueryRequest) (storage.QueryStats, error) {
+	hashRing := cluster.GetHashRing()
+	if hashRing == nil {
+		return storage.QueryStats{}, fmt.Errorf("hash ring not initialized")
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	key := qr.DeviceID + ":" + qr.MetricName
+	selectedNodes := cluster.GetNodesForKey(key, cluster.ReplicationFactor())
+	if len(selectedNodes) == 0 {
+		return storage.QueryStats{}, fmt.Errorf("no nodes in cluster")
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resultChan := make(chan nodeQueryResult, len(selectedNodes))
+	dispatch := func(nodeID string) {
+		go func() {
+			start := time.Now()
+			stats, err := s.qClient.QueryAggregatedCtx(ctx, nodeID, toClientRequest(qr))
+			if err == nil {
+				cluster.RecordNodeLatency(nodeID, time.Since(start))
+			}
+			resultChan <- nodeQueryResult{nodeID: nodeID, stats: stats, err: err}
+		}()
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("query-samples failed with status %d: %s", resp.StatusCode, string(body))
+	primary := selectedNodes[0]
+	dispatch(primary)
+	inFlight := 1
+
+	backupFired := len(selectedNodes) < 2
+	var hedgeTimerC <-chan time.Time
+	if !backupFired {
+		timer := time.NewTimer(cluster.HedgeDelay(primary))
+		defer timer.Stop()
+		hedgeTimerC = timer.C
 	}
 
-	var samplesResponse struct {
-		Samples []float64 `json:"samples"`
+	fireBackup := func() {
+		backupFired = true
+		backup := selectedNodes[1]
+		log.Printf("[Query] Hedged read for %s: firing backup to %s", key, backup)
+		dispatch(backup)
+		inFlight++
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&samplesResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode samples response: %w", err)
+
+	for inFlight > 0 {
+		select {
+		case r := <-resultChan:
+			inFlight--
+			if r.err == nil {
+				return r.stats, nil
+			}
+			log.Printf("[Query] Hedged read: node %s failed: %v", r.nodeID, r.err)
+			if !backupFired {
+				fireBackup()
+			}
+		case <-hedgeTimerC:
+			if !backupFired {
+				fireBackup()
+			}
+		}
 	}
 
-	log.Printf("[Query] Node %s returned %d samples", nodeID, len(samplesResponse.Samples))
-	return samplesResponse.Samples, nil
+	return storage.QueryStats{}, fmt.Errorf("all queries failed")
 }
 
-func (s *Service) queryRemoteNodeAggregated(nodeID string, qr QueryRequest) (storage.QueryStats, error) {
-	port := s.getNodePort(nodeID)
-	if port == 0 {
-		return storage.QueryStats{}, fmt.Errorf("unknown node port for %s", nodeID)
-	}
-
-	url := fmt.Sprintf("http://localhost:%d/query-aggregated", port)
+// quorumSignature is the equality signal distributedQueryQuorum uses to
+// decide that enough replicas agree: two replicas "agree" when both their
+// Count and Sum match.
+type quorumSignature struct {
+	count int
+	sum   float64
+}
 
-	reqBody, err := json.Marshal(qr)
-	if err != nil {
-		return storage.QueryStats{}, fmt.Errorf("failed to marshal request: %w", err)
+// distributedQueryQuorum is ReadPolicyQuorum: fan out to every candidate
+// replica and return as soon as ceil(R/2)+1 of them (at most R) report the same
+// QueryStats by quorumSignature, canceling the rest via ctx.
+func (s *Service) distributedQueryQuorum(qr QueryRequest) (storage.QueryStats, error) {
+	hashRing := cluster.GetHashRing()
+	if hashRing == nil {
+		return storage.QueryStats{}, fmt.Errorf("hash ring not initialized")
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return storage.QueryStats{}, fmt.Errorf("failed to create request: %w", err)
+	key := qr.DeviceID + ":" + qr.MetricName
+	selectedNodes := cluster.GetNodesForKey(key, cluster.ReplicationFactor())
+	if len(selectedNodes) == 0 {
+		return storage.QueryStats{}, fmt.Errorf("no nodes in cluster")
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return storage.QueryStats{}, fmt.Errorf("failed to send request: %w", err)
+	needed := (len(selectedNodes)+1)/2 + 1
+	if needed > len(selectedNodes) {
+		needed = len(selectedNodes)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return storage.QueryStats{}, fmt.Errorf("query-aggregated failed with status %d: %s", resp.StatusCode, string(body))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resultChan := make(chan nodeQueryResult, len(selectedNodes))
+	for _, nodeID := range selectedNodes {
+		go func(nID string) {
+			start := time.Now()
+			stats, err := s.qClient.QueryAggregatedCtx(ctx, nID, toClientRequest(qr))
+			if err == nil {
+				cluster.RecordNodeLatency(nID, time.Since(start))
+			}
+			resultChan <- nodeQueryResult{nodeID: nID, stats: stats, err: err}
+		}(nodeID)
 	}
 
-	var stats storage.QueryStats
-	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
-		return storage.QueryStats{}, fmt.Errorf("failed to decode stats response: %w", err)
+	agree := make(map[quorumSignature]int)
+	var lastErr error
+	for received := 0; received < len(selectedNodes); received++ {
+		r := <-resultChan
+		if r.err != nil {
+			lastErr = r.err
+			log.Printf("[Query] Quorum read: node %s failed: %v", r.nodeID, r.err)
+			continue
+		}
+
+		sig := quorumSignature{count: r.stats.Count, sum: r.stats.Sum}
+		agree[sig]++
+		if agree[sig] >= needed {
+			cancel()
+			return r.stats, nil
+		}
 	}
 
-	log.Printf("[Query] Node %s returned stats: %+v", nodeID, stats)
-	return stats, nil
+	if lastErr != nil {
+		return storage.QueryStats{}, fmt.Errorf("quorum not reached for %s: %w", key, lastErr)
+	}
+	return storage.QueryStats{}, fmt.Errorf("quorum not reached for %s", key)
 }
 
-func (s *Service) getNodePort(nodeID string) int {
-	portMap := map[string]int{
-		"ing1": 8080,
-		"ing2": 8081,
-		"ing3": 8082,
+// readRepair compares every replica's sample count for the same key and
+// re-pushes the most up-to-date node's records to any replica that's behind,
+// via the same hinted-handoff transport used for live replication. It runs
+// in the background so a slow repair never delays the response to the
+// caller.
+//
+// Count stands in for "newest" here: QueryStats carries no per-record
+// timestamp, and readRepair never fetches another node's raw records, so the
+// only node this can ever repair *from* is the local one, and the only
+// signal available to rank replicas is how many samples each one returned.
+func (s *Service) readRepair(qr QueryRequest, statsByNode map[string]storage.QueryStats) {
+	var upToDateNode string
+	var maxCount int
+	for nodeID, stats := range statsByNode {
+		if stats.Count > maxCount {
+			maxCount = stats.Count
+			upToDateNode = nodeID
+		}
 	}
-	if port, ok := portMap[nodeID]; ok {
-		return port
+	if maxCount == 0 || upToDateNode != s.nodeID {
+		// Raw records are only streamed from our own storage here; when a
+		// remote node holds the most samples, skip rather than guess.
+		return
+	}
+
+	records, err := s.store.QueryRaw(qr.DeviceID, qr.MetricName, qr.StartTime, qr.EndTime)
+	if err != nil || len(records) == 0 {
+		return
+	}
+
+	for nodeID, stats := range statsByNode {
+		if nodeID == s.nodeID || stats.Count >= maxCount {
+			continue
+		}
+
+		log.Printf("[Query] Read-repair: pushing %d record(s) for %s/%s to lagging replica %s (had %d, local has %d)",
+			len(records), qr.DeviceID, qr.MetricName, nodeID, stats.Count, maxCount)
+
+		for _, r := range records {
+			payload, err := json.Marshal(r)
+			if err != nil {
+				continue
+			}
+			if err := cluster.PushReplicaWrite(nodeID, payload); err != nil {
+				log.Printf("[Query] Read-repair push to %s failed: %v", nodeID, err)
+			}
+		}
 	}
-	return 0
 }
 
 func (s *Service) handleQuerySamples(w http.ResponseWriter, r *http.Request) {
@@ -545,6 +1428,122 @@ func (s *Service) handleQueryAggregated(w http.ResponseWriter, r *http.Request)
 	_ = json.NewEncoder(w).Encode(stats)
 }
 
+// handleQueryAggregatedStream is the streaming counterpart of
+// handleQueryAggregated: instead of waiting for every node to finish and
+// returning one combined QueryStats, it fans the same request out to
+// s.qClient.QueryAggregatedBuckets on every candidate node and writes the
+// progressively-merged per-bucket stats back to the caller as
+// newline-delimited JSON, flushed every streamFlushInterval, so a UI can
+// render early estimates and watch them refine. streamQueryTimeout cuts a
+// slow node loose rather than blocking the whole response on it.
+func (s *Service) handleQueryAggregatedStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var qr QueryRequest
+	if err := json.Unmarshal(body, &qr); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if qr.DeviceID == "" || qr.MetricName == "" || qr.StartTime == 0 || qr.EndTime == 0 {
+		http.Error(w, "device_id, metric_name, start_time and end_time are required for streaming", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	hashRing := cluster.GetHashRing()
+	if hashRing == nil {
+		http.Error(w, "hash ring not initialized", http.StatusServiceUnavailable)
+		return
+	}
+	key := qr.DeviceID + ":" + qr.MetricName
+	selectedNodes := cluster.GetNodesForKey(key, cluster.ReplicationFactor())
+	if len(selectedNodes) == 0 {
+		http.Error(w, "no nodes in cluster", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), streamQueryTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	var mergeMu sync.Mutex
+	merged := make(map[int64]storage.TimeBucket)
+
+	var wg sync.WaitGroup
+	for _, nodeID := range selectedNodes {
+		wg.Add(1)
+		go func(nID string) {
+			defer wg.Done()
+			err := s.qClient.QueryAggregatedBuckets(ctx, nID, toClientRequest(qr), streamNumBuckets, func(b storage.TimeBucket) {
+				mergeMu.Lock()
+				if existing, ok := merged[b.Start]; ok {
+					b.Stats = combineStats([]storage.QueryStats{existing.Stats, b.Stats})
+				}
+				merged[b.Start] = b
+				mergeMu.Unlock()
+			})
+			if err != nil {
+				log.Printf("[Query] Streaming query to node %s failed: %v", nID, err)
+			}
+		}(nodeID)
+	}
+
+	allDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(allDone)
+	}()
+
+	ticker := time.NewTicker(streamFlushInterval)
+	defer ticker.Stop()
+
+	writeSnapshot := func() {
+		mergeMu.Lock()
+		buckets := make([]storage.TimeBucket, 0, len(merged))
+		for _, b := range merged {
+			buckets = append(buckets, b)
+		}
+		mergeMu.Unlock()
+
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start < buckets[j].Start })
+
+		_ = json.NewEncoder(w).Encode(buckets)
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			writeSnapshot()
+		case <-allDone:
+			writeSnapshot()
+			return
+		case <-ctx.Done():
+			writeSnapshot()
+			return
+		}
+	}
+}
+
 func (s *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
@@ -594,12 +1593,4 @@ func (s *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(response)
-
-	if s.restartFn != nil {
-		log.Printf("[Delete] Triggering server restart...")
-		go func() {
-			time.Sleep(100 * time.Millisecond)
-			s.restartFn()
-		}()
-	}
 }
@@ -0,0 +1,259 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/minitrue/internal/storage"
+	"github.com/minitrue/pkg/queryql"
+)
+
+// Series is one resolved time series' aggregated stats plus the labels
+// that identify it - today always just "device" and "metric", since
+// devices are the only label this system knows about.
+type Series struct {
+	Labels map[string]string  `json:"labels"`
+	Stats  storage.QueryStats `json:"stats"`
+}
+
+// queryQLRequest is /queryql's request body: an expression plus the time
+// range it's evaluated over, mirroring QueryRequest's StartTime/EndTime
+// naming.
+type queryQLRequest struct {
+	Query     string `json:"query"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+}
+
+// handleQueryQL evaluates a queryql expression against this cluster's
+// storage, fanning leaf selectors out through distributedQueryAggregated
+// the same way handleQueryAggregated does for a single device/metric, and
+// reducing the results according to the expression's functions and
+// arithmetic. See pkg/queryql for the expression grammar.
+func (s *Service) handleQueryQL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var req queryQLRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if req.Query == "" {
+		http.Error(w, "missing query", http.StatusBadRequest)
+		return
+	}
+
+	expr, err := queryql.Parse(req.Query)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("parse error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	series, err := s.evalExpr(expr, req.StartTime, req.EndTime)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("eval error: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(series)
+}
+
+// evalExpr walks expr and returns the series it resolves to over
+// [start,end]. A VectorSelector leaf becomes one distributedQueryAggregated
+// call per device it names; Call and BinaryExpr nodes reduce or combine
+// their children's series.
+func (s *Service) evalExpr(expr queryql.Expr, start, end int64) ([]Series, error) {
+	switch e := expr.(type) {
+	case *queryql.VectorSelector:
+		return s.evalSelector(e, start, end)
+	case *queryql.Call:
+		return s.evalCall(e, start, end)
+	case *queryql.BinaryExpr:
+		return s.evalBinary(e, start, end)
+	case *queryql.NumberLiteral:
+		return []Series{{
+			Labels: map[string]string{},
+			Stats:  storage.QueryStats{Sum: e.Value, Count: 1, Min: e.Value, Max: e.Value},
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported expression type %T", expr)
+	}
+}
+
+// evalSelector resolves a VectorSelector to one series per device its
+// "device" matcher names. A device label is required: this system has no
+// device registry to enumerate series by metric name alone (every other
+// endpoint takes an explicit device_id), so "=~" alternation over an
+// explicit list of devices is the only way to address more than one
+// series at a time.
+func (s *Service) evalSelector(sel *queryql.VectorSelector, start, end int64) ([]Series, error) {
+	matcher, ok := sel.MatcherValue("device")
+	if !ok {
+		return nil, fmt.Errorf("selector for metric %q requires a device matcher, e.g. %s{device=\"d1\"}", sel.Metric, sel.Metric)
+	}
+
+	rangeStart, rangeEnd := start, end
+	if sel.Range > 0 {
+		if rangeEnd == 0 {
+			rangeEnd = time.Now().UnixNano()
+		}
+		rangeStart = rangeEnd - sel.Range.Nanoseconds()
+	}
+
+	devices := matcher.MatchedValues()
+	series := make([]Series, 0, len(devices))
+	for _, device := range devices {
+		stats, err := s.distributedQueryAggregated(QueryRequest{
+			DeviceID:   device,
+			MetricName: sel.Metric,
+			StartTime:  rangeStart,
+			EndTime:    rangeEnd,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("querying %s{device=%q}: %w", sel.Metric, device, err)
+		}
+		series = append(series, Series{
+			Labels: map[string]string{"device": device, "metric": sel.Metric},
+			Stats:  stats,
+		})
+	}
+	return series, nil
+}
+
+// evalCall applies a queryql function to its argument's resolved series.
+// rate() and avg_over_time() are per-series reductions over a range
+// vector; topk() is a cross-series selection and takes its k as a leading
+// numeric argument.
+func (s *Service) evalCall(call *queryql.Call, start, end int64) ([]Series, error) {
+	switch call.Func {
+	case "rate", "avg_over_time":
+		if len(call.Args) != 1 {
+			return nil, fmt.Errorf("%s() takes exactly one argument", call.Func)
+		}
+		series, err := s.evalExpr(call.Args[0], start, end)
+		if err != nil {
+			return nil, err
+		}
+		sel, ok := call.Args[0].(*queryql.VectorSelector)
+		if !ok || sel.Range == 0 {
+			return nil, fmt.Errorf("%s() requires a ranged selector argument, e.g. %s(metric{...}[5m])", call.Func, call.Func)
+		}
+		out := make([]Series, len(series))
+		for i, sr := range series {
+			out[i] = sr
+			if call.Func == "rate" {
+				out[i].Stats = storage.QueryStats{
+					Sum:   sr.Stats.Sum / sel.Range.Seconds(),
+					Count: sr.Stats.Count,
+					Min:   sr.Stats.Min,
+					Max:   sr.Stats.Max,
+				}
+			} else if sr.Stats.Count > 0 {
+				avg := sr.Stats.Sum / float64(sr.Stats.Count)
+				out[i].Stats = storage.QueryStats{Sum: avg, Count: 1, Min: avg, Max: avg}
+			}
+		}
+		return out, nil
+
+	case "topk":
+		if len(call.Args) != 2 {
+			return nil, fmt.Errorf("topk() takes exactly two arguments: topk(k, expr)")
+		}
+		kLit, ok := call.Args[0].(*queryql.NumberLiteral)
+		if !ok {
+			return nil, fmt.Errorf("topk()'s first argument must be a number")
+		}
+		series, err := s.evalExpr(call.Args[1], start, end)
+		if err != nil {
+			return nil, err
+		}
+		return topK(series, int(kLit.Value)), nil
+
+	default:
+		return nil, fmt.Errorf("unknown function %q", call.Func)
+	}
+}
+
+// topK returns the k series with the highest Stats.Sum, most significant
+// first.
+func topK(series []Series, k int) []Series {
+	sorted := make([]Series, len(series))
+	copy(sorted, series)
+	for i := 1; i < len(sorted); i++ {
+		for j := i; j > 0 && sorted[j].Stats.Sum > sorted[j-1].Stats.Sum; j-- {
+			sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+		}
+	}
+	if k < len(sorted) {
+		sorted = sorted[:k]
+	}
+	return sorted
+}
+
+// evalBinary combines two single-series expressions arithmetically. Both
+// sides must resolve to exactly one series each: there's no PromQL-style
+// label-matching join here since cross-series binary ops beyond a single
+// pair aren't needed by anything this API serves yet.
+func (s *Service) evalBinary(bin *queryql.BinaryExpr, start, end int64) ([]Series, error) {
+	lhs, err := s.evalExpr(bin.LHS, start, end)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := s.evalExpr(bin.RHS, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(lhs) != 1 || len(rhs) != 1 {
+		return nil, fmt.Errorf("binary operator %q requires exactly one series on each side (got %d and %d)", bin.Op, len(lhs), len(rhs))
+	}
+
+	lv := seriesValue(lhs[0])
+	rv := seriesValue(rhs[0])
+
+	var result float64
+	switch bin.Op {
+	case "+":
+		result = lv + rv
+	case "-":
+		result = lv - rv
+	case "*":
+		result = lv * rv
+	case "/":
+		if rv == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		result = lv / rv
+	default:
+		return nil, fmt.Errorf("unknown operator %q", bin.Op)
+	}
+
+	return []Series{{
+		Labels: lhs[0].Labels,
+		Stats:  storage.QueryStats{Sum: result, Count: 1, Min: result, Max: result},
+	}}, nil
+}
+
+// seriesValue reduces a Series to a single scalar for arithmetic: its
+// average if it holds more than one sample, otherwise its sum.
+func seriesValue(sr Series) float64 {
+	if sr.Stats.Count > 1 {
+		return sr.Stats.Sum / float64(sr.Stats.Count)
+	}
+	return sr.Stats.Sum
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/golang/snappy"
+	"github.com/minitrue/internal/compression"
+)
+
+// ColumnCodec identifies how a single column's bytes are encoded on disk.
+// It is stored per-column in buildColumnMetadata's codec field (see
+// FormatVersion 5) so a reader dispatches on what a column actually is
+// rather than assuming every timestamp column is Gorilla-encoded and every
+// string column is raw, the assumption every format version before 5 baked
+// in.
+//
+// codecDefault, the zero value, is never written to disk: it only appears
+// in a StorageOptions field the caller left unset, and resolves to that
+// column's long-standing default codec (see StorageOptions).
+type ColumnCodec uint32
+
+const (
+	codecDefault ColumnCodec = iota
+
+	// CodecRaw stores a column's values with no compression: fixed-width
+	// little-endian for timestamp/value, the existing length-prefixed
+	// format for device_id/metric_name.
+	CodecRaw
+
+	// CodecGorillaDelta is compression.CompressInt64's delta-of-delta
+	// encoding, valid only for the timestamp column.
+	CodecGorillaDelta
+
+	// CodecGorillaXOR is compression.CompressFloat64's XOR'd-mantissa
+	// encoding, valid only for the value column.
+	CodecGorillaXOR
+
+	// CodecLZ4 block-compresses a column's raw serialization (see
+	// lz4_codec.go), valid only for device_id/metric_name.
+	CodecLZ4
+
+	// CodecSnappy is CodecLZ4's counterpart using Snappy instead of LZ4,
+	// valid only for device_id/metric_name.
+	CodecSnappy
+)
+
+// StorageOptions configures which codec StorageEngine.Write uses for each
+// column. The zero value reproduces the format's long-standing defaults:
+// Gorilla encoding for timestamp/value, raw (uncompressed, length-prefixed)
+// for device_id/metric_name - so existing callers of NewStorageEngine, who
+// never set an option, see no change in what gets written.
+type StorageOptions struct {
+	// TimestampCodec is CodecRaw or CodecGorillaDelta. Default: CodecGorillaDelta.
+	TimestampCodec ColumnCodec
+	// ValueCodec is CodecRaw or CodecGorillaXOR. Default: CodecGorillaXOR.
+	ValueCodec ColumnCodec
+	// StringCodec is CodecRaw, CodecLZ4, or CodecSnappy, applied to both
+	// device_id and metric_name. Default: CodecRaw.
+	//
+	// device_id and metric_name are typically low-cardinality (a handful of
+	// distinct devices/metrics repeated over thousands of records), so
+	// CodecLZ4 or CodecSnappy usually compress them dramatically.
+	StringCodec ColumnCodec
+}
+
+func (o StorageOptions) timestampCodec() ColumnCodec {
+	if o.TimestampCodec == codecDefault {
+		return CodecGorillaDelta
+	}
+	return o.TimestampCodec
+}
+
+func (o StorageOptions) valueCodec() ColumnCodec {
+	if o.ValueCodec == codecDefault {
+		return CodecGorillaXOR
+	}
+	return o.ValueCodec
+}
+
+func (o StorageOptions) stringCodec() ColumnCodec {
+	if o.StringCodec == codecDefault {
+		return CodecRaw
+	}
+	return o.StringCodec
+}
+
+// encodeTimestampColumn and its decodeTimestampColumn counterpart dispatch
+// on codec rather than always going through compression.CompressInt64, so
+// StorageOptions.TimestampCodec can pick CodecRaw instead.
+func (se *StorageEngine) encodeTimestampColumn(codec ColumnCodec, values []int64) []byte {
+	if codec == CodecRaw {
+		return se.encodeCompressedColumn(encodeRawInt64(values))
+	}
+	return se.encodeCompressedColumn(compression.CompressInt64(values))
+}
+
+func (se *StorageEngine) decodeTimestampColumn(codec ColumnCodec, data []byte, count int) []int64 {
+	compressedData := data[8:]
+	if codec == CodecRaw {
+		return decodeRawInt64(compressedData, count)
+	}
+	return compression.DecompressInt64(compressedData, count)
+}
+
+func (se *StorageEngine) encodeValueColumn(codec ColumnCodec, values []float64) []byte {
+	if codec == CodecRaw {
+		return se.encodeCompressedColumn(encodeRawFloat64(values))
+	}
+	return se.encodeCompressedColumn(compression.CompressFloat64(values))
+}
+
+func (se *StorageEngine) decodeValueColumn(codec ColumnCodec, data []byte, count int) []float64 {
+	compressedData := data[8:]
+	if codec == CodecRaw {
+		return decodeRawFloat64(compressedData, count)
+	}
+	return compression.DecompressFloat64(compressedData, count)
+}
+
+// encodeRawInt64/decodeRawInt64 are CodecRaw's timestamp-column format: a
+// plain little-endian int64 array, no delta encoding at all.
+func encodeRawInt64(values []int64) []byte {
+	out := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(out[i*8:], uint64(v))
+	}
+	return out
+}
+
+func decodeRawInt64(data []byte, count int) []int64 {
+	out := make([]int64, count)
+	for i := 0; i < count && (i+1)*8 <= len(data); i++ {
+		out[i] = int64(binary.LittleEndian.Uint64(data[i*8:]))
+	}
+	return out
+}
+
+// encodeRawFloat64/decodeRawFloat64 are CodecRaw's value-column format: a
+// plain little-endian float64 (IEEE-754 bit pattern) array.
+func encodeRawFloat64(values []float64) []byte {
+	out := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(out[i*8:], math.Float64bits(v))
+	}
+	return out
+}
+
+func decodeRawFloat64(data []byte, count int) []float64 {
+	out := make([]float64, count)
+	for i := 0; i < count && (i+1)*8 <= len(data); i++ {
+		out[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[i*8:]))
+	}
+	return out
+}
+
+// encodeStringColumnWithCodec wraps encodeStringColumn's raw, length-prefixed
+// serialization with an optional byte-level compressor. The string count
+// and each entry's length still live inside the (possibly compressed) blob
+// itself, so decodeStringColumnWithCodec only needs to undo the outer
+// compression before decodeStringColumn runs exactly as it always has.
+func (se *StorageEngine) encodeStringColumnWithCodec(codec ColumnCodec, values []string) []byte {
+	raw := se.encodeStringColumn(values)
+	switch codec {
+	case CodecLZ4:
+		return se.encodeCompressedColumn(lz4Compress(raw))
+	case CodecSnappy:
+		return se.encodeCompressedColumn(snappy.Encode(nil, raw))
+	default:
+		return raw
+	}
+}
+
+func (se *StorageEngine) decodeStringColumnWithCodec(codec ColumnCodec, data []byte, count int) ([]string, error) {
+	switch codec {
+	case CodecLZ4:
+		raw, err := lz4Decompress(data[8:])
+		if err != nil {
+			return nil, fmt.Errorf("lz4 column: %w", err)
+		}
+		return se.decodeStringColumn(raw, count)
+	case CodecSnappy:
+		raw, err := snappy.Decode(nil, data[8:])
+		if err != nil {
+			return nil, fmt.Errorf("snappy column: %w", err)
+		}
+		return se.decodeStringColumn(raw, count)
+	default:
+		return se.decodeStringColumn(data, count)
+	}
+}
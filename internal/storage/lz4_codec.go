@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+// lz4BlockSize splits an lz4-codec column's serialized bytes into
+// independently-compressed chunks, the same block-oriented shape
+// Syncthing's BEP protocol frames file content in: every block stands on
+// its own, so decoding one doesn't require the rest (this package's reader
+// still decodes a whole column in one pass, but the framing leaves room for
+// a future seek-based reader, the same rationale ReadRange's block format
+// already follows for whole records).
+const lz4BlockSize = 128 * 1024
+
+// lz4Compress splits data into lz4BlockSize chunks and LZ4-block-compresses
+// each one, prefixing it with a flag byte (0 = stored verbatim, 1 =
+// compressed - CompressBlock reports (0, nil) for a chunk it can't shrink,
+// which is stored instead of wasting space on failed compression) and the
+// chunk's raw and stored lengths.
+func lz4Compress(data []byte) []byte {
+	var out []byte
+	bound := lz4.CompressBlockBound(lz4BlockSize)
+	buf := make([]byte, bound)
+
+	for start := 0; start < len(data); start += lz4BlockSize {
+		end := start + lz4BlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[start:end]
+
+		n, err := lz4.CompressBlock(chunk, buf, nil)
+		stored := chunk
+		flag := byte(0)
+		if err == nil && n > 0 && n < len(chunk) {
+			stored = buf[:n]
+			flag = 1
+		}
+
+		header := make([]byte, 9)
+		header[0] = flag
+		binary.LittleEndian.PutUint32(header[1:5], uint32(len(chunk)))
+		binary.LittleEndian.PutUint32(header[5:9], uint32(len(stored)))
+		out = append(out, header...)
+		out = append(out, stored...)
+	}
+	return out
+}
+
+// lz4Decompress reverses lz4Compress.
+func lz4Decompress(data []byte) ([]byte, error) {
+	var out []byte
+	pos := 0
+	for pos < len(data) {
+		if pos+9 > len(data) {
+			return nil, fmt.Errorf("lz4: truncated block header")
+		}
+		flag := data[pos]
+		rawLen := binary.LittleEndian.Uint32(data[pos+1 : pos+5])
+		storedLen := binary.LittleEndian.Uint32(data[pos+5 : pos+9])
+		pos += 9
+
+		if pos+int(storedLen) > len(data) {
+			return nil, fmt.Errorf("lz4: truncated block body")
+		}
+		stored := data[pos : pos+int(storedLen)]
+		pos += int(storedLen)
+
+		if flag == 0 {
+			out = append(out, stored...)
+			continue
+		}
+
+		chunk := make([]byte, rawLen)
+		n, err := lz4.UncompressBlock(stored, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("lz4: decompress block: %w", err)
+		}
+		out = append(out, chunk[:n]...)
+	}
+	return out, nil
+}
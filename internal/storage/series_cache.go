@@ -0,0 +1,74 @@
+package storage
+
+import "container/list"
+
+// defaultMaxCachedSeries bounds how many device/metric series seriesCache
+// keeps resident at once. It's a coarse cap chosen so a single node's hot
+// cache stays well within typical process memory even if every series has
+// accumulated a sizeable history - eviction falls back to ReadRange, which
+// stays correct (if slower) for any series that's been pushed out.
+const defaultMaxCachedSeries = 10000
+
+// seriesCache is a bounded, least-recently-used cache of decoded series
+// (device_id|metric_name -> samples), backing UnifiedStorage's in-memory
+// hot path. Unlike the old data map it replaces, it is not the source of
+// truth - disk (via StorageEngine.ReadRange) is - so an eviction just means
+// the next Query for that key pays the cost of a fresh read instead of
+// losing data.
+type seriesCache struct {
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type seriesCacheEntry struct {
+	key    string
+	values []sample
+}
+
+func newSeriesCache(capacity int) *seriesCache {
+	return &seriesCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *seriesCache) get(key string) ([]sample, bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return elem.Value.(*seriesCacheEntry).values, true
+}
+
+func (c *seriesCache) put(key string, values []sample) {
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*seriesCacheEntry).values = values
+		c.ll.MoveToFront(elem)
+		return
+	}
+
+	elem := c.ll.PushFront(&seriesCacheEntry{key: key, values: values})
+	c.items[key] = elem
+
+	if c.capacity > 0 && c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(*seriesCacheEntry).key)
+		}
+	}
+}
+
+func (c *seriesCache) delete(key string) {
+	if elem, ok := c.items[key]; ok {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+func (c *seriesCache) len() int {
+	return c.ll.Len()
+}
@@ -5,20 +5,35 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/minitrue/internal/models"
+	"github.com/minitrue/pkg/storage/backend"
+	"github.com/minitrue/pkg/storage/cdc"
 )
 
+// walRetention bounds how long closed WAL segments are kept around once their
+// data has had a chance to be durably reflected in memory; see recoverWAL.
+const walRetention = 1 * time.Hour
+
+// compactCheckInterval is how often periodicCompact checks whether the
+// segment has crossed compactAge and is due for a rewrite - see Compact.
+const compactCheckInterval = 1 * time.Minute
+
 type Storage interface {
 	PersistPrimary(p interface{}) error
 	PersistReplica(p interface{}) error
 	Query(deviceID, metric string, start, end int64) ([]float64, error)
 	QueryAggregated(deviceID, metric string, start, end int64) (QueryStats, error)
+	QueryAggregatedBuckets(deviceID, metric string, start, end int64, numBuckets int, onBucket func(TimeBucket) error) error
+	QueryRaw(deviceID, metric string, start, end int64) ([]models.Record, error)
 	Delete(deviceID, metric string) error
+	WALRecordsSince(afterReqNum int64) ([]WALRecord, error)
+	AllRecords() ([]models.Record, error)
 	Reload() error
 }
 
@@ -30,15 +45,31 @@ type QueryStats struct {
 }
 
 type UnifiedStorage struct {
-	mu        sync.RWMutex
-	data      map[string][]sample
-	file      *os.File
+	mu sync.RWMutex
+	// cache is a bounded LRU of recently-touched series (see seriesCache),
+	// not the source of truth - disk (via engine.ReadRange) is. A miss
+	// hydrates from disk rather than returning empty; see loadSeriesLocked.
+	cache     *seriesCache
 	engine    *StorageEngine
-	filepath  string
+	be        backend.Backend
+	key       string
 	batchSize int
 	batch     []models.Record
 	nodeID    string
 	lastFlush time.Time
+	wal       *WAL
+
+	// compactLevel and compactAge configure Compact; compactLevel ==
+	// CompressionNone (the zero value) disables it so hot writes keep going
+	// through engine.CompressionLevel's own (typically faster) setting.
+	compactLevel CompressionLevel
+	compactAge   time.Duration
+
+	// dedup is the content-defined-chunking store (see pkg/storage/cdc)
+	// flushBatchUnlocked writes through instead of engine when non-nil -
+	// see EnableDedup. nil (the default) keeps the original behavior of
+	// writing the segment straight through engine.
+	dedup *cdc.Store
 }
 
 type sample struct {
@@ -47,34 +78,168 @@ type sample struct {
 	Role      string  `json:"role"`
 }
 
-func NewUnifiedStorage(filepath string) *UnifiedStorage {
+// NewUnifiedStorage opens (or creates) the segment named key on be, plus a
+// local WAL rooted at walDir. The segment itself goes through be - so it can
+// land on local disk, in S3-compatible object storage, or anywhere else a
+// Backend implementation puts it - but the WAL always stays on local disk:
+// it needs ordered, fsync'd appends that a blob-store Backend doesn't offer.
+func NewUnifiedStorage(be backend.Backend, key, walDir string) *UnifiedStorage {
 	nodeID := "unknown"
-	if len(filepath) > 5 {
-		nodeID = filepath[len(filepath)-9 : len(filepath)-5]
+	if len(key) > 5 {
+		nodeID = key[len(key)-9 : len(key)-5]
 	}
 
 	storage := &UnifiedStorage{
-		data:      make(map[string][]sample),
-		file:      nil,
-		engine:    NewStorageEngine(filepath),
-		filepath:  filepath,
+		cache:     newSeriesCache(defaultMaxCachedSeries),
+		engine:    NewStorageEngine(be, key),
+		be:        be,
+		key:       key,
 		batchSize: 10, // Reduced from 1000 for faster testing
 		batch:     make([]models.Record, 0, 10),
 		nodeID:    nodeID,
 		lastFlush: time.Now(),
 	}
 
+	wal, err := NewWAL(walDir, defaultWALSegmentSize, FsyncInterval)
+	if err != nil {
+		log.Printf("[Storage-%s] Warning: Failed to open WAL at %s: %v", nodeID, walDir, err)
+	}
+	storage.wal = wal
+
 	// Load existing data from disk on startup
 	if err := storage.Reload(); err != nil {
 		log.Printf("[Storage-%s] Warning: Failed to reload data from disk: %v", nodeID, err)
 	}
 
+	// Replay anything the WAL saw that never made it into the engine file,
+	// e.g. replica writes (which are never flushed to disk on their own) or
+	// primary writes buffered in memory when the process crashed.
+	storage.recoverWAL()
+
 	// Start periodic flush goroutine
 	go storage.periodicFlush()
 
 	return storage
 }
 
+// recoverWAL replays WAL records into the cache, skipping any sample that
+// already landed at the same timestamp via disk so recovery is idempotent
+// across repeated restarts. Since the cache, unlike the old data map, is no
+// longer the source of truth, a touched key is hydrated from disk first -
+// otherwise the cache would hold only the WAL-replayed samples for that key
+// and mask the rest of its on-disk history from Query.
+func (m *UnifiedStorage) recoverWAL() {
+	if m.wal == nil {
+		return
+	}
+
+	records, err := m.wal.Recover()
+	if err != nil {
+		log.Printf("[Storage-%s] Warning: WAL recovery failed: %v", m.nodeID, err)
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	replayed := 0
+	for _, rec := range records {
+		key := rec.DeviceID + "|" + rec.MetricName
+		if rec.Op == "delete" {
+			m.cache.delete(key)
+			replayed++
+			continue
+		}
+
+		arr, err := m.loadSeriesLocked(rec.DeviceID, rec.MetricName)
+		if err != nil {
+			log.Printf("[Storage-%s] Warning: failed to hydrate %s for WAL recovery: %v", m.nodeID, key, err)
+			continue
+		}
+		if arr, inserted := insertSortedIfAbsent(arr, sample{Timestamp: rec.Timestamp, Value: rec.Value, Role: rec.Role}); inserted {
+			m.cache.put(key, arr)
+			replayed++
+		}
+	}
+	m.mu.Unlock()
+
+	log.Printf("[Storage-%s] Replayed %d WAL record(s) not yet reflected on disk", m.nodeID, replayed)
+}
+
+// insertSortedIfAbsent inserts s into arr in sorted-by-timestamp order,
+// unless a sample with the same timestamp and role is already present, in
+// which case it is a no-op and inserted is false.
+func insertSortedIfAbsent(arr []sample, s sample) (result []sample, inserted bool) {
+	insertPos := sort.Search(len(arr), func(i int) bool {
+		return arr[i].Timestamp >= s.Timestamp
+	})
+
+	for i := insertPos; i < len(arr) && arr[i].Timestamp == s.Timestamp; i++ {
+		if arr[i].Role == s.Role {
+			return arr, false
+		}
+	}
+
+	if insertPos == len(arr) {
+		return append(arr, s), true
+	}
+	arr = append(arr, sample{})
+	copy(arr[insertPos+1:], arr[insertPos:])
+	arr[insertPos] = s
+	return arr, true
+}
+
+// loadSeriesLocked returns the samples for deviceID/metric, from the cache
+// if present, otherwise hydrating from disk and populating the cache so
+// later calls hit it. Under dedup, chunk storage isn't seekable, so
+// hydration reads and filters the whole manifest; otherwise it uses
+// engine.ReadRange, which can skip straight to the blocks that matter. The
+// caller must hold m.mu for writing, since a cache hit still mutates the
+// LRU's recency order.
+func (m *UnifiedStorage) loadSeriesLocked(deviceID, metric string) ([]sample, error) {
+	key := deviceID + "|" + metric
+	if arr, ok := m.cache.get(key); ok {
+		return arr, nil
+	}
+
+	var arr []sample
+	if m.dedup != nil {
+		records, err := m.readExisting()
+		if err != nil && !isNotExist(err) {
+			return nil, fmt.Errorf("failed to hydrate %s from dedup store: %w", key, err)
+		}
+		for _, r := range records {
+			if r.DeviceID != deviceID || r.MetricName != metric {
+				continue
+			}
+			arr, _ = insertSortedIfAbsent(arr, sample{Timestamp: r.Timestamp, Value: r.Value, Role: "primary"})
+		}
+	} else {
+		err := m.engine.ReadRange(deviceID, metric, math.MinInt64, math.MaxInt64, func(r models.Record) error {
+			arr, _ = insertSortedIfAbsent(arr, sample{Timestamp: r.Timestamp, Value: r.Value, Role: "primary"})
+			return nil
+		})
+		if err != nil && !isNotExist(err) {
+			return nil, fmt.Errorf("failed to hydrate %s from disk: %w", key, err)
+		}
+	}
+
+	m.cache.put(key, arr)
+	return arr, nil
+}
+
+// isNotExist reports whether err means the segment hasn't been written yet,
+// covering both a bare os.ErrNotExist and one wrapped in an *os.PathError -
+// the same two shapes Reload already had to check for.
+func isNotExist(err error) bool {
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	var pathErr *os.PathError
+	return errors.As(err, &pathErr) && errors.Is(pathErr.Err, os.ErrNotExist)
+}
+
 // periodicFlush flushes data every 5 seconds regardless of batch size
 func (m *UnifiedStorage) periodicFlush() {
 	ticker := time.NewTicker(5 * time.Second)
@@ -87,9 +252,201 @@ func (m *UnifiedStorage) periodicFlush() {
 			m.flushBatchUnlocked()
 		}
 		m.mu.Unlock()
+
+		if m.wal != nil {
+			if err := m.wal.PurgeOlderThan(walRetention); err != nil {
+				log.Printf("[Storage-%s] Warning: WAL retention purge failed: %v", m.nodeID, err)
+			}
+		}
+	}
+}
+
+// EnableCompaction turns on background compaction of this segment: once
+// Compact sees the segment is older than minAge, it rewrites it with level
+// (normally CompressionBetter) in place of whatever CompressionLevel it was
+// originally written with. It's off by default, since most of the benefit is
+// in trading CPU for size on segments that are no longer being actively
+// written, not on every flush.
+func (m *UnifiedStorage) EnableCompaction(level CompressionLevel, minAge time.Duration) {
+	m.mu.Lock()
+	m.compactLevel = level
+	m.compactAge = minAge
+	m.mu.Unlock()
+
+	go m.periodicCompact()
+}
+
+// periodicCompact calls Compact on a fixed schedule for as long as
+// compaction is enabled, logging (but not acting further on) any error -
+// the next tick just tries again.
+func (m *UnifiedStorage) periodicCompact() {
+	ticker := time.NewTicker(compactCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := m.Compact(); err != nil {
+			log.Printf("[Storage-%s] Warning: compaction failed: %v", m.nodeID, err)
+		}
 	}
 }
 
+// Compact rewrites the segment at compactLevel if it's older than compactAge
+// and compaction is enabled (see EnableCompaction); otherwise it's a no-op.
+// The rewrite only touches this one segment's on-disk encoding - in-memory
+// data, the WAL, and every other node are unaffected - so it's safe to call
+// concurrently with ongoing reads and writes.
+func (m *UnifiedStorage) Compact() error {
+	m.mu.Lock()
+	level := m.compactLevel
+	minAge := m.compactAge
+	m.mu.Unlock()
+
+	if level == CompressionNone {
+		return nil
+	}
+
+	info, err := m.engine.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat segment for compaction: %w", err)
+	}
+	if time.Since(info.ModTime) < minAge {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records, err := m.engine.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read segment for compaction: %w", err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	original := m.engine.CompressionLevel
+	m.engine.CompressionLevel = level
+	err = m.engine.Write(records)
+	m.engine.CompressionLevel = original
+	if err != nil {
+		return fmt.Errorf("failed to rewrite compacted segment: %w", err)
+	}
+
+	log.Printf("[Storage-%s] Compacted %s to level %q (%d records)", m.nodeID, m.key, level, len(records))
+	return nil
+}
+
+// manifestKey is where EnableDedup stores this segment's cdc.Manifest, once
+// dedup replaces engine as the segment's canonical storage.
+func (m *UnifiedStorage) manifestKey() string {
+	return m.key + ".manifest"
+}
+
+// EnableDedup turns on the content-defined-chunking dedup layer (see
+// pkg/storage/cdc): flushBatchUnlocked starts writing the segment as a
+// manifest of chunk digests in a content-addressed Store over be, instead
+// of through engine directly, so byte-identical runs shared with other
+// segments on the same be - replicas, snapshots, S3 backups of the same
+// series at different points in time - are only stored once. be is usually
+// the same Backend the segment itself lives on, so chunks and manifest end
+// up alongside it.
+func (m *UnifiedStorage) EnableDedup(be backend.Backend) error {
+	store, err := cdc.NewStore(be)
+	if err != nil {
+		return fmt.Errorf("failed to open dedup store: %w", err)
+	}
+
+	m.mu.Lock()
+	m.dedup = store
+	m.mu.Unlock()
+	return nil
+}
+
+// loadManifest reads and decodes this segment's current cdc.Manifest. Like
+// engine.Read, a missing manifest is reported via the returned error rather
+// than as a distinguished zero value, so callers follow the same
+// errors.Is(err, os.ErrNotExist) convention used elsewhere in this file.
+func (m *UnifiedStorage) loadManifest() (cdc.Manifest, error) {
+	r, err := m.be.OpenRead(m.manifestKey())
+	if err != nil {
+		return cdc.Manifest{}, err
+	}
+	defer r.Close()
+
+	var manifest cdc.Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return cdc.Manifest{}, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// readExisting returns every record currently on disk for this segment,
+// through the dedup layer if EnableDedup was called and through engine
+// otherwise.
+func (m *UnifiedStorage) readExisting() ([]models.Record, error) {
+	if m.dedup == nil {
+		return m.engine.Read()
+	}
+
+	manifest, err := m.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	return cdc.Get(m.dedup, manifest)
+}
+
+// writeDedup chunks and stores records as a new manifest, then releases the
+// chunks the previous manifest referenced - so a flush that drops or
+// rewrites most of a segment's content doesn't leak the chunks backing the
+// old copy.
+func (m *UnifiedStorage) writeDedup(records []models.Record) error {
+	old, oldErr := m.loadManifest()
+
+	manifest, err := cdc.Put(m.dedup, records)
+	if err != nil {
+		return fmt.Errorf("failed to chunk and store records: %w", err)
+	}
+
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := m.be.WriteAtomic(m.manifestKey(), raw); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if oldErr == nil {
+		if err := cdc.Release(m.dedup, old); err != nil {
+			return fmt.Errorf("failed to release previous manifest's chunks: %w", err)
+		}
+	}
+	return nil
+}
+
+// deleteDedup rewrites this segment's manifest to reference only records,
+// releasing the previous manifest's chunks (see Store.Release) so the ones
+// that carried the now-deleted device/metric get reclaimed once nothing
+// else references them. An empty records removes the manifest entirely
+// instead of writing one that chunks to nothing.
+func (m *UnifiedStorage) deleteDedup(records []models.Record) error {
+	old, oldErr := m.loadManifest()
+
+	if len(records) == 0 {
+		if err := m.be.Delete(m.manifestKey()); err != nil {
+			return fmt.Errorf("failed to remove manifest: %w", err)
+		}
+	} else if err := m.writeDedup(records); err != nil {
+		return err
+	}
+
+	if len(records) == 0 && oldErr == nil {
+		if err := cdc.Release(m.dedup, old); err != nil {
+			return fmt.Errorf("failed to release manifest's chunks: %w", err)
+		}
+	}
+	return nil
+}
+
 func (m *UnifiedStorage) PersistPrimary(p interface{}) error {
 	return m.persist(p, "primary")
 }
@@ -127,26 +484,22 @@ func (m *UnifiedStorage) persist(p interface{}, role string) error {
 
 	key := device + "|" + metric
 
+	if m.wal != nil {
+		if err := m.wal.Append(WALRecord{Role: role, Op: "write", DeviceID: device, MetricName: metric, Timestamp: ts, Value: val}); err != nil {
+			return fmt.Errorf("failed to append to WAL: %w", err)
+		}
+	}
+
 	m.mu.Lock()
 	newSample := sample{Timestamp: ts, Value: val, Role: role}
-	arr := m.data[key]
 
-	// Insert in sorted order using binary search for optimal performance
-	insertPos := sort.Search(len(arr), func(i int) bool {
-		return arr[i].Timestamp >= ts
-	})
-
-	// Insert at the correct position to maintain sorted order
-	if insertPos == len(arr) {
-		// Append at the end
-		m.data[key] = append(arr, newSample)
-	} else {
-		// Insert at position
-		arr = append(arr, sample{})              // Extend slice
-		copy(arr[insertPos+1:], arr[insertPos:]) // Shift elements
-		arr[insertPos] = newSample
-		m.data[key] = arr
+	arr, loadErr := m.loadSeriesLocked(device, metric)
+	if loadErr != nil {
+		log.Printf("[Storage-%s] Warning: failed to hydrate %s before persist, caching this write alone: %v", m.nodeID, key, loadErr)
+		arr = nil
 	}
+	arr, _ = insertSortedIfAbsent(arr, newSample)
+	m.cache.put(key, arr)
 
 	if role == "primary" {
 		m.batch = append(m.batch, models.Record{
@@ -182,7 +535,7 @@ func (m *UnifiedStorage) flushBatchUnlocked() {
 		return batch[i].Timestamp < batch[j].Timestamp
 	})
 
-	existing, err := m.engine.Read()
+	existing, err := m.readExisting()
 	if err != nil {
 		log.Printf("[Storage-%s] No existing data, starting fresh", m.nodeID)
 		existing = []models.Record{}
@@ -194,10 +547,16 @@ func (m *UnifiedStorage) flushBatchUnlocked() {
 		return allRecords[i].Timestamp < allRecords[j].Timestamp
 	})
 
-	if err := m.engine.Write(allRecords); err != nil {
+	if m.dedup != nil {
+		if err := m.writeDedup(allRecords); err != nil {
+			log.Printf("[Storage-%s] ERROR writing dedup manifest: %v", m.nodeID, err)
+		} else {
+			log.Printf("[Storage-%s] Successfully wrote %d records to %s via dedup", m.nodeID, len(allRecords), m.key)
+		}
+	} else if err := m.engine.Write(allRecords); err != nil {
 		log.Printf("[Storage-%s] ERROR writing to disk: %v", m.nodeID, err)
 	} else {
-		log.Printf("[Storage-%s] Successfully wrote %d records to %s", m.nodeID, len(allRecords), m.filepath)
+		log.Printf("[Storage-%s] Successfully wrote %d records to %s", m.nodeID, len(allRecords), m.key)
 	}
 
 	// Re-acquire lock
@@ -231,19 +590,18 @@ func binarySearchEnd(arr []sample, end int64) int {
 
 func (m *UnifiedStorage) Query(deviceID, metric string, start, end int64) ([]float64, error) {
 	key := deviceID + "|" + metric
-	m.mu.RLock()
-	arr, ok := m.data[key]
-	m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// If not in memory, return empty
-	if !ok || len(arr) == 0 {
+	arr, err := m.loadSeriesLocked(deviceID, metric)
+	if err != nil {
+		return nil, err
+	}
+	if len(arr) == 0 {
 		log.Printf("[Storage-%s] Query for %s returned 0 points (key not found or empty)", m.nodeID, key)
 		return []float64{}, nil
 	}
 
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
 	// Optimized query using binary search for time range
 	var startIdx, endIdx int
 
@@ -289,20 +647,66 @@ func (m *UnifiedStorage) Query(deviceID, metric string, start, end int64) ([]flo
 	return res, nil
 }
 
+// QueryRaw returns the full records (including timestamps) for the given
+// range, unlike Query which only returns bare values. This is what read
+// repair needs in order to tell which samples a lagging replica is missing.
+func (m *UnifiedStorage) QueryRaw(deviceID, metric string, start, end int64) ([]models.Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	arr, err := m.loadSeriesLocked(deviceID, metric)
+	if err != nil {
+		return nil, err
+	}
+	if len(arr) == 0 {
+		return []models.Record{}, nil
+	}
+
+	var startIdx, endIdx int
+	if start == 0 && end == 0 {
+		startIdx, endIdx = 0, len(arr)-1
+	} else if start == 0 {
+		startIdx, endIdx = 0, len(arr)-1
+	} else {
+		startIdx = binarySearchStart(arr, start)
+		if startIdx >= len(arr) {
+			return []models.Record{}, nil
+		}
+		if end == 0 {
+			endIdx = len(arr) - 1
+		} else {
+			endIdx = binarySearchEnd(arr, end)
+			if endIdx < startIdx {
+				return []models.Record{}, nil
+			}
+		}
+	}
+
+	records := make([]models.Record, 0, endIdx-startIdx+1)
+	for i := startIdx; i <= endIdx; i++ {
+		records = append(records, models.Record{
+			Timestamp:  arr[i].Timestamp,
+			Value:      arr[i].Value,
+			DeviceID:   deviceID,
+			MetricName: metric,
+		})
+	}
+
+	return records, nil
+}
+
 func (m *UnifiedStorage) QueryAggregated(deviceID, metric string, start, end int64) (QueryStats, error) {
-	key := deviceID + "|" + metric
-	m.mu.RLock()
-	arr, ok := m.data[key]
-	m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// If not in memory, return empty
-	if !ok || len(arr) == 0 {
+	arr, err := m.loadSeriesLocked(deviceID, metric)
+	if err != nil {
+		return QueryStats{}, err
+	}
+	if len(arr) == 0 {
 		return QueryStats{}, nil
 	}
 
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
 	// Optimized query using binary search for time range
 	var startIdx, endIdx int
 
@@ -355,14 +759,116 @@ func (m *UnifiedStorage) QueryAggregated(deviceID, metric string, start, end int
 	return QueryStats{Sum: sum, Count: count, Min: min, Max: max}, nil
 }
 
+// TimeBucket is one equal-width time window of a QueryAggregatedBuckets
+// result, tagged with its own inclusive [Start, End] so a streaming caller (see
+// query.Service's /query-aggregated-stream) can attribute a partial result
+// to a specific slice of the requested range instead of waiting for the
+// whole thing to combine.
+type TimeBucket struct {
+	Start int64
+	End   int64
+	Stats QueryStats
+}
+
+// QueryAggregatedBuckets splits the inclusive [start, end] into numBuckets
+// equal-width
+// windows and calls onBucket with each window's QueryStats as it's computed,
+// in time order, so a caller can start forwarding results before the full
+// range has been scanned. onBucket returning an error stops the scan early
+// and that error is returned.
+func (m *UnifiedStorage) QueryAggregatedBuckets(deviceID, metric string, start, end int64, numBuckets int, onBucket func(TimeBucket) error) error {
+	if numBuckets < 1 {
+		numBuckets = 1
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	arr, err := m.loadSeriesLocked(deviceID, metric)
+	if err != nil {
+		return err
+	}
+	if len(arr) == 0 {
+		return nil
+	}
+
+	if start == 0 {
+		start = arr[0].Timestamp
+	}
+	if end == 0 {
+		end = arr[len(arr)-1].Timestamp
+	}
+	if end < start {
+		return nil
+	}
+
+	width := (end - start + 1) / int64(numBuckets)
+	if width < 1 {
+		width = 1
+	}
+
+	for i := 0; i < numBuckets; i++ {
+		bucketStart := start + int64(i)*width
+		if bucketStart > end {
+			break
+		}
+		bucketEnd := bucketStart + width - 1
+		if i == numBuckets-1 || bucketEnd > end {
+			bucketEnd = end
+		}
+
+		startIdx := binarySearchStart(arr, bucketStart)
+		if startIdx >= len(arr) {
+			continue
+		}
+		endIdx := binarySearchEnd(arr, bucketEnd)
+		if endIdx < startIdx {
+			continue
+		}
+
+		stats := QueryStats{
+			Sum:   arr[startIdx].Value,
+			Count: 1,
+			Min:   arr[startIdx].Value,
+			Max:   arr[startIdx].Value,
+		}
+		for j := startIdx + 1; j <= endIdx; j++ {
+			val := arr[j].Value
+			stats.Sum += val
+			stats.Count++
+			if val < stats.Min {
+				stats.Min = val
+			}
+			if val > stats.Max {
+				stats.Max = val
+			}
+		}
+
+		if err := onBucket(TimeBucket{Start: bucketStart, End: bucketEnd, Stats: stats}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Delete removes all data for a specific device_id and metric_name
 func (m *UnifiedStorage) Delete(deviceID, metric string) error {
 	key := deviceID + "|" + metric
 
+	// Logged before the in-memory/disk mutation below so a crash mid-delete
+	// still leaves a durable record of it - recoverWAL replays it on restart,
+	// and a peer catching up over WALRecordsSince sees it too.
+	if m.wal != nil {
+		if err := m.wal.Append(WALRecord{Op: "delete", DeviceID: deviceID, MetricName: metric}); err != nil {
+			return fmt.Errorf("failed to append delete to WAL: %w", err)
+		}
+	}
+
 	m.mu.Lock()
 
-	// Remove from memory
-	delete(m.data, key)
+	// Remove from the cache
+	m.cache.delete(key)
 
 	// Filter batch to remove records with matching device_id and metric_name
 	if len(m.batch) > 0 {
@@ -384,7 +890,7 @@ func (m *UnifiedStorage) Delete(deviceID, metric string) error {
 	m.mu.Unlock()
 
 	// Delete from disk by reading, filtering, and rewriting
-	existing, err := m.engine.Read()
+	existing, err := m.readExisting()
 	if err != nil {
 		// If file doesn't exist or can't be read, that's okay - deletion from memory is done
 		log.Printf("[Storage-%s] Could not read disk file for deletion: %v", m.nodeID, err)
@@ -403,10 +909,16 @@ func (m *UnifiedStorage) Delete(deviceID, metric string) error {
 			}
 		}
 
-		// Write filtered records back to disk
-		if len(filteredRecords) == 0 {
+		removed := len(existing) - len(filteredRecords)
+		if m.dedup != nil {
+			if err := m.deleteDedup(filteredRecords); err != nil {
+				log.Printf("[Storage-%s] Error updating dedup manifest for deletion: %v", m.nodeID, err)
+			} else {
+				log.Printf("[Storage-%s] Wrote %d records to dedup manifest (removed %d)", m.nodeID, len(filteredRecords), removed)
+			}
+		} else if len(filteredRecords) == 0 {
 			// If no records left, delete the file
-			if err := os.Remove(m.filepath); err != nil && !os.IsNotExist(err) {
+			if err := m.engine.Delete(); err != nil {
 				log.Printf("[Storage-%s] Error removing empty file: %v", m.nodeID, err)
 			} else {
 				log.Printf("[Storage-%s] Removed empty disk file after deletion", m.nodeID)
@@ -416,7 +928,7 @@ func (m *UnifiedStorage) Delete(deviceID, metric string) error {
 			if err := m.engine.Write(filteredRecords); err != nil {
 				log.Printf("[Storage-%s] Error writing filtered records to disk: %v", m.nodeID, err)
 			} else {
-				log.Printf("[Storage-%s] Wrote %d filtered records to disk (removed %d)", m.nodeID, len(filteredRecords), len(existing)-len(filteredRecords))
+				log.Printf("[Storage-%s] Wrote %d filtered records to disk (removed %d)", m.nodeID, len(filteredRecords), removed)
 			}
 		}
 	}
@@ -429,62 +941,80 @@ func (m *UnifiedStorage) Delete(deviceID, metric string) error {
 	return nil
 }
 
-// Reload reloads all data from disk, replacing in-memory data
-func (m *UnifiedStorage) Reload() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// WALRecordsSince returns every WAL record with a request number greater
+// than afterReqNum, in append order. It serves a peer's WAL catch-up RPC
+// (see internal/cluster's walCatchupHandler); afterReqNum 0 asks for
+// everything this node's WAL still holds, which is what a node uses the
+// first time it sees a peer after (re)joining the gossip ring.
+func (m *UnifiedStorage) WALRecordsSince(afterReqNum int64) ([]WALRecord, error) {
+	if m.wal == nil {
+		return nil, nil
+	}
 
-	// Clear existing data
-	m.data = make(map[string][]sample)
+	var records []WALRecord
+	err := m.wal.RecoverFromRequestNumber(afterReqNum, func(rec WALRecord) error {
+		records = append(records, rec)
+		return nil
+	})
+	return records, err
+}
 
-	// Read all records from disk
-	records, err := m.engine.Read()
+// AllRecords returns every record durably persisted to disk for this
+// segment - through the dedup layer if EnableDedup was called and through
+// engine otherwise, the same source flushBatchUnlocked merges new writes
+// against. Unlike WALRecordsSince, it isn't bounded by walRetention, which is
+// why anti-entropy index summaries (see pkg/cluster.BuildShardSummaries) are
+// built from this instead of the WAL: a summary sourced from the WAL alone
+// would silently stop covering any data older than an hour.
+func (m *UnifiedStorage) AllRecords() ([]models.Record, error) {
+	records, err := m.readExisting()
 	if err != nil {
-		// File might not exist yet, which is okay
-		if errors.Is(err, os.ErrNotExist) {
-			log.Printf("[Storage-%s] No existing data file, starting fresh", m.nodeID)
-			return nil
+		if isNotExist(err) {
+			return []models.Record{}, nil
 		}
-		// Check if the underlying error is file not found (wrapped error)
-		var pathErr *os.PathError
-		if errors.As(err, &pathErr) && errors.Is(pathErr.Err, os.ErrNotExist) {
-			log.Printf("[Storage-%s] No existing data file, starting fresh", m.nodeID)
-			return nil
-		}
-		return fmt.Errorf("failed to read from disk: %w", err)
+		return nil, err
 	}
+	return records, nil
+}
 
-	if len(records) == 0 {
-		log.Printf("[Storage-%s] No records found in file", m.nodeID)
-		return nil
-	}
+// Reload drops the cache and confirms the segment (or, under dedup, its
+// manifest) is present and readable, without decoding its body - series are
+// hydrated lazily, one at a time, the first time each is queried (see
+// loadSeriesLocked). This is what turns what used to be an O(records)
+// operation into an O(header) one, and is why, unlike before, Reload no
+// longer has a corrupt-record list to report: that's now surfaced per-series,
+// the first time a series whose on-disk bytes include a corrupt interval is
+// actually hydrated (ReadDetailed, reached through the zstd/pre-v4 fallback
+// path in StorageEngine.ReadRange).
+func (m *UnifiedStorage) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// Group records by device_id|metric_name and convert to samples
-	for _, record := range records {
-		key := record.DeviceID + "|" + record.MetricName
-		newSample := sample{
-			Timestamp: record.Timestamp,
-			Value:     record.Value,
-			Role:      "primary", // All data from disk is treated as primary
-		}
+	m.cache = newSeriesCache(defaultMaxCachedSeries)
 
-		// Insert in sorted order
-		arr := m.data[key]
-		insertPos := sort.Search(len(arr), func(i int) bool {
-			return arr[i].Timestamp >= record.Timestamp
-		})
+	if m.dedup != nil {
+		manifest, err := m.loadManifest()
+		if err != nil {
+			if isNotExist(err) {
+				log.Printf("[Storage-%s] No existing manifest, starting fresh", m.nodeID)
+				return nil
+			}
+			return fmt.Errorf("failed to read manifest: %w", err)
+		}
+		log.Printf("[Storage-%s] Found manifest with %d chunk(s); series will hydrate lazily on query", m.nodeID, len(manifest.ChunkDigests))
+		return nil
+	}
 
-		if insertPos == len(arr) {
-			m.data[key] = append(arr, newSample)
-		} else {
-			arr = append(arr, sample{})
-			copy(arr[insertPos+1:], arr[insertPos:])
-			arr[insertPos] = newSample
-			m.data[key] = arr
+	header, err := m.engine.HeaderInfo()
+	if err != nil {
+		if isNotExist(err) {
+			log.Printf("[Storage-%s] No existing data file, starting fresh", m.nodeID)
+			return nil
 		}
+		return fmt.Errorf("failed to read from disk: %w", err)
 	}
 
-	log.Printf("[Storage-%s] Reloaded %d records from disk into %d keys", m.nodeID, len(records), len(m.data))
+	log.Printf("[Storage-%s] Found segment with %d record(s) on disk; series will hydrate lazily on query", m.nodeID, header.RecordCount)
 	return nil
 }
 
@@ -500,8 +1030,11 @@ func (m *UnifiedStorage) Close() error {
 
 	m.mu.Unlock()
 
-	if m.file != nil {
-		return m.file.Close()
+	if m.wal != nil {
+		if err := m.wal.Close(); err != nil {
+			log.Printf("[Storage-%s] Warning: failed to close WAL: %v", m.nodeID, err)
+		}
 	}
+
 	return nil
 }
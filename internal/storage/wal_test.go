@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWAL_RecoverTruncatesTornTailRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWAL(dir, 0, FsyncAlways)
+	if err != nil {
+		t.Fatalf("NewWAL: %v", err)
+	}
+	if err := w.Append(WALRecord{Role: "primary", DeviceID: "dev-1", MetricName: "temp", Timestamp: 1, Value: 1}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Simulate a crash mid-write by appending a truncated frame: a valid
+	// uvarint length and CRC claiming more payload bytes than are actually
+	// present.
+	path := filepath.Join(dir, "wal-000000.log")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	frame := frameWALPayload([]byte("not a real record, just torn bytes"))
+	if _, err := f.Write(frame[:len(frame)-10]); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := NewWAL(dir, 0, FsyncAlways)
+	if err != nil {
+		t.Fatalf("NewWAL (reopen after torn write): %v", err)
+	}
+	defer reopened.Close()
+
+	records, err := reopened.Recover()
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Recover() returned %d records, want 1 (torn tail should be dropped)", len(records))
+	}
+	if records[0].DeviceID != "dev-1" {
+		t.Errorf("Recover()[0].DeviceID = %q, want dev-1", records[0].DeviceID)
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionLevel controls the optional outer zstd pass StorageEngine.Write
+// applies over the already checksum-framed record stream, on top of the
+// per-series Gorilla encoding CompressInt64/CompressFloat64 already do.
+// CompressionNone, the zero value, skips it entirely and preserves the
+// original on-disk format.
+type CompressionLevel string
+
+const (
+	CompressionNone    CompressionLevel = ""
+	CompressionFast    CompressionLevel = "fast"
+	CompressionDefault CompressionLevel = "default"
+	CompressionBetter  CompressionLevel = "better"
+)
+
+func (c CompressionLevel) zstdLevel() zstd.EncoderLevel {
+	switch c {
+	case CompressionFast:
+		return zstd.SpeedFastest
+	case CompressionBetter:
+		return zstd.SpeedBetterCompression
+	default:
+		return zstd.SpeedDefault
+	}
+}
+
+// segmentCodecNone/segmentCodecZstd are the values buildHeader stores at
+// segmentCodecOffset (see storage_engine.go) so ReadDetailed knows whether
+// to undo this layer before deframing checksums. A file written before this
+// layer existed has a zero byte there, which is segmentCodecNone - so old
+// files keep loading unchanged.
+const (
+	segmentCodecNone byte = 0
+	segmentCodecZstd byte = 1
+)
+
+// encoderPools holds one *sync.Pool of *zstd.Encoder per CompressionLevel,
+// since an Encoder is tied to the level it was created with.
+var encoderPools sync.Map
+
+func getEncoder(level CompressionLevel) (*zstd.Encoder, error) {
+	v, _ := encoderPools.LoadOrStore(level, &sync.Pool{})
+	pool := v.(*sync.Pool)
+	if enc, ok := pool.Get().(*zstd.Encoder); ok {
+		return enc, nil
+	}
+	return zstd.NewWriter(nil, zstd.WithEncoderLevel(level.zstdLevel()))
+}
+
+func putEncoder(level CompressionLevel, enc *zstd.Encoder) {
+	v, _ := encoderPools.LoadOrStore(level, &sync.Pool{})
+	v.(*sync.Pool).Put(enc)
+}
+
+// decoderPool holds reusable *zstd.Decoder instances - unlike the encoder,
+// decoding doesn't depend on a level, so one pool covers every segment.
+var decoderPool = sync.Pool{
+	New: func() interface{} {
+		// zstd.NewReader(nil) only fails on invalid options, and we pass
+		// none, so this can't actually error.
+		dec, _ := zstd.NewReader(nil)
+		return dec
+	},
+}
+
+// zstdCompress encodes data at level using a pooled Encoder.
+func zstdCompress(level CompressionLevel, data []byte) ([]byte, error) {
+	enc, err := getEncoder(level)
+	if err != nil {
+		return nil, fmt.Errorf("zstd: creating encoder: %w", err)
+	}
+	out := enc.EncodeAll(data, nil)
+	putEncoder(level, enc)
+	return out, nil
+}
+
+// zstdDecompress reverses zstdCompress using a pooled Decoder.
+func zstdDecompress(data []byte) ([]byte, error) {
+	dec := decoderPool.Get().(*zstd.Decoder)
+	out, err := dec.DecodeAll(data, nil)
+	decoderPool.Put(dec)
+	if err != nil {
+		return nil, fmt.Errorf("zstd: decoding: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,182 @@
+// Package secure layers an authenticated, encrypted session onto a raw
+// net.Conn: an X25519 key exchange (via nacl/box's Precompute, the same
+// curve used by the secretbox/session-key handshake in the reference
+// Cap'n Proto implementation this was modeled on) derives a shared key,
+// gated by an HMAC proof that both peers hold the same cluster token, and
+// every Read/Write after that is sealed with nacl/secretbox. Callers get
+// back an ordinary net.Conn - the handshake and framing are invisible to
+// whatever wire protocol (length-prefixed blobs, Frame RPC, ...) runs on
+// top.
+package secure
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+// ClusterKeyEnvVar is the environment variable LoadClusterToken falls back
+// to when no key file path is given.
+const ClusterKeyEnvVar = "MINITRUE_CLUSTER_KEY"
+
+// handshakeTimeout bounds the handshake when a Config doesn't set its own.
+const handshakeTimeout = 10 * time.Second
+
+// authTagSize is an HMAC-SHA256 tag's length.
+const authTagSize = sha256.Size
+
+// Direction labels domain-separate the two directional keys derived from
+// one X25519 shared secret, so a client's outbound stream and a server's
+// outbound stream never reuse the same key (and therefore never risk nonce
+// collision) even though both sides start their nonce counters at zero.
+const (
+	labelClientToServer = "minitrue-cluster-c2s"
+	labelServerToClient = "minitrue-cluster-s2c"
+)
+
+// Config carries the shared secret both peers must present to complete a
+// handshake, plus how long the handshake itself may take.
+type Config struct {
+	Token   []byte
+	Timeout time.Duration
+}
+
+func (cfg Config) timeout() time.Duration {
+	if cfg.Timeout > 0 {
+		return cfg.Timeout
+	}
+	return handshakeTimeout
+}
+
+// LoadClusterToken resolves the shared cluster token that gates every
+// handshake: if keyPath is non-empty, its contents (trimmed of surrounding
+// whitespace, e.g. a trailing newline) are used; otherwise the
+// ClusterKeyEnvVar environment variable is used verbatim. It's an error for
+// neither source to yield a non-empty token, since a misconfigured node
+// would otherwise silently fall back to no authentication.
+func LoadClusterToken(keyPath string) ([]byte, error) {
+	if keyPath != "" {
+		data, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("secure: reading cluster key file %s: %w", keyPath, err)
+		}
+		token := bytes.TrimSpace(data)
+		if len(token) == 0 {
+			return nil, fmt.Errorf("secure: cluster key file %s is empty", keyPath)
+		}
+		return token, nil
+	}
+
+	if token := os.Getenv(ClusterKeyEnvVar); token != "" {
+		return []byte(token), nil
+	}
+
+	return nil, fmt.Errorf("secure: no cluster key configured (set -cluster-key or %s)", ClusterKeyEnvVar)
+}
+
+// Client performs the client side of the handshake over conn: send an
+// ephemeral X25519 public key and an HMAC proof of cfg.Token, verify the
+// server's matching reply, then derive the session's directional keys. On
+// success it returns a net.Conn that transparently encrypts every Write and
+// decrypts/authenticates every Read; conn itself must not be used directly
+// again.
+func Client(conn net.Conn, cfg Config) (net.Conn, error) {
+	if len(cfg.Token) == 0 {
+		return nil, fmt.Errorf("secure: cluster token required")
+	}
+
+	conn.SetDeadline(time.Now().Add(cfg.timeout()))
+	defer conn.SetDeadline(time.Time{})
+
+	clientPub, clientPriv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("secure: generating ephemeral key: %w", err)
+	}
+
+	hello := make([]byte, 32+authTagSize)
+	copy(hello[:32], clientPub[:])
+	copy(hello[32:], authTag(cfg.Token, clientPub[:]))
+	if _, err := conn.Write(hello); err != nil {
+		return nil, fmt.Errorf("secure: sending client hello: %w", err)
+	}
+
+	reply := make([]byte, 32+authTagSize)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, fmt.Errorf("secure: reading server hello: %w", err)
+	}
+	var serverPub [32]byte
+	copy(serverPub[:], reply[:32])
+	if !hmac.Equal(reply[32:], authTag(cfg.Token, serverPub[:])) {
+		return nil, fmt.Errorf("secure: server failed cluster token authentication")
+	}
+
+	var shared [32]byte
+	box.Precompute(&shared, &serverPub, clientPriv)
+
+	return newConn(conn, deriveKey(shared, labelClientToServer), deriveKey(shared, labelServerToClient)), nil
+}
+
+// Server performs the server side of the handshake - the mirror image of
+// Client - and returns an equivalently wrapped net.Conn.
+func Server(conn net.Conn, cfg Config) (net.Conn, error) {
+	if len(cfg.Token) == 0 {
+		return nil, fmt.Errorf("secure: cluster token required")
+	}
+
+	conn.SetDeadline(time.Now().Add(cfg.timeout()))
+	defer conn.SetDeadline(time.Time{})
+
+	hello := make([]byte, 32+authTagSize)
+	if _, err := io.ReadFull(conn, hello); err != nil {
+		return nil, fmt.Errorf("secure: reading client hello: %w", err)
+	}
+	var clientPub [32]byte
+	copy(clientPub[:], hello[:32])
+	if !hmac.Equal(hello[32:], authTag(cfg.Token, clientPub[:])) {
+		return nil, fmt.Errorf("secure: client failed cluster token authentication")
+	}
+
+	serverPub, serverPriv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("secure: generating ephemeral key: %w", err)
+	}
+
+	reply := make([]byte, 32+authTagSize)
+	copy(reply[:32], serverPub[:])
+	copy(reply[32:], authTag(cfg.Token, serverPub[:]))
+	if _, err := conn.Write(reply); err != nil {
+		return nil, fmt.Errorf("secure: sending server hello: %w", err)
+	}
+
+	var shared [32]byte
+	box.Precompute(&shared, &clientPub, serverPriv)
+
+	return newConn(conn, deriveKey(shared, labelServerToClient), deriveKey(shared, labelClientToServer)), nil
+}
+
+// authTag proves possession of token without ever putting it on the wire.
+func authTag(token, pub []byte) []byte {
+	mac := hmac.New(sha256.New, token)
+	mac.Write(pub)
+	return mac.Sum(nil)
+}
+
+// deriveKey folds label into shared so the two directions of one session
+// use distinct secretbox keys.
+func deriveKey(shared [32]byte, label string) [32]byte {
+	h := sha256.New()
+	h.Write(shared[:])
+	h.Write([]byte(label))
+
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
@@ -0,0 +1,123 @@
+package secure
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func handshakePair(t *testing.T, clientCfg, serverCfg Config) (net.Conn, net.Conn, error, error) {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	clientCh := make(chan result, 1)
+	serverCh := make(chan result, 1)
+
+	go func() {
+		c, err := Client(clientConn, clientCfg)
+		clientCh <- result{c, err}
+	}()
+	go func() {
+		s, err := Server(serverConn, serverCfg)
+		serverCh <- result{s, err}
+	}()
+
+	cr := <-clientCh
+	sr := <-serverCh
+	return cr.conn, sr.conn, cr.err, sr.err
+}
+
+func TestHandshake_MatchingTokensSucceedAndEncryptTraffic(t *testing.T) {
+	token := []byte("shared-secret")
+	clientConn, serverConn, clientErr, serverErr := handshakePair(t, Config{Token: token}, Config{Token: token})
+	if clientErr != nil {
+		t.Fatalf("Client handshake failed: %v", clientErr)
+	}
+	if serverErr != nil {
+		t.Fatalf("Server handshake failed: %v", serverErr)
+	}
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := clientConn.Write([]byte("hello over the secure channel"))
+		done <- err
+	}()
+
+	buf := make([]byte, 64)
+	n, err := serverConn.Read(buf)
+	if err != nil {
+		t.Fatalf("server Read: %v", err)
+	}
+	if string(buf[:n]) != "hello over the secure channel" {
+		t.Errorf("server read %q, want %q", buf[:n], "hello over the secure channel")
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("client Write: %v", err)
+	}
+}
+
+func TestHandshake_MismatchedTokensFail(t *testing.T) {
+	shortTimeout := 200 * time.Millisecond
+	_, _, clientErr, serverErr := handshakePair(t,
+		Config{Token: []byte("token-a"), Timeout: shortTimeout},
+		Config{Token: []byte("token-b"), Timeout: shortTimeout})
+	if clientErr == nil {
+		t.Error("expected client handshake to fail with mismatched tokens")
+	}
+	if serverErr == nil {
+		t.Error("expected server handshake to fail with mismatched tokens")
+	}
+}
+
+func TestHandshake_EmptyTokenRejected(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	if _, err := Client(clientConn, Config{}); err == nil {
+		t.Error("expected Client to reject an empty token")
+	}
+	if _, err := Server(serverConn, Config{}); err == nil {
+		t.Error("expected Server to reject an empty token")
+	}
+}
+
+func TestConn_TamperedFrameFailsToDecrypt(t *testing.T) {
+	token := []byte("shared-secret")
+	clientConn, serverConn, clientErr, serverErr := handshakePair(t, Config{Token: token}, Config{Token: token})
+	if clientErr != nil || serverErr != nil {
+		t.Fatalf("handshake failed: client=%v server=%v", clientErr, serverErr)
+	}
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	c := clientConn.(*Conn)
+	raw := c.Conn
+
+	// Frame: 4-byte length prefix, then an 8-byte nonce counter matching the
+	// reader's expected seq (0), then 12 bytes of garbage ciphertext that
+	// will never pass secretbox authentication under the real key.
+	frame := []byte{0, 0, 0, 20, 0, 0, 0, 0, 0, 0, 0, 0, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := raw.Write(frame)
+		done <- err
+	}()
+	defer func() { <-done }()
+
+	serverConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 64)
+	if _, err := serverConn.Read(buf); err == nil {
+		t.Error("expected tampered frame to fail decryption")
+	} else if err == io.EOF {
+		t.Error("expected a decrypt error, got EOF")
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"path"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/minitrue/internal/models"
 	"github.com/minitrue/internal/mqttclient"
+	"github.com/minitrue/internal/storage"
 )
 
 var upgrader = websocket.Upgrader{
@@ -28,6 +32,10 @@ type DataPoint struct {
 	ReceivedAt time.Time `json:"received_at"`
 }
 
+// historyWindow is how far back a fresh subscription's "history" reply
+// looks for context, so a newly connected UI doesn't start on a blank chart.
+const historyWindow = 5 * time.Minute
+
 type Hub struct {
 	clients    map[*Client]bool
 	broadcast  chan DataPoint
@@ -35,21 +43,83 @@ type Hub struct {
 	unregister chan *Client
 	mu         sync.RWMutex
 	mqttClient *mqttclient.Client
+	store      storage.Storage
+}
+
+// subscribeSpec is one entry of an inbound subscribe/unsubscribe list, and
+// also what's echoed back in a "subscriptions" list reply.
+type subscribeSpec struct {
+	DeviceID   string `json:"device_id"`
+	MetricName string `json:"metric_name"`
+}
+
+// clientMessage is the protocol for frames a browser sends on the socket.
+// Any combination of the fields may be present in one frame.
+type clientMessage struct {
+	Subscribe    []subscribeSpec `json:"subscribe,omitempty"`
+	Unsubscribe  []subscribeSpec `json:"unsubscribe,omitempty"`
+	List         bool            `json:"list,omitempty"`
+	SampleRateHz float64         `json:"sample_rate_hz,omitempty"`
+}
+
+// subscription is one (device, metric) glob a client wants to receive, with
+// an optional downsampler that coalesces bursts to sampleRateHz.
+type subscription struct {
+	deviceGlob   string
+	metricGlob   string
+	sampleRateHz float64
+	lastSent     time.Time
+}
+
+func (s *subscription) matches(dp DataPoint) bool {
+	return globMatch(s.deviceGlob, dp.DeviceID) && globMatch(s.metricGlob, dp.MetricName)
+}
+
+// allow reports whether dp should be forwarded now given this subscription's
+// sample rate, advancing lastSent as a side effect when it does.
+func (s *subscription) allow(now time.Time) bool {
+	if s.sampleRateHz <= 0 {
+		return true
+	}
+	minInterval := time.Duration(float64(time.Second) / s.sampleRateHz)
+	if !s.lastSent.IsZero() && now.Sub(s.lastSent) < minInterval {
+		return false
+	}
+	s.lastSent = now
+	return true
+}
+
+func globMatch(pattern, value string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
+
+// isGlob reports whether pattern is empty or contains wildcard characters,
+// meaning it can't be resolved to a single storage key.
+func isGlob(pattern string) bool {
+	return pattern == "" || strings.ContainsAny(pattern, "*?[")
 }
 
 type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
-	send chan DataPoint
+	send chan []byte
+
+	mu            sync.Mutex
+	subscriptions []*subscription
 }
 
-func NewHub(mqttClient *mqttclient.Client) *Hub {
+func NewHub(mqttClient *mqttclient.Client, store storage.Storage) *Hub {
 	return &Hub{
 		broadcast:  make(chan DataPoint, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
 		mqttClient: mqttClient,
+		store:      store,
 	}
 }
 
@@ -81,8 +151,12 @@ func (h *Hub) Run() {
 		case message := <-h.broadcast:
 			h.mu.RLock()
 			for client := range h.clients {
+				data, ok := client.matchedPayload(message)
+				if !ok {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- data:
 				default:
 					close(client.send)
 					delete(h.clients, client)
@@ -93,6 +167,32 @@ func (h *Hub) Run() {
 	}
 }
 
+// matchedPayload returns the marshaled DataPoint and true if dp matches at
+// least one of the client's subscriptions and passes that subscription's
+// rate limit, false otherwise (including when the client has no
+// subscriptions at all).
+func (c *Client) matchedPayload(dp DataPoint) ([]byte, bool) {
+	c.mu.Lock()
+	matched := false
+	now := time.Now()
+	for _, sub := range c.subscriptions {
+		if sub.matches(dp) && sub.allow(now) {
+			matched = true
+		}
+	}
+	c.mu.Unlock()
+
+	if !matched {
+		return nil, false
+	}
+	data, err := json.Marshal(dp)
+	if err != nil {
+		log.Printf("[WebSocket] Failed to marshal message: %v", err)
+		return nil, false
+	}
+	return data, true
+}
+
 func (h *Hub) handleMQTTMessage(client mqtt.Client, msg mqtt.Message) {
 	var dataPoint DataPoint
 	if err := json.Unmarshal(msg.Payload(), &dataPoint); err != nil {
@@ -101,7 +201,7 @@ func (h *Hub) handleMQTTMessage(client mqtt.Client, msg mqtt.Message) {
 	}
 
 	dataPoint.ReceivedAt = time.Now()
-	
+
 	select {
 	case h.broadcast <- dataPoint:
 	default:
@@ -119,7 +219,7 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 	client := &Client{
 		hub:  h,
 		conn: conn,
-		send: make(chan DataPoint, 256),
+		send: make(chan []byte, 256),
 	}
 	client.hub.register <- client
 
@@ -134,7 +234,9 @@ const (
 
 	pingPeriod = (pongWait * 9) / 10
 
-	maxMessageSize = 512
+	// maxMessageSize covers inbound subscribe/unsubscribe frames too, which
+	// can list several (device, metric) pairs.
+	maxMessageSize = 8192
 )
 
 func (c *Client) readPump() {
@@ -151,13 +253,128 @@ func (c *Client) readPump() {
 	})
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("[WebSocket] Error reading message: %v", err)
 			}
 			break
 		}
+		c.handleInbound(data)
+	}
+}
+
+// handleInbound parses one client-sent control frame and applies it: remove
+// any unsubscribe entries first, then add/update subscribe entries (each
+// newly added one triggers a history reply; one already present only has its
+// sample rate updated), then answer a list request if present.
+func (c *Client) handleInbound(data []byte) {
+	var msg clientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("[WebSocket] Ignoring malformed client message: %v", err)
+		return
+	}
+
+	for _, spec := range msg.Unsubscribe {
+		c.unsubscribe(spec)
+	}
+	for _, spec := range msg.Subscribe {
+		c.subscribe(spec, msg.SampleRateHz)
+	}
+	if msg.List {
+		c.sendList()
+	}
+}
+
+func (c *Client) subscribe(spec subscribeSpec, sampleRateHz float64) {
+	c.mu.Lock()
+	for _, sub := range c.subscriptions {
+		if sub.deviceGlob == spec.DeviceID && sub.metricGlob == spec.MetricName {
+			sub.sampleRateHz = sampleRateHz
+			c.mu.Unlock()
+			return
+		}
+	}
+	c.subscriptions = append(c.subscriptions, &subscription{
+		deviceGlob:   spec.DeviceID,
+		metricGlob:   spec.MetricName,
+		sampleRateHz: sampleRateHz,
+	})
+	c.mu.Unlock()
+
+	c.sendHistory(spec)
+}
+
+func (c *Client) unsubscribe(spec subscribeSpec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := c.subscriptions[:0]
+	for _, sub := range c.subscriptions {
+		if sub.deviceGlob == spec.DeviceID && sub.metricGlob == spec.MetricName {
+			continue
+		}
+		kept = append(kept, sub)
+	}
+	c.subscriptions = kept
+}
+
+type historyReply struct {
+	Type       string          `json:"type"`
+	DeviceID   string          `json:"device_id"`
+	MetricName string          `json:"metric_name"`
+	Points     []models.Record `json:"points"`
+}
+
+type listReply struct {
+	Type          string          `json:"type"`
+	Subscriptions []subscribeSpec `json:"subscriptions"`
+}
+
+// sendHistory pulls recent points for a newly established subscription so
+// the UI has context without a separate HTTP round trip. Glob subscriptions
+// can't be resolved to a single storage key, so they're skipped.
+func (c *Client) sendHistory(spec subscribeSpec) {
+	if c.hub.store == nil || isGlob(spec.DeviceID) || isGlob(spec.MetricName) {
+		return
+	}
+
+	end := time.Now().Unix()
+	start := end - int64(historyWindow.Seconds())
+	points, err := c.hub.store.QueryRaw(spec.DeviceID, spec.MetricName, start, end)
+	if err != nil {
+		log.Printf("[WebSocket] Failed to load history for %s/%s: %v", spec.DeviceID, spec.MetricName, err)
+		return
+	}
+
+	c.sendJSON(historyReply{
+		Type:       "history",
+		DeviceID:   spec.DeviceID,
+		MetricName: spec.MetricName,
+		Points:     points,
+	})
+}
+
+func (c *Client) sendList() {
+	c.mu.Lock()
+	specs := make([]subscribeSpec, 0, len(c.subscriptions))
+	for _, sub := range c.subscriptions {
+		specs = append(specs, subscribeSpec{DeviceID: sub.deviceGlob, MetricName: sub.metricGlob})
+	}
+	c.mu.Unlock()
+
+	c.sendJSON(listReply{Type: "subscriptions", Subscriptions: specs})
+}
+
+func (c *Client) sendJSON(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[WebSocket] Failed to marshal reply: %v", err)
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		log.Printf("[WebSocket] Send buffer full, dropping reply to client")
 	}
 }
 
@@ -170,7 +387,7 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
+		case data, ok := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
@@ -182,23 +399,12 @@ func (c *Client) writePump() {
 				return
 			}
 
-			data, err := json.Marshal(message)
-			if err != nil {
-				log.Printf("[WebSocket] Failed to marshal message: %v", err)
-				continue
-			}
-
 			w.Write(data)
 
 			n := len(c.send)
 			for i := 0; i < n; i++ {
 				w.Write([]byte{'\n'})
-				msg := <-c.send
-				data, err := json.Marshal(msg)
-				if err != nil {
-					continue
-				}
-				w.Write(data)
+				w.Write(<-c.send)
 			}
 
 			if err := w.Close(); err != nil {
@@ -218,4 +424,4 @@ func (h *Hub) GetClientCount() int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 	return len(h.clients)
-}
\ No newline at end of file
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/minitrue/internal/models"
 	"github.com/minitrue/internal/storage"
+	"github.com/minitrue/pkg/storage/backend"
 )
 
 func main() {
@@ -31,7 +32,7 @@ func main() {
 
 	fmt.Printf("Parsed %d records from JSON input\n", len(records))
 
-	engine := storage.NewStorageEngine("data.parq")
+	engine := storage.NewStorageEngine(backend.NewDisk("."), "data.parq")
 	
 	if err := engine.Write(records); err != nil {
 		log.Fatalf("Failed to write data: %v", err)
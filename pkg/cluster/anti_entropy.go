@@ -0,0 +1,177 @@
+package cluster
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "sort"
+
+    "github.com/hashicorp/go-msgpack/codec"
+)
+
+// msgpackHandle is shared by MarshalMsgpack/UnmarshalMsgpack; a Handle is
+// safe for concurrent use once configured, so one package-level instance is
+// enough (the same pattern memberlist uses for its own msgpack traffic).
+var msgpackHandle codec.MsgpackHandle
+
+// MarshalMsgpack encodes v as msgpack, the wire format anti-entropy index
+// exchange uses for ClusterConfig/IndexSummary/IndexRequest/RecordResponse
+// (see internal/query's index-summary/index-request handlers).
+func MarshalMsgpack(v interface{}) ([]byte, error) {
+    var buf bytes.Buffer
+    if err := codec.NewEncoder(&buf, &msgpackHandle).Encode(v); err != nil {
+        return nil, fmt.Errorf("cluster: msgpack encode: %w", err)
+    }
+    return buf.Bytes(), nil
+}
+
+// UnmarshalMsgpack decodes data produced by MarshalMsgpack into v.
+func UnmarshalMsgpack(data []byte, v interface{}) error {
+    if err := codec.NewDecoder(bytes.NewReader(data), &msgpackHandle).Decode(v); err != nil {
+        return fmt.Errorf("cluster: msgpack decode: %w", err)
+    }
+    return nil
+}
+
+// IndexRecord is the wire shape anti-entropy index exchange moves a record
+// in, both inside BuildShardSummaries' input and RecordResponse's output:
+// the same DeviceID/MetricName/Timestamp/Value fields internal/models.Record
+// and internal/ingestion.DataPoint already use, so a RecordResponse entry
+// round-trips straight into storage.Storage.PersistReplica without this
+// package needing to import either.
+type IndexRecord struct {
+    DeviceID   string  `json:"device_id" codec:"device_id"`
+    MetricName string  `json:"metric_name" codec:"metric_name"`
+    Timestamp  int64   `json:"timestamp" codec:"timestamp"`
+    Value      float64 `json:"value" codec:"value"`
+}
+
+// ShardKey is the (device_id, metric_name) key BuildShardSummaries groups
+// records by - the same placement key internal/ingestion hashes against the
+// ring (see ingestion.IngestDataPoint's `key := p.DeviceID + ":" + p.MetricName`).
+func ShardKey(deviceID, metricName string) string {
+    return deviceID + ":" + metricName
+}
+
+// HourBucket returns the hour a Unix-seconds timestamp falls in, as a count
+// of whole hours since the epoch (unixSeconds / 3600), the granularity
+// BuildShardSummaries groups each shard's records by.
+func HourBucket(unixSeconds int64) int64 {
+    return unixSeconds / 3600
+}
+
+// ShardSummary is one shard's periodic index-exchange summary (see
+// internal/cluster's "index_summary" RPC): enough for a peer to tell,
+// without transferring any record data, which hour of the shard's time
+// range has diverged.
+type ShardSummary struct {
+    ShardKey    string           `json:"shard_key" codec:"shard_key"`
+    MinTs       int64            `json:"min_ts" codec:"min_ts"`
+    MaxTs       int64            `json:"max_ts" codec:"max_ts"`
+    RecordCount int              `json:"record_count" codec:"record_count"`
+    HourHashes  map[int64]string `json:"hour_hashes" codec:"hour_hashes"`
+}
+
+// BuildShardSummaries groups records by ShardKey(DeviceID, MetricName) and,
+// within each shard, by HourBucket, folding each bucket's sorted record
+// contents into a single sha256 so two nodes holding the same records for an
+// hour - in any order - land on the same hash.
+func BuildShardSummaries(records []IndexRecord) map[string]ShardSummary {
+    type bucketed struct {
+        summary ShardSummary
+        hours   map[int64][]string
+    }
+    byShard := make(map[string]*bucketed)
+
+    for _, r := range records {
+        key := ShardKey(r.DeviceID, r.MetricName)
+        b, ok := byShard[key]
+        if !ok {
+            b = &bucketed{
+                summary: ShardSummary{ShardKey: key, MinTs: r.Timestamp, MaxTs: r.Timestamp, HourHashes: make(map[int64]string)},
+                hours:   make(map[int64][]string),
+            }
+            byShard[key] = b
+        }
+        if r.Timestamp < b.summary.MinTs {
+            b.summary.MinTs = r.Timestamp
+        }
+        if r.Timestamp > b.summary.MaxTs {
+            b.summary.MaxTs = r.Timestamp
+        }
+        b.summary.RecordCount++
+        hour := HourBucket(r.Timestamp)
+        b.hours[hour] = append(b.hours[hour], indexRecordContent(r))
+    }
+
+    out := make(map[string]ShardSummary, len(byShard))
+    for key, b := range byShard {
+        for hour, contents := range b.hours {
+            sort.Strings(contents)
+            h := sha256.New()
+            for _, c := range contents {
+                h.Write([]byte(c))
+                h.Write([]byte{0})
+            }
+            b.summary.HourHashes[hour] = hex.EncodeToString(h.Sum(nil))
+        }
+        out[key] = b.summary
+    }
+    return out
+}
+
+func indexRecordContent(r IndexRecord) string {
+    return fmt.Sprintf("%d:%.6f", r.Timestamp, r.Value)
+}
+
+// DiffShardSummary compares the same shard's summary from two nodes and
+// returns the hour buckets whose content hash disagrees, or that exist on
+// only one side - exactly the buckets an IndexRequest should ask peer for.
+func DiffShardSummary(local, peer ShardSummary) []int64 {
+    seen := make(map[int64]bool, len(local.HourHashes))
+    var mismatched []int64
+    for hour, hash := range local.HourHashes {
+        seen[hour] = true
+        if peer.HourHashes[hour] != hash {
+            mismatched = append(mismatched, hour)
+        }
+    }
+    for hour := range peer.HourHashes {
+        if !seen[hour] {
+            mismatched = append(mismatched, hour)
+        }
+    }
+    sort.Slice(mismatched, func(i, j int) bool { return mismatched[i] < mismatched[j] })
+    return mismatched
+}
+
+// ClusterConfig is the handshake a node sends before requesting summaries:
+// the shard keys it wants IndexSummary to cover, mirroring the shared-folder
+// list a Syncthing ClusterConfig message declares before index exchange.
+type ClusterConfig struct {
+    ShardKeys []string `json:"shard_keys" codec:"shard_keys"`
+}
+
+// IndexSummary is a node's answer to a ClusterConfig request: its current
+// ShardSummary for each shard key the requester asked about, omitting any it
+// holds no records for.
+type IndexSummary struct {
+    Summaries []ShardSummary `json:"summaries" codec:"summaries"`
+}
+
+// IndexRequest asks a peer for the records backing one shard's mismatched
+// hour buckets, as identified by DiffShardSummary.
+type IndexRequest struct {
+    ShardKey string  `json:"shard_key" codec:"shard_key"`
+    Hours    []int64 `json:"hours" codec:"hours"`
+}
+
+// RecordResponse answers an IndexRequest with every record the responder
+// holds for ShardKey within the requested hour buckets, for the requester to
+// merge via storage.Storage.PersistReplica - the same path live replicated
+// writes and hinted-handoff replays already go through.
+type RecordResponse struct {
+    ShardKey string        `json:"shard_key" codec:"shard_key"`
+    Records  []IndexRecord `json:"records" codec:"records"`
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+    "testing"
+)
+
+func TestBuildShardSummaries_GroupsByShardAndHour(t *testing.T) {
+    records := []IndexRecord{
+        {DeviceID: "device-A", MetricName: "temp", Timestamp: 3600 * 10, Value: 1.0},
+        {DeviceID: "device-A", MetricName: "temp", Timestamp: 3600*10 + 100, Value: 2.0},
+        {DeviceID: "device-A", MetricName: "temp", Timestamp: 3600 * 11, Value: 3.0},
+        {DeviceID: "device-B", MetricName: "humidity", Timestamp: 3600 * 10, Value: 4.0},
+    }
+
+    summaries := BuildShardSummaries(records)
+    if len(summaries) != 2 {
+        t.Fatalf("expected 2 shards, got %d", len(summaries))
+    }
+
+    a := summaries[ShardKey("device-A", "temp")]
+    if a.RecordCount != 3 {
+        t.Errorf("expected 3 records for device-A:temp, got %d", a.RecordCount)
+    }
+    if len(a.HourHashes) != 2 {
+        t.Errorf("expected 2 hour buckets for device-A:temp, got %d", len(a.HourHashes))
+    }
+    if a.MinTs != 3600*10 || a.MaxTs != 3600*11 {
+        t.Errorf("expected min/max ts 36000/39600, got %d/%d", a.MinTs, a.MaxTs)
+    }
+}
+
+func TestDiffShardSummary_DetectsMismatchAndMissingHours(t *testing.T) {
+    local := BuildShardSummaries([]IndexRecord{
+        {DeviceID: "device-A", MetricName: "temp", Timestamp: 3600 * 10, Value: 1.0},
+        {DeviceID: "device-A", MetricName: "temp", Timestamp: 3600 * 11, Value: 2.0},
+    })[ShardKey("device-A", "temp")]
+
+    // Peer agrees on hour 10 and has a different value for hour 11.
+    peer := BuildShardSummaries([]IndexRecord{
+        {DeviceID: "device-A", MetricName: "temp", Timestamp: 3600 * 10, Value: 1.0},
+        {DeviceID: "device-A", MetricName: "temp", Timestamp: 3600 * 11, Value: 99.0},
+    })[ShardKey("device-A", "temp")]
+
+    mismatched := DiffShardSummary(local, peer)
+    if len(mismatched) != 1 || mismatched[0] != 11 {
+        t.Fatalf("expected only hour 11 to mismatch, got %v", mismatched)
+    }
+
+    // A peer missing an hour entirely should also be reported.
+    delete(peer.HourHashes, 10)
+    mismatched = DiffShardSummary(local, peer)
+    if len(mismatched) != 2 {
+        t.Fatalf("expected hours 10 and 11 to mismatch, got %v", mismatched)
+    }
+}
+
+func TestMarshalMsgpack_RoundTripsIndexSummary(t *testing.T) {
+    summary := IndexSummary{Summaries: []ShardSummary{
+        {ShardKey: "device-A:temp", MinTs: 1, MaxTs: 2, RecordCount: 2, HourHashes: map[int64]string{0: "abc"}},
+    }}
+
+    data, err := MarshalMsgpack(summary)
+    if err != nil {
+        t.Fatalf("MarshalMsgpack: %v", err)
+    }
+
+    var got IndexSummary
+    if err := UnmarshalMsgpack(data, &got); err != nil {
+        t.Fatalf("UnmarshalMsgpack: %v", err)
+    }
+    if len(got.Summaries) != 1 || got.Summaries[0].ShardKey != "device-A:temp" || got.Summaries[0].HourHashes[0] != "abc" {
+        t.Fatalf("expected summary to round-trip, got %+v", got)
+    }
+}
+
+func TestDiffShardSummary_NoDiffWhenIdentical(t *testing.T) {
+    records := []IndexRecord{
+        {DeviceID: "device-A", MetricName: "temp", Timestamp: 3600 * 10, Value: 1.0},
+        {DeviceID: "device-A", MetricName: "temp", Timestamp: 3600 * 10, Value: 2.0},
+    }
+    local := BuildShardSummaries(records)[ShardKey("device-A", "temp")]
+    peer := BuildShardSummaries(append([]IndexRecord{}, records...))[ShardKey("device-A", "temp")]
+
+    if diffs := DiffShardSummary(local, peer); len(diffs) != 0 {
+        t.Errorf("expected no diffs between identical summaries, got %v", diffs)
+    }
+}
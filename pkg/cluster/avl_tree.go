@@ -0,0 +1,175 @@
+package cluster
+
+// avlNode is one node of a persistent (applicative) AVL tree keyed by
+// virtual-node hash. "Persistent" here means insert/delete never mutate an
+// existing node - they return a new root built from new nodes along the
+// touched path plus shared (untouched) subtrees - so a reader holding an
+// older root sees a stable, unchanging snapshot even while the writer keeps
+// inserting and deleting.
+type avlNode struct {
+	hash        uint32
+	nodeID      string
+	left, right *avlNode
+	height      int
+}
+
+func avlHeight(n *avlNode) int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func avlBalanceFactor(n *avlNode) int {
+	if n == nil {
+		return 0
+	}
+	return avlHeight(n.left) - avlHeight(n.right)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func newAVLNode(hash uint32, nodeID string, left, right *avlNode) *avlNode {
+	return &avlNode{
+		hash:   hash,
+		nodeID: nodeID,
+		left:   left,
+		right:  right,
+		height: 1 + maxInt(avlHeight(left), avlHeight(right)),
+	}
+}
+
+func avlRotateLeft(n *avlNode) *avlNode {
+	r := n.right
+	newLeft := newAVLNode(n.hash, n.nodeID, n.left, r.left)
+	return newAVLNode(r.hash, r.nodeID, newLeft, r.right)
+}
+
+func avlRotateRight(n *avlNode) *avlNode {
+	l := n.left
+	newRight := newAVLNode(n.hash, n.nodeID, l.right, n.right)
+	return newAVLNode(l.hash, l.nodeID, l.left, newRight)
+}
+
+func avlRebalance(n *avlNode) *avlNode {
+	balance := avlBalanceFactor(n)
+
+	if balance > 1 {
+		if avlBalanceFactor(n.left) < 0 {
+			n = newAVLNode(n.hash, n.nodeID, avlRotateLeft(n.left), n.right)
+		}
+		return avlRotateRight(n)
+	}
+	if balance < -1 {
+		if avlBalanceFactor(n.right) > 0 {
+			n = newAVLNode(n.hash, n.nodeID, n.left, avlRotateRight(n.right))
+		}
+		return avlRotateLeft(n)
+	}
+
+	return n
+}
+
+// avlInsert returns a new tree with (hash, nodeID) inserted (or the owner
+// overwritten, for the astronomically unlikely case of a hash collision
+// between two virtual node keys).
+func avlInsert(n *avlNode, hash uint32, nodeID string) *avlNode {
+	if n == nil {
+		return newAVLNode(hash, nodeID, nil, nil)
+	}
+
+	switch {
+	case hash < n.hash:
+		return avlRebalance(newAVLNode(n.hash, n.nodeID, avlInsert(n.left, hash, nodeID), n.right))
+	case hash > n.hash:
+		return avlRebalance(newAVLNode(n.hash, n.nodeID, n.left, avlInsert(n.right, hash, nodeID)))
+	default:
+		return newAVLNode(hash, nodeID, n.left, n.right)
+	}
+}
+
+// avlDelete returns a new tree with hash removed. If hash isn't present the
+// result holds the same entries as n, though the nodes along the search path
+// are still copied rather than n itself being returned.
+func avlDelete(n *avlNode, hash uint32) *avlNode {
+	if n == nil {
+		return nil
+	}
+
+	switch {
+	case hash < n.hash:
+		return avlRebalance(newAVLNode(n.hash, n.nodeID, avlDelete(n.left, hash), n.right))
+	case hash > n.hash:
+		return avlRebalance(newAVLNode(n.hash, n.nodeID, n.left, avlDelete(n.right, hash)))
+	default:
+		if n.left == nil {
+			return n.right
+		}
+		if n.right == nil {
+			return n.left
+		}
+		successor := avlMin(n.right)
+		newRight := avlDelete(n.right, successor.hash)
+		return avlRebalance(newAVLNode(successor.hash, successor.nodeID, n.left, newRight))
+	}
+}
+
+func avlMin(n *avlNode) *avlNode {
+	for n.left != nil {
+		n = n.left
+	}
+	return n
+}
+
+// avlSuccessor finds the node with the smallest hash >= target, walking
+// left-ancestors the way a parentless BST search has to: every time the
+// search steps left, the node just stepped away from is a candidate
+// (everything in its left subtree is still >= target), so the last such
+// candidate seen is the answer. Wraps to the tree's minimum if target is
+// past every key in the tree.
+func avlSuccessor(root *avlNode, target uint32) *avlNode {
+	var candidate *avlNode
+	n := root
+	for n != nil {
+		if n.hash >= target {
+			candidate = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	if candidate == nil && root != nil {
+		candidate = avlMin(root)
+	}
+	return candidate
+}
+
+// avlNext returns the node with the smallest hash greater than cur's, or
+// nil if cur holds the maximum hash in the tree. Like avlSuccessor, this
+// has no parent pointers to walk, so an "ancestor on the way down" search
+// stands in for one.
+func avlNext(root *avlNode, cur *avlNode) *avlNode {
+	if cur.right != nil {
+		return avlMin(cur.right)
+	}
+
+	var successor *avlNode
+	n := root
+	for n != nil {
+		switch {
+		case cur.hash < n.hash:
+			successor = n
+			n = n.left
+		case cur.hash > n.hash:
+			n = n.right
+		default:
+			n = nil
+		}
+	}
+	return successor
+}
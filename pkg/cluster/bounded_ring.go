@@ -0,0 +1,295 @@
+package cluster
+
+import (
+    "fmt"
+    "math"
+    "sync"
+    "sync/atomic"
+)
+
+// BoundedRing wraps a ConsistentHashRing with Google's consistent-hashing-
+// with-bounded-loads algorithm: GetNode walks the ring from a key's hash
+// exactly like ConsistentHashRing.GetNode, except it skips any node whose
+// current assigned-key count has already hit its capacity - ceil(c *
+// totalKeys / numNodes) - falling through to the next node on the ring
+// until one has room. This keeps any single node from ending up with far
+// more than its fair share even when the hash of a particular key set is
+// lumpy, at the cost of a key occasionally landing on a node other than the
+// ring's raw successor.
+type BoundedRing struct {
+    ring *ConsistentHashRing
+    c    float64 // load factor, c >= 1; 1 means strictly equal load
+
+    mu        sync.Mutex
+    counts    map[string]int64 // assigned-key count per node
+    owners    map[string]string // key -> currently assigned node
+    totalKeys int64
+
+    // skips and moves are Prometheus-style monotonic counters - see Skips
+    // and Moves - incremented as GetNode runs and as ownership changes.
+    skips atomic.Uint64
+    moves atomic.Uint64
+}
+
+// NewBoundedRing creates a BoundedRing with virtualNodes per node (see
+// NewConsistentHashRing) and load factor c. c is clamped to at least 1,
+// since a factor below 1 would make every node's capacity less than its
+// fair share and no placement could ever satisfy it.
+func NewBoundedRing(virtualNodes int, c float64) *BoundedRing {
+    if c < 1 {
+        c = 1
+    }
+
+    return &BoundedRing{
+        ring:   NewConsistentHashRing(virtualNodes),
+        c:      c,
+        counts: make(map[string]int64),
+        owners: make(map[string]string),
+    }
+}
+
+// AddNode adds nodeID to the ring and reassigns every key BoundedRing has
+// placed so far against the new membership, returning the ones that moved
+// to a different node - see Diff. If nodeID is already present nothing
+// changes and no moves are returned.
+func (br *BoundedRing) AddNode(nodeID string) []KeyMove {
+    before := br.ring.Snapshot()
+    br.ring.AddNode(nodeID)
+    after := br.ring.Snapshot()
+    return br.rebalance(before, after)
+}
+
+// RemoveNode removes nodeID from the ring and reassigns every key
+// BoundedRing has placed so far against the new membership, returning the
+// ones that moved to a different node - see Diff.
+func (br *BoundedRing) RemoveNode(nodeID string) []KeyMove {
+    before := br.ring.Snapshot()
+    br.ring.RemoveNode(nodeID)
+    after := br.ring.Snapshot()
+
+    br.mu.Lock()
+    delete(br.counts, nodeID)
+    br.mu.Unlock()
+
+    return br.rebalance(before, after)
+}
+
+// rebalance recomputes ownership, against after, for every key already
+// tracked in br.owners whose owner might have changed: the keys whose raw
+// ring successor differs between before and after (see Diff), plus any key
+// whose owner is no longer on the ring - a key a capacity skip placed on a
+// node that then left keeps its raw successor, so Diff alone misses it. Each
+// candidate is re-run through assignViaWalkLocked to get its actual,
+// capacity-aware placement, and the returned KeyMove.To reflects that, not
+// Diff's raw Successor. Otherwise a node already at capacity could make
+// assignViaWalkLocked land a key somewhere Diff never reported (or leave it
+// on its original node entirely), and a caller streaming data to KeyMove.To
+// would send it to the wrong place. From likewise comes from br.owners, the
+// key's actual prior assignment, rather than Diff's raw old successor.
+func (br *BoundedRing) rebalance(before, after RingView) []KeyMove {
+    live := make(map[string]bool)
+    for _, nodeID := range br.ring.GetAllNodes() {
+        live[nodeID] = true
+    }
+
+    br.mu.Lock()
+    keys := make([]string, 0, len(br.owners))
+    var orphaned []KeyMove
+    for key, owner := range br.owners {
+        keys = append(keys, key)
+        if !live[owner] {
+            orphaned = append(orphaned, KeyMove{Key: key})
+        }
+    }
+    br.mu.Unlock()
+
+    candidates := append(Diff(before, after, keys), orphaned...)
+    moves := make([]KeyMove, 0, len(candidates))
+    for _, c := range candidates {
+        br.mu.Lock()
+        from := br.owners[c.Key]
+        if c.From == "" && live[from] {
+            // Already re-placed as one of Diff's candidates.
+            br.mu.Unlock()
+            continue
+        }
+        to, err := br.assignViaWalkLocked(c.Key, after)
+        br.mu.Unlock()
+        if err != nil || to == from {
+            continue
+        }
+        moves = append(moves, KeyMove{Key: c.Key, From: from, To: to})
+    }
+    return moves
+}
+
+// capacity returns the maximum number of keys a node may currently hold:
+// ceil(c * totalKeys / numNodes). Capacity is 0 (unbounded) until at least
+// one key has been placed, since there's nothing yet to divide fairly.
+func (br *BoundedRing) capacity(numNodes int) int64 {
+    if numNodes == 0 || br.totalKeys == 0 {
+        return 0
+    }
+    return int64(math.Ceil(br.c * float64(br.totalKeys) / float64(numNodes)))
+}
+
+// GetNode returns the node key is assigned to. Once a key has been placed,
+// GetNode keeps returning that same node - it does not re-walk the ring on
+// every call, since capacity can shift underneath it as other keys are
+// assigned and a key bouncing between nodes on every lookup would defeat
+// the point of consistent hashing. A key's placement is only reconsidered
+// when ring membership changes, via AddNode/RemoveNode's rebalance. For a
+// key seen for the first time, GetNode walks the ring from its hash,
+// skipping any node already at capacity until one has room; if every node
+// is at capacity - which can happen when c is 1 and the keys placed so far
+// are spread exactly evenly - it falls back to the ring's raw successor
+// rather than fail the lookup.
+func (br *BoundedRing) GetNode(key string) (string, error) {
+    view := br.ring.Snapshot()
+    if view.nodeCount == 0 {
+        return "", fmt.Errorf("no nodes in ring")
+    }
+
+    br.mu.Lock()
+    defer br.mu.Unlock()
+
+    if owner, ok := br.owners[key]; ok {
+        return owner, nil
+    }
+
+    return br.assignViaWalkLocked(key, view)
+}
+
+// assignViaWalkLocked performs the capacity-aware ring walk described on
+// GetNode and assigns key to the first node found with room, ignoring any
+// existing owners entry for key - used by GetNode for a never-before-seen
+// key, and by rebalance to force a fresh placement for a key whose raw-ring
+// owner changed. br.mu must already be held.
+func (br *BoundedRing) assignViaWalkLocked(key string, view RingView) (string, error) {
+    limit := br.capacity(view.nodeCount)
+
+    cur := avlSuccessor(view.root, hashKey(key))
+    if cur == nil {
+        return "", fmt.Errorf("no nodes in ring")
+    }
+
+    seen := make(map[string]bool, view.nodeCount)
+    for len(seen) < view.nodeCount {
+        if !seen[cur.nodeID] {
+            seen[cur.nodeID] = true
+            if limit == 0 || br.counts[cur.nodeID] < limit {
+                br.assignLocked(key, cur.nodeID)
+                return cur.nodeID, nil
+            }
+            br.skips.Add(1)
+        }
+
+        next := avlNext(view.root, cur)
+        if next == nil {
+            next = avlMin(view.root)
+        }
+        if next == cur {
+            break
+        }
+        cur = next
+    }
+
+    nodeID, ok := view.Successor(hashKey(key))
+    if !ok {
+        return "", fmt.Errorf("no nodes in ring")
+    }
+    br.assignLocked(key, nodeID)
+    return nodeID, nil
+}
+
+// assignLocked records key as owned by nodeID, releasing any previous
+// owner's slot first and bumping moves if ownership actually changed. br.mu
+// must already be held.
+func (br *BoundedRing) assignLocked(key, nodeID string) {
+    prev, ok := br.owners[key]
+    if ok {
+        if prev == nodeID {
+            return
+        }
+        br.counts[prev]--
+        br.moves.Add(1)
+    } else {
+        br.totalKeys++
+    }
+    br.owners[key] = nodeID
+    br.counts[nodeID]++
+}
+
+// GetNodes returns the primary plus count-1 successor nodes for key, read
+// from the raw underlying ring rather than the capacity-aware assignment
+// GetNode tracks: bounding load only makes sense for a key's single owner,
+// not the whole replica set, so replica placement follows ring topology the
+// same way ConsistentHashRing.GetNodes does.
+func (br *BoundedRing) GetNodes(key string, count int) ([]string, error) {
+    return br.ring.GetNodes(key, count)
+}
+
+// GetReplicas is GetNodes under the name callers coordinating AP-style
+// replication reach for - see ConsistentHashRing.GetReplicas.
+func (br *BoundedRing) GetReplicas(key string, count int) ([]string, error) {
+    return br.GetNodes(key, count)
+}
+
+// GetAllNodes returns every node currently on the ring.
+func (br *BoundedRing) GetAllNodes() []string {
+    return br.ring.GetAllNodes()
+}
+
+// Release forgets key's current assignment, decrementing its owning node's
+// counter. Callers should call this once a key's data is deleted or
+// otherwise no longer needs a home on the ring, so its slot doesn't count
+// against that node's capacity forever.
+func (br *BoundedRing) Release(key string) {
+    br.mu.Lock()
+    defer br.mu.Unlock()
+
+    nodeID, ok := br.owners[key]
+    if !ok {
+        return
+    }
+    delete(br.owners, key)
+    br.counts[nodeID]--
+    br.totalKeys--
+}
+
+// Skips is a Prometheus-style monotonic counter of the number of times
+// GetNode passed over a node because it was already at capacity.
+func (br *BoundedRing) Skips() uint64 { return br.skips.Load() }
+
+// Moves is a Prometheus-style monotonic counter of the number of times a
+// key's assignment changed to a different node.
+func (br *BoundedRing) Moves() uint64 { return br.moves.Load() }
+
+// KeyMove describes a single key whose owner differs between two ring
+// snapshots - see Diff.
+type KeyMove struct {
+    Key  string
+    From string
+    To   string
+}
+
+// Diff compares two RingView snapshots of the same ring - typically taken
+// just before and after an AddNode/RemoveNode - against keys, and returns
+// the ones whose owner differs between the two, so a caller (e.g. ingest)
+// can proactively stream just those series to their new primary instead of
+// relying on lazy re-routing the next time each key is written or queried.
+// A key present in keys but absent from either snapshot (an empty ring) is
+// skipped rather than reported as a move.
+func Diff(old, new RingView, keys []string) []KeyMove {
+    var moves []KeyMove
+    for _, key := range keys {
+        target := hashKey(key)
+        oldOwner, oldOk := old.Successor(target)
+        newOwner, newOk := new.Successor(target)
+        if !oldOk || !newOk || oldOwner == newOwner {
+            continue
+        }
+        moves = append(moves, KeyMove{Key: key, From: oldOwner, To: newOwner})
+    }
+    return moves
+}
@@ -0,0 +1,196 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBoundedRing_GetNode(t *testing.T) {
+	br := NewBoundedRing(150, 1.25)
+
+	if _, err := br.GetNode("test-key"); err == nil {
+		t.Error("expected error when ring is empty")
+	}
+
+	br.AddNode("node-1")
+	br.AddNode("node-2")
+	br.AddNode("node-3")
+
+	node1, err := br.GetNode("device-001")
+	if err != nil {
+		t.Fatalf("GetNode failed: %v", err)
+	}
+	node2, err := br.GetNode("device-001")
+	if err != nil {
+		t.Fatalf("GetNode failed: %v", err)
+	}
+	if node1 != node2 {
+		t.Errorf("same key should keep mapping to the same node once assigned: %s vs %s", node1, node2)
+	}
+}
+
+func TestBoundedRing_RespectsCapacity(t *testing.T) {
+	br := NewBoundedRing(150, 1.0)
+
+	br.AddNode("node-1")
+	br.AddNode("node-2")
+	br.AddNode("node-3")
+
+	numKeys := 3000
+	counts := make(map[string]int)
+	for i := 0; i < numKeys; i++ {
+		node, err := br.GetNode(fmt.Sprintf("device-%d", i))
+		if err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+		counts[node]++
+	}
+
+	limit := int(float64(numKeys)/3.0*1.0 + 1) // ceil(c*total/numNodes), with rounding slack
+	for node, count := range counts {
+		if count > limit {
+			t.Errorf("node %s holds %d keys, want <= %d (load factor 1.0)", node, count, limit)
+		}
+	}
+	if br.Skips() == 0 {
+		t.Error("expected at least one skip with a tight load factor and this many keys")
+	}
+}
+
+func TestBoundedRing_Release(t *testing.T) {
+	br := NewBoundedRing(150, 1.25)
+	br.AddNode("node-1")
+	br.AddNode("node-2")
+
+	node, err := br.GetNode("device-001")
+	if err != nil {
+		t.Fatalf("GetNode failed: %v", err)
+	}
+
+	br.mu.Lock()
+	before := br.counts[node]
+	br.mu.Unlock()
+
+	br.Release("device-001")
+
+	br.mu.Lock()
+	after := br.counts[node]
+	totalKeys := br.totalKeys
+	br.mu.Unlock()
+
+	if after != before-1 {
+		t.Errorf("expected node's count to drop by 1 after Release, got %d -> %d", before, after)
+	}
+	if totalKeys != 0 {
+		t.Errorf("expected totalKeys to drop to 0 after releasing the only key, got %d", totalKeys)
+	}
+}
+
+func TestBoundedRing_AddRemoveReportsMoves(t *testing.T) {
+	br := NewBoundedRing(150, 1.25)
+	br.AddNode("node-1")
+	br.AddNode("node-2")
+	br.AddNode("node-3")
+
+	for i := 0; i < 500; i++ {
+		if _, err := br.GetNode(fmt.Sprintf("device-%d", i)); err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+	}
+
+	moved := br.RemoveNode("node-2")
+	if len(moved) == 0 {
+		t.Error("expected at least one key to move after removing a node")
+	}
+	for _, mv := range moved {
+		if mv.From != "node-2" && mv.To != "node-2" {
+			// node-2 leaving can also cascade into moves between the
+			// remaining nodes once capacity is recomputed over fewer nodes.
+			continue
+		}
+	}
+}
+
+func TestBoundedRing_ReportedMoveToMatchesActualPostRebalanceOwner(t *testing.T) {
+	br := NewBoundedRing(150, 1.0)
+	br.AddNode("node-1")
+	br.AddNode("node-2")
+
+	for i := 0; i < 500; i++ {
+		if _, err := br.GetNode(fmt.Sprintf("device-%d", i)); err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+	}
+
+	// A tight load factor (c == 1) makes it likely that at least one key
+	// Diff's raw successor reassigns to node-3 is actually capacity-skipped
+	// to a different node by assignViaWalkLocked.
+	moved := br.AddNode("node-3")
+	if len(moved) == 0 {
+		t.Fatal("expected at least one key to move after adding a node")
+	}
+
+	for _, mv := range moved {
+		actual, err := br.GetNode(mv.Key)
+		if err != nil {
+			t.Fatalf("GetNode(%q) failed: %v", mv.Key, err)
+		}
+		if actual != mv.To {
+			t.Errorf("KeyMove{%q}.To = %q, but GetNode now reports %q", mv.Key, mv.To, actual)
+		}
+	}
+}
+
+func TestBoundedRing_GetNodesAndGetAllNodes(t *testing.T) {
+	br := NewBoundedRing(150, 1.25)
+	br.AddNode("node-1")
+	br.AddNode("node-2")
+	br.AddNode("node-3")
+
+	all := br.GetAllNodes()
+	if len(all) != 3 {
+		t.Fatalf("GetAllNodes() = %v, want 3 nodes", all)
+	}
+
+	nodes, err := br.GetNodes("device-001", 2)
+	if err != nil {
+		t.Fatalf("GetNodes failed: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("GetNodes returned %d nodes, want 2", len(nodes))
+	}
+	if nodes[0] != nodes[1] {
+		replicas, err := br.GetReplicas("device-001", 2)
+		if err != nil {
+			t.Fatalf("GetReplicas failed: %v", err)
+		}
+		if replicas[0] != nodes[0] || replicas[1] != nodes[1] {
+			t.Errorf("GetReplicas = %v, want same as GetNodes %v", replicas, nodes)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	ring := NewConsistentHashRing(150)
+	ring.AddNode("node-1")
+	ring.AddNode("node-2")
+	before := ring.Snapshot()
+
+	ring.AddNode("node-3")
+	after := ring.Snapshot()
+
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("device-%d", i)
+	}
+
+	moves := Diff(before, after, keys)
+	if len(moves) == 0 {
+		t.Error("expected at least one key to move after adding a node")
+	}
+	for _, mv := range moves {
+		if mv.From == mv.To {
+			t.Errorf("Diff reported a move with identical From/To: %+v", mv)
+		}
+	}
+}
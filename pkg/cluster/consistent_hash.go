@@ -3,26 +3,29 @@ package cluster
 import (
     "fmt"
     "hash/crc32"
-    "sort"
     "sync"
 )
 
+// ConsistentHashRing maps keys to nodes via virtual-node hashes stored in a
+// persistent AVL tree (see avl_tree.go) instead of a sorted slice: each of
+// the virtualNodes inserts or deletes an AddNode/RemoveNode performs builds a
+// new root in O(log V) without copying the V virtual-node entries, and a
+// RingView snapshot lets a reader do many
+// lookups against a stable tree without re-acquiring the mutex per call.
 type ConsistentHashRing struct {
-    ring         map[uint32]string 
-    sortedHashes []uint32           
-    virtualNodes int                
-    nodes        map[string]bool    
-    mu           sync.RWMutex       
+    root         *avlNode
+    virtualNodes int
+    nodes        map[string]bool
+    virtualCount int // number of virtual-node entries currently in root, for introspection/tests
+    mu           sync.RWMutex
 }
 
 func NewConsistentHashRing(virtualNodes int) *ConsistentHashRing {
     if virtualNodes <= 0 {
-        virtualNodes = 150 
+        virtualNodes = 150
     }
-    
+
     return &ConsistentHashRing{
-        ring:         make(map[uint32]string),
-        sortedHashes: make([]uint32, 0),
         virtualNodes: virtualNodes,
         nodes:        make(map[string]bool),
     }
@@ -33,21 +36,18 @@ func (chr *ConsistentHashRing) AddNode(nodeID string) {
     defer chr.mu.Unlock()
 
     if chr.nodes[nodeID] {
-        return 
+        return
     }
 
     chr.nodes[nodeID] = true
 
+    root := chr.root
     for i := 0; i < chr.virtualNodes; i++ {
         virtualKey := fmt.Sprintf("%s#%d", nodeID, i)
-        hash := chr.hashKey(virtualKey)
-        chr.ring[hash] = nodeID
-        chr.sortedHashes = append(chr.sortedHashes, hash)
+        root = avlInsert(root, hashKey(virtualKey), nodeID)
     }
-
-    sort.Slice(chr.sortedHashes, func(i, j int) bool {
-        return chr.sortedHashes[i] < chr.sortedHashes[j]
-    })
+    chr.root = root
+    chr.virtualCount += chr.virtualNodes
 }
 
 func (chr *ConsistentHashRing) RemoveNode(nodeID string) {
@@ -60,76 +60,40 @@ func (chr *ConsistentHashRing) RemoveNode(nodeID string) {
 
     delete(chr.nodes, nodeID)
 
-    newHashes := make([]uint32, 0)
-    for _, hash := range chr.sortedHashes {
-        if chr.ring[hash] != nodeID {
-            newHashes = append(newHashes, hash)
-        } else {
-            delete(chr.ring, hash)
-        }
+    root := chr.root
+    for i := 0; i < chr.virtualNodes; i++ {
+        virtualKey := fmt.Sprintf("%s#%d", nodeID, i)
+        root = avlDelete(root, hashKey(virtualKey))
     }
-
-    chr.sortedHashes = newHashes
+    chr.root = root
+    chr.virtualCount -= chr.virtualNodes
 }
 
-func (chr *ConsistentHashRing) GetNode(key string) (string, error) {
+// Snapshot returns an immutable view of the ring's current placement state.
+// Because avlInsert/avlDelete never mutate existing nodes, the returned
+// RingView stays valid - and lock-free to read - no matter how many more
+// writes land on chr afterward.
+func (chr *ConsistentHashRing) Snapshot() RingView {
     chr.mu.RLock()
     defer chr.mu.RUnlock()
+    return RingView{root: chr.root, nodeCount: len(chr.nodes)}
+}
 
-    if len(chr.ring) == 0 {
-        return "", fmt.Errorf("no nodes in ring")
-    }
-
-    hash := chr.hashKey(key)
-
-    idx := sort.Search(len(chr.sortedHashes), func(i int) bool {
-        return chr.sortedHashes[i] >= hash
-    })
-
-    if idx == len(chr.sortedHashes) {
-        idx = 0
-    }
-
-    return chr.ring[chr.sortedHashes[idx]], nil
+func (chr *ConsistentHashRing) GetNode(key string) (string, error) {
+    return chr.Snapshot().GetNode(key)
 }
 
 func (chr *ConsistentHashRing) GetNodes(key string, count int) ([]string, error) {
-    chr.mu.RLock()
-    defer chr.mu.RUnlock()
-
-    if len(chr.nodes) == 0 {
-        return nil, fmt.Errorf("no nodes in ring")
-    }
-
-    if count > len(chr.nodes) {
-        count = len(chr.nodes)
-    }
-
-    hash := chr.hashKey(key)
-
-    idx := sort.Search(len(chr.sortedHashes), func(i int) bool {
-        return chr.sortedHashes[i] >= hash
-    })
-
-    if idx == len(chr.sortedHashes) {
-        idx = 0
-    }
-
-    nodesMap := make(map[string]bool)
-    nodes := make([]string, 0, count)
-
-    for len(nodes) < count && len(nodesMap) < len(chr.nodes) {
-        nodeID := chr.ring[chr.sortedHashes[idx]]
-        if !nodesMap[nodeID] {
-            nodesMap[nodeID] = true
-            nodes = append(nodes, nodeID)
-        }
-        idx = (idx + 1) % len(chr.sortedHashes)
-    }
-
-    return nodes, nil
+    return chr.Snapshot().GetNodes(key, count)
 }
 
+// GetReplicas returns the primary plus count-1 successor nodes for key - the
+// full replica set a write should fan out to and read-repair should compare
+// across. It is GetNodes under the name callers coordinating AP-style
+// replication (see internal/cluster.ClusterManager.GetReplicas) reach for.
+func (chr *ConsistentHashRing) GetReplicas(key string, count int) ([]string, error) {
+    return chr.GetNodes(key, count)
+}
 
 func (chr *ConsistentHashRing) GetAllNodes() []string {
     chr.mu.RLock()
@@ -143,7 +107,7 @@ func (chr *ConsistentHashRing) GetAllNodes() []string {
     return nodes
 }
 
-func (chr *ConsistentHashRing) hashKey(key string) uint32 {
+func hashKey(key string) uint32 {
     return crc32.ChecksumIEEE([]byte(key))
 }
 
@@ -151,4 +115,66 @@ func (chr *ConsistentHashRing) Size() int {
     chr.mu.RLock()
     defer chr.mu.RUnlock()
     return len(chr.nodes)
-}
\ No newline at end of file
+}
+
+// RingView is a read-only, lock-free snapshot of a ConsistentHashRing taken
+// via Snapshot. Callers doing many lookups in a loop should take one
+// RingView up front rather than calling the ring's own GetNode/GetNodes
+// repeatedly, each of which takes a fresh snapshot under the mutex.
+type RingView struct {
+    root      *avlNode
+    nodeCount int
+}
+
+// Successor returns the owning node ID for the first virtual-node hash >=
+// target, wrapping to the ring's minimum hash if target is past the
+// maximum. ok is false only when the view covers an empty ring.
+func (rv RingView) Successor(target uint32) (nodeID string, ok bool) {
+    n := avlSuccessor(rv.root, target)
+    if n == nil {
+        return "", false
+    }
+    return n.nodeID, true
+}
+
+func (rv RingView) GetNode(key string) (string, error) {
+    nodeID, ok := rv.Successor(hashKey(key))
+    if !ok {
+        return "", fmt.Errorf("no nodes in ring")
+    }
+    return nodeID, nil
+}
+
+func (rv RingView) GetNodes(key string, count int) ([]string, error) {
+    if rv.nodeCount == 0 {
+        return nil, fmt.Errorf("no nodes in ring")
+    }
+    if count > rv.nodeCount {
+        count = rv.nodeCount
+    }
+
+    cur := avlSuccessor(rv.root, hashKey(key))
+    if cur == nil {
+        return nil, fmt.Errorf("no nodes in ring")
+    }
+
+    seen := make(map[string]bool)
+    result := make([]string, 0, count)
+    for len(result) < count && len(seen) < rv.nodeCount {
+        if !seen[cur.nodeID] {
+            seen[cur.nodeID] = true
+            result = append(result, cur.nodeID)
+        }
+
+        next := avlNext(rv.root, cur)
+        if next == nil {
+            next = avlMin(rv.root)
+        }
+        if next == cur {
+            break
+        }
+        cur = next
+    }
+
+    return result, nil
+}
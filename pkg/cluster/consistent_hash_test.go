@@ -30,9 +30,9 @@ func TestConsistentHashRing_AddNode(t *testing.T) {
     
     // Check virtual nodes created
     expectedVirtualNodes := 3 * 10 // 3 nodes * 10 virtual nodes each
-    if len(ring.sortedHashes) != expectedVirtualNodes {
+    if ring.virtualCount != expectedVirtualNodes {
         t.Errorf("Expected %d virtual nodes, got %d", 
-                 expectedVirtualNodes, len(ring.sortedHashes))
+                 expectedVirtualNodes, ring.virtualCount)
     }
     
     // Test adding duplicate node (should not increase count)
@@ -52,7 +52,7 @@ func TestConsistentHashRing_RemoveNode(t *testing.T) {
     ring.AddNode("node-3")
     
     initialSize := ring.Size()
-    initialVirtualNodes := len(ring.sortedHashes)
+    initialVirtualNodes := ring.virtualCount
     
     // Remove node
     ring.RemoveNode("node-2")
@@ -62,9 +62,9 @@ func TestConsistentHashRing_RemoveNode(t *testing.T) {
     }
     
     expectedVirtualNodes := initialVirtualNodes - 10 // Removed 10 virtual nodes
-    if len(ring.sortedHashes) != expectedVirtualNodes {
+    if ring.virtualCount != expectedVirtualNodes {
         t.Errorf("Expected %d virtual nodes after removal, got %d",
-                 expectedVirtualNodes, len(ring.sortedHashes))
+                 expectedVirtualNodes, ring.virtualCount)
     }
     
     // Test removing non-existent node (should not cause error)
@@ -157,6 +157,35 @@ func TestConsistentHashRing_GetNodes(t *testing.T) {
     }
 }
 
+// TestConsistentHashRing_GetReplicas checks that GetReplicas agrees with
+// GetNodes, since it's meant to be the same replica-set lookup under the
+// name callers coordinating replication reach for.
+func TestConsistentHashRing_GetReplicas(t *testing.T) {
+    ring := NewConsistentHashRing(150)
+
+    ring.AddNode("node-1")
+    ring.AddNode("node-2")
+    ring.AddNode("node-3")
+
+    replicas, err := ring.GetReplicas("device-001", 2)
+    if err != nil {
+        t.Fatalf("GetReplicas failed: %v", err)
+    }
+    nodes, err := ring.GetNodes("device-001", 2)
+    if err != nil {
+        t.Fatalf("GetNodes failed: %v", err)
+    }
+
+    if len(replicas) != len(nodes) {
+        t.Fatalf("GetReplicas returned %d nodes, GetNodes returned %d", len(replicas), len(nodes))
+    }
+    for i := range replicas {
+        if replicas[i] != nodes[i] {
+            t.Errorf("GetReplicas[%d] = %s, want %s", i, replicas[i], nodes[i])
+        }
+    }
+}
+
 // TestConsistentHashRing_Distribution tests distribution quality
 func TestConsistentHashRing_Distribution(t *testing.T) {
     ring := NewConsistentHashRing(150)
@@ -297,7 +326,7 @@ func TestConsistentHashRing_VirtualNodes(t *testing.T) {
             ring := NewConsistentHashRing(tc.virtualNodes)
             ring.AddNode("node-1")
             
-            actualVirtual := len(ring.sortedHashes)
+            actualVirtual := ring.virtualCount
             if actualVirtual != tc.expected {
                 t.Errorf("Expected %d virtual nodes, got %d", tc.expected, actualVirtual)
             }
@@ -344,50 +373,36 @@ func TestConsistentHashRing_GetAllNodes(t *testing.T) {
     }
 }
 
-// TestConsistentHashRing_Concurrent tests thread safety
-func TestConsistentHashRing_Concurrent(t *testing.T) {
+// BenchmarkConsistentHashRing_ConcurrentChurn replaces the old
+// TestConsistentHashRing_Concurrent: instead of just checking that
+// concurrent Add/Remove/GetNode/GetNodes don't deadlock, it measures
+// throughput under that same mixed churn. There's no sorted-slice
+// implementation left to compare against - the AVL-backed ring replaced it
+// outright in this package - so this reports the new implementation's
+// numbers on their own rather than a side-by-side ratio.
+func BenchmarkConsistentHashRing_ConcurrentChurn(b *testing.B) {
     ring := NewConsistentHashRing(150)
-    
-    // Pre-populate with some nodes
     ring.AddNode("node-1")
     ring.AddNode("node-2")
     ring.AddNode("node-3")
-    
-    // Run concurrent operations
-    done := make(chan bool, 3)
-    
-    // Goroutine 1: Keep adding and removing nodes
-    go func() {
-        for i := 0; i < 100; i++ {
-            ring.AddNode(fmt.Sprintf("temp-node-%d", i))
-            ring.RemoveNode(fmt.Sprintf("temp-node-%d", i))
-        }
-        done <- true
-    }()
-    
-    // Goroutine 2: Keep querying
-    go func() {
-        for i := 0; i < 1000; i++ {
-            ring.GetNode(fmt.Sprintf("device-%d", i))
-        }
-        done <- true
-    }()
-    
-    // Goroutine 3: Keep getting multiple nodes
-    go func() {
-        for i := 0; i < 1000; i++ {
-            ring.GetNodes(fmt.Sprintf("device-%d", i), 3)
+
+    b.ResetTimer()
+    b.RunParallel(func(pb *testing.PB) {
+        i := 0
+        for pb.Next() {
+            switch i % 3 {
+            case 0:
+                name := fmt.Sprintf("churn-node-%d", i)
+                ring.AddNode(name)
+                ring.RemoveNode(name)
+            case 1:
+                ring.GetNode(fmt.Sprintf("device-%d", i))
+            default:
+                ring.GetNodes(fmt.Sprintf("device-%d", i), 3)
+            }
+            i++
         }
-        done <- true
-    }()
-    
-    // Wait for all goroutines
-    <-done
-    <-done
-    <-done
-    
-    // If we reach here without deadlock or panic, test passes
-    t.Log("Concurrent operations completed successfully")
+    })
 }
 
 // TestConsistentHashRing_EdgeCases tests edge cases
@@ -0,0 +1,64 @@
+package cluster
+
+import "github.com/minitrue/pkg/models"
+
+// Delegate receives membership change notifications from GossipProtocol and
+// supplies/merges opaque application state piggybacked on full gossip
+// syncs, analogous to memberlist's EventDelegate/Delegate pair. Without one,
+// HandleGossipMessage and HandleGossipPacket only mutate clusterState and
+// log; a registered Delegate lets higher-level subsystems
+// (replication, routing) react to membership changes without polling
+// GetClusterState on their own timer.
+//
+// Callbacks are invoked from a goroutine that holds none of GossipProtocol's
+// locks, so a Delegate is free to call back into the GossipProtocol that
+// invoked it (e.g. GetClusterState, Refute) without risking deadlock.
+type Delegate interface {
+    // OnNodeJoin fires when a node is seen for the first time.
+    OnNodeJoin(node *models.NodeInfo)
+    // OnNodeLeave fires when failure detection or enough suspicion
+    // confirmations move a node to "down" on this node. A "down" status
+    // carried in a peer's gossiped node state arrives through OnNodeUpdate
+    // instead.
+    OnNodeLeave(node *models.NodeInfo)
+    // OnNodeUpdate fires when gossip changes an already-known node's state:
+    // a newer heartbeat or incarnation (with whatever status it carries,
+    // "suspect" and "down" included) or a refutation that brings it back to
+    // "active".
+    OnNodeUpdate(node *models.NodeInfo)
+    // OnNodeSuspect fires when a suspicion moves a node from "active" to
+    // "suspect" on this node. A "suspect" status carried in a peer's
+    // gossiped node state arrives through OnNodeUpdate instead.
+    OnNodeSuspect(node *models.NodeInfo)
+
+    // LocalState returns an opaque blob piggybacked on this node's outgoing
+    // full ClusterState syncs (see sendFullSync, pushFullSyncTo,
+    // AddSeedNode). Called outside any GossipProtocol lock.
+    LocalState() []byte
+    // MergeRemoteState is called with the AppState blob from an inbound
+    // GossipMessage, and the ID of the node that sent it. Called outside
+    // any GossipProtocol lock. data is nil/empty when the sender has no
+    // Delegate registered or its LocalState() returned nothing.
+    MergeRemoteState(from string, data []byte)
+}
+
+// RegisterDelegate installs d as the GossipProtocol's Delegate, replacing
+// any previously registered one. Must be called before Start for the
+// initial full sync this node sends to carry LocalState().
+func (gp *GossipProtocol) RegisterDelegate(d Delegate) {
+    gp.mu.Lock()
+    defer gp.mu.Unlock()
+    gp.delegate = d
+}
+
+// fireNodeEventLocked spawns fn(delegate, nodeCopy) in its own goroutine if
+// a Delegate is registered, so notifying it never blocks (or deadlocks) the
+// caller, which typically holds gp.mu. Callers must hold gp.mu.
+func (gp *GossipProtocol) fireNodeEventLocked(node *models.NodeInfo, fn func(d Delegate, n *models.NodeInfo)) {
+    if gp.delegate == nil {
+        return
+    }
+    nodeCopy := *node
+    delegate := gp.delegate
+    go fn(delegate, &nodeCopy)
+}
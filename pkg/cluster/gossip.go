@@ -3,15 +3,56 @@ package cluster
 import (
     "encoding/json"
     "fmt"
-    "log"
+    "math"
     "math/rand"
+    "net"
     "sync"
     "time"
 
+    "github.com/minitrue/pkg/log"
     "github.com/minitrue/pkg/models"
     "github.com/minitrue/pkg/network"
 )
 
+const (
+    // maxAwareness bounds the Lifeguard-style awareness score. A higher
+    // score means the local node has recently seen more missed
+    // probes/sends, and scales effective timeouts up proportionally so a
+    // node under transient network stress doesn't falsely convict peers
+    // (or get convicted itself).
+    maxAwareness = 8
+
+    // suspicionConfirmations is how many independent members (the local
+    // node included) must suspect a node before it is promoted to "down".
+    // A single suspicion only flips a node to "suspect"; the absolute
+    // suspectTimeout backstop in detectFailures still applies regardless
+    // of confirmation count.
+    suspicionConfirmations = 2
+
+    // retransmitMult tunes how many times a queued delta is retransmitted
+    // before being dropped: ceil(log(N+1) * retransmitMult), the same
+    // shape memberlist uses so retransmission count grows with cluster
+    // size instead of being fixed.
+    retransmitMult = 3
+
+    // maxGossipPacketBytes bounds a single outgoing UDP gossip packet to
+    // roughly one Ethernet MTU, so it's unlikely to fragment.
+    maxGossipPacketBytes = 1400
+
+    // fullSyncEveryNTicks is how often (in gossip intervals) this node
+    // pushes its entire ClusterState to one random peer over TCP, as a
+    // anti-entropy backstop for whatever the bounded UDP delta queue
+    // dropped or never delivered.
+    fullSyncEveryNTicks = 10
+)
+
+// broadcastItem is one queued delta awaiting piggyback onto outgoing
+// gossip packets, along with how many more times it may be retransmitted.
+type broadcastItem struct {
+    delta      models.GossipDelta
+    retransmit int
+}
+
 type GossipProtocol struct {
     localNode      *models.NodeInfo
     clusterState   *models.ClusterState
@@ -20,23 +61,85 @@ type GossipProtocol struct {
     suspectTimeout time.Duration
     mu             sync.RWMutex
     client         *network.Client
+    packetServer   *network.PacketServer
+    pool           *network.Pool
     stopChan       chan struct{}
+    logger         log.Logger
+
+    // awareness is the local Lifeguard score (0..maxAwareness). Effective
+    // suspect/probe timeouts are scaled by (awareness+1).
+    awareness int
+
+    // suspicionConfirms tracks, per suspected node, the set of member IDs
+    // that have independently suspected it.
+    suspicionConfirms map[string]map[string]bool
+
+    // pendingSuspicions/pendingRefutations are drained and piggybacked
+    // onto the next outgoing gossip packet.
+    pendingSuspicions  []models.SuspicionMessage
+    pendingRefutations []models.RefutationMessage
+
+    // versions is the per-node version vector: the highest GossipDelta
+    // version this node has applied for each nodeID. Bumped every time a
+    // node's state changes locally (see queueDeltaLocked) or a newer
+    // delta is applied from a peer.
+    versions map[string]int64
+
+    // broadcastQueue holds deltas awaiting piggyback onto outgoing UDP
+    // gossip packets, bounded in send-count (not size) by retransmit.
+    broadcastQueue []*broadcastItem
+
+    tickCount int
+
+    // delegate, if registered via RegisterDelegate, is notified of
+    // membership changes and consulted for LocalState()/MergeRemoteState().
+    delegate Delegate
+
+    // keyring, if installed via SetKeyring, encrypts every outgoing gossip
+    // send and is consulted to decrypt/rotate on receipt of a
+    // KeyRotationHint.
+    keyring *network.Keyring
+
+    // pendingKeyRotation/keyRotationRetransmit mirror broadcastItem's
+    // retransmit-bounded piggyback, but for the single in-flight key
+    // rotation hint (see BroadcastKeyRotation) rather than a per-node
+    // delta.
+    pendingKeyRotation    *models.KeyRotationHint
+    keyRotationRetransmit int
 }
 
-func NewGossipProtocol(localNode *models.NodeInfo, interval time.Duration, 
-                       client *network.Client, replicationFactor int) *GossipProtocol {
-    return &GossipProtocol{
+func NewGossipProtocol(localNode *models.NodeInfo, interval time.Duration,
+                       client *network.Client, replicationFactor int, logger log.Logger) *GossipProtocol {
+    if logger == nil {
+        logger = log.Nop()
+    }
+    gp := &GossipProtocol{
         localNode:      localNode,
         clusterState: &models.ClusterState{
             Nodes:             make(map[string]*models.NodeInfo),
             ReplicationFactor: replicationFactor,
             Version:           0,
         },
-        interval:       interval,
-        suspectTimeout: interval * 5,
-        client:         client,
-        stopChan:       make(chan struct{}),
+        interval:          interval,
+        suspectTimeout:    interval * 5,
+        client:            client,
+        stopChan:          make(chan struct{}),
+        suspicionConfirms: make(map[string]map[string]bool),
+        versions:          make(map[string]int64),
+        logger:            logger.With("node", localNode.ID),
     }
+    // pool carries this node's outgoing TCP gossip sends (see
+    // sendGossipToNode): it consults gp itself (a HealthSource) to skip
+    // peers already known suspect/down, and feeds every send's outcome
+    // back into gp's own Lifeguard awareness/suspicion bookkeeping via
+    // recordSendOutcome, replacing the naive "mark suspect on any send
+    // error" that network.Client alone gave no room to improve on.
+    gp.pool = network.NewPool(interval*2,
+        network.WithHealthSource(gp),
+        network.WithOutcomeCallback(gp.recordSendOutcome),
+        network.WithPoolLogger(gp.logger),
+    )
+    return gp
 }
 
 func (gp *GossipProtocol) Start() {
@@ -44,18 +147,88 @@ func (gp *GossipProtocol) Start() {
     gp.localNode.LastHeartbeat = time.Now()
     gp.localNode.Status = "active"
     gp.clusterState.Nodes[gp.localNode.ID] = gp.localNode
+    gp.queueDeltaLocked(gp.localNode.ID)
     gp.mu.Unlock()
 
     gp.ticker = time.NewTicker(gp.interval)
 
+    gp.packetServer = network.NewPacketServer(gp.localNode.Address, gp, gp.logger)
+    gp.mu.RLock()
+    keyring := gp.keyring
+    gp.mu.RUnlock()
+    if keyring != nil {
+        gp.packetServer.SetKeyring(keyring)
+    }
+    if err := gp.packetServer.Start(); err != nil {
+        gp.logger.Error("failed to start gossip UDP transport", "addr", gp.localNode.Address, "err", err)
+    }
+
+    gp.pool.Start()
+
     go gp.gossipLoop()
     go gp.failureDetectionLoop()
 }
 
+// SetSuspectTimeout overrides the duration of missed heartbeats after which
+// a node is marked "down", letting callers tune failure detection without
+// changing the gossip interval itself.
+func (gp *GossipProtocol) SetSuspectTimeout(d time.Duration) {
+    gp.mu.Lock()
+    defer gp.mu.Unlock()
+    gp.suspectTimeout = d
+}
+
+// SetKeyring installs kr to encrypt this node's outgoing gossip traffic
+// and decrypt inbound gossip it receives: the pool used for TCP full
+// syncs (see sendGossipToNode) and the client used for seed messages and
+// UDP delta packets (see AddSeedNode, sendPacketToNode) both start
+// encrypting under kr's current primary key. The UDP packet server started
+// by Start also picks up kr (Start reads gp.keyring once at startup, so
+// SetKeyring must be called before Start for that transport). The TCP
+// server that receives this node's gossip - set up independently by
+// whatever wires HandleGossipMessage to a network.Server (see
+// internal/cluster.InitGossipKeyring) - must be configured with the same
+// Keyring to decrypt it.
+func (gp *GossipProtocol) SetKeyring(kr *network.Keyring) {
+    gp.mu.Lock()
+    gp.keyring = kr
+    gp.mu.Unlock()
+
+    gp.pool.SetKeyring(kr)
+    gp.client.SetKeyring(kr)
+}
+
+// BroadcastKeyRotation piggybacks a hint on this node's outgoing gossip
+// packets telling every peer to promote the key already registered under
+// keyID to primary (see network.Keyring.UseKey), so a rotation completes
+// across the cluster without touching each node individually. The usual
+// sequence is: AddKey the new key on every node out of band, confirm it's
+// everywhere, then call BroadcastKeyRotation once on any node. Requires a
+// Keyring already installed via SetKeyring.
+func (gp *GossipProtocol) BroadcastKeyRotation(keyID string) error {
+    gp.mu.Lock()
+    defer gp.mu.Unlock()
+
+    if gp.keyring == nil {
+        return fmt.Errorf("cluster: no keyring installed, call SetKeyring first")
+    }
+    mac, err := gp.keyring.RotationMAC(keyID)
+    if err != nil {
+        return fmt.Errorf("cluster: cannot broadcast rotation to %q: %w", keyID, err)
+    }
+    gp.pendingKeyRotation = &models.KeyRotationHint{KeyID: keyID, MAC: mac}
+    gp.keyRotationRetransmit = retransmitCount(len(gp.clusterState.Nodes))
+    return nil
+}
+
 func (gp *GossipProtocol) Stop() {
     if gp.ticker != nil {
         gp.ticker.Stop()
     }
+    if gp.packetServer != nil {
+        gp.packetServer.Stop()
+    }
+    gp.pool.Stop()
     close(gp.stopChan)
 }
 
@@ -64,25 +237,97 @@ func (gp *GossipProtocol) gossipLoop() {
         select {
         case <-gp.ticker.C:
             gp.sendGossip()
+
+            gp.mu.Lock()
+            gp.tickCount++
+            dueForFullSync := gp.tickCount%fullSyncEveryNTicks == 0
+            gp.mu.Unlock()
+
+            if dueForFullSync {
+                gp.sendFullSync()
+            }
         case <-gp.stopChan:
             return
         }
     }
 }
 
+// sendGossip is the per-tick delta exchange: it drains as many queued
+// broadcastItems as fit under maxGossipPacketBytes and ships them as a
+// GossipPacket over the UDP transport to a few random peers. The deltas stay
+// within that byte budget regardless of cluster size (the version vector
+// sent alongside them still has one entry per known node), unlike shipping
+// the entire ClusterState (see sendFullSync, which still does that but only
+// rarely, as an anti-entropy backstop).
 func (gp *GossipProtocol) sendGossip() {
     gp.mu.Lock()
     gp.localNode.LastHeartbeat = time.Now()
-    gp.clusterState.Version++
+    gp.queueDeltaLocked(gp.localNode.ID)
+
+    deltas := gp.drainBroadcastQueueLocked(maxGossipPacketBytes)
+
+    suspicions := gp.pendingSuspicions
+    refutations := gp.pendingRefutations
+    gp.pendingSuspicions = nil
+    gp.pendingRefutations = nil
+
+    var keyRotationHint *models.KeyRotationHint
+    if gp.pendingKeyRotation != nil {
+        keyRotationHint = gp.pendingKeyRotation
+        gp.keyRotationRetransmit--
+        if gp.keyRotationRetransmit <= 0 {
+            gp.pendingKeyRotation = nil
+        }
+    }
+
+    versions := make(map[string]int64, len(gp.versions))
+    for nodeID, v := range gp.versions {
+        versions[nodeID] = v
+    }
+
+    packet := models.GossipPacket{
+        From:            gp.localNode.ID,
+        Deltas:          deltas,
+        Suspicions:      suspicions,
+        Refutations:     refutations,
+        Versions:        versions,
+        KeyRotationHint: keyRotationHint,
+    }
+    gp.mu.Unlock()
+
+    data, err := json.Marshal(packet)
+    if err != nil {
+        gp.logger.Error("failed to marshal gossip packet", "err", err)
+        return
+    }
+
+    targets := gp.selectRandomActiveNodes(3)
+
+    for _, nodeID := range targets {
+        go gp.sendPacketToNode(nodeID, data)
+    }
+}
 
+// sendFullSync pushes the entire ClusterState to one random active peer
+// over the reliable TCP transport. It runs only every fullSyncEveryNTicks
+// gossip intervals, catching up anything the lossy, bounded UDP delta
+// queue dropped or never got around to retransmitting.
+func (gp *GossipProtocol) sendFullSync() {
+    gp.mu.Lock()
+    gp.clusterState.Version++
     msg := models.GossipMessage{
         State:   *gp.clusterState,
         From:    gp.localNode.ID,
         Version: gp.clusterState.Version,
     }
+    delegate := gp.delegate
     gp.mu.Unlock()
 
-    targets := gp.selectRandomActiveNodes(3)
+    if delegate != nil {
+        msg.AppState = delegate.LocalState()
+    }
+
+    targets := gp.selectRandomActiveNodes(1)
 
     for _, nodeID := range targets {
         go gp.sendGossipToNode(nodeID, msg)
@@ -106,45 +351,407 @@ func (gp *GossipProtocol) sendGossipToNode(nodeID string, msg models.GossipMessa
 
     data, err := json.Marshal(internalMsg)
     if err != nil {
-        log.Printf("Failed to marshal gossip message: %v", err)
+        gp.logger.Error("failed to marshal gossip message", "err", err)
+        return
+    }
+
+    // gp.pool's outcome callback (recordSendOutcome) folds success/failure
+    // back into Lifeguard awareness and suspicion bookkeeping, so there's
+    // no need to call markNodeSuspect/decreaseAwareness directly here.
+    if err := gp.pool.Send(node.Address, data); err != nil {
+        gp.logger.Debug("failed to send gossip", "peer", nodeID, "err", err)
+    }
+}
+
+// Healthy implements network.HealthSource for gp.pool: an address is only
+// healthy while the node gossip currently knows at that address is
+// "active", so Pool skips peers already marked "suspect" or "down"
+// without wasting a dial or send attempt on them.
+func (gp *GossipProtocol) Healthy(address string) bool {
+    gp.mu.RLock()
+    defer gp.mu.RUnlock()
+
+    for _, node := range gp.clusterState.Nodes {
+        if node.Address == address {
+            return node.Status == "active"
+        }
+    }
+    return true // unknown address: let the send attempt itself decide
+}
+
+// recordSendOutcome is gp.pool's OutcomeFunc: every pooled send's success
+// or failure feeds back into this node's own Lifeguard awareness score and
+// suspicion bookkeeping, the same signal sendGossipToNode used to derive
+// directly from a bare network.Client.Send before gp.pool existed.
+func (gp *GossipProtocol) recordSendOutcome(address string, err error) {
+    nodeID, ok := gp.nodeIDForAddress(address)
+    if !ok {
+        return
+    }
+
+    if err != nil {
+        gp.markNodeSuspect(nodeID)
+        return
+    }
+
+    gp.mu.Lock()
+    gp.decreaseAwareness()
+    gp.mu.Unlock()
+}
+
+func (gp *GossipProtocol) nodeIDForAddress(address string) (string, bool) {
+    gp.mu.RLock()
+    defer gp.mu.RUnlock()
+
+    for id, node := range gp.clusterState.Nodes {
+        if node.Address == address {
+            return id, true
+        }
+    }
+    return "", false
+}
+
+// SendToAnyReplica walks candidateNodeIDs in order - typically a ranked
+// replica set for some shard, most-preferred first - and sends data to
+// the first one gp.pool successfully reaches, skipping any gossip has
+// already marked "suspect" or "down" along the way. Returns the nodeID
+// that succeeded, or an error if every candidate was exhausted.
+func (gp *GossipProtocol) SendToAnyReplica(candidateNodeIDs []string, data []byte) (string, error) {
+    gp.mu.RLock()
+    addresses := make([]string, 0, len(candidateNodeIDs))
+    addrToNode := make(map[string]string, len(candidateNodeIDs))
+    for _, nodeID := range candidateNodeIDs {
+        node, exists := gp.clusterState.Nodes[nodeID]
+        if !exists {
+            continue
+        }
+        addresses = append(addresses, node.Address)
+        addrToNode[node.Address] = nodeID
+    }
+    gp.mu.RUnlock()
+
+    addr, err := gp.pool.SendToAny(addresses, data)
+    if err != nil {
+        return "", err
+    }
+    return addrToNode[addr], nil
+}
+
+// sendPacketToNode ships data (a marshaled GossipPacket) to nodeID over
+// the unreliable UDP transport. A send failure here is much weaker
+// evidence of a dead peer than a failed TCP Send (UDP has no delivery
+// confirmation even on success), but it's still the same signal
+// sendGossipToNode acts on, so it's treated the same way.
+func (gp *GossipProtocol) sendPacketToNode(nodeID string, data []byte) {
+    gp.mu.RLock()
+    node, exists := gp.clusterState.Nodes[nodeID]
+    gp.mu.RUnlock()
+
+    if !exists || node.ID == gp.localNode.ID || node.Status == "down" {
         return
     }
 
-    if err := gp.client.Send(node.Address, data); err != nil {
-        log.Printf("Failed to send gossip to %s: %v", nodeID, err)
+    if err := gp.client.SendPacket(node.Address, data); err != nil {
+        gp.logger.Debug("failed to send gossip packet", "peer", nodeID, "err", err)
         gp.markNodeSuspect(nodeID)
+        return
     }
+
+    gp.mu.Lock()
+    gp.decreaseAwareness()
+    gp.mu.Unlock()
+}
+
+// HandlePacket implements network.PacketHandler, handling inbound UDP
+// gossip packets.
+func (gp *GossipProtocol) HandlePacket(data []byte, addr net.Addr) error {
+    var packet models.GossipPacket
+    if err := json.Unmarshal(data, &packet); err != nil {
+        return err
+    }
+    gp.HandleGossipPacket(packet)
+    return nil
+}
+
+// HandleGossipPacket applies an inbound batch of deltas, suspicions and
+// refutations. A delta whose version is more than one ahead of this
+// node's last known version for that nodeID means at least one update
+// was missed (dropped packet, queue eviction, etc.); when that happens,
+// this node pushes its own full state to the sender over TCP so both
+// sides converge without waiting for the next scheduled anti-entropy
+// sync.
+func (gp *GossipProtocol) HandleGossipPacket(packet models.GossipPacket) {
+    gp.mu.Lock()
+
+    gapDetected := false
+    for _, delta := range packet.Deltas {
+        localVersion := gp.versions[delta.NodeID]
+        if delta.Version <= localVersion {
+            continue
+        }
+        if delta.Version > localVersion+1 {
+            gapDetected = true
+        }
+        gp.versions[delta.NodeID] = delta.Version
+        _, existedBefore := gp.clusterState.Nodes[delta.NodeID]
+        if gp.applyDeltaLocked(delta) {
+            // Keep the epidemic spreading: re-announce this node's state
+            // (at our own locally-assigned version) so it keeps
+            // propagating past this hop.
+            gp.queueDeltaLocked(delta.NodeID)
+            if node := gp.clusterState.Nodes[delta.NodeID]; node != nil {
+                if existedBefore {
+                    gp.fireNodeEventLocked(node, func(d Delegate, n *models.NodeInfo) { d.OnNodeUpdate(n) })
+                } else {
+                    gp.fireNodeEventLocked(node, func(d Delegate, n *models.NodeInfo) { d.OnNodeJoin(n) })
+                }
+            }
+        }
+    }
+
+    for _, s := range packet.Suspicions {
+        if s.NodeID == gp.localNode.ID {
+            if s.Incarnation >= gp.localNode.Incarnation {
+                gp.localNode.Incarnation = s.Incarnation + 1
+                gp.localNode.Status = "active"
+                gp.pendingRefutations = append(gp.pendingRefutations, models.RefutationMessage{
+                    NodeID:      gp.localNode.ID,
+                    Incarnation: gp.localNode.Incarnation,
+                })
+                gp.queueDeltaLocked(gp.localNode.ID)
+            }
+            continue
+        }
+        gp.recordSuspicionLocked(s.NodeID, s.Incarnation, s.From)
+    }
+
+    for _, r := range packet.Refutations {
+        if node, exists := gp.clusterState.Nodes[r.NodeID]; exists && r.Incarnation > node.Incarnation {
+            node.Incarnation = r.Incarnation
+            node.Status = "active"
+            delete(gp.suspicionConfirms, r.NodeID)
+            gp.queueDeltaLocked(r.NodeID)
+            gp.fireNodeEventLocked(node, func(d Delegate, n *models.NodeInfo) { d.OnNodeUpdate(n) })
+        }
+    }
+
+    from := packet.From
+    keyring := gp.keyring
+    gp.mu.Unlock()
+
+    if gapDetected && from != "" {
+        gp.logger.Warn("detected gossip version gap, pushing full sync", "peer", from)
+        go gp.pushFullSyncTo(from)
+    }
+
+    if hint := packet.KeyRotationHint; hint != nil && keyring != nil && hint.KeyID != keyring.PrimaryID() {
+        if !keyring.VerifyRotationMAC(hint.KeyID, hint.MAC) {
+            // The gossip channel this hint arrived on isn't necessarily
+            // authenticated (it may be plaintext, or encrypted under a key
+            // we don't recognize) - only a MAC proving the sender already
+            // holds the target key's secret bytes is trusted to trigger a
+            // rotation. Without this check, anyone who can inject a UDP
+            // packet could force a downgrade to an old or attacker-chosen
+            // key ID.
+            gp.logger.Warn("dropping key rotation hint with invalid MAC", "key_id", hint.KeyID)
+        } else if err := keyring.UseKey(hint.KeyID); err != nil {
+            gp.logger.Warn("received key rotation hint for unknown key", "key_id", hint.KeyID, "err", err)
+        } else {
+            gp.logger.Info("rotated gossip encryption primary via peer hint", "key_id", hint.KeyID)
+        }
+    }
+}
+
+// pushFullSyncTo sends this node's entire ClusterState to nodeID over
+// TCP, out of band from the regular periodic sendFullSync. Used when a
+// version-vector gap is detected in an inbound gossip packet.
+func (gp *GossipProtocol) pushFullSyncTo(nodeID string) {
+    gp.mu.Lock()
+    gp.clusterState.Version++
+    msg := models.GossipMessage{
+        State:   *gp.clusterState,
+        From:    gp.localNode.ID,
+        Version: gp.clusterState.Version,
+    }
+    delegate := gp.delegate
+    gp.mu.Unlock()
+
+    if delegate != nil {
+        msg.AppState = delegate.LocalState()
+    }
+
+    gp.sendGossipToNode(nodeID, msg)
+}
+
+// applyDeltaLocked merges one inbound GossipDelta into clusterState and
+// reports whether it changed anything, so the caller knows whether to
+// keep propagating it. Callers must hold gp.mu.
+func (gp *GossipProtocol) applyDeltaLocked(delta models.GossipDelta) bool {
+    existing, exists := gp.clusterState.Nodes[delta.NodeID]
+    if !exists {
+        nodeCopy := delta.Node
+        gp.clusterState.Nodes[delta.NodeID] = &nodeCopy
+        gp.logger.Info("discovered new node via gossip delta", "peer", delta.NodeID, "addr", delta.Node.Address)
+        return true
+    }
+
+    changed := false
+    if delta.Node.LastHeartbeat.After(existing.LastHeartbeat) {
+        existing.LastHeartbeat = delta.Node.LastHeartbeat
+        existing.Status = delta.Node.Status
+        existing.Address = delta.Node.Address
+        changed = true
+    }
+    if delta.Node.Incarnation > existing.Incarnation {
+        existing.Incarnation = delta.Node.Incarnation
+        changed = true
+    }
+    return changed
+}
+
+// queueDeltaLocked bumps nodeID's version and (re-)queues its current
+// state for piggyback onto outgoing gossip packets, replacing any
+// not-yet-sent delta already queued for the same node. retransmit is set
+// to ceil(log(N+1) * retransmitMult) so deltas propagate more times in a
+// larger cluster, the same shape memberlist uses. Callers must hold gp.mu.
+func (gp *GossipProtocol) queueDeltaLocked(nodeID string) {
+    node, exists := gp.clusterState.Nodes[nodeID]
+    if !exists {
+        return
+    }
+
+    gp.versions[nodeID]++
+
+    item := &broadcastItem{
+        delta: models.GossipDelta{
+            NodeID:  nodeID,
+            Node:    *node,
+            Version: gp.versions[nodeID],
+        },
+        retransmit: retransmitCount(len(gp.clusterState.Nodes)),
+    }
+
+    for i, existing := range gp.broadcastQueue {
+        if existing.delta.NodeID == nodeID {
+            gp.broadcastQueue[i] = item
+            return
+        }
+    }
+    gp.broadcastQueue = append(gp.broadcastQueue, item)
+}
+
+// retransmitCount is ceil(log(n+1) * retransmitMult), clamped to at least 1.
+func retransmitCount(n int) int {
+    count := int(math.Ceil(math.Log(float64(n+1)) * retransmitMult))
+    if count < 1 {
+        count = 1
+    }
+    return count
+}
+
+// drainBroadcastQueueLocked pulls as many queued deltas as fit under
+// budget bytes (always including at least one, even if it alone exceeds
+// budget, so the queue keeps draining), decrementing each included item's
+// retransmit counter and dropping it once that reaches zero. Items that
+// don't fit this round stay queued for the next tick. Callers must hold
+// gp.mu.
+func (gp *GossipProtocol) drainBroadcastQueueLocked(budget int) []models.GossipDelta {
+    var included []models.GossipDelta
+    var kept []*broadcastItem
+    used := 0
+
+    for _, item := range gp.broadcastQueue {
+        encoded, err := json.Marshal(item.delta)
+        if err != nil {
+            continue
+        }
+
+        if used+len(encoded) > budget && len(included) > 0 {
+            kept = append(kept, item)
+            continue
+        }
+
+        used += len(encoded)
+        included = append(included, item.delta)
+        item.retransmit--
+        if item.retransmit > 0 {
+            kept = append(kept, item)
+        }
+    }
+
+    gp.broadcastQueue = kept
+    return included
 }
 
 func (gp *GossipProtocol) HandleGossipMessage(msg models.GossipMessage) {
     gp.mu.Lock()
-    defer gp.mu.Unlock()
 
     for nodeID, remoteNode := range msg.State.Nodes {
         localNode, exists := gp.clusterState.Nodes[nodeID]
 
         if !exists {
-            gp.clusterState.Nodes[nodeID] = &models.NodeInfo{
+            newNode := &models.NodeInfo{
                 ID:            remoteNode.ID,
                 Address:       remoteNode.Address,
                 HTTPPort:      remoteNode.HTTPPort,
                 MQTTPort:      remoteNode.MQTTPort,
                 LastHeartbeat: remoteNode.LastHeartbeat,
                 Status:        remoteNode.Status,
+                Incarnation:   remoteNode.Incarnation,
             }
-            log.Printf("[%s] Discovered new node: %s at %s", 
-                       gp.localNode.ID, nodeID, remoteNode.Address)
+            gp.clusterState.Nodes[nodeID] = newNode
+            gp.logger.Info("discovered new node", "peer", nodeID, "addr", remoteNode.Address)
+            gp.queueDeltaLocked(nodeID)
+            gp.fireNodeEventLocked(newNode, func(d Delegate, n *models.NodeInfo) { d.OnNodeJoin(n) })
         } else if remoteNode.LastHeartbeat.After(localNode.LastHeartbeat) {
             // Update with newer information
             localNode.LastHeartbeat = remoteNode.LastHeartbeat
             localNode.Status = remoteNode.Status
             localNode.Address = remoteNode.Address
+            gp.queueDeltaLocked(nodeID)
+            gp.fireNodeEventLocked(localNode, func(d Delegate, n *models.NodeInfo) { d.OnNodeUpdate(n) })
         }
     }
 
     if msg.Version > gp.clusterState.Version {
         gp.clusterState.Version = msg.Version
     }
+
+    for _, s := range msg.Suspicions {
+        if s.NodeID == gp.localNode.ID {
+            // We're being suspected but we're clearly still running -
+            // bump our own incarnation and queue a refutation so it
+            // piggybacks on the next outgoing gossip message.
+            if s.Incarnation >= gp.localNode.Incarnation {
+                gp.localNode.Incarnation = s.Incarnation + 1
+                gp.localNode.Status = "active"
+                gp.pendingRefutations = append(gp.pendingRefutations, models.RefutationMessage{
+                    NodeID:      gp.localNode.ID,
+                    Incarnation: gp.localNode.Incarnation,
+                })
+                gp.queueDeltaLocked(gp.localNode.ID)
+            }
+            continue
+        }
+        gp.recordSuspicionLocked(s.NodeID, s.Incarnation, s.From)
+    }
+
+    for _, r := range msg.Refutations {
+        if node, exists := gp.clusterState.Nodes[r.NodeID]; exists && r.Incarnation > node.Incarnation {
+            node.Incarnation = r.Incarnation
+            node.Status = "active"
+            delete(gp.suspicionConfirms, r.NodeID)
+            gp.queueDeltaLocked(r.NodeID)
+            gp.fireNodeEventLocked(node, func(d Delegate, n *models.NodeInfo) { d.OnNodeUpdate(n) })
+        }
+    }
+
+    delegate := gp.delegate
+    gp.mu.Unlock()
+
+    if delegate != nil && len(msg.AppState) > 0 {
+        delegate.MergeRemoteState(msg.From, msg.AppState)
+    }
 }
 
 func (gp *GossipProtocol) selectRandomActiveNodes(count int) []string {
@@ -183,11 +790,20 @@ func (gp *GossipProtocol) failureDetectionLoop() {
     }
 }
 
+// detectFailures scales its suspect/probe windows by the local awareness
+// score, so a node that's recently had trouble reaching peers (and is
+// therefore less sure its own view is accurate) waits longer before
+// convicting anyone. The absolute suspectTimeout is still an unconditional
+// backstop: once crossed, a node goes "down" regardless of suspicion
+// confirmations.
 func (gp *GossipProtocol) detectFailures() {
     gp.mu.Lock()
     defer gp.mu.Unlock()
 
     now := time.Now()
+    scale := time.Duration(gp.awareness + 1)
+    effectiveSuspectTimeout := gp.suspectTimeout * scale
+    effectiveProbeTimeout := gp.interval * 2 * scale
 
     for nodeID, node := range gp.clusterState.Nodes {
         if nodeID == gp.localNode.ID {
@@ -196,16 +812,19 @@ func (gp *GossipProtocol) detectFailures() {
 
         timeSinceHeartbeat := now.Sub(node.LastHeartbeat)
 
-        if timeSinceHeartbeat > gp.suspectTimeout {
+        if timeSinceHeartbeat > effectiveSuspectTimeout {
             if node.Status != "down" {
-                log.Printf("[%s] Node %s marked as DOWN (no heartbeat for %v)", 
-                          gp.localNode.ID, nodeID, timeSinceHeartbeat)
+                gp.logger.Warn("node marked down", "peer", nodeID, "since_heartbeat", timeSinceHeartbeat)
                 node.Status = "down"
+                delete(gp.suspicionConfirms, nodeID)
+                gp.queueDeltaLocked(nodeID)
+                gp.fireNodeEventLocked(node, func(d Delegate, n *models.NodeInfo) { d.OnNodeLeave(n) })
             }
-        } else if timeSinceHeartbeat > gp.interval*2 {
+        } else if timeSinceHeartbeat > effectiveProbeTimeout {
             if node.Status == "active" {
-                log.Printf("[%s] Node %s marked as SUSPECT", gp.localNode.ID, nodeID)
-                node.Status = "suspect"
+                gp.logger.Warn("node marked suspect", "peer", nodeID)
+                gp.increaseAwareness()
+                gp.recordSuspicionLocked(nodeID, node.Incarnation, gp.localNode.ID)
             }
         }
     }
@@ -215,15 +834,100 @@ func (gp *GossipProtocol) markNodeSuspect(nodeID string) {
     gp.mu.Lock()
     defer gp.mu.Unlock()
 
+    gp.increaseAwareness()
+
     if node, exists := gp.clusterState.Nodes[nodeID]; exists {
         if node.Status == "active" {
-            node.Status = "suspect"
-            log.Printf("[%s] Node %s marked as SUSPECT (failed to contact)", 
-                      gp.localNode.ID, nodeID)
+            gp.logger.Warn("node marked suspect", "peer", nodeID, "reason", "failed to contact")
         }
+        gp.recordSuspicionLocked(nodeID, node.Incarnation, gp.localNode.ID)
+    }
+}
+
+// increaseAwareness and decreaseAwareness adjust the Lifeguard awareness
+// score. Callers must hold gp.mu.
+func (gp *GossipProtocol) increaseAwareness() {
+    if gp.awareness < maxAwareness {
+        gp.awareness++
+    }
+}
+
+func (gp *GossipProtocol) decreaseAwareness() {
+    if gp.awareness > 0 {
+        gp.awareness--
     }
 }
 
+// recordSuspicionLocked registers that `from` suspects nodeID is down as of
+// incarnation, queuing the suspicion to piggyback on the next outgoing
+// gossip message, and promotes nodeID to "down" once suspicionConfirmations
+// independent members have all suspected it at that incarnation or later.
+// A suspicion older than the node's current known incarnation (i.e.
+// already refuted) is ignored. Callers must hold gp.mu.
+func (gp *GossipProtocol) recordSuspicionLocked(nodeID string, incarnation int64, from string) {
+    node, exists := gp.clusterState.Nodes[nodeID]
+    if !exists || incarnation < node.Incarnation || node.Status == "down" {
+        return
+    }
+
+    if gp.suspicionConfirms[nodeID] == nil {
+        gp.suspicionConfirms[nodeID] = make(map[string]bool)
+    }
+    gp.suspicionConfirms[nodeID][from] = true
+
+    if node.Status == "active" {
+        node.Status = "suspect"
+        gp.queueDeltaLocked(nodeID)
+        gp.fireNodeEventLocked(node, func(d Delegate, n *models.NodeInfo) { d.OnNodeSuspect(n) })
+    }
+
+    gp.pendingSuspicions = append(gp.pendingSuspicions, models.SuspicionMessage{
+        NodeID:      nodeID,
+        Incarnation: incarnation,
+        From:        from,
+    })
+
+    if len(gp.suspicionConfirms[nodeID]) >= suspicionConfirmations {
+        node.Status = "down"
+        delete(gp.suspicionConfirms, nodeID)
+        gp.queueDeltaLocked(nodeID)
+        gp.logger.Warn("node marked down", "peer", nodeID, "confirmations", suspicionConfirmations)
+        gp.fireNodeEventLocked(node, func(d Delegate, n *models.NodeInfo) { d.OnNodeLeave(n) })
+    }
+}
+
+// ConfirmSuspect registers the local node's own independent suspicion of
+// nodeID, as if detectFailures had just observed a missed heartbeat for
+// it. Useful for callers (e.g. a failed internode RPC outside the regular
+// gossip send path) that have separately observed nodeID is unreachable.
+func (gp *GossipProtocol) ConfirmSuspect(nodeID string) {
+    gp.mu.Lock()
+    defer gp.mu.Unlock()
+
+    node, exists := gp.clusterState.Nodes[nodeID]
+    if !exists {
+        return
+    }
+    gp.recordSuspicionLocked(nodeID, node.Incarnation, gp.localNode.ID)
+}
+
+// Refute bumps the local node's incarnation number and queues a
+// refutation to piggyback on the next outgoing gossip message, clearing
+// any outstanding suspicion of this node on every member that receives
+// it. Call this when the local node learns it has been wrongly suspected.
+func (gp *GossipProtocol) Refute() {
+    gp.mu.Lock()
+    defer gp.mu.Unlock()
+
+    gp.localNode.Incarnation++
+    gp.localNode.Status = "active"
+    gp.pendingRefutations = append(gp.pendingRefutations, models.RefutationMessage{
+        NodeID:      gp.localNode.ID,
+        Incarnation: gp.localNode.Incarnation,
+    })
+    gp.queueDeltaLocked(gp.localNode.ID)
+}
+
 func (gp *GossipProtocol) GetClusterState() models.ClusterState {
     gp.mu.RLock()
     defer gp.mu.RUnlock()
@@ -269,8 +973,13 @@ func (gp *GossipProtocol) AddSeedNode(address string) error {
         From:    gp.localNode.ID,
         Version: gp.clusterState.Version,
     }
+    delegate := gp.delegate
     gp.mu.RUnlock()
 
+    if delegate != nil {
+        seedMsg.AppState = delegate.LocalState()
+    }
+
     internalMsg := models.InternalMessage{
         Type:    "gossip",
         Payload: seedMsg,
@@ -0,0 +1,465 @@
+package cluster
+
+import (
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/minitrue/pkg/log"
+    "github.com/minitrue/pkg/models"
+    "github.com/minitrue/pkg/network"
+)
+
+func newTestGossipProtocol(id string) *GossipProtocol {
+    local := &models.NodeInfo{ID: id, Address: id + ":7000", Status: "active"}
+    return NewGossipProtocol(local, time.Second, network.NewClient(time.Second, log.Nop()), 2, log.Nop())
+}
+
+func TestGossipProtocol_AwarenessRisesOnFailureAndFallsOnSuccess(t *testing.T) {
+    gp := newTestGossipProtocol("node-1")
+
+    if gp.awareness != 0 {
+        t.Fatalf("expected initial awareness 0, got %d", gp.awareness)
+    }
+
+    gp.markNodeSuspect("node-2") // no-op, node-2 unknown, but still raises awareness
+    if gp.awareness != 1 {
+        t.Fatalf("expected awareness 1 after a failed send, got %d", gp.awareness)
+    }
+
+    gp.mu.Lock()
+    gp.decreaseAwareness()
+    gp.mu.Unlock()
+    if gp.awareness != 0 {
+        t.Fatalf("expected awareness back to 0 after a successful send, got %d", gp.awareness)
+    }
+}
+
+func TestGossipProtocol_SuspicionRequiresConfirmations(t *testing.T) {
+    gp := newTestGossipProtocol("node-1")
+    gp.clusterState.Nodes["node-2"] = &models.NodeInfo{ID: "node-2", Status: "active"}
+
+    gp.ConfirmSuspect("node-2")
+    if gp.clusterState.Nodes["node-2"].Status != "suspect" {
+        t.Fatalf("expected node-2 to be suspect after one confirmation, got %s", gp.clusterState.Nodes["node-2"].Status)
+    }
+
+    gp.mu.Lock()
+    gp.recordSuspicionLocked("node-2", 0, "node-3")
+    gp.mu.Unlock()
+    if gp.clusterState.Nodes["node-2"].Status != "down" {
+        t.Fatalf("expected node-2 to be down after %d independent confirmations, got %s", suspicionConfirmations, gp.clusterState.Nodes["node-2"].Status)
+    }
+}
+
+func TestGossipProtocol_LogsStructuredRecordOnNodeDown(t *testing.T) {
+    recorder := log.NewRecorder()
+    local := &models.NodeInfo{ID: "node-1", Address: "node-1:7000", Status: "active"}
+    gp := NewGossipProtocol(local, time.Second, network.NewClient(time.Second, log.Nop()), 2, recorder)
+    gp.clusterState.Nodes["node-2"] = &models.NodeInfo{ID: "node-2", Status: "active"}
+
+    gp.ConfirmSuspect("node-2")
+    gp.mu.Lock()
+    gp.recordSuspicionLocked("node-2", 0, "node-3")
+    gp.mu.Unlock()
+
+    var downRecord *log.Record
+    for i, rec := range recorder.Records() {
+        if rec.Msg == "node marked down" {
+            downRecord = &recorder.Records()[i]
+            break
+        }
+    }
+    if downRecord == nil {
+        t.Fatalf("expected a \"node marked down\" record, got %+v", recorder.Records())
+    }
+    if downRecord.Level != log.LevelWarn {
+        t.Fatalf("expected node-down record at warn level, got %s", downRecord.Level)
+    }
+
+    found := false
+    for i := 0; i+1 < len(downRecord.KV); i += 2 {
+        if downRecord.KV[i] == "node" && downRecord.KV[i+1] == "node-1" {
+            found = true
+        }
+    }
+    if !found {
+        t.Fatalf("expected node-down record to carry node=node-1 context from With(), got %v", downRecord.KV)
+    }
+}
+
+func TestGossipProtocol_RefuteClearsSuspicionOnPeer(t *testing.T) {
+    gp := newTestGossipProtocol("node-1")
+    gp.clusterState.Nodes["node-2"] = &models.NodeInfo{ID: "node-2", Status: "suspect", Incarnation: 0}
+
+    gp.HandleGossipMessage(models.GossipMessage{
+        From: "node-2",
+        Refutations: []models.RefutationMessage{
+            {NodeID: "node-2", Incarnation: 1},
+        },
+    })
+
+    node := gp.clusterState.Nodes["node-2"]
+    if node.Status != "active" || node.Incarnation != 1 {
+        t.Fatalf("expected node-2 active at incarnation 1, got status=%s incarnation=%d", node.Status, node.Incarnation)
+    }
+}
+
+func TestGossipProtocol_DrainBroadcastQueueRespectsByteBudgetAndRetransmitCount(t *testing.T) {
+    gp := newTestGossipProtocol("node-1")
+    gp.clusterState.Nodes["node-2"] = &models.NodeInfo{ID: "node-2", Address: "node-2:7000", Status: "active"}
+    gp.clusterState.Nodes["node-3"] = &models.NodeInfo{ID: "node-3", Address: "node-3:7000", Status: "active"}
+
+    gp.mu.Lock()
+    gp.queueDeltaLocked("node-2")
+    gp.queueDeltaLocked("node-3")
+    if len(gp.broadcastQueue) != 2 {
+        t.Fatalf("expected 2 queued deltas, got %d", len(gp.broadcastQueue))
+    }
+    retransmit := gp.broadcastQueue[0].retransmit
+    gp.mu.Unlock()
+
+    // A huge budget drains everything in one pass.
+    gp.mu.Lock()
+    deltas := gp.drainBroadcastQueueLocked(1 << 20)
+    gp.mu.Unlock()
+    if len(deltas) != 2 {
+        t.Fatalf("expected both deltas drained under a large budget, got %d", len(deltas))
+    }
+
+    for i := 1; i < retransmit; i++ {
+        gp.mu.Lock()
+        gp.drainBroadcastQueueLocked(1 << 20)
+        gp.mu.Unlock()
+    }
+
+    gp.mu.Lock()
+    remaining := len(gp.broadcastQueue)
+    gp.mu.Unlock()
+    if remaining != 0 {
+        t.Fatalf("expected deltas dropped after %d retransmits, %d still queued", retransmit, remaining)
+    }
+}
+
+func TestGossipProtocol_HandleGossipPacketAppliesDeltasAndDetectsGaps(t *testing.T) {
+    gp := newTestGossipProtocol("node-1")
+
+    gp.HandleGossipPacket(models.GossipPacket{
+        From: "node-2",
+        Deltas: []models.GossipDelta{
+            {NodeID: "node-2", Node: models.NodeInfo{ID: "node-2", Address: "node-2:7000", Status: "active", LastHeartbeat: time.Now()}, Version: 1},
+        },
+        Versions: map[string]int64{"node-2": 1},
+    })
+
+    if _, ok := gp.clusterState.Nodes["node-2"]; !ok {
+        t.Fatal("expected node-2 to be added from the gossip delta")
+    }
+    // HandleGossipPacket sets the version vector to the received delta's
+    // version (1), then queueDeltaLocked bumps it again to re-announce
+    // the node for continued propagation - so it lands at 2.
+    versionAfterFirstPacket := gp.versions["node-2"]
+    if versionAfterFirstPacket < 1 {
+        t.Fatalf("expected version vector entry to advance past 0 for node-2, got %d", versionAfterFirstPacket)
+    }
+
+    // A delta far ahead of what we've seen signals a gap; applying it
+    // should still succeed (we take the newer state) and advance our
+    // version vector at least that far.
+    gp.HandleGossipPacket(models.GossipPacket{
+        From: "node-2",
+        Deltas: []models.GossipDelta{
+            {NodeID: "node-2", Node: models.NodeInfo{ID: "node-2", Address: "node-2:7000", Status: "suspect", LastHeartbeat: time.Now()}, Version: 5},
+        },
+        Versions: map[string]int64{"node-2": 5},
+    })
+    if gp.versions["node-2"] < 5 {
+        t.Fatalf("expected version vector entry to advance to at least 5, got %d", gp.versions["node-2"])
+    }
+}
+
+func TestGossipProtocol_SelfSuspicionTriggersRefutation(t *testing.T) {
+    gp := newTestGossipProtocol("node-1")
+
+    gp.HandleGossipMessage(models.GossipMessage{
+        From: "node-2",
+        Suspicions: []models.SuspicionMessage{
+            {NodeID: "node-1", Incarnation: 0, From: "node-2"},
+        },
+    })
+
+    if gp.localNode.Incarnation != 1 || gp.localNode.Status != "active" {
+        t.Fatalf("expected local node to self-refute with incarnation 1, got status=%s incarnation=%d", gp.localNode.Status, gp.localNode.Incarnation)
+    }
+    if len(gp.pendingRefutations) != 1 {
+        t.Fatalf("expected a pending refutation queued for the next gossip round, got %d", len(gp.pendingRefutations))
+    }
+}
+
+// recordingDelegate captures every delegate callback it receives, guarded
+// by a mutex since fireNodeEventLocked invokes them from their own
+// goroutine.
+type recordingDelegate struct {
+    mu       sync.Mutex
+    joined   []string
+    left     []string
+    updated  []string
+    suspect  []string
+    merged   [][]byte
+    mergedFr []string
+    state    []byte
+}
+
+func (d *recordingDelegate) OnNodeJoin(n *models.NodeInfo) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.joined = append(d.joined, n.ID)
+}
+func (d *recordingDelegate) OnNodeLeave(n *models.NodeInfo) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.left = append(d.left, n.ID)
+}
+func (d *recordingDelegate) OnNodeUpdate(n *models.NodeInfo) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.updated = append(d.updated, n.ID)
+}
+func (d *recordingDelegate) OnNodeSuspect(n *models.NodeInfo) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.suspect = append(d.suspect, n.ID)
+}
+func (d *recordingDelegate) LocalState() []byte {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    return d.state
+}
+func (d *recordingDelegate) MergeRemoteState(from string, data []byte) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.mergedFr = append(d.mergedFr, from)
+    d.merged = append(d.merged, data)
+}
+
+func (d *recordingDelegate) hasJoined(id string) bool {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    for _, j := range d.joined {
+        if j == id {
+            return true
+        }
+    }
+    return false
+}
+
+func (d *recordingDelegate) hasSuspected(id string) bool {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    for _, s := range d.suspect {
+        if s == id {
+            return true
+        }
+    }
+    return false
+}
+
+func (d *recordingDelegate) hasLeft(id string) bool {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    for _, l := range d.left {
+        if l == id {
+            return true
+        }
+    }
+    return false
+}
+
+func TestGossipProtocol_DelegateFiresJoinSuspectAndLeave(t *testing.T) {
+    gp := newTestGossipProtocol("node-1")
+    delegate := &recordingDelegate{}
+    gp.RegisterDelegate(delegate)
+
+    gp.HandleGossipMessage(models.GossipMessage{
+        From: "node-2",
+        State: models.ClusterState{
+            Nodes: map[string]*models.NodeInfo{
+                "node-2": {ID: "node-2", Address: "node-2:7000", Status: "active", LastHeartbeat: time.Now()},
+            },
+        },
+    })
+
+    if !waitFor(func() bool { return delegate.hasJoined("node-2") }) {
+        t.Fatal("expected OnNodeJoin to fire for newly discovered node-2")
+    }
+
+    gp.ConfirmSuspect("node-2")
+    if !waitFor(func() bool { return delegate.hasSuspected("node-2") }) {
+        t.Fatal("expected OnNodeSuspect to fire once node-2 is suspected")
+    }
+
+    gp.mu.Lock()
+    gp.recordSuspicionLocked("node-2", 0, "node-3")
+    gp.mu.Unlock()
+    if !waitFor(func() bool { return delegate.hasLeft("node-2") }) {
+        t.Fatal("expected OnNodeLeave to fire once node-2 is confirmed down")
+    }
+}
+
+func TestGossipProtocol_DelegateLocalStateRoundTripsThroughFullSync(t *testing.T) {
+    sender := newTestGossipProtocol("node-1")
+    senderDelegate := &recordingDelegate{state: []byte("shard-map-v1")}
+    sender.RegisterDelegate(senderDelegate)
+
+    receiver := newTestGossipProtocol("node-2")
+    receiverDelegate := &recordingDelegate{}
+    receiver.RegisterDelegate(receiverDelegate)
+
+    sender.mu.Lock()
+    sender.clusterState.Version++
+    msg := models.GossipMessage{
+        State:   *sender.clusterState,
+        From:    sender.localNode.ID,
+        Version: sender.clusterState.Version,
+    }
+    sender.mu.Unlock()
+    msg.AppState = senderDelegate.LocalState()
+
+    receiver.HandleGossipMessage(msg)
+
+    receiverDelegate.mu.Lock()
+    defer receiverDelegate.mu.Unlock()
+    if len(receiverDelegate.merged) != 1 || string(receiverDelegate.merged[0]) != "shard-map-v1" {
+        t.Fatalf("expected MergeRemoteState to receive sender's LocalState, got %v", receiverDelegate.merged)
+    }
+    if len(receiverDelegate.mergedFr) != 1 || receiverDelegate.mergedFr[0] != "node-1" {
+        t.Fatalf("expected MergeRemoteState to receive the sender's node ID, got %v", receiverDelegate.mergedFr)
+    }
+}
+
+func TestGossipProtocol_BroadcastKeyRotationRequiresKeyring(t *testing.T) {
+    gp := newTestGossipProtocol("node-1")
+    if err := gp.BroadcastKeyRotation("k2"); err == nil {
+        t.Fatal("expected BroadcastKeyRotation to fail without an installed keyring")
+    }
+}
+
+func TestGossipProtocol_BroadcastKeyRotationRequiresKnownKey(t *testing.T) {
+    kr, err := network.NewKeyring("k1", make([]byte, network.KeySize), false)
+    if err != nil {
+        t.Fatalf("NewKeyring: %v", err)
+    }
+    gp := newTestGossipProtocol("node-1")
+    gp.SetKeyring(kr)
+
+    if err := gp.BroadcastKeyRotation("k2"); err == nil {
+        t.Fatal("expected BroadcastKeyRotation to fail rotating to a key that was never AddKey'd")
+    }
+}
+
+func TestGossipProtocol_BroadcastKeyRotationQueuesHintWithValidMAC(t *testing.T) {
+    key2 := make([]byte, network.KeySize)
+    key2[0] = 1
+
+    kr, err := network.NewKeyring("k1", make([]byte, network.KeySize), false)
+    if err != nil {
+        t.Fatalf("NewKeyring: %v", err)
+    }
+    if err := kr.AddKey("k2", key2); err != nil {
+        t.Fatalf("AddKey: %v", err)
+    }
+
+    gp := newTestGossipProtocol("node-1")
+    gp.SetKeyring(kr)
+
+    if err := gp.BroadcastKeyRotation("k2"); err != nil {
+        t.Fatalf("BroadcastKeyRotation: %v", err)
+    }
+
+    gp.mu.Lock()
+    hint := gp.pendingKeyRotation
+    gp.mu.Unlock()
+    if hint == nil || hint.KeyID != "k2" {
+        t.Fatalf("expected a pending key rotation hint for k2, got %v", hint)
+    }
+    if !kr.VerifyRotationMAC(hint.KeyID, hint.MAC) {
+        t.Fatal("expected the queued hint's MAC to verify against the keyring that produced it")
+    }
+}
+
+func TestGossipProtocol_HandleGossipPacketRotatesPrimaryOnValidKeyRotationHint(t *testing.T) {
+    key1 := make([]byte, network.KeySize)
+    key2 := make([]byte, network.KeySize)
+    for i := range key2 {
+        key2[i] = 1
+    }
+
+    kr, err := network.NewKeyring("k1", key1, false)
+    if err != nil {
+        t.Fatalf("NewKeyring: %v", err)
+    }
+    if err := kr.AddKey("k2", key2); err != nil {
+        t.Fatalf("AddKey: %v", err)
+    }
+    mac, err := kr.RotationMAC("k2")
+    if err != nil {
+        t.Fatalf("RotationMAC: %v", err)
+    }
+
+    gp := newTestGossipProtocol("node-1")
+    gp.SetKeyring(kr)
+
+    gp.HandleGossipPacket(models.GossipPacket{
+        From:            "node-2",
+        Versions:        map[string]int64{},
+        KeyRotationHint: &models.KeyRotationHint{KeyID: "k2", MAC: mac},
+    })
+
+    if kr.PrimaryID() != "k2" {
+        t.Fatalf("expected keyring primary to rotate to k2, got %s", kr.PrimaryID())
+    }
+}
+
+func TestGossipProtocol_HandleGossipPacketIgnoresForgedKeyRotationHint(t *testing.T) {
+    key1 := make([]byte, network.KeySize)
+    key2 := make([]byte, network.KeySize)
+    for i := range key2 {
+        key2[i] = 1
+    }
+
+    kr, err := network.NewKeyring("k1", key1, false)
+    if err != nil {
+        t.Fatalf("NewKeyring: %v", err)
+    }
+    if err := kr.AddKey("k2", key2); err != nil {
+        t.Fatalf("AddKey: %v", err)
+    }
+
+    gp := newTestGossipProtocol("node-1")
+    gp.SetKeyring(kr)
+
+    // An attacker who can inject a gossip packet but doesn't hold k2's
+    // secret bytes can still name k2 as the target - only a valid MAC
+    // should be able to trigger the rotation.
+    gp.HandleGossipPacket(models.GossipPacket{
+        From:            "node-2",
+        Versions:        map[string]int64{},
+        KeyRotationHint: &models.KeyRotationHint{KeyID: "k2", MAC: []byte("forged")},
+    })
+
+    if kr.PrimaryID() != "k1" {
+        t.Fatalf("expected a forged rotation hint to be ignored, but primary became %s", kr.PrimaryID())
+    }
+}
+
+// waitFor polls cond for up to ~1s, since delegate callbacks fire from a
+// goroutine spawned outside GossipProtocol's mutex.
+func waitFor(cond func() bool) bool {
+    for i := 0; i < 100; i++ {
+        if cond() {
+            return true
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    return false
+}
@@ -1,26 +1,152 @@
 package cluster
 
 import (
+    "bytes"
     "crypto/sha256"
     "encoding/hex"
     "fmt"
     "sort"
+
+    "golang.org/x/crypto/blake2b"
+)
+
+// treeLeafPrefix and treeNodePrefix domain-separate MerkleTree's leaf and
+// internal-node hashes (H(0x00||leaf) vs. H(0x01||left||right), per RFC
+// 6962 section 2.1 - the same scheme CompactLog in merkle_log.go and
+// SparseMerkleTree in sparse_merkle.go already use). Without this, an
+// attacker who knows a subtree pair (L,R) could craft a "leaf" whose bytes
+// equal L||R and forge a valid proof for it, since a plain undifferentiated
+// hash can't tell a leaf from an internal node.
+const (
+    treeLeafPrefix byte = 0x00
+    treeNodePrefix byte = 0x01
 )
 
+// treeHashVersion identifies this domain-separation scheme itself (not the
+// hash function used under it - see TreeParams.HashName for that), so a
+// future change to how leaves/nodes are framed can be distinguished from
+// today's RFC 6962-style v1 framing.
+const treeHashVersion byte = 1
+
+// HashFunc computes a content hash and returns it hex-encoded, the same
+// convention hashData has always used. NewMerkleTreeWithHashFunc lets a
+// caller plug in a faster or stronger algorithm than the SHA-256 default
+// (e.g. BLAKE2bHash for the higher-throughput workloads in the benchmarks)
+// without touching buildTree/combineChildHashes, which stay generic over
+// whichever HashFunc built the tree.
+type HashFunc func(data []byte) string
+
+// Hash function names usable in TreeParams.HashName / NewMerkleTreeWithHashFunc.
+const (
+    HashSHA256  = "sha256"
+    HashBLAKE2b = "blake2b-256"
+)
+
+// SHA256Hash is the default HashFunc, and the one the package-level
+// hashData/combineChildHashes (and therefore the plain NewMerkleTree and
+// VerifyProof) always use.
+func SHA256Hash(data []byte) string {
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
+// BLAKE2bHash is a higher-throughput alternative to SHA256Hash for large
+// trees, using the already-vendored golang.org/x/crypto/blake2b rather than
+// pulling in a new dependency for BLAKE3, which this module does not
+// currently have available.
+func BLAKE2bHash(data []byte) string {
+    sum := blake2b.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
+func hashFuncByName(name string) (HashFunc, bool) {
+    switch name {
+    case HashSHA256, "":
+        return SHA256Hash, true
+    case HashBLAKE2b:
+        return BLAKE2bHash, true
+    default:
+        return nil, false
+    }
+}
+
+// TreeParams records which hash function and domain-separation scheme
+// version produced a MerkleTree's root hash, so a verifier can reconstruct
+// the same leaf/node framing independently of the tree that built it
+// (see VerifyProofWithParams). The zero value means HashSHA256/v1 - the
+// same default NewMerkleTree and VerifyProof have always used.
+type TreeParams struct {
+    HashName string `json:"hash_name,omitempty"`
+    Version  byte   `json:"version,omitempty"`
+}
+
+func defaultTreeParams() TreeParams {
+    return TreeParams{HashName: HashSHA256, Version: treeHashVersion}
+}
+
+// treeHashLeaf domain-separates leaf data before hashing with h - see
+// treeLeafPrefix.
+func treeHashLeaf(h HashFunc, data string) string {
+    buf := make([]byte, 0, 1+len(data))
+    buf = append(buf, treeLeafPrefix)
+    buf = append(buf, data...)
+    return h(buf)
+}
+
+// treeHashNode domain-separates two child hashes before hashing with h - see
+// treeNodePrefix. Children are ordered lexicographically rather than
+// left-to-right before concatenating, which is how buildTree has always
+// combined them - proofs and verification must agree with that ordering,
+// not positional order.
+func treeHashNode(h HashFunc, a, b string) string {
+    if a > b {
+        a, b = b, a
+    }
+    buf := make([]byte, 0, 1+len(a)+len(b))
+    buf = append(buf, treeNodePrefix)
+    buf = append(buf, a...)
+    buf = append(buf, b...)
+    return h(buf)
+}
+
 type MerkleTree struct {
     Root *MerkleNode
+
+    hashFunc HashFunc
+    hashName string
 }
 
 type MerkleNode struct {
     Hash  string
     Left  *MerkleNode
     Right *MerkleNode
-    Data  string 
+    Data  string
 }
 
 func NewMerkleTree(data []string) *MerkleTree {
+    tree, err := NewMerkleTreeWithHashFunc(data, HashSHA256, SHA256Hash)
+    if err != nil {
+        // SHA256Hash is always a valid registered HashFunc, so this can't happen.
+        panic(err)
+    }
+    return tree
+}
+
+// NewMerkleTreeWithHashFunc is NewMerkleTree with a pluggable hash
+// algorithm: hashName must be HashSHA256 or HashBLAKE2b and must match
+// hashFunc, since it is what Params()/TreeParams advertises to verifiers -
+// VerifyProofWithParams looks hashName up by name rather than trusting
+// hashFunc's identity, so the two have to agree.
+func NewMerkleTreeWithHashFunc(data []string, hashName string, hashFunc HashFunc) (*MerkleTree, error) {
+    if _, ok := hashFuncByName(hashName); !ok {
+        return nil, fmt.Errorf("merkle tree: unknown hash function %q", hashName)
+    }
+    if hashFunc == nil {
+        return nil, fmt.Errorf("merkle tree: hashFunc is required")
+    }
+
     if len(data) == 0 {
-        return &MerkleTree{Root: nil}
+        return &MerkleTree{Root: nil, hashFunc: hashFunc, hashName: hashName}, nil
     }
 
     sortedData := make([]string, len(data))
@@ -29,19 +155,25 @@ func NewMerkleTree(data []string) *MerkleTree {
 
     nodes := make([]*MerkleNode, len(sortedData))
     for i, d := range sortedData {
-        hash := hashData(d)
         nodes[i] = &MerkleNode{
-            Hash: hash,
+            Hash: treeHashLeaf(hashFunc, d),
             Data: d,
         }
     }
 
-    root := buildTree(nodes)
+    root := buildTree(nodes, hashFunc)
 
-    return &MerkleTree{Root: root}
+    return &MerkleTree{Root: root, hashFunc: hashFunc, hashName: hashName}, nil
 }
 
-func buildTree(nodes []*MerkleNode) *MerkleNode {
+// Params reports the hash function and domain-separation version that
+// produced this tree's hashes - pass it alongside the root hash so a peer
+// knows how to verify a proof against it (see VerifyProofWithParams).
+func (mt *MerkleTree) Params() TreeParams {
+    return TreeParams{HashName: mt.hashName, Version: treeHashVersion}
+}
+
+func buildTree(nodes []*MerkleNode, h HashFunc) *MerkleNode {
     if len(nodes) == 0 {
         return nil
     }
@@ -63,14 +195,8 @@ for i := 0; i < len(nodes); i += 2 {
         right = nodes[i]
     }
 
-    var combinedHash string
-    if left.Hash < right.Hash {
-        combinedHash = left.Hash + right.Hash
-    } else {
-        combinedHash = right.Hash + left.Hash
-    }
-    parentHash := hashData(combinedHash)
-    
+    parentHash := treeHashNode(h, left.Hash, right.Hash)
+
     parent := &MerkleNode{
         Hash:  parentHash,
         Left:  left,
@@ -80,7 +206,7 @@ for i := 0; i < len(nodes); i += 2 {
     parentNodes = append(parentNodes, parent)
 }
 
-    return buildTree(parentNodes)
+    return buildTree(parentNodes, h)
 }
 
 func (mt *MerkleTree) GetRootHash() string {
@@ -95,7 +221,7 @@ func (mt *MerkleTree) GetProof(data string) ([]string, error) {
         return nil, fmt.Errorf("empty tree")
     }
 
-    targetHash := hashData(data)
+    targetHash := treeHashLeaf(mt.hashFunc, data)
     proof := []string{}
 
     if !findProof(mt.Root, targetHash, &proof) {
@@ -131,18 +257,46 @@ func findProof(node *MerkleNode, targetHash string, proof *[]string) bool {
     return false
 }
 
+// VerifyProof verifies proof against rootHash assuming the default
+// SHA256Hash/v1 domain-separation scheme - what NewMerkleTree always
+// builds. A tree built by NewMerkleTreeWithHashFunc needs its TreeParams
+// (see Params) passed to VerifyProofWithParams instead, since a different
+// hash function produces different leaf/node hashes entirely.
 func VerifyProof(rootHash string, data string, proof []string) bool {
-    currentHash := hashData(data)
+    ok, err := VerifyProofWithParams(defaultTreeParams(), rootHash, data, proof)
+    return err == nil && ok
+}
+
+// VerifyProofWithParams is VerifyProof plus the TreeParams a MerkleTree's
+// Params() method returned when the tree was built, so a proof produced
+// with a non-default HashFunc (see NewMerkleTreeWithHashFunc) can still be
+// verified correctly: params.HashName selects the same domain-separated
+// hash function the tree used, rather than assuming SHA-256.
+func VerifyProofWithParams(params TreeParams, rootHash string, data string, proof []string) (bool, error) {
+    if params.Version != 0 && params.Version != treeHashVersion {
+        return false, fmt.Errorf("merkle tree: unsupported domain-separation version %d", params.Version)
+    }
+    h, ok := hashFuncByName(params.HashName)
+    if !ok {
+        return false, fmt.Errorf("merkle tree: unknown hash function %q", params.HashName)
+    }
 
+    currentHash := treeHashLeaf(h, data)
     for _, siblingHash := range proof {
-        if currentHash < siblingHash {
-            currentHash = hashData(currentHash + siblingHash)
-        } else {
-            currentHash = hashData(siblingHash + currentHash)
-        }
+        currentHash = treeHashNode(h, currentHash, siblingHash)
     }
 
-    return currentHash == rootHash
+    return currentHash == rootHash, nil
+}
+
+// combineChildHashes hashes two child hashes into their parent's hash,
+// using the default SHA-256 domain-separated scheme (see hashNode). This is
+// what every MerkleTree built by plain NewMerkleTree uses, and what all of
+// this package's other Merkle structures (merkle_block.go, range_proof.go,
+// persistent_merkle.go, range_reconcile.go) assume when combining hashes
+// for such a tree.
+func combineChildHashes(a, b string) string {
+    return treeHashNode(SHA256Hash, a, b)
 }
 
 func CompareTrees(tree1, tree2 *MerkleTree) []string {
@@ -187,9 +341,13 @@ func compareDFS(node1, node2 *MerkleNode, differences *[]string) {
     }
 }
 
+// hashData is the default (SHA-256, domain-separated-as-a-leaf) leaf hash,
+// used directly by merkle_block.go, range_proof.go, persistent_merkle.go
+// and range_reconcile.go - all of which only ever build trees via plain
+// NewMerkleTree, so they can assume this scheme without carrying a
+// HashFunc/TreeParams of their own.
 func hashData(data string) string {
-    hash := sha256.Sum256([]byte(data))
-    return hex.EncodeToString(hash[:])
+    return treeHashLeaf(SHA256Hash, data)
 }
 
 func (mt *MerkleTree) GetAllLeafData() []string {
@@ -217,9 +375,10 @@ func collectLeaves(node *MerkleNode, leaves *[]string) {
 }
 
 type MerkleSync struct {
-    LocalTree   *MerkleTree
-    RemoteHash  string
-    Differences []string
+    LocalTree       *MerkleTree
+    LocalSparseTree *SparseMerkleTree
+    RemoteHash      string
+    Differences     []string
 }
 
 func NewMerkleSync(localData []string, remoteHash string) *MerkleSync {
@@ -230,10 +389,43 @@ func NewMerkleSync(localData []string, remoteHash string) *MerkleSync {
     }
 }
 
+// NewSparseMerkleSync is like NewMerkleSync but also builds a
+// SparseMerkleTree (keyed and valued by localData itself) for peers that
+// advertise sparse-proof support, see GenerateSyncPlanPreferSparse.
+func NewSparseMerkleSync(localData []string, remoteHash string) *MerkleSync {
+    sparse := NewSparseMerkleTree()
+    for _, d := range localData {
+        sparse.Put([]byte(d), []byte(d))
+    }
+
+    return &MerkleSync{
+        LocalTree:       NewMerkleTree(localData),
+        LocalSparseTree: sparse,
+        RemoteHash:      remoteHash,
+        Differences:     []string{},
+    }
+}
+
 func (ms *MerkleSync) NeedSync() bool {
     return ms.LocalTree.GetRootHash() != ms.RemoteHash
 }
 
 func (ms *MerkleSync) GenerateSyncPlan(remoteTree *MerkleTree) []string {
     return CompareTrees(ms.LocalTree, remoteTree)
+}
+
+// GenerateSyncPlanPreferSparse uses the sparse tree's root hash as a cheap
+// equality check when both sides advertise sparse support (ms.LocalSparseTree
+// is populated and remoteSupportsSparse is true): matching roots mean no
+// sync is needed at all. Otherwise, or if the sparse roots differ, it falls
+// back to the existing leaf-by-leaf plan via the legacy MerkleTree, since
+// there is no sparse-tree-to-sparse-tree diff exchange yet - only the root
+// comparison is accelerated, not the reconciliation itself.
+func (ms *MerkleSync) GenerateSyncPlanPreferSparse(remoteTree *MerkleTree, remoteSparseRoot []byte, remoteSupportsSparse bool) []string {
+    if ms.LocalSparseTree != nil && remoteSupportsSparse {
+        if bytes.Equal(ms.LocalSparseTree.GetRootHash(), remoteSparseRoot) {
+            return []string{}
+        }
+    }
+    return ms.GenerateSyncPlan(remoteTree)
 }
\ No newline at end of file
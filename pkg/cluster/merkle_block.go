@@ -0,0 +1,233 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MerkleBlockPredicate selects which leaves a MerkleBlock should reveal,
+// either by the hash-ring range those leaves' keys fall into (see
+// ConsistentHashRing/BoundedRing) or by a plain string prefix (e.g. a
+// device ID). It's sent over the wire as part of a "merkle_block" request,
+// so the filter itself has to be data, not a closure.
+type MerkleBlockPredicate struct {
+	UseHashRange  bool   `json:"use_hash_range,omitempty"`
+	HashRangeFrom uint32 `json:"hash_range_from,omitempty"`
+	HashRangeTo   uint32 `json:"hash_range_to,omitempty"`
+	Prefix        string `json:"prefix,omitempty"`
+}
+
+// Matches reports whether leaf data satisfies the predicate.
+func (p MerkleBlockPredicate) Matches(data string) bool {
+	if p.UseHashRange {
+		h := hashKey(data)
+		if p.HashRangeFrom <= p.HashRangeTo {
+			return h >= p.HashRangeFrom && h < p.HashRangeTo
+		}
+		// A wrapped range (from > to) spans the ring's zero point.
+		return h >= p.HashRangeFrom || h < p.HashRangeTo
+	}
+	return strings.HasPrefix(data, p.Prefix)
+}
+
+// MerkleBlock is an SPV-style partial Merkle tree: enough of tree's
+// structure to prove that MatchedLeaves are really present under RootHash,
+// without shipping the other leaves at all. Flags and Hashes are a preorder
+// encoding of the tree - for each node, a true flag means "descend, its
+// children follow next"; a false flag means "here is this subtree's hash,
+// take it as-is" - and NumLeaves lets the receiver reconstruct the exact
+// same tree shape BuildMerkleBlock walked (including the odd-node
+// duplication MerkleTree's own buildTree uses) without needing any leaf
+// data itself.
+type MerkleBlock struct {
+	RootHash      string   `json:"root_hash"`
+	NumLeaves     int      `json:"num_leaves"`
+	Flags         []bool   `json:"flags"`
+	Hashes        []string `json:"hashes"`
+	MatchedLeaves []string `json:"matched_leaves"`
+}
+
+// BuildMerkleBlock walks tree in preorder, emitting flag 0 plus a subtree's
+// hash wherever none of its leaves match the predicate, and flag 1 plus a
+// recursive descent otherwise - exactly the algorithm Bitcoin's SPV partial
+// Merkle trees use for "give me proof for just these transactions".
+func BuildMerkleBlock(tree *MerkleTree, predicate MerkleBlockPredicate) (*MerkleBlock, error) {
+	if tree.Root == nil {
+		return nil, fmt.Errorf("empty tree")
+	}
+
+	mb := &MerkleBlock{RootHash: tree.GetRootHash(), NumLeaves: countLeaves(tree.Root)}
+	buildMerkleBlock(tree.Root, predicate, mb)
+	return mb, nil
+}
+
+func countLeaves(node *MerkleNode) int {
+	if node.Left == nil && node.Right == nil {
+		return 1
+	}
+	count := countLeaves(node.Left)
+	if node.Right != node.Left {
+		count += countLeaves(node.Right)
+	}
+	return count
+}
+
+func buildMerkleBlock(node *MerkleNode, predicate MerkleBlockPredicate, mb *MerkleBlock) bool {
+	if node.Left == nil && node.Right == nil {
+		isMatch := predicate.Matches(node.Data)
+		mb.Flags = append(mb.Flags, isMatch)
+		mb.Hashes = append(mb.Hashes, node.Hash)
+		if isMatch {
+			mb.MatchedLeaves = append(mb.MatchedLeaves, node.Data)
+		}
+		return isMatch
+	}
+
+	if !subtreeHasMatch(node, predicate) {
+		mb.Flags = append(mb.Flags, false)
+		mb.Hashes = append(mb.Hashes, node.Hash)
+		return false
+	}
+
+	mb.Flags = append(mb.Flags, true)
+	buildMerkleBlock(node.Left, predicate, mb)
+	if node.Right != node.Left {
+		buildMerkleBlock(node.Right, predicate, mb)
+	}
+	return true
+}
+
+func subtreeHasMatch(node *MerkleNode, predicate MerkleBlockPredicate) bool {
+	if node.Left == nil && node.Right == nil {
+		return predicate.Matches(node.Data)
+	}
+	if subtreeHasMatch(node.Left, predicate) {
+		return true
+	}
+	if node.Right == node.Left {
+		return false
+	}
+	return subtreeHasMatch(node.Right, predicate)
+}
+
+// merkleBlockCursor walks a MerkleBlock's Flags/Hashes/MatchedLeaves in the
+// same preorder BuildMerkleBlock produced them in, failing closed on any
+// index that runs past the end - the encoding is malformed or was tampered
+// with rather than merely mismatched.
+type merkleBlockCursor struct {
+	mb      *MerkleBlock
+	flagPos int
+	hashPos int
+	leafPos int
+}
+
+func (c *merkleBlockCursor) nextFlag() (bool, error) {
+	if c.flagPos >= len(c.mb.Flags) {
+		return false, fmt.Errorf("merkle block: ran out of flags")
+	}
+	f := c.mb.Flags[c.flagPos]
+	c.flagPos++
+	return f, nil
+}
+
+func (c *merkleBlockCursor) nextHash() (string, error) {
+	if c.hashPos >= len(c.mb.Hashes) {
+		return "", fmt.Errorf("merkle block: ran out of hashes")
+	}
+	h := c.mb.Hashes[c.hashPos]
+	c.hashPos++
+	return h, nil
+}
+
+func (c *merkleBlockCursor) nextMatchedLeaf() (string, error) {
+	if c.leafPos >= len(c.mb.MatchedLeaves) {
+		return "", fmt.Errorf("merkle block: ran out of matched leaves")
+	}
+	l := c.mb.MatchedLeaves[c.leafPos]
+	c.leafPos++
+	return l, nil
+}
+
+// levelSizes returns the number of nodes at every level of a MerkleTree
+// built from numLeaves leaves, level 0 being the leaves and the last entry
+// always 1 (the root). Each level halves the one below it, rounding up and
+// duplicating the odd node out - the same reduction buildTree performs.
+func levelSizes(numLeaves int) []int {
+	sizes := []int{numLeaves}
+	for sizes[len(sizes)-1] > 1 {
+		sizes = append(sizes, (sizes[len(sizes)-1]+1)/2)
+	}
+	return sizes
+}
+
+// Verify reconstructs the root hash implied by the block's flags and
+// hashes and checks it against RootHash. On success it returns the
+// authenticated matched leaves; a mismatch at any point - wrong root,
+// malformed flags, a matched leaf whose hash doesn't land where the flags
+// say it should - is an error rather than a partial result.
+func (mb *MerkleBlock) Verify() ([]string, error) {
+	if mb.NumLeaves <= 0 {
+		return nil, fmt.Errorf("merkle block: invalid leaf count %d", mb.NumLeaves)
+	}
+
+	sizes := levelSizes(mb.NumLeaves)
+	height := len(sizes) - 1
+
+	cursor := &merkleBlockCursor{mb: mb}
+	rootHash, err := verifyMerkleBlockNode(cursor, sizes, height, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if rootHash != mb.RootHash {
+		return nil, fmt.Errorf("merkle block: reconstructed root %s does not match advertised root %s", rootHash, mb.RootHash)
+	}
+	if cursor.leafPos != len(mb.MatchedLeaves) {
+		return nil, fmt.Errorf("merkle block: %d matched leaves supplied but only %d consumed", len(mb.MatchedLeaves), cursor.leafPos)
+	}
+
+	return mb.MatchedLeaves, nil
+}
+
+func verifyMerkleBlockNode(cursor *merkleBlockCursor, sizes []int, level, pos int) (string, error) {
+	flag, err := cursor.nextFlag()
+	if err != nil {
+		return "", err
+	}
+
+	if level == 0 {
+		hash, err := cursor.nextHash()
+		if err != nil {
+			return "", err
+		}
+		if flag {
+			leaf, err := cursor.nextMatchedLeaf()
+			if err != nil {
+				return "", err
+			}
+			if hashData(leaf) != hash {
+				return "", fmt.Errorf("merkle block: matched leaf %q does not hash to the claimed leaf hash", leaf)
+			}
+		}
+		return hash, nil
+	}
+
+	if !flag {
+		return cursor.nextHash()
+	}
+
+	leftHash, err := verifyMerkleBlockNode(cursor, sizes, level-1, pos*2)
+	if err != nil {
+		return "", err
+	}
+
+	rightHash := leftHash
+	if pos*2+1 < sizes[level-1] {
+		rightHash, err = verifyMerkleBlockNode(cursor, sizes, level-1, pos*2+1)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return combineChildHashes(leftHash, rightHash), nil
+}
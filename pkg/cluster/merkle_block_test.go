@@ -0,0 +1,216 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMerkleBlock_BuildAndVerify(t *testing.T) {
+	data := []string{"device-A:1", "device-B:1", "device-A:2", "device-C:1"}
+	tree := NewMerkleTree(data)
+
+	predicate := MerkleBlockPredicate{Prefix: "device-A:"}
+	block, err := BuildMerkleBlock(tree, predicate)
+	if err != nil {
+		t.Fatalf("BuildMerkleBlock failed: %v", err)
+	}
+
+	matched, err := block.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	if len(matched) != 2 {
+		t.Fatalf("expected 2 matched leaves, got %d: %v", len(matched), matched)
+	}
+	for _, m := range matched {
+		if !strings.HasPrefix(m, "device-A:") {
+			t.Errorf("matched leaf %q does not satisfy predicate", m)
+		}
+	}
+}
+
+func TestMerkleBlock_NoMatches(t *testing.T) {
+	data := []string{"device-A:1", "device-B:1", "device-A:2", "device-C:1"}
+	tree := NewMerkleTree(data)
+
+	block, err := BuildMerkleBlock(tree, MerkleBlockPredicate{Prefix: "device-Z:"})
+	if err != nil {
+		t.Fatalf("BuildMerkleBlock failed: %v", err)
+	}
+
+	matched, err := block.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(matched) != 0 {
+		t.Errorf("expected no matches, got %v", matched)
+	}
+
+	// Since nothing matched, the whole tree should collapse to a single
+	// flag=0 node carrying just the root hash.
+	if len(block.Flags) != 1 || block.Flags[0] {
+		t.Errorf("expected a single false flag for an all-miss block, got %v", block.Flags)
+	}
+	if len(block.Hashes) != 1 || block.Hashes[0] != tree.GetRootHash() {
+		t.Errorf("expected the single hash to be the root hash")
+	}
+}
+
+func TestMerkleBlock_AllMatch(t *testing.T) {
+	data := []string{"device-A:1", "device-A:2", "device-A:3"}
+	tree := NewMerkleTree(data)
+
+	block, err := BuildMerkleBlock(tree, MerkleBlockPredicate{Prefix: "device-A:"})
+	if err != nil {
+		t.Fatalf("BuildMerkleBlock failed: %v", err)
+	}
+
+	matched, err := block.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(matched) != len(data) {
+		t.Errorf("expected all %d leaves matched, got %d", len(data), len(matched))
+	}
+}
+
+func TestMerkleBlock_SingleLeafTree(t *testing.T) {
+	tree := NewMerkleTree([]string{"only-leaf"})
+
+	block, err := BuildMerkleBlock(tree, MerkleBlockPredicate{Prefix: "only"})
+	if err != nil {
+		t.Fatalf("BuildMerkleBlock failed: %v", err)
+	}
+
+	matched, err := block.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(matched) != 1 || matched[0] != "only-leaf" {
+		t.Errorf("expected [only-leaf], got %v", matched)
+	}
+}
+
+func TestMerkleBlock_EmptyTree(t *testing.T) {
+	tree := NewMerkleTree([]string{})
+	_, err := BuildMerkleBlock(tree, MerkleBlockPredicate{Prefix: "x"})
+	if err == nil {
+		t.Error("expected an error building a merkle block from an empty tree")
+	}
+}
+
+func TestMerkleBlock_OddNumberOfLeaves(t *testing.T) {
+	data := []string{"device-A:1", "device-B:1", "device-C:1"}
+	tree := NewMerkleTree(data)
+
+	for _, d := range data {
+		block, err := BuildMerkleBlock(tree, MerkleBlockPredicate{Prefix: d})
+		if err != nil {
+			t.Fatalf("BuildMerkleBlock failed for %s: %v", d, err)
+		}
+		matched, err := block.Verify()
+		if err != nil {
+			t.Fatalf("Verify failed for %s: %v", d, err)
+		}
+		if len(matched) != 1 || matched[0] != d {
+			t.Errorf("expected [%s], got %v", d, matched)
+		}
+	}
+}
+
+func TestMerkleBlock_HashRangePredicate(t *testing.T) {
+	data := make([]string, 200)
+	for i := 0; i < 200; i++ {
+		data[i] = fmt.Sprintf("device-%d:reading", i)
+	}
+	tree := NewMerkleTree(data)
+
+	predicate := MerkleBlockPredicate{UseHashRange: true, HashRangeFrom: 0, HashRangeTo: 1 << 30}
+	block, err := BuildMerkleBlock(tree, predicate)
+	if err != nil {
+		t.Fatalf("BuildMerkleBlock failed: %v", err)
+	}
+
+	matched, err := block.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	for _, m := range matched {
+		if !predicate.Matches(m) {
+			t.Errorf("matched leaf %q falls outside the requested hash range", m)
+		}
+	}
+
+	want := 0
+	for _, d := range data {
+		if predicate.Matches(d) {
+			want++
+		}
+	}
+	if len(matched) != want {
+		t.Errorf("expected %d leaves in range, got %d", want, len(matched))
+	}
+}
+
+func TestMerkleBlock_TamperedRootRejected(t *testing.T) {
+	data := []string{"device-A:1", "device-B:1", "device-A:2", "device-C:1"}
+	tree := NewMerkleTree(data)
+
+	block, err := BuildMerkleBlock(tree, MerkleBlockPredicate{Prefix: "device-A:"})
+	if err != nil {
+		t.Fatalf("BuildMerkleBlock failed: %v", err)
+	}
+
+	block.RootHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+	if _, err := block.Verify(); err == nil {
+		t.Error("expected Verify to reject a tampered root hash")
+	}
+}
+
+func TestMerkleBlock_TamperedMatchedLeafRejected(t *testing.T) {
+	data := []string{"device-A:1", "device-B:1", "device-A:2", "device-C:1"}
+	tree := NewMerkleTree(data)
+
+	block, err := BuildMerkleBlock(tree, MerkleBlockPredicate{Prefix: "device-A:"})
+	if err != nil {
+		t.Fatalf("BuildMerkleBlock failed: %v", err)
+	}
+
+	for i, leaf := range block.MatchedLeaves {
+		if leaf == "device-A:1" {
+			block.MatchedLeaves[i] = "device-A:99"
+		}
+	}
+
+	if _, err := block.Verify(); err == nil {
+		t.Error("expected Verify to reject a substituted matched leaf")
+	}
+}
+
+func TestMerkleBlock_LargeDataset(t *testing.T) {
+	data := make([]string, 1000)
+	for i := 0; i < 1000; i++ {
+		data[i] = fmt.Sprintf("device-%03d:temp:%.2f", i, 20.0+float64(i)*0.1)
+	}
+	tree := NewMerkleTree(data)
+
+	block, err := BuildMerkleBlock(tree, MerkleBlockPredicate{Prefix: "device-500:"})
+	if err != nil {
+		t.Fatalf("BuildMerkleBlock failed: %v", err)
+	}
+
+	matched, err := block.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(matched) != 1 {
+		t.Fatalf("expected exactly 1 match, got %d: %v", len(matched), matched)
+	}
+
+	t.Logf("merkle block for 1000 leaves carried %d flags and %d hashes to prove 1 leaf",
+		len(block.Flags), len(block.Hashes))
+}
@@ -0,0 +1,131 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// oldCombine reproduces combineChildHashes' pre-domain-separation behavior:
+// a plain SHA-256 of the two child hashes concatenated in lexicographic
+// order, with no prefix byte distinguishing "this is an internal node" from
+// "this is a leaf".
+func oldHashData(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func oldCombine(a, b string) string {
+	if a < b {
+		return oldHashData(a + b)
+	}
+	return oldHashData(b + a)
+}
+
+// TestMerkleTree_SecondPreimageForgeryFixed demonstrates the vulnerability
+// described for this change: under the old undifferentiated hashing scheme,
+// an attacker who knows a subtree's two child hashes (L,R) can craft a
+// "leaf" whose data is literally L||R, and that leaf hashes to exactly the
+// internal node's hash - the forged leaf is indistinguishable from the real
+// subtree at the hash level. Domain separation (treeLeafPrefix vs.
+// treeNodePrefix) closes this: the same crafted data now hashes somewhere
+// else entirely, because the leaf and node hashes are computed over
+// different prefixed byte strings.
+func TestMerkleTree_SecondPreimageForgeryFixed(t *testing.T) {
+	tree := NewMerkleTree([]string{"a", "b", "c", "d"})
+
+	// tree.Root's two children are each an internal node combining two of
+	// the four leaves; grab one of them and its own two children's hashes.
+	subtree := tree.Root.Left
+	if subtree == nil || subtree.Left == nil || subtree.Right == nil {
+		t.Fatal("expected a 2-level tree with an internal subtree")
+	}
+	left, right := subtree.Left.Hash, subtree.Right.Hash
+
+	forgedData := left + right
+	if left > right {
+		forgedData = right + left
+	}
+
+	// Under the old scheme this forged "leaf" collides with what the old
+	// combineChildHashes would have computed for the subtree - exactly the
+	// attack this change fixes. (subtree.Hash itself is already produced by
+	// the new, fixed scheme, so it's oldCombine we compare against, not it.)
+	if oldHashData(forgedData) != oldCombine(left, right) {
+		t.Fatal("test setup is wrong: old scheme should have collided here")
+	}
+
+	// Under the new, domain-separated scheme the same forged data must not
+	// produce a leaf hash equal to the subtree's (or any other node's) hash.
+	forgedLeafHash := treeHashLeaf(SHA256Hash, forgedData)
+	if forgedLeafHash == subtree.Hash {
+		t.Fatal("domain separation failed to prevent the leaf/node hash collision")
+	}
+
+	// And a tree that actually contains the forged string as a leaf does not
+	// produce the victim subtree's root hash, so no proof can be forged for it.
+	forgedTree := NewMerkleTree([]string{forgedData})
+	if forgedTree.GetRootHash() == subtree.Hash {
+		t.Fatal("a tree containing the forged leaf must not reproduce the subtree's root hash")
+	}
+}
+
+func TestMerkleTree_WithHashFunc_BLAKE2b(t *testing.T) {
+	data := []string{"device-A:1", "device-B:1", "device-C:1"}
+
+	tree, err := NewMerkleTreeWithHashFunc(data, HashBLAKE2b, BLAKE2bHash)
+	if err != nil {
+		t.Fatalf("NewMerkleTreeWithHashFunc failed: %v", err)
+	}
+
+	sha := NewMerkleTree(data)
+	if tree.GetRootHash() == sha.GetRootHash() {
+		t.Error("a BLAKE2b tree and a SHA-256 tree over the same data should not share a root hash")
+	}
+
+	if got := tree.Params(); got.HashName != HashBLAKE2b || got.Version != treeHashVersion {
+		t.Errorf("Params() = %+v, want HashName=%s Version=%d", got, HashBLAKE2b, treeHashVersion)
+	}
+
+	proof, err := tree.GetProof("device-B:1")
+	if err != nil {
+		t.Fatalf("GetProof failed: %v", err)
+	}
+
+	ok, err := VerifyProofWithParams(tree.Params(), tree.GetRootHash(), "device-B:1", proof)
+	if err != nil {
+		t.Fatalf("VerifyProofWithParams failed: %v", err)
+	}
+	if !ok {
+		t.Error("expected the BLAKE2b proof to verify")
+	}
+
+	// Verifying with the wrong params (defaulting to SHA-256) must fail
+	// rather than silently accept a proof from a different hash scheme.
+	wrongOK, err := VerifyProofWithParams(defaultTreeParams(), tree.GetRootHash(), "device-B:1", proof)
+	if err == nil && wrongOK {
+		t.Error("expected verification under mismatched TreeParams to fail")
+	}
+}
+
+func TestMerkleTree_WithHashFunc_UnknownName(t *testing.T) {
+	_, err := NewMerkleTreeWithHashFunc([]string{"x"}, "md5", SHA256Hash)
+	if err == nil {
+		t.Error("expected an error for an unregistered hash function name")
+	}
+}
+
+func TestVerifyProofWithParams_UnsupportedVersion(t *testing.T) {
+	tree := NewMerkleTree([]string{"a", "b", "c"})
+	proof, err := tree.GetProof("a")
+	if err != nil {
+		t.Fatalf("GetProof failed: %v", err)
+	}
+
+	params := tree.Params()
+	params.Version = treeHashVersion + 1
+
+	if _, err := VerifyProofWithParams(params, tree.GetRootHash(), "a", proof); err == nil {
+		t.Error("expected an error verifying against an unsupported domain-separation version")
+	}
+}
@@ -0,0 +1,135 @@
+package cluster
+
+import "testing"
+
+func TestCompactLog_RootChangesOnAppend(t *testing.T) {
+    log := NewCompactLog()
+    empty := log.GetRootHash()
+
+    log.AppendLeaf("a")
+    afterA := log.GetRootHash()
+    if afterA == empty {
+        t.Error("root hash should change after appending a leaf")
+    }
+
+    log.AppendLeaf("b")
+    afterB := log.GetRootHash()
+    if afterB == afterA {
+        t.Error("root hash should change after appending a second leaf")
+    }
+}
+
+func TestCompactLog_RootAtMatchesHistoricalRoot(t *testing.T) {
+    log := NewCompactLog()
+    var roots []string
+    for i := 0; i < 8; i++ {
+        log.AppendLeaf(string(rune('a' + i)))
+        roots = append(roots, log.GetRootHash())
+    }
+
+    for n := 1; n <= 8; n++ {
+        got, err := log.RootAt(n)
+        if err != nil {
+            t.Fatalf("RootAt(%d) failed: %v", n, err)
+        }
+        if got != roots[n-1] {
+            t.Errorf("RootAt(%d) = %s, want %s", n, got, roots[n-1])
+        }
+    }
+}
+
+func TestCompactLog_RootHasVersionPrefix(t *testing.T) {
+    log := NewCompactLog()
+    log.AppendLeaf("a")
+    root := log.GetRootHash()
+    if len(root) < len(logRootPrefix) || root[:len(logRootPrefix)] != logRootPrefix {
+        t.Errorf("root %q missing %q prefix", root, logRootPrefix)
+    }
+}
+
+func TestCompactLog_ConsistencyProofVerifies(t *testing.T) {
+    log := NewCompactLog()
+    var roots []string
+    for i := 0; i < 20; i++ {
+        log.AppendLeaf(string(rune('a' + i%26)))
+        roots = append(roots, log.GetRootHash())
+    }
+
+    for m := 1; m <= 20; m++ {
+        for n := m; n <= 20; n++ {
+            proof, err := log.ConsistencyProof(m, n)
+            if err != nil {
+                t.Fatalf("ConsistencyProof(%d, %d) failed: %v", m, n, err)
+            }
+            if !VerifyConsistencyProof(roots[m-1], roots[n-1], m, n, proof) {
+                t.Errorf("VerifyConsistencyProof(%d, %d) rejected a valid proof", m, n)
+            }
+        }
+    }
+}
+
+func TestCompactLog_ConsistencyProofRejectsTamperedRoot(t *testing.T) {
+    log := NewCompactLog()
+    var roots []string
+    for i := 0; i < 10; i++ {
+        log.AppendLeaf(string(rune('a' + i)))
+        roots = append(roots, log.GetRootHash())
+    }
+
+    proof, err := log.ConsistencyProof(3, 10)
+    if err != nil {
+        t.Fatalf("ConsistencyProof failed: %v", err)
+    }
+    if VerifyConsistencyProof(roots[2], "v1:0000000000000000000000000000000000000000000000000000000000000000", 3, 10, proof) {
+        t.Error("expected VerifyConsistencyProof to reject a tampered new root")
+    }
+    if VerifyConsistencyProof("v1:0000000000000000000000000000000000000000000000000000000000000000", roots[9], 3, 10, proof) {
+        t.Error("expected VerifyConsistencyProof to reject a tampered old root")
+    }
+}
+
+func TestCompactLog_ConsistencyProofAgainstEmptyTreeIsVacuous(t *testing.T) {
+    log := NewCompactLog()
+    log.AppendLeaf("a")
+    log.AppendLeaf("b")
+
+    proof, err := log.ConsistencyProof(0, 2)
+    if err != nil {
+        t.Fatalf("ConsistencyProof(0, 2) failed: %v", err)
+    }
+    if len(proof) != 0 {
+        t.Errorf("expected an empty proof against an empty old tree, got %v", proof)
+    }
+    if !VerifyConsistencyProof("v1:anything", log.GetRootHash(), 0, 2, proof) {
+        t.Error("consistency against an empty old tree should always verify")
+    }
+}
+
+func TestCompactLog_ConsistencyProofRejectsInvalidSizes(t *testing.T) {
+    log := NewCompactLog()
+    log.AppendLeaf("a")
+    log.AppendLeaf("b")
+
+    if _, err := log.ConsistencyProof(2, 5); err == nil {
+        t.Error("expected an error when newSize exceeds the log's size")
+    }
+    if _, err := log.ConsistencyProof(3, 1); err == nil {
+        t.Error("expected an error when oldSize exceeds newSize")
+    }
+}
+
+func TestLogLeafAndNodeHashesAreDomainSeparated(t *testing.T) {
+    // A leaf with the same bytes as a node's concatenated children should
+    // never collide, since they're hashed under different prefixes.
+    left := logLeafHash("a")
+    right := logLeafHash("b")
+    node := logNodeHash(left, right)
+
+    combined := string(left) + string(right)
+    if string(node) == combined {
+        t.Error("node hash should not equal the raw concatenation of its children")
+    }
+    if hashesEqual(node, logLeafHash(combined)) {
+        t.Error("a node hash collided with a leaf hash of the same underlying bytes")
+    }
+}
@@ -0,0 +1,146 @@
+package cluster
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MerkleStore persists MerkleTree nodes by their content hash, the same role
+// NodeDB plays for MutableTree: a PersistentMerkleTree keeps only the nodes
+// it is actively touching in memory and fetches everything else from here,
+// which is what lets a node hold a tree far larger than RAM and keep it
+// across restarts. Nodes being content-addressed also means identical
+// subtrees - duplicate runs of readings, repeated structure across devices -
+// collapse to a single stored entry instead of being duplicated per tree.
+type MerkleStore interface {
+	Get(hash string) ([]byte, error)
+	Put(hash string, data []byte) error
+	Delete(hash string) error
+	Batch() MerkleStoreBatch
+}
+
+// MerkleStoreBatch groups writes so a whole tree (or a whole changed
+// subtree) is persisted as a single atomic unit instead of node-by-node.
+type MerkleStoreBatch interface {
+	Put(hash string, data []byte)
+	Delete(hash string)
+	Write() error
+}
+
+// MemMerkleStore is an in-memory MerkleStore, the default: useful for tests
+// and for trees that don't need to survive a process restart.
+type MemMerkleStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func NewMemMerkleStore() *MemMerkleStore {
+	return &MemMerkleStore{data: make(map[string][]byte)}
+}
+
+func (s *MemMerkleStore) Get(hash string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.data[hash]
+	if !ok {
+		return nil, fmt.Errorf("merkle node %s not found", hash)
+	}
+	return v, nil
+}
+
+func (s *MemMerkleStore) Put(hash string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[hash] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *MemMerkleStore) Delete(hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, hash)
+	return nil
+}
+
+func (s *MemMerkleStore) Batch() MerkleStoreBatch {
+	return &memMerkleBatch{store: s}
+}
+
+type memMerkleBatch struct {
+	store   *MemMerkleStore
+	puts    map[string][]byte
+	deletes []string
+}
+
+func (b *memMerkleBatch) Put(hash string, data []byte) {
+	if b.puts == nil {
+		b.puts = make(map[string][]byte)
+	}
+	b.puts[hash] = append([]byte(nil), data...)
+}
+
+func (b *memMerkleBatch) Delete(hash string) {
+	b.deletes = append(b.deletes, hash)
+}
+
+func (b *memMerkleBatch) Write() error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+	for k, v := range b.puts {
+		b.store.data[k] = v
+	}
+	for _, k := range b.deletes {
+		delete(b.store.data, k)
+	}
+	return nil
+}
+
+// FileNodeDBMerkleStore adapts a NodeDB - FileNodeDB or MemNodeDB - into a
+// MerkleStore. This is the "generic KV adapter" rather than a BoltDB or
+// BadgerDB binding: this repo hand-rolls its own on-disk formats instead of
+// vendoring a third-party embedded store, the same tradeoff it already makes
+// for its write-ahead log, gossip protocol and NodeDB itself, so reusing
+// NodeDB's append-only file format is the path of least surprise. Anything
+// that already speaks the NodeDB interface - including a future real
+// BoltDB/BadgerDB-backed NodeDB - gets MerkleStore support for free.
+type FileNodeDBMerkleStore struct {
+	db NodeDB
+}
+
+// NewFileNodeDBMerkleStore wraps db (typically a *FileNodeDB opened with
+// NewFileNodeDB) as a MerkleStore.
+func NewFileNodeDBMerkleStore(db NodeDB) *FileNodeDBMerkleStore {
+	return &FileNodeDBMerkleStore{db: db}
+}
+
+func (s *FileNodeDBMerkleStore) Get(hash string) ([]byte, error) {
+	return s.db.Get([]byte(hash))
+}
+
+func (s *FileNodeDBMerkleStore) Put(hash string, data []byte) error {
+	return s.db.Set([]byte(hash), data)
+}
+
+func (s *FileNodeDBMerkleStore) Delete(hash string) error {
+	return s.db.Delete([]byte(hash))
+}
+
+func (s *FileNodeDBMerkleStore) Batch() MerkleStoreBatch {
+	return &fileNodeDBMerkleBatch{batch: s.db.Batch()}
+}
+
+type fileNodeDBMerkleBatch struct {
+	batch NodeBatch
+}
+
+func (b *fileNodeDBMerkleBatch) Put(hash string, data []byte) {
+	b.batch.Set([]byte(hash), data)
+}
+
+func (b *fileNodeDBMerkleBatch) Delete(hash string) {
+	b.batch.Delete([]byte(hash))
+}
+
+func (b *fileNodeDBMerkleBatch) Write() error {
+	return b.batch.Write()
+}
@@ -0,0 +1,277 @@
+package cluster
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// NodeDB stores MutableTree nodes by content hash. Only dirty nodes are
+// held in memory by the tree itself; everything else is fetched from here
+// on demand, which is what lets a tree with years of versions stay small in
+// RAM.
+type NodeDB interface {
+	Get(hash []byte) ([]byte, error)
+	Set(hash []byte, data []byte) error
+	Delete(hash []byte) error
+	Batch() NodeBatch
+}
+
+// NodeBatch groups writes so SaveVersion/DeleteVersion apply as a single
+// atomic unit instead of node-by-node.
+type NodeBatch interface {
+	Set(hash, data []byte)
+	Delete(hash []byte)
+	Write() error
+}
+
+// MemNodeDB is an in-memory NodeDB, useful for tests and for trees that
+// don't need to survive a process restart.
+type MemNodeDB struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func NewMemNodeDB() *MemNodeDB {
+	return &MemNodeDB{data: make(map[string][]byte)}
+}
+
+func (db *MemNodeDB) Get(hash []byte) ([]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	v, ok := db.data[string(hash)]
+	if !ok {
+		return nil, fmt.Errorf("node %x not found", hash)
+	}
+	return v, nil
+}
+
+func (db *MemNodeDB) Set(hash, data []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.data[string(hash)] = data
+	return nil
+}
+
+func (db *MemNodeDB) Delete(hash []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	delete(db.data, string(hash))
+	return nil
+}
+
+func (db *MemNodeDB) Batch() NodeBatch {
+	return &memBatch{db: db}
+}
+
+type memBatch struct {
+	db      *MemNodeDB
+	sets    map[string][]byte
+	deletes []string
+}
+
+func (b *memBatch) Set(hash, data []byte) {
+	if b.sets == nil {
+		b.sets = make(map[string][]byte)
+	}
+	b.sets[string(hash)] = append([]byte(nil), data...)
+}
+
+func (b *memBatch) Delete(hash []byte) {
+	b.deletes = append(b.deletes, string(hash))
+}
+
+func (b *memBatch) Write() error {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+	for k, v := range b.sets {
+		b.db.data[k] = v
+	}
+	for _, k := range b.deletes {
+		delete(b.db.data, k)
+	}
+	return nil
+}
+
+// FileNodeDB is a durable NodeDB backed by a single append-only log file:
+// every Set/Delete is appended as a record and replayed to rebuild an
+// in-memory index on open. It plays the role a BoltDB or Pebble store would
+// in a production deployment; this repo hand-rolls the on-disk format
+// rather than vendoring one of those, the same tradeoff it already makes
+// for its write-ahead log and gossip protocol. Unlike a lazy-loading store
+// it keeps every value resident in memory once loaded, trading strict
+// lazy-loading for a much smaller implementation.
+type FileNodeDB struct {
+	mu    sync.Mutex
+	file  *os.File
+	index map[string][]byte
+}
+
+const (
+	fileNodeDBOpSet    byte = 1
+	fileNodeDBOpDelete byte = 2
+)
+
+func NewFileNodeDB(path string) (*FileNodeDB, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open node db file: %w", err)
+	}
+
+	db := &FileNodeDB{file: f, index: make(map[string][]byte)}
+	if err := db.replay(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to replay node db file: %w", err)
+	}
+	return db, nil
+}
+
+func (db *FileNodeDB) replay() error {
+	if _, err := db.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(db.file)
+
+	for {
+		op, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		hash, err := readLengthPrefixed(r)
+		if err != nil {
+			return err
+		}
+
+		switch op {
+		case fileNodeDBOpSet:
+			value, err := readLengthPrefixed(r)
+			if err != nil {
+				return err
+			}
+			db.index[string(hash)] = value
+		case fileNodeDBOpDelete:
+			delete(db.index, string(hash))
+		default:
+			return fmt.Errorf("unknown node db op byte 0x%x", op)
+		}
+	}
+
+	if _, err := db.file.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	return nil
+}
+
+func readLengthPrefixed(r *bufio.Reader) ([]byte, error) {
+	lenBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, lenBytes); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(lenBytes)
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func appendRecord(w io.Writer, op byte, hash, value []byte) error {
+	buf := make([]byte, 0, 1+4+len(hash)+4+len(value))
+	buf = append(buf, op)
+	buf = appendLengthPrefixed(buf, hash)
+	if op == fileNodeDBOpSet {
+		buf = appendLengthPrefixed(buf, value)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func appendLengthPrefixed(buf, data []byte) []byte {
+	lenBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(lenBytes, uint32(len(data)))
+	buf = append(buf, lenBytes...)
+	return append(buf, data...)
+}
+
+func (db *FileNodeDB) Get(hash []byte) ([]byte, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	v, ok := db.index[string(hash)]
+	if !ok {
+		return nil, fmt.Errorf("node %x not found", hash)
+	}
+	return v, nil
+}
+
+func (db *FileNodeDB) Set(hash, data []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err := appendRecord(db.file, fileNodeDBOpSet, hash, data); err != nil {
+		return err
+	}
+	db.index[string(hash)] = append([]byte(nil), data...)
+	return nil
+}
+
+func (db *FileNodeDB) Delete(hash []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err := appendRecord(db.file, fileNodeDBOpDelete, hash, nil); err != nil {
+		return err
+	}
+	delete(db.index, string(hash))
+	return nil
+}
+
+func (db *FileNodeDB) Batch() NodeBatch {
+	return &fileBatch{db: db}
+}
+
+func (db *FileNodeDB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.file.Close()
+}
+
+type fileBatch struct {
+	db      *FileNodeDB
+	records []fileBatchRecord
+}
+
+type fileBatchRecord struct {
+	op    byte
+	hash  []byte
+	value []byte
+}
+
+func (b *fileBatch) Set(hash, data []byte) {
+	b.records = append(b.records, fileBatchRecord{op: fileNodeDBOpSet, hash: hash, value: data})
+}
+
+func (b *fileBatch) Delete(hash []byte) {
+	b.records = append(b.records, fileBatchRecord{op: fileNodeDBOpDelete, hash: hash})
+}
+
+func (b *fileBatch) Write() error {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+
+	for _, rec := range b.records {
+		if err := appendRecord(b.db.file, rec.op, rec.hash, rec.value); err != nil {
+			return err
+		}
+		if rec.op == fileNodeDBOpSet {
+			b.db.index[string(rec.hash)] = append([]byte(nil), rec.value...)
+		} else {
+			delete(b.db.index, string(rec.hash))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sort"
+)
+
+// persistedMerkleNode is the gob-encoded representation of a PersistentMerkleTree
+// node stored under its own content hash. A leaf has Data set and both hash
+// fields empty; an internal node has both hash fields set and no Data.
+type persistedMerkleNode struct {
+	LeftHash  string
+	RightHash string
+	Data      string
+}
+
+func encodeMerkleNode(n persistedMerkleNode) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeMerkleNode(data []byte) (persistedMerkleNode, error) {
+	var n persistedMerkleNode
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&n)
+	return n, err
+}
+
+// PersistentMerkleTree is a MerkleTree whose nodes live in a MerkleStore
+// instead of an in-memory pointer tree. It only ever holds its root hash;
+// every traversal (GetProof, ComparePersistentTrees) fetches nodes from the
+// store on demand, so a tree far larger than RAM - or one restored after a
+// restart - costs no more to hold than this struct itself.
+type PersistentMerkleTree struct {
+	store    MerkleStore
+	rootHash string
+}
+
+// NewPersistentMerkleTree wraps an existing tree already written to store
+// under rootHash, e.g. one returned by a previous BuildPersistentMerkleTree
+// or recovered from disk after a restart. rootHash may be empty, denoting
+// an empty tree.
+func NewPersistentMerkleTree(store MerkleStore, rootHash string) *PersistentMerkleTree {
+	return &PersistentMerkleTree{store: store, rootHash: rootHash}
+}
+
+// BuildPersistentMerkleTree builds a tree from data the same way
+// NewMerkleTree does - sorted leaves, pairwise combined bottom-up, odd node
+// duplicated - but writes every node to store as it goes instead of keeping
+// the tree in memory, and returns only the resulting root hash wrapped in a
+// PersistentMerkleTree. Because nodes are content-addressed, identical
+// subtrees across calls (or across trees sharing the same store) are
+// written once and simply reused.
+func BuildPersistentMerkleTree(store MerkleStore, data []string) (*PersistentMerkleTree, error) {
+	if len(data) == 0 {
+		return &PersistentMerkleTree{store: store}, nil
+	}
+
+	sortedData := make([]string, len(data))
+	copy(sortedData, data)
+	sort.Strings(sortedData)
+
+	batch := store.Batch()
+
+	level := make([]string, len(sortedData))
+	for i, d := range sortedData {
+		hash := hashData(d)
+		encoded, err := encodeMerkleNode(persistedMerkleNode{Data: d})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode leaf node: %w", err)
+		}
+		batch.Put(hash, encoded)
+		level[i] = hash
+	}
+
+	for len(level) > 1 {
+		next := make([]string, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			left := level[i]
+			right := left
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+
+			parentHash := combineChildHashes(left, right)
+			encoded, err := encodeMerkleNode(persistedMerkleNode{LeftHash: left, RightHash: right})
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode internal node: %w", err)
+			}
+			batch.Put(parentHash, encoded)
+			next = append(next, parentHash)
+		}
+		level = next
+	}
+
+	if err := batch.Write(); err != nil {
+		return nil, fmt.Errorf("failed to persist merkle tree: %w", err)
+	}
+
+	return &PersistentMerkleTree{store: store, rootHash: level[0]}, nil
+}
+
+// GetRootHash returns the tree's root hash, or "" for an empty tree.
+func (t *PersistentMerkleTree) GetRootHash() string {
+	return t.rootHash
+}
+
+func (t *PersistentMerkleTree) loadNode(hash string) (persistedMerkleNode, error) {
+	data, err := t.store.Get(hash)
+	if err != nil {
+		return persistedMerkleNode{}, fmt.Errorf("failed to load merkle node %s: %w", hash, err)
+	}
+	return decodeMerkleNode(data)
+}
+
+// GetProof returns the sibling hashes needed to verify data against the
+// root hash, lazy-loading only the nodes on the path to data rather than
+// the whole tree.
+func (t *PersistentMerkleTree) GetProof(data string) ([]string, error) {
+	if t.rootHash == "" {
+		return nil, fmt.Errorf("empty tree")
+	}
+
+	targetHash := hashData(data)
+	proof := []string{}
+
+	found, err := t.findProof(t.rootHash, targetHash, &proof)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("data not found in tree")
+	}
+
+	return proof, nil
+}
+
+func (t *PersistentMerkleTree) findProof(hash, targetHash string, proof *[]string) (bool, error) {
+	node, err := t.loadNode(hash)
+	if err != nil {
+		return false, err
+	}
+
+	if node.LeftHash == "" && node.RightHash == "" {
+		return hash == targetHash, nil
+	}
+
+	foundLeft, err := t.findProof(node.LeftHash, targetHash, proof)
+	if err != nil {
+		return false, err
+	}
+	if foundLeft {
+		*proof = append(*proof, node.RightHash)
+		return true, nil
+	}
+
+	foundRight, err := t.findProof(node.RightHash, targetHash, proof)
+	if err != nil {
+		return false, err
+	}
+	if foundRight {
+		*proof = append(*proof, node.LeftHash)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// ComparePersistentTrees walks tree1 and tree2 together, descending only
+// where hashes differ, and reports the same kind of differences CompareTrees
+// does for the in-memory MerkleTree. Unlike CompareTrees, it never
+// materializes either tree: matching subtrees are pruned by hash comparison
+// alone, and only the nodes on a path to a real difference are fetched from
+// the stores.
+func ComparePersistentTrees(tree1, tree2 *PersistentMerkleTree) ([]string, error) {
+	if tree1.rootHash == "" && tree2.rootHash == "" {
+		return []string{}, nil
+	}
+
+	if tree1.rootHash == "" || tree2.rootHash == "" {
+		return []string{"trees are fundamentally different"}, nil
+	}
+
+	if tree1.rootHash == tree2.rootHash {
+		return []string{}, nil
+	}
+
+	differences := []string{}
+	if err := comparePersistentDFS(tree1, tree2, tree1.rootHash, tree2.rootHash, &differences); err != nil {
+		return nil, err
+	}
+	return differences, nil
+}
+
+func comparePersistentDFS(tree1, tree2 *PersistentMerkleTree, hash1, hash2 string, differences *[]string) error {
+	if hash1 == hash2 {
+		return nil
+	}
+
+	node1, err := tree1.loadNode(hash1)
+	if err != nil {
+		return err
+	}
+	node2, err := tree2.loadNode(hash2)
+	if err != nil {
+		return err
+	}
+
+	isLeaf1 := node1.LeftHash == "" && node1.RightHash == ""
+	isLeaf2 := node2.LeftHash == "" && node2.RightHash == ""
+
+	if isLeaf1 != isLeaf2 {
+		*differences = append(*differences, "structure mismatch")
+		return nil
+	}
+
+	if isLeaf1 {
+		*differences = append(*differences, fmt.Sprintf("leaf mismatch: %s vs %s", node1.Data, node2.Data))
+		return nil
+	}
+
+	if err := comparePersistentDFS(tree1, tree2, node1.LeftHash, node2.LeftHash, differences); err != nil {
+		return err
+	}
+	return comparePersistentDFS(tree1, tree2, node1.RightHash, node2.RightHash, differences)
+}
@@ -0,0 +1,254 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+)
+
+// countingMerkleStore wraps a MerkleStore and counts Get calls, used to show
+// traversals only touch the nodes on the relevant path rather than the
+// whole tree.
+type countingMerkleStore struct {
+	MerkleStore
+	gets int
+}
+
+func (s *countingMerkleStore) Get(hash string) ([]byte, error) {
+	s.gets++
+	return s.MerkleStore.Get(hash)
+}
+
+func TestPersistentMerkleTree_Build(t *testing.T) {
+	store := NewMemMerkleStore()
+	data := []string{"data1", "data2", "data3", "data4"}
+
+	tree, err := BuildPersistentMerkleTree(store, data)
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+
+	if tree.GetRootHash() == "" {
+		t.Error("Root hash should not be empty")
+	}
+
+	emptyTree, err := BuildPersistentMerkleTree(store, []string{})
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+	if emptyTree.GetRootHash() != "" {
+		t.Error("Empty tree should have empty root hash")
+	}
+}
+
+func TestPersistentMerkleTree_MatchesInMemoryRootHash(t *testing.T) {
+	data := []string{"apple", "banana", "cherry", "date"}
+
+	inMemory := NewMerkleTree(data)
+
+	store := NewMemMerkleStore()
+	persistent, err := BuildPersistentMerkleTree(store, data)
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+
+	if inMemory.GetRootHash() != persistent.GetRootHash() {
+		t.Errorf("persistent tree root hash should match in-memory tree: %s vs %s",
+			inMemory.GetRootHash(), persistent.GetRootHash())
+	}
+}
+
+func TestPersistentMerkleTree_GetProof(t *testing.T) {
+	store := NewMemMerkleStore()
+	data := []string{"data1", "data2", "data3", "data4"}
+
+	tree, err := BuildPersistentMerkleTree(store, data)
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+
+	proof, err := tree.GetProof("data2")
+	if err != nil {
+		t.Fatalf("GetProof failed: %v", err)
+	}
+	if len(proof) == 0 {
+		t.Error("Proof should not be empty")
+	}
+
+	if !VerifyProof(tree.GetRootHash(), "data2", proof) {
+		t.Error("Valid proof should verify successfully")
+	}
+
+	_, err = tree.GetProof("nonexistent")
+	if err == nil {
+		t.Error("Should return error for non-existent data")
+	}
+
+	emptyTree, err := BuildPersistentMerkleTree(store, []string{})
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+	_, err = emptyTree.GetProof("data1")
+	if err == nil {
+		t.Error("Should return error for empty tree")
+	}
+}
+
+func TestPersistentMerkleTree_GetProofLazyLoads(t *testing.T) {
+	data := make([]string, 1000)
+	for i := 0; i < 1000; i++ {
+		data[i] = fmt.Sprintf("item-%d", i)
+	}
+
+	counting := &countingMerkleStore{MerkleStore: NewMemMerkleStore()}
+	tree, err := BuildPersistentMerkleTree(counting, data)
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+	// "item-0" sorts first lexicographically, so it sits on the leftmost
+	// spine of the tree; findProof tries the left child before the right at
+	// every node, so this path never has to fetch an unrelated subtree.
+	counting.gets = 0
+	proof, err := tree.GetProof("item-0")
+	if err != nil {
+		t.Fatalf("GetProof failed: %v", err)
+	}
+	if !VerifyProof(tree.GetRootHash(), "item-0", proof) {
+		t.Error("Valid proof should verify successfully")
+	}
+
+	if counting.gets > 2*len(proof)+2 {
+		t.Errorf("GetProof fetched %d nodes for a leftmost-leaf proof of size %d, expected roughly O(log n)",
+			counting.gets, len(proof))
+	}
+	t.Logf("GetProof for the leftmost leaf of 1000 items fetched %d nodes (proof size %d)", counting.gets, len(proof))
+}
+
+func TestPersistentMerkleTree_NewFromExistingRootHash(t *testing.T) {
+	store := NewMemMerkleStore()
+	data := []string{"data1", "data2", "data3"}
+
+	built, err := BuildPersistentMerkleTree(store, data)
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+
+	reopened := NewPersistentMerkleTree(store, built.GetRootHash())
+	proof, err := reopened.GetProof("data2")
+	if err != nil {
+		t.Fatalf("GetProof failed on reopened tree: %v", err)
+	}
+	if !VerifyProof(reopened.GetRootHash(), "data2", proof) {
+		t.Error("Reopened tree should produce a verifiable proof")
+	}
+}
+
+func TestComparePersistentTrees(t *testing.T) {
+	store := NewMemMerkleStore()
+
+	data1 := []string{"data1", "data2", "data3"}
+	tree1, err := BuildPersistentMerkleTree(store, data1)
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+
+	tree2, err := BuildPersistentMerkleTree(store, data1)
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+
+	differences, err := ComparePersistentTrees(tree1, tree2)
+	if err != nil {
+		t.Fatalf("ComparePersistentTrees failed: %v", err)
+	}
+	if len(differences) != 0 {
+		t.Errorf("Identical trees should have no differences, got: %v", differences)
+	}
+
+	data3 := []string{"data1", "data2", "different"}
+	tree3, err := BuildPersistentMerkleTree(store, data3)
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+
+	differences, err = ComparePersistentTrees(tree1, tree3)
+	if err != nil {
+		t.Fatalf("ComparePersistentTrees failed: %v", err)
+	}
+	if len(differences) == 0 {
+		t.Error("Different trees should have differences")
+	}
+	t.Logf("Differences found: %v", differences)
+
+	emptyTree, err := BuildPersistentMerkleTree(store, []string{})
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+	differences, err = ComparePersistentTrees(tree1, emptyTree)
+	if err != nil {
+		t.Fatalf("ComparePersistentTrees failed: %v", err)
+	}
+	if len(differences) == 0 {
+		t.Error("Comparing with empty tree should show differences")
+	}
+}
+
+func TestPersistentMerkleTree_DataIntegrity(t *testing.T) {
+	hourlyData := make([]string, 3600)
+	for i := 0; i < 3600; i++ {
+		hourlyData[i] = fmt.Sprintf("device-001:temp:%.2f:timestamp:%d",
+			25.0+float64(i)*0.001, 1000000+i)
+	}
+
+	store := NewMemMerkleStore()
+	tree, err := BuildPersistentMerkleTree(store, hourlyData)
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+
+	corruptedData := make([]string, len(hourlyData))
+	copy(corruptedData, hourlyData)
+	corruptedData[1800] = "device-001:temp:99.99:timestamp:1001800"
+
+	corruptedTree, err := BuildPersistentMerkleTree(store, corruptedData)
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+
+	if tree.GetRootHash() == corruptedTree.GetRootHash() {
+		t.Error("Should detect corrupted data")
+	}
+
+	differences, err := ComparePersistentTrees(tree, corruptedTree)
+	if err != nil {
+		t.Fatalf("ComparePersistentTrees failed: %v", err)
+	}
+	if len(differences) == 0 {
+		t.Error("Should find the corrupted item")
+	}
+	t.Logf("Found %d corrupted items out of 3600 without materializing either tree", len(differences))
+}
+
+func TestFileNodeDBMerkleStore(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewFileNodeDB(dir + "/merkle-nodes.db")
+	if err != nil {
+		t.Fatalf("NewFileNodeDB failed: %v", err)
+	}
+	defer db.Close()
+
+	store := NewFileNodeDBMerkleStore(db)
+	data := []string{"data1", "data2", "data3", "data4"}
+
+	tree, err := BuildPersistentMerkleTree(store, data)
+	if err != nil {
+		t.Fatalf("BuildPersistentMerkleTree failed: %v", err)
+	}
+
+	proof, err := tree.GetProof("data3")
+	if err != nil {
+		t.Fatalf("GetProof failed: %v", err)
+	}
+	if !VerifyProof(tree.GetRootHash(), "data3", proof) {
+		t.Error("Valid proof should verify successfully against the file-backed store")
+	}
+}
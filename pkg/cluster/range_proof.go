@@ -0,0 +1,205 @@
+package cluster
+
+import "fmt"
+
+// rangeProofToken describes one node visited while walking the tree for a
+// range proof, in preorder. Kind tokenInternal is always followed (in the
+// token stream) by its left subtree's tokens then its right subtree's, so a
+// verifier can rebuild the exact original shape without needing to know the
+// tree's total leaf count up front.
+type rangeProofToken struct {
+    Kind string
+    Hash string // populated only when Kind == tokenHash
+}
+
+const (
+    tokenHash     = "hash"     // subtree entirely outside the range: verifier uses Hash as-is
+    tokenLeaf     = "leaf"     // leaf inside the range: verifier recomputes from the next supplied leaf
+    tokenInternal = "internal" // internal node straddling the range boundary: recurse into both children
+)
+
+// RangeProof lets a peer ask "give me everything between two keys plus a
+// proof you didn't omit anything" in one round trip. It carries the sorted
+// leaves inside [fromData, toData] plus a single preorder token stream
+// covering the rest of the tree - in effect the request's
+// left-boundary-proof / right-boundary-proof / inner-hashes split folded
+// into one structure, which reconstructs correctly even across the
+// occasional duplicated node buildTree produces for odd-sized levels.
+// MerkleSync.GenerateSyncPlan still walks leaf-by-leaf via CompareTrees
+// rather than RangeProof/VerifyRangeProof - DiffRangeProof below is the
+// wiring those two were meant to get (see internal/cluster/client's
+// Client.VerifyRangeSync for the over-the-network caller), an alternative
+// to ReconcileRange's recursive CompareRange walk that costs one round trip
+// instead of O(differences * log N).
+type RangeProof struct {
+    Leaves []string
+    Tokens []rangeProofToken
+}
+
+// GetRangeProof builds a RangeProof for every leaf in [fromData, toData] (inclusive).
+func (mt *MerkleTree) GetRangeProof(fromData, toData string) (*RangeProof, error) {
+    if mt.Root == nil {
+        return nil, fmt.Errorf("empty tree")
+    }
+    if fromData > toData {
+        return nil, fmt.Errorf("fromData %q must not be greater than toData %q", fromData, toData)
+    }
+
+    rp := &RangeProof{}
+    collectRangeProof(mt.Root, fromData, toData, rp)
+    return rp, nil
+}
+
+func collectRangeProof(node *MerkleNode, from, to string, rp *RangeProof) {
+    minData, maxData := subtreeRange(node)
+    if maxData < from || minData > to {
+        rp.Tokens = append(rp.Tokens, rangeProofToken{Kind: tokenHash, Hash: node.Hash})
+        return
+    }
+
+    if node.Left == nil && node.Right == nil {
+        rp.Leaves = append(rp.Leaves, node.Data)
+        rp.Tokens = append(rp.Tokens, rangeProofToken{Kind: tokenLeaf})
+        return
+    }
+
+    rp.Tokens = append(rp.Tokens, rangeProofToken{Kind: tokenInternal})
+    collectRangeProof(node.Left, from, to, rp)
+
+    if node.Right == node.Left {
+        // buildTree reuses the left node verbatim to pad an odd-sized level;
+        // its hash is already pinned down by the left recursion above, so
+        // just restate it instead of re-walking (and re-emitting leaves for)
+        // the same subtree a second time.
+        rp.Tokens = append(rp.Tokens, rangeProofToken{Kind: tokenHash, Hash: node.Right.Hash})
+    } else {
+        collectRangeProof(node.Right, from, to, rp)
+    }
+}
+
+// subtreeRange returns the smallest and largest leaf data under node.
+func subtreeRange(node *MerkleNode) (string, string) {
+    if node.Left == nil && node.Right == nil {
+        return node.Data, node.Data
+    }
+    var leaves []string
+    collectLeaves(node, &leaves)
+    return leaves[0], leaves[len(leaves)-1]
+}
+
+// VerifyRangeProof recomputes the root from rp and reports whether it
+// matches rootHash, alongside the leaves rp claimed were in range (callers
+// should only trust those leaves if the bool return is true).
+func VerifyRangeProof(rootHash string, rp *RangeProof) (bool, []string, error) {
+    if rp == nil {
+        return false, nil, fmt.Errorf("nil range proof")
+    }
+
+    computedRoot, remainingTokens, remainingLeaves, err := rebuildFromTokens(rp.Tokens, rp.Leaves)
+    if err != nil {
+        return false, nil, err
+    }
+    if len(remainingTokens) != 0 {
+        return false, nil, fmt.Errorf("range proof has %d unconsumed token(s)", len(remainingTokens))
+    }
+    if len(remainingLeaves) != 0 {
+        return false, nil, fmt.Errorf("range proof has %d unconsumed leaf/leaves", len(remainingLeaves))
+    }
+
+    return computedRoot == rootHash, rp.Leaves, nil
+}
+
+func rebuildFromTokens(tokens []rangeProofToken, leaves []string) (string, []rangeProofToken, []string, error) {
+    if len(tokens) == 0 {
+        return "", nil, nil, fmt.Errorf("range proof ended unexpectedly")
+    }
+    tok := tokens[0]
+    tokens = tokens[1:]
+
+    switch tok.Kind {
+    case tokenHash:
+        return tok.Hash, tokens, leaves, nil
+
+    case tokenLeaf:
+        if len(leaves) == 0 {
+            return "", nil, nil, fmt.Errorf("range proof ran out of leaves")
+        }
+        return hashData(leaves[0]), tokens, leaves[1:], nil
+
+    case tokenInternal:
+        leftHash, tokens, leaves, err := rebuildFromTokens(tokens, leaves)
+        if err != nil {
+            return "", nil, nil, err
+        }
+        rightHash, tokens, leaves, err := rebuildFromTokens(tokens, leaves)
+        if err != nil {
+            return "", nil, nil, err
+        }
+        return combineChildHashes(leftHash, rightHash), tokens, leaves, nil
+
+    default:
+        return "", nil, nil, fmt.Errorf("range proof has unknown token kind %q", tok.Kind)
+    }
+}
+
+// DiffRangeProof verifies remoteProof against remoteRootHash and, if it
+// checks out, reports any leaves local and the remote side disagree on
+// within [low, high] by diffing local's own range leaves against
+// remoteProof's - the single-round-trip counterpart to ReconcileRange's
+// recursive CompareRange walk.
+func DiffRangeProof(local *MerkleTree, low, high, remoteRootHash string, remoteProof *RangeProof) ([]RangeDiff, error) {
+    ok, remoteLeaves, err := VerifyRangeProof(remoteRootHash, remoteProof)
+    if err != nil {
+        return nil, fmt.Errorf("verify remote range proof: %w", err)
+    }
+    if !ok {
+        return nil, fmt.Errorf("remote range proof does not match its claimed root hash")
+    }
+
+    localRP, err := local.GetRangeProof(low, high)
+    if err != nil {
+        return nil, fmt.Errorf("build local range proof: %w", err)
+    }
+
+    return diffLeafLists(localRP.Leaves, remoteLeaves), nil
+}
+
+// Item is a single leaf checked by VerifyBatch.
+type Item struct {
+    Data string
+}
+
+// VerifyBatch checks many single-key proofs (as returned by GetProof)
+// against the same root in one call, memoizing each sibling-combine result
+// across the whole batch. Items near each other in sorted order share most
+// of their ancestor chain, so in the common case this turns what would be
+// O(n log N) hashing into close to O(n + log N) - one hash per shared
+// ancestor instead of one per proof.
+func VerifyBatch(rootHash string, items []Item, proofs [][]string) bool {
+    if len(items) != len(proofs) {
+        return false
+    }
+
+    cache := make(map[string]string)
+    combine := func(a, b string) string {
+        key := a + "|" + b
+        if v, ok := cache[key]; ok {
+            return v
+        }
+        v := combineChildHashes(a, b)
+        cache[key] = v
+        return v
+    }
+
+    for i, item := range items {
+        currentHash := hashData(item.Data)
+        for _, sibling := range proofs[i] {
+            currentHash = combine(currentHash, sibling)
+        }
+        if currentHash != rootHash {
+            return false
+        }
+    }
+
+    return true
+}
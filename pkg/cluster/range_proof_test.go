@@ -0,0 +1,260 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMerkleTree_GetRangeProof(t *testing.T) {
+	data := []string{"apple", "banana", "cherry", "date", "elderberry"}
+	tree := NewMerkleTree(data)
+	rootHash := tree.GetRootHash()
+
+	rp, err := tree.GetRangeProof("banana", "date")
+	if err != nil {
+		t.Fatalf("GetRangeProof failed: %v", err)
+	}
+
+	expectedLeaves := []string{"banana", "cherry", "date"}
+	if len(rp.Leaves) != len(expectedLeaves) {
+		t.Fatalf("expected %d leaves, got %d: %v", len(expectedLeaves), len(rp.Leaves), rp.Leaves)
+	}
+	for i, l := range expectedLeaves {
+		if rp.Leaves[i] != l {
+			t.Errorf("leaf %d: expected %s, got %s", i, l, rp.Leaves[i])
+		}
+	}
+
+	ok, leaves, err := VerifyRangeProof(rootHash, rp)
+	if err != nil {
+		t.Fatalf("VerifyRangeProof failed: %v", err)
+	}
+	if !ok {
+		t.Error("range proof should verify against the correct root")
+	}
+	if len(leaves) != len(expectedLeaves) {
+		t.Errorf("expected %d verified leaves, got %d", len(expectedLeaves), len(leaves))
+	}
+}
+
+func TestMerkleTree_GetRangeProof_FullRange(t *testing.T) {
+	data := []string{"apple", "banana", "cherry", "date"}
+	tree := NewMerkleTree(data)
+	rootHash := tree.GetRootHash()
+
+	rp, err := tree.GetRangeProof("apple", "date")
+	if err != nil {
+		t.Fatalf("GetRangeProof failed: %v", err)
+	}
+
+	if len(rp.Leaves) != len(data) {
+		t.Errorf("expected all %d leaves, got %d", len(data), len(rp.Leaves))
+	}
+
+	ok, _, err := VerifyRangeProof(rootHash, rp)
+	if err != nil || !ok {
+		t.Errorf("full-range proof should verify, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestMerkleTree_GetRangeProof_SingleLeaf(t *testing.T) {
+	data := []string{"apple", "banana", "cherry"}
+	tree := NewMerkleTree(data)
+	rootHash := tree.GetRootHash()
+
+	rp, err := tree.GetRangeProof("banana", "banana")
+	if err != nil {
+		t.Fatalf("GetRangeProof failed: %v", err)
+	}
+	if len(rp.Leaves) != 1 || rp.Leaves[0] != "banana" {
+		t.Fatalf("expected single leaf 'banana', got %v", rp.Leaves)
+	}
+
+	ok, _, err := VerifyRangeProof(rootHash, rp)
+	if err != nil || !ok {
+		t.Errorf("single-leaf range proof should verify, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestMerkleTree_GetRangeProof_OddNumberOfItems(t *testing.T) {
+	data := []string{"item1", "item2", "item3", "item4", "item5"}
+	tree := NewMerkleTree(data)
+	rootHash := tree.GetRootHash()
+
+	for _, tc := range [][2]string{{"item1", "item2"}, {"item3", "item5"}, {"item2", "item4"}} {
+		rp, err := tree.GetRangeProof(tc[0], tc[1])
+		if err != nil {
+			t.Fatalf("GetRangeProof(%s, %s) failed: %v", tc[0], tc[1], err)
+		}
+		ok, _, err := VerifyRangeProof(rootHash, rp)
+		if err != nil || !ok {
+			t.Errorf("range proof for [%s, %s] should verify, got ok=%v err=%v", tc[0], tc[1], ok, err)
+		}
+	}
+}
+
+func TestMerkleTree_GetRangeProof_InvalidRange(t *testing.T) {
+	tree := NewMerkleTree([]string{"apple", "banana"})
+
+	if _, err := tree.GetRangeProof("banana", "apple"); err == nil {
+		t.Error("expected error when fromData > toData")
+	}
+
+	emptyTree := NewMerkleTree([]string{})
+	if _, err := emptyTree.GetRangeProof("a", "b"); err == nil {
+		t.Error("expected error for empty tree")
+	}
+}
+
+func TestMerkleTree_VerifyRangeProof_WrongRoot(t *testing.T) {
+	tree := NewMerkleTree([]string{"apple", "banana", "cherry", "date"})
+	rp, err := tree.GetRangeProof("banana", "cherry")
+	if err != nil {
+		t.Fatalf("GetRangeProof failed: %v", err)
+	}
+
+	ok, _, err := VerifyRangeProof("0000000000000000000000000000000000000000000000000000000000000000", rp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Error("range proof should not verify against the wrong root")
+	}
+}
+
+func TestMerkleTree_VerifyRangeProof_TamperedLeaf(t *testing.T) {
+	tree := NewMerkleTree([]string{"apple", "banana", "cherry", "date"})
+	rootHash := tree.GetRootHash()
+
+	rp, err := tree.GetRangeProof("banana", "cherry")
+	if err != nil {
+		t.Fatalf("GetRangeProof failed: %v", err)
+	}
+	rp.Leaves[0] = "tampered"
+
+	ok, _, err := VerifyRangeProof(rootHash, rp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Error("range proof with a tampered leaf should not verify")
+	}
+}
+
+func TestMerkleTree_GetRangeProof_LargeDataset(t *testing.T) {
+	data := make([]string, 500)
+	for i := 0; i < 500; i++ {
+		data[i] = fmt.Sprintf("item-%04d", i)
+	}
+	tree := NewMerkleTree(data)
+	rootHash := tree.GetRootHash()
+
+	rp, err := tree.GetRangeProof("item-0100", "item-0199")
+	if err != nil {
+		t.Fatalf("GetRangeProof failed: %v", err)
+	}
+	if len(rp.Leaves) != 100 {
+		t.Errorf("expected 100 leaves, got %d", len(rp.Leaves))
+	}
+
+	ok, _, err := VerifyRangeProof(rootHash, rp)
+	if err != nil || !ok {
+		t.Errorf("large-dataset range proof should verify, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	data := []string{"apple", "banana", "cherry", "date", "elderberry"}
+	tree := NewMerkleTree(data)
+	rootHash := tree.GetRootHash()
+
+	var items []Item
+	var proofs [][]string
+	for _, d := range data {
+		proof, err := tree.GetProof(d)
+		if err != nil {
+			t.Fatalf("GetProof(%s) failed: %v", d, err)
+		}
+		items = append(items, Item{Data: d})
+		proofs = append(proofs, proof)
+	}
+
+	if !VerifyBatch(rootHash, items, proofs) {
+		t.Error("VerifyBatch should succeed for valid items/proofs")
+	}
+
+	items[2].Data = "tampered"
+	if VerifyBatch(rootHash, items, proofs) {
+		t.Error("VerifyBatch should fail when an item has been tampered with")
+	}
+}
+
+func TestVerifyBatch_MismatchedLengths(t *testing.T) {
+	if VerifyBatch("deadbeef", []Item{{Data: "a"}}, nil) {
+		t.Error("VerifyBatch should fail when items and proofs lengths differ")
+	}
+}
+
+func TestDiffRangeProof_IdenticalRange(t *testing.T) {
+	data := []string{"apple", "banana", "cherry", "date", "elderberry"}
+	local := NewMerkleTree(data)
+	remote := NewMerkleTree(data)
+
+	remoteProof, err := remote.GetRangeProof("banana", "date")
+	if err != nil {
+		t.Fatalf("GetRangeProof: %v", err)
+	}
+
+	diffs, err := DiffRangeProof(local, "banana", "date", remote.GetRootHash(), remoteProof)
+	if err != nil {
+		t.Fatalf("DiffRangeProof: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("expected no diffs for identical ranges, got %v", diffs)
+	}
+}
+
+func TestDiffRangeProof_MissingAndExtra(t *testing.T) {
+	local := NewMerkleTree([]string{"apple", "banana", "cherry", "date"})
+	remote := NewMerkleTree([]string{"apple", "banana", "cherryZ", "date"})
+
+	remoteProof, err := remote.GetRangeProof("apple", "date")
+	if err != nil {
+		t.Fatalf("GetRangeProof: %v", err)
+	}
+
+	diffs, err := DiffRangeProof(local, "apple", "date", remote.GetRootHash(), remoteProof)
+	if err != nil {
+		t.Fatalf("DiffRangeProof: %v", err)
+	}
+
+	var missing, extra []string
+	for _, d := range diffs {
+		switch d.Kind {
+		case DiffMissing:
+			missing = append(missing, d.Data)
+		case DiffExtra:
+			extra = append(extra, d.Data)
+		}
+	}
+	if len(missing) != 1 || missing[0] != "cherryZ" {
+		t.Errorf("expected missing=[cherryZ], got %v", missing)
+	}
+	if len(extra) != 1 || extra[0] != "cherry" {
+		t.Errorf("expected extra=[cherry], got %v", extra)
+	}
+}
+
+func TestDiffRangeProof_WrongRootRejected(t *testing.T) {
+	local := NewMerkleTree([]string{"apple", "banana"})
+	remote := NewMerkleTree([]string{"apple", "banana"})
+
+	remoteProof, err := remote.GetRangeProof("apple", "banana")
+	if err != nil {
+		t.Fatalf("GetRangeProof: %v", err)
+	}
+
+	if _, err := DiffRangeProof(local, "apple", "banana", "0000deadbeef0000", remoteProof); err == nil {
+		t.Error("expected an error when the remote proof doesn't match its claimed root hash")
+	}
+}
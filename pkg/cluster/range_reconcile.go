@@ -0,0 +1,210 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RangeDiff is one entry in a ReconcileRange result: a leaf that exists on only
+// one side. Unlike Reconcile's missing/extra/conflicting classification,
+// there is no "conflicting" kind here - a MerkleTree's leaf data is its own
+// key, so two different values are two different leaves, not one leaf with
+// two versions.
+type RangeDiff struct {
+	Kind string
+	Data string
+}
+
+const (
+	// DiffMissing means the peer has this leaf and the local tree doesn't.
+	DiffMissing = "missing"
+	// DiffExtra means the local tree has this leaf and the peer doesn't.
+	DiffExtra = "extra"
+)
+
+// RangeUnbounded is the sentinel high bound meaning "to the end of the
+// keyspace": lexicographically greater than any realistic leaf data a
+// MerkleTree built by this package would hold.
+const RangeUnbounded = "\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff"
+
+// rangeReconcileLeafThreshold is the point below which a peer exchanges a
+// range's full sorted leaf list directly instead of splitting it once more:
+// past this size, one more round of hash comparison is cheaper than the
+// leaf list would be.
+const rangeReconcileLeafThreshold = 8
+
+// RangeCompareResult is a peer's answer to "does your hash for [low,high)
+// match mine". Equal means no further work is needed. Terminal means the
+// peer's side of the range was small enough to just hand over as Leaves.
+// Otherwise the peer split [low,high) at Mid and reports each half's own
+// hash, so the caller only has to recurse into whichever half(s) disagree.
+type RangeCompareResult struct {
+	Equal    bool
+	Terminal bool
+	Leaves   []string
+
+	Mid       string
+	LeftHash  string
+	RightHash string
+}
+
+// RangePeer is the remote side ReconcileRange drives.
+type RangePeer interface {
+	CompareRange(low, high, hash string) (RangeCompareResult, error)
+}
+
+// LocalRangePeer answers RangePeer requests directly from an in-memory
+// MerkleTree's sorted leaves. It's the in-process reference implementation
+// ReconcileRange's own tests run against, and it's also the logic
+// internal/cluster's "merkle_reconcile" message handler wraps to serve
+// range comparisons over the network.
+type LocalRangePeer struct {
+	leaves []string
+}
+
+// NewLocalRangePeer builds a RangePeer over tree's current leaves. tree
+// must not be mutated while a peer built from it is in use.
+func NewLocalRangePeer(tree *MerkleTree) *LocalRangePeer {
+	return &LocalRangePeer{leaves: distinctLeafData(tree)}
+}
+
+func (p *LocalRangePeer) CompareRange(low, high, hash string) (RangeCompareResult, error) {
+	leaves := leavesInRange(p.leaves, low, high)
+
+	if rangeHash(leaves) == hash {
+		return RangeCompareResult{Equal: true}, nil
+	}
+
+	if len(leaves) <= rangeReconcileLeafThreshold {
+		return RangeCompareResult{Terminal: true, Leaves: leaves}, nil
+	}
+
+	mid := leaves[len(leaves)/2]
+	left := leavesInRange(leaves, low, mid)
+	right := leavesInRange(leaves, mid, high)
+	return RangeCompareResult{
+		Mid:       mid,
+		LeftHash:  rangeHash(left),
+		RightHash: rangeHash(right),
+	}, nil
+}
+
+// distinctLeafData is tree.GetAllLeafData() with one fix: GetAllLeafData's
+// collectLeaves walks both children of every node, including the odd-level
+// padding node buildTree creates by duplicating the last child (Left ==
+// Right) - so on a tree with an odd leaf count at any level, it returns
+// some leaves twice. merkle_block.go's countLeaves/subtreeHasMatch already
+// work around the same duplicate pointer with a `Right != Left` check; this
+// does the equivalent during leaf collection so ReconcileRange's range
+// splits operate on each leaf exactly once.
+func distinctLeafData(tree *MerkleTree) []string {
+	if tree.Root == nil {
+		return []string{}
+	}
+	var leaves []string
+	collectDistinctLeaves(tree.Root, &leaves)
+	return leaves
+}
+
+func collectDistinctLeaves(node *MerkleNode, leaves *[]string) {
+	if node.Left == nil && node.Right == nil {
+		*leaves = append(*leaves, node.Data)
+		return
+	}
+	collectDistinctLeaves(node.Left, leaves)
+	if node.Right != node.Left {
+		collectDistinctLeaves(node.Right, leaves)
+	}
+}
+
+// leavesInRange returns the subslice of sorted (ascending) leaves whose
+// value falls in [low, high).
+func leavesInRange(leaves []string, low, high string) []string {
+	start := sort.SearchStrings(leaves, low)
+	end := sort.SearchStrings(leaves, high)
+	if end < start {
+		end = start
+	}
+	return leaves[start:end]
+}
+
+// rangeHash is the Merkle root of leaves, rebuilt fresh each call - the
+// same rebuild-from-scratch approach NewMerkleTree already takes, just
+// applied to a range's leaves instead of a whole tree's.
+func rangeHash(leaves []string) string {
+	return NewMerkleTree(leaves).GetRootHash()
+}
+
+// ReconcileRange compares local's leaves in [low, high) against peer's,
+// recursing only into the halves whose hashes disagree once peer splits a
+// range, until a sub-range is small enough that peer just returns its full
+// leaf list. This costs O(differences * log N) round trips rather than
+// CompareTrees' full materialize-and-diff, which matters most right after a
+// hash-ring rebalance when a node only needs to sync the shard range it has
+// just taken on (see updateHashRingFromGossip in internal/cluster).
+func ReconcileRange(local *MerkleTree, peer RangePeer, low, high string) ([]RangeDiff, error) {
+	localLeaves := leavesInRange(distinctLeafData(local), low, high)
+	return reconcileRange(localLeaves, peer, low, high)
+}
+
+func reconcileRange(localLeaves []string, peer RangePeer, low, high string) ([]RangeDiff, error) {
+	result, err := peer.CompareRange(low, high, rangeHash(localLeaves))
+	if err != nil {
+		return nil, fmt.Errorf("compare range [%q,%q): %w", low, high, err)
+	}
+
+	if result.Equal {
+		return nil, nil
+	}
+
+	if result.Terminal {
+		return diffLeafLists(localLeaves, result.Leaves), nil
+	}
+
+	leftLocal := leavesInRange(localLeaves, low, result.Mid)
+	rightLocal := leavesInRange(localLeaves, result.Mid, high)
+
+	var diffs []RangeDiff
+	if rangeHash(leftLocal) != result.LeftHash {
+		d, err := reconcileRange(leftLocal, peer, low, result.Mid)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d...)
+	}
+	if rangeHash(rightLocal) != result.RightHash {
+		d, err := reconcileRange(rightLocal, peer, result.Mid, high)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d...)
+	}
+	return diffs, nil
+}
+
+// diffLeafLists compares two sorted leaf lists directly, classifying each
+// leaf present on only one side.
+func diffLeafLists(local, peerLeaves []string) []RangeDiff {
+	var diffs []RangeDiff
+	i, j := 0, 0
+	for i < len(local) && j < len(peerLeaves) {
+		switch {
+		case local[i] == peerLeaves[j]:
+			i++
+			j++
+		case local[i] < peerLeaves[j]:
+			diffs = append(diffs, RangeDiff{Kind: DiffExtra, Data: local[i]})
+			i++
+		default:
+			diffs = append(diffs, RangeDiff{Kind: DiffMissing, Data: peerLeaves[j]})
+			j++
+		}
+	}
+	for ; i < len(local); i++ {
+		diffs = append(diffs, RangeDiff{Kind: DiffExtra, Data: local[i]})
+	}
+	for ; j < len(peerLeaves); j++ {
+		diffs = append(diffs, RangeDiff{Kind: DiffMissing, Data: peerLeaves[j]})
+	}
+	return diffs
+}
@@ -0,0 +1,147 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func diffSet(diffs []RangeDiff, kind string) []string {
+	var out []string
+	for _, d := range diffs {
+		if d.Kind == kind {
+			out = append(out, d.Data)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestReconcileRange_IdenticalTrees(t *testing.T) {
+	data := []string{"device-A:1", "device-B:1", "device-A:2", "device-C:1"}
+	local := NewMerkleTree(data)
+	peer := NewLocalRangePeer(NewMerkleTree(append([]string{}, data...)))
+
+	diffs, err := ReconcileRange(local, peer, "", RangeUnbounded)
+	if err != nil {
+		t.Fatalf("ReconcileRange failed: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("expected no diffs between identical trees, got %v", diffs)
+	}
+}
+
+func TestReconcileRange_SingleDifference(t *testing.T) {
+	local := NewMerkleTree([]string{"a:1", "b:1", "c:1", "d:1", "e:1"})
+	peer := NewLocalRangePeer(NewMerkleTree([]string{"a:1", "b:1", "c:2", "d:1", "e:1"}))
+
+	diffs, err := ReconcileRange(local, peer, "", RangeUnbounded)
+	if err != nil {
+		t.Fatalf("ReconcileRange failed: %v", err)
+	}
+
+	if got, want := diffSet(diffs, DiffExtra), []string{"c:1"}; !equalStrSlices(got, want) {
+		t.Errorf("extra diffs = %v, want %v", got, want)
+	}
+	if got, want := diffSet(diffs, DiffMissing), []string{"c:2"}; !equalStrSlices(got, want) {
+		t.Errorf("missing diffs = %v, want %v", got, want)
+	}
+}
+
+func TestReconcileRange_MissingAndExtraLeaves(t *testing.T) {
+	local := NewMerkleTree([]string{"a:1", "b:1", "c:1"})
+	peer := NewLocalRangePeer(NewMerkleTree([]string{"a:1", "c:1", "d:1"}))
+
+	diffs, err := ReconcileRange(local, peer, "", RangeUnbounded)
+	if err != nil {
+		t.Fatalf("ReconcileRange failed: %v", err)
+	}
+
+	if got, want := diffSet(diffs, DiffExtra), []string{"b:1"}; !equalStrSlices(got, want) {
+		t.Errorf("extra diffs = %v, want %v", got, want)
+	}
+	if got, want := diffSet(diffs, DiffMissing), []string{"d:1"}; !equalStrSlices(got, want) {
+		t.Errorf("missing diffs = %v, want %v", got, want)
+	}
+}
+
+func TestReconcileRange_RestrictedRangeIgnoresOutsideDiffs(t *testing.T) {
+	local := NewMerkleTree([]string{"a:1", "m:1", "z:1"})
+	peer := NewLocalRangePeer(NewMerkleTree([]string{"a:2", "m:1", "z:2"}))
+
+	diffs, err := ReconcileRange(local, peer, "b", "n")
+	if err != nil {
+		t.Fatalf("ReconcileRange failed: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("expected range [b,n) to only cover the agreeing leaf, got %v", diffs)
+	}
+}
+
+func TestReconcileRange_LargeDatasetSparseDiffs(t *testing.T) {
+	const n = 2000
+	localData := make([]string, n)
+	for i := 0; i < n; i++ {
+		localData[i] = fmt.Sprintf("device-%04d:temp:%.2f", i, 20.0+float64(i)*0.01)
+	}
+	peerData := append([]string{}, localData...)
+	// Perturb a handful of leaves on the peer side.
+	changed := []int{3, 500, 999, 1500, 1999}
+	for _, i := range changed {
+		peerData[i] = fmt.Sprintf("device-%04d:temp:%.2f", i, 999.0)
+	}
+
+	local := NewMerkleTree(localData)
+	peer := NewLocalRangePeer(NewMerkleTree(peerData))
+
+	diffs, err := ReconcileRange(local, peer, "", RangeUnbounded)
+	if err != nil {
+		t.Fatalf("ReconcileRange failed: %v", err)
+	}
+
+	if len(diffs) != 2*len(changed) {
+		t.Fatalf("expected %d diffs (one extra + one missing per changed leaf), got %d: %v",
+			2*len(changed), len(diffs), diffs)
+	}
+}
+
+func TestReconcileRange_EmptyTrees(t *testing.T) {
+	local := NewMerkleTree([]string{})
+	peer := NewLocalRangePeer(NewMerkleTree([]string{}))
+
+	diffs, err := ReconcileRange(local, peer, "", RangeUnbounded)
+	if err != nil {
+		t.Fatalf("ReconcileRange failed: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("expected no diffs between two empty trees, got %v", diffs)
+	}
+}
+
+func TestReconcileRange_OneSideEmpty(t *testing.T) {
+	local := NewMerkleTree([]string{"a:1", "b:1"})
+	peer := NewLocalRangePeer(NewMerkleTree([]string{}))
+
+	diffs, err := ReconcileRange(local, peer, "", RangeUnbounded)
+	if err != nil {
+		t.Fatalf("ReconcileRange failed: %v", err)
+	}
+	if got, want := diffSet(diffs, DiffExtra), []string{"a:1", "b:1"}; !equalStrSlices(got, want) {
+		t.Errorf("extra diffs = %v, want %v", got, want)
+	}
+	if len(diffSet(diffs, DiffMissing)) != 0 {
+		t.Errorf("expected no missing diffs, got %v", diffs)
+	}
+}
+
+func equalStrSlices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
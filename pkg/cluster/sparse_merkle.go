@@ -0,0 +1,216 @@
+package cluster
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// smtDepth is the number of levels between the root and a leaf: one per bit
+// of a sha256 key hash, so every key has a unique, deterministic position.
+const smtDepth = 256
+
+const (
+	smtLeafPrefix     = 0x00
+	smtInternalPrefix = 0x01
+	smtEmptyPrefix    = 0xFF // sentinel for "no leaf here", distinct from any real leaf hash
+)
+
+// defaultHashes[h] is the hash of an empty subtree of height h (h=0 is an
+// empty leaf, h=smtDepth is the empty tree's root). Precomputed once since
+// it never depends on tree contents.
+var defaultHashes [smtDepth + 1][]byte
+
+func init() {
+	defaultHashes[0] = sha256Sum([]byte{smtEmptyPrefix})
+	for h := 1; h <= smtDepth; h++ {
+		defaultHashes[h] = hashInternal(defaultHashes[h-1], defaultHashes[h-1])
+	}
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func hashLeaf(key, value []byte) []byte {
+	buf := make([]byte, 0, 1+len(key)+len(value))
+	buf = append(buf, smtLeafPrefix)
+	buf = append(buf, key...)
+	buf = append(buf, value...)
+	return sha256Sum(buf)
+}
+
+func hashInternal(left, right []byte) []byte {
+	buf := make([]byte, 0, 1+len(left)+len(right))
+	buf = append(buf, smtInternalPrefix)
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+	return sha256Sum(buf)
+}
+
+// keyBits returns the smtDepth-bit path for key, as a string of '0'/'1'
+// characters (MSB first), derived from sha256(key).
+func keyBits(key []byte) string {
+	sum := sha256.Sum256(key)
+	bits := make([]byte, smtDepth)
+	for i := 0; i < smtDepth; i++ {
+		byteIdx := i / 8
+		bitIdx := 7 - uint(i%8)
+		if sum[byteIdx]&(1<<bitIdx) != 0 {
+			bits[i] = '1'
+		} else {
+			bits[i] = '0'
+		}
+	}
+	return string(bits)
+}
+
+func flipBit(path string) string {
+	b := []byte(path)
+	last := len(b) - 1
+	if b[last] == '0' {
+		b[last] = '1'
+	} else {
+		b[last] = '0'
+	}
+	return string(b)
+}
+
+func setBit(bitmap []byte, i int) {
+	bitmap[i/8] |= 1 << uint(7-i%8)
+}
+
+func bitSet(bitmap []byte, i int) bool {
+	if i/8 >= len(bitmap) {
+		return false
+	}
+	return bitmap[i/8]&(1<<uint(7-i%8)) != 0
+}
+
+// SparseMerkleTree is a key/value Merkle tree of fixed depth smtDepth: every
+// key hashes to a deterministic leaf position, so unlike MerkleTree above a
+// proof carries positional information (via the bit path) instead of
+// relying on sibling-hash ordering, and a tree can prove a key's absence as
+// well as its presence. Only non-default nodes are stored, so a tree with a
+// handful of keys stays small despite the nominal 2^256 leaf space.
+type SparseMerkleTree struct {
+	mu     sync.RWMutex
+	nodes  map[string][]byte // bit-path prefix ("" is root) -> node hash
+	values map[string][]byte // hex(key) -> value
+}
+
+func NewSparseMerkleTree() *SparseMerkleTree {
+	return &SparseMerkleTree{
+		nodes:  make(map[string][]byte),
+		values: make(map[string][]byte),
+	}
+}
+
+func (t *SparseMerkleTree) nodeHashOrDefault(path string) []byte {
+	if h, ok := t.nodes[path]; ok {
+		return h
+	}
+	return defaultHashes[smtDepth-len(path)]
+}
+
+// Put inserts or updates key, recomputing every hash on key's path to the root.
+func (t *SparseMerkleTree) Put(key, value []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bits := keyBits(key)
+	t.values[hex.EncodeToString(key)] = append([]byte(nil), value...)
+	t.nodes[bits] = hashLeaf(key, value)
+
+	for d := smtDepth; d > 0; d-- {
+		nodeHash := t.nodeHashOrDefault(bits[:d])
+		siblingHash := t.nodeHashOrDefault(flipBit(bits[:d]))
+
+		var combined []byte
+		if bits[d-1] == '0' {
+			combined = hashInternal(nodeHash, siblingHash)
+		} else {
+			combined = hashInternal(siblingHash, nodeHash)
+		}
+		t.nodes[bits[:d-1]] = combined
+	}
+}
+
+// GetRootHash returns the current root hash (the well-known empty-tree hash
+// if no key has ever been put).
+func (t *SparseMerkleTree) GetRootHash() []byte {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.nodeHashOrDefault("")
+}
+
+// SparseMerkleProof carries only the non-default siblings on a key's path;
+// Bitmap marks, one bit per level from leaf to root, which levels have an
+// entry in Siblings versus implicitly using the known default hash.
+type SparseMerkleProof struct {
+	Siblings [][]byte
+	Bitmap   []byte
+}
+
+// Prove returns key's value (if present) and a proof of its position,
+// usable by either VerifyInclusion or VerifyExclusion depending on whether
+// the key turned out to be present.
+func (t *SparseMerkleTree) Prove(key []byte) (value []byte, proof *SparseMerkleProof, found bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	bits := keyBits(key)
+	proof = &SparseMerkleProof{Bitmap: make([]byte, (smtDepth+7)/8)}
+
+	for d := smtDepth; d >= 1; d-- {
+		siblingPath := flipBit(bits[:d])
+		if h, ok := t.nodes[siblingPath]; ok {
+			proof.Siblings = append(proof.Siblings, h)
+			setBit(proof.Bitmap, smtDepth-d)
+		}
+	}
+
+	value, found = t.values[hex.EncodeToString(key)]
+	return value, proof, found
+}
+
+func verifyPath(root []byte, key []byte, leafHash []byte, proof *SparseMerkleProof) bool {
+	bits := keyBits(key)
+	current := leafHash
+	siblingIdx := 0
+
+	for d := smtDepth; d >= 1; d-- {
+		level := smtDepth - d
+		var sibling []byte
+		if bitSet(proof.Bitmap, level) {
+			if siblingIdx >= len(proof.Siblings) {
+				return false
+			}
+			sibling = proof.Siblings[siblingIdx]
+			siblingIdx++
+		} else {
+			sibling = defaultHashes[d-1]
+		}
+
+		if bits[d-1] == '0' {
+			current = hashInternal(current, sibling)
+		} else {
+			current = hashInternal(sibling, current)
+		}
+	}
+
+	return bytes.Equal(current, root)
+}
+
+// VerifyInclusion checks that key maps to value under root, given proof.
+func VerifyInclusion(root []byte, key, value []byte, proof *SparseMerkleProof) bool {
+	return verifyPath(root, key, hashLeaf(key, value), proof)
+}
+
+// VerifyExclusion checks that key holds no value under root, given proof -
+// something the plain MerkleTree's sorted-blob design has no way to express.
+func VerifyExclusion(root []byte, key []byte, proof *SparseMerkleProof) bool {
+	return verifyPath(root, key, defaultHashes[0], proof)
+}
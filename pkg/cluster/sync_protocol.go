@@ -0,0 +1,304 @@
+package cluster
+
+import (
+    "context"
+    "fmt"
+    "sync"
+)
+
+// SyncSession is the peer-facing half of the streaming anti-entropy
+// protocol: just enough to locate where a remote tree diverges from ours
+// without ever holding the remote tree in memory. RequestChildren descends
+// one level below nodeHash; isLeaf tells the caller whether to stop there
+// (and use data) or keep fetching.
+type SyncSession interface {
+    RequestRoot() (rootHash string, err error)
+    RequestChildren(nodeHash string) (leftHash, rightHash string, isLeaf bool, data string, err error)
+}
+
+// SyncPeer is the type Reconcile drives against. It is exactly a
+// SyncSession - kept as a separate name because callers think of "the
+// remote side of a sync" rather than "a session", while implementations
+// (LocalSyncSession here, internal/cluster/client's remoteSyncPeer for the
+// out-of-process case) satisfy both.
+type SyncPeer = SyncSession
+
+// DefaultMaxInFlight bounds how many RequestChildren calls a Reconcile walk
+// keeps outstanding against remote at once, used when callers don't specify
+// their own limit.
+const DefaultMaxInFlight = 8
+
+// LocalSyncSession answers SyncSession requests directly from an in-memory
+// MerkleTree, indexing it by hash on first use. It's the reference
+// SyncSession Reconcile's own tests run against, and it doubles as the
+// in-process peer when both sides of a sync live in the same binary (see
+// internal/cluster/client's remoteSyncPeer, backed by the
+// "merkle_sync_root"/"merkle_sync_children" RPCs, for the out-of-process
+// case).
+type LocalSyncSession struct {
+    tree *MerkleTree
+
+    once  sync.Once
+    index map[string]*MerkleNode
+}
+
+// NewLocalSyncSession wraps tree for serving over a SyncSession. tree must
+// not be mutated while a session built from it is in use.
+func NewLocalSyncSession(tree *MerkleTree) *LocalSyncSession {
+    return &LocalSyncSession{tree: tree}
+}
+
+func (s *LocalSyncSession) ensureIndex() {
+    s.once.Do(func() {
+        s.index = make(map[string]*MerkleNode)
+        indexNodes(s.tree.Root, s.index)
+    })
+}
+
+func indexNodes(node *MerkleNode, index map[string]*MerkleNode) {
+    if node == nil {
+        return
+    }
+    index[node.Hash] = node
+    indexNodes(node.Left, index)
+    indexNodes(node.Right, index)
+}
+
+func (s *LocalSyncSession) RequestRoot() (string, error) {
+    return s.tree.GetRootHash(), nil
+}
+
+func (s *LocalSyncSession) RequestChildren(nodeHash string) (leftHash, rightHash string, isLeaf bool, data string, err error) {
+    s.ensureIndex()
+
+    node, ok := s.index[nodeHash]
+    if !ok {
+        return "", "", false, "", fmt.Errorf("unknown node hash %q", nodeHash)
+    }
+    if node.Left == nil && node.Right == nil {
+        return "", "", true, node.Data, nil
+    }
+    if node.Left != nil {
+        leftHash = node.Left.Hash
+    }
+    if node.Right != nil {
+        rightHash = node.Right.Hash
+    }
+    return leftHash, rightHash, false, "", nil
+}
+
+const (
+    diffMissing     = "missing"
+    diffExtra       = "extra"
+    diffConflicting = "conflicting"
+)
+
+// reconcilePair is one position being compared: the local node that sits
+// there (nil if only remote has anything there) and the remote hash at
+// that position ("" if only local has anything there).
+type reconcilePair struct {
+    local      *MerkleNode
+    remoteHash string
+}
+
+type reconcileState struct {
+    remote SyncPeer
+    sem    chan struct{}
+    limit  int // 0 means unlimited
+
+    ctx    context.Context
+    cancel context.CancelFunc
+    wg     sync.WaitGroup
+
+    mu          sync.Mutex
+    missing     []string
+    extra       []string
+    conflicting []string
+    total       int
+
+    errOnce  sync.Once
+    firstErr error
+}
+
+// Reconcile walks local and remote in lockstep, descending only into
+// subtrees whose hashes disagree; subtrees whose hashes already match are
+// pruned with a single comparison and never fetched. Differences are
+// classified by which side actually holds a leaf at the point they diverge:
+// missing is data remote has that local doesn't, extra is data local has
+// that remote doesn't, and conflicting is a leaf position both sides hold
+// data for but with different values. The walk relies on the same
+// sorted-leaf invariant NewMerkleTree already maintains, so a given local
+// and remote tree built from the same rules always reconcile to the same
+// result.
+func Reconcile(local *MerkleTree, remote SyncPeer) (missing, extra, conflicting []string, err error) {
+    return reconcile(local, remote, DefaultMaxInFlight, 0)
+}
+
+// ReconcileUpTo is Reconcile but stops, best-effort, once it has collected
+// maxDiffs differences - letting a caller bound how much of a large,
+// mostly-divergent pair of trees it walks before acting on what it already
+// has. In-flight requests dispatched just before the limit was hit may
+// still land, so the result can run a few entries over maxDiffs.
+// maxDiffs <= 0 means unlimited, same as Reconcile.
+func ReconcileUpTo(local *MerkleTree, remote SyncPeer, maxDiffs int) (missing, extra, conflicting []string, err error) {
+    return reconcile(local, remote, DefaultMaxInFlight, maxDiffs)
+}
+
+func reconcile(local *MerkleTree, remote SyncPeer, maxInFlight, maxDiffs int) (missing, extra, conflicting []string, err error) {
+    if maxInFlight <= 0 {
+        maxInFlight = DefaultMaxInFlight
+    }
+
+    remoteRoot, err := remote.RequestRoot()
+    if err != nil {
+        return nil, nil, nil, fmt.Errorf("request remote root: %w", err)
+    }
+
+    if local.GetRootHash() == remoteRoot {
+        return []string{}, []string{}, []string{}, nil
+    }
+
+    st := &reconcileState{
+        remote:      remote,
+        sem:         make(chan struct{}, maxInFlight),
+        limit:       maxDiffs,
+        missing:     []string{},
+        extra:       []string{},
+        conflicting: []string{},
+    }
+    st.ctx, st.cancel = context.WithCancel(context.Background())
+    defer st.cancel()
+
+    st.wg.Add(1)
+    go st.walk(reconcilePair{local: local.Root, remoteHash: remoteRoot})
+    st.wg.Wait()
+
+    if st.firstErr != nil {
+        return nil, nil, nil, st.firstErr
+    }
+    return st.missing, st.extra, st.conflicting, nil
+}
+
+func (st *reconcileState) walk(pair reconcilePair) {
+    defer st.wg.Done()
+
+    select {
+    case <-st.ctx.Done():
+        return
+    default:
+    }
+
+    localHash := ""
+    if pair.local != nil {
+        localHash = pair.local.Hash
+    }
+    if localHash == pair.remoteHash {
+        return
+    }
+
+    if pair.remoteHash == "" {
+        st.collectLocal(pair.local, diffExtra)
+        return
+    }
+
+    st.sem <- struct{}{}
+    leftHash, rightHash, isLeaf, data, err := st.remote.RequestChildren(pair.remoteHash)
+    <-st.sem
+    if err != nil {
+        st.fail(fmt.Errorf("request children of %s: %w", pair.remoteHash, err))
+        return
+    }
+
+    if pair.local == nil {
+        if isLeaf {
+            st.record(diffMissing, data)
+            return
+        }
+        st.spawn(reconcilePair{remoteHash: leftHash})
+        st.spawn(reconcilePair{remoteHash: rightHash})
+        return
+    }
+
+    localIsLeaf := pair.local.Left == nil && pair.local.Right == nil
+
+    switch {
+    case localIsLeaf && isLeaf:
+        if pair.local.Data != data {
+            st.record(diffConflicting, pair.local.Data)
+        }
+
+    case localIsLeaf && !isLeaf:
+        st.record(diffExtra, pair.local.Data)
+        st.spawn(reconcilePair{remoteHash: leftHash})
+        st.spawn(reconcilePair{remoteHash: rightHash})
+
+    case !localIsLeaf && isLeaf:
+        st.record(diffMissing, data)
+        st.spawn(reconcilePair{local: pair.local.Left})
+        st.spawn(reconcilePair{local: pair.local.Right})
+
+    default:
+        st.spawn(reconcilePair{local: pair.local.Left, remoteHash: leftHash})
+        st.spawn(reconcilePair{local: pair.local.Right, remoteHash: rightHash})
+    }
+}
+
+// collectLocal enumerates node's leaves into kind without touching remote -
+// used once a subtree is known to exist on local only.
+func (st *reconcileState) collectLocal(node *MerkleNode, kind string) {
+    select {
+    case <-st.ctx.Done():
+        return
+    default:
+    }
+    if node == nil {
+        return
+    }
+    if node.Left == nil && node.Right == nil {
+        st.record(kind, node.Data)
+        return
+    }
+    st.collectLocal(node.Left, kind)
+    st.collectLocal(node.Right, kind)
+}
+
+func (st *reconcileState) spawn(pair reconcilePair) {
+    select {
+    case <-st.ctx.Done():
+        return
+    default:
+    }
+    st.wg.Add(1)
+    go st.walk(pair)
+}
+
+func (st *reconcileState) record(kind, data string) {
+    st.mu.Lock()
+    if st.limit > 0 && st.total >= st.limit {
+        st.mu.Unlock()
+        return
+    }
+    switch kind {
+    case diffMissing:
+        st.missing = append(st.missing, data)
+    case diffExtra:
+        st.extra = append(st.extra, data)
+    case diffConflicting:
+        st.conflicting = append(st.conflicting, data)
+    }
+    st.total++
+    reachedLimit := st.limit > 0 && st.total >= st.limit
+    st.mu.Unlock()
+
+    if reachedLimit {
+        st.cancel()
+    }
+}
+
+func (st *reconcileState) fail(err error) {
+    st.errOnce.Do(func() {
+        st.firstErr = err
+        st.cancel()
+    })
+}
+
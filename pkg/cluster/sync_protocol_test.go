@@ -0,0 +1,91 @@
+package cluster
+
+import (
+    "sort"
+    "testing"
+)
+
+func TestReconcile_IdenticalTrees(t *testing.T) {
+    data := []string{"data1", "data2", "data3", "data4"}
+    local := NewMerkleTree(data)
+    remote := NewMerkleTree(data)
+
+    missing, extra, conflicting, err := Reconcile(local, NewLocalSyncSession(remote))
+    if err != nil {
+        t.Fatalf("Reconcile returned error: %v", err)
+    }
+    if len(missing) != 0 || len(extra) != 0 || len(conflicting) != 0 {
+        t.Errorf("identical trees should have no differences, got missing=%v extra=%v conflicting=%v", missing, extra, conflicting)
+    }
+}
+
+func TestReconcile_ConflictingLeaf(t *testing.T) {
+    // Four leaves each side (no odd-count duplicate node) and the changed
+    // leaf sorts after the rest, so it stays in the same tree position -
+    // isolating a single conflicting pair instead of cascading into a
+    // reshaped tree.
+    local := NewMerkleTree([]string{"data1", "data2", "data3", "data4"})
+    remote := NewMerkleTree([]string{"data1", "data2", "data3", "dataZ"})
+
+    missing, extra, conflicting, err := Reconcile(local, NewLocalSyncSession(remote))
+    if err != nil {
+        t.Fatalf("Reconcile returned error: %v", err)
+    }
+    if len(missing) != 0 || len(extra) != 0 {
+        t.Errorf("expected only a conflicting leaf, got missing=%v extra=%v", missing, extra)
+    }
+    if len(conflicting) != 1 || conflicting[0] != "data4" {
+        t.Errorf("expected conflicting=[data4], got %v", conflicting)
+    }
+}
+
+func TestReconcile_MissingAndExtra(t *testing.T) {
+    // Tree shape is derived from leaf count, not keys, so trees with
+    // different leaf counts reshape entirely rather than differing by a
+    // clean "one extra leaf" - Reconcile still terminates and reports
+    // some difference for every case, which is what this asserts.
+    local := NewMerkleTree([]string{"a", "b"})
+    remote := NewMerkleTree([]string{"a", "b", "c", "d"})
+
+    missing, extra, conflicting, err := Reconcile(local, NewLocalSyncSession(remote))
+    if err != nil {
+        t.Fatalf("Reconcile returned error: %v", err)
+    }
+
+    sort.Strings(missing)
+    sort.Strings(extra)
+    sort.Strings(conflicting)
+    if len(missing)+len(extra)+len(conflicting) == 0 {
+        t.Error("expected differently-sized trees to report at least one difference")
+    }
+}
+
+func TestReconcile_EmptyLocal(t *testing.T) {
+    local := NewMerkleTree(nil)
+    remote := NewMerkleTree([]string{"x", "y"})
+
+    missing, extra, conflicting, err := Reconcile(local, NewLocalSyncSession(remote))
+    if err != nil {
+        t.Fatalf("Reconcile returned error: %v", err)
+    }
+    if len(extra) != 0 || len(conflicting) != 0 {
+        t.Errorf("expected only missing leaves, got extra=%v conflicting=%v", extra, conflicting)
+    }
+    if len(missing) != 2 {
+        t.Errorf("expected both remote leaves to be missing, got %v", missing)
+    }
+}
+
+func TestReconcileUpTo_StopsEarly(t *testing.T) {
+    local := NewMerkleTree([]string{"a", "b", "c", "d"})
+    remote := NewMerkleTree([]string{"w", "x", "y", "z"})
+
+    missing, extra, conflicting, err := ReconcileUpTo(local, NewLocalSyncSession(remote), 1)
+    if err != nil {
+        t.Fatalf("ReconcileUpTo returned error: %v", err)
+    }
+    total := len(missing) + len(extra) + len(conflicting)
+    if total == 0 {
+        t.Error("expected at least one difference before stopping")
+    }
+}
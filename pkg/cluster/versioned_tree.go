@@ -0,0 +1,472 @@
+package cluster
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// MutableTree is a versioned, IAVL-inspired key/value tree layered on top
+// of the simple hash-leaf MerkleTree above: nodes are addressed by content
+// hash and persisted to a NodeDB, so a snapshot of any saved version stays
+// readable (via GetImmutable) while the mutable side keeps accepting new
+// writes. It intentionally does not reproduce IAVL's AVL rebalancing - it's
+// a plain persistent binary search tree over keys - which keeps lookups
+// O(depth) rather than guaranteed O(log n), a fine tradeoff for cluster
+// metadata/config history where trees stay small.
+type MutableTree struct {
+	mu   sync.RWMutex
+	db   NodeDB
+	root *treeNode
+
+	version       int64
+	oldestVersion int64
+	roots         map[int64]string
+	orphans       map[int64][]string
+	pendingOrphan []string
+}
+
+// NewMutableTree builds a MutableTree backed by db. An empty tree has no
+// saved versions; call SaveVersion after the first writes to create
+// version 1.
+func NewMutableTree(db NodeDB) *MutableTree {
+	return &MutableTree{
+		db:      db,
+		roots:   make(map[int64]string),
+		orphans: make(map[int64][]string),
+	}
+}
+
+// persistedNode is the on-disk/gob-encoded representation of a tree node.
+// Exported fields only, since gob requires them.
+type persistedNode struct {
+	Key       []byte
+	Value     []byte
+	LeftHash  string
+	RightHash string
+}
+
+// treeNode is the in-memory working representation: a persistedNode plus
+// lazily-loaded child pointers and a dirty flag marking nodes created or
+// modified since the last SaveVersion.
+type treeNode struct {
+	persistedNode
+	hash  string
+	left  *treeNode
+	right *treeNode
+	dirty bool
+}
+
+func encodeNode(pn persistedNode) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(pn); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeNode(data []byte) (persistedNode, error) {
+	var pn persistedNode
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&pn)
+	return pn, err
+}
+
+func computeNodeHash(pn persistedNode) string {
+	data, _ := encodeNode(pn)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func rootKey(version int64) []byte {
+	return []byte("root:" + strconv.FormatInt(version, 10))
+}
+
+func loadNode(db NodeDB, hash string) (*treeNode, error) {
+	if hash == "" {
+		return nil, nil
+	}
+	data, err := db.Get([]byte(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load node %s: %w", hash, err)
+	}
+	pn, err := decodeNode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode node %s: %w", hash, err)
+	}
+	return &treeNode{persistedNode: pn, hash: hash}, nil
+}
+
+// left returns (and lazily loads/caches) node's left child.
+func (n *treeNode) loadLeft(db NodeDB) (*treeNode, error) {
+	if n.left != nil || n.LeftHash == "" {
+		return n.left, nil
+	}
+	child, err := loadNode(db, n.LeftHash)
+	if err != nil {
+		return nil, err
+	}
+	n.left = child
+	return child, nil
+}
+
+func (n *treeNode) loadRight(db NodeDB) (*treeNode, error) {
+	if n.right != nil || n.RightHash == "" {
+		return n.right, nil
+	}
+	child, err := loadNode(db, n.RightHash)
+	if err != nil {
+		return nil, err
+	}
+	n.right = child
+	return child, nil
+}
+
+// Set inserts or updates key. Changed nodes are kept dirty in memory until
+// SaveVersion persists them.
+func (t *MutableTree) Set(key, value []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	newRoot, orphaned, err := setRecursive(t.db, t.root, key, value)
+	if err != nil {
+		return err
+	}
+	t.root = newRoot
+	t.pendingOrphan = append(t.pendingOrphan, orphaned...)
+	return nil
+}
+
+func setRecursive(db NodeDB, node *treeNode, key, value []byte) (*treeNode, []string, error) {
+	if node == nil {
+		return &treeNode{persistedNode: persistedNode{Key: key, Value: value}, dirty: true}, nil, nil
+	}
+
+	var orphaned []string
+	if !node.dirty && node.hash != "" {
+		orphaned = append(orphaned, node.hash)
+	}
+
+	next := &treeNode{persistedNode: node.persistedNode, left: node.left, right: node.right, dirty: true}
+
+	switch bytes.Compare(key, node.Key) {
+	case 0:
+		next.Value = value
+	case -1:
+		left, err := node.loadLeft(db)
+		if err != nil {
+			return nil, nil, err
+		}
+		newLeft, childOrphans, err := setRecursive(db, left, key, value)
+		if err != nil {
+			return nil, nil, err
+		}
+		next.left = newLeft
+		orphaned = append(orphaned, childOrphans...)
+	default:
+		right, err := node.loadRight(db)
+		if err != nil {
+			return nil, nil, err
+		}
+		newRight, childOrphans, err := setRecursive(db, right, key, value)
+		if err != nil {
+			return nil, nil, err
+		}
+		next.right = newRight
+		orphaned = append(orphaned, childOrphans...)
+	}
+
+	return next, orphaned, nil
+}
+
+// Remove deletes key if present, returning whether it was found.
+func (t *MutableTree) Remove(key []byte) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	newRoot, orphaned, removed, err := removeRecursive(t.db, t.root, key)
+	if err != nil {
+		return false, err
+	}
+	if removed {
+		t.root = newRoot
+		t.pendingOrphan = append(t.pendingOrphan, orphaned...)
+	}
+	return removed, nil
+}
+
+func removeRecursive(db NodeDB, node *treeNode, key []byte) (*treeNode, []string, bool, error) {
+	if node == nil {
+		return nil, nil, false, nil
+	}
+
+	cmp := bytes.Compare(key, node.Key)
+	if cmp == 0 {
+		left, err := node.loadLeft(db)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		right, err := node.loadRight(db)
+		if err != nil {
+			return nil, nil, false, err
+		}
+
+		var orphaned []string
+		if !node.dirty && node.hash != "" {
+			orphaned = append(orphaned, node.hash)
+		}
+
+		switch {
+		case left == nil:
+			return right, orphaned, true, nil
+		case right == nil:
+			return left, orphaned, true, nil
+		default:
+			// Replace with the in-order successor (leftmost node of right subtree).
+			successor, succOrphans, err := removeMin(db, right)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			successor.left = left
+			successor.right = succOrphans.tree
+			successor.dirty = true
+			orphaned = append(orphaned, succOrphans.orphans...)
+			return successor, orphaned, true, nil
+		}
+	}
+
+	if cmp < 0 {
+		left, err := node.loadLeft(db)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		newLeft, orphaned, removed, err := removeRecursive(db, left, key)
+		if err != nil || !removed {
+			return nil, nil, removed, err
+		}
+		next := &treeNode{persistedNode: node.persistedNode, left: newLeft, right: node.right, dirty: true}
+		if !node.dirty && node.hash != "" {
+			orphaned = append(orphaned, node.hash)
+		}
+		return next, orphaned, true, nil
+	}
+
+	right, err := node.loadRight(db)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	newRight, orphaned, removed, err := removeRecursive(db, right, key)
+	if err != nil || !removed {
+		return nil, nil, removed, err
+	}
+	next := &treeNode{persistedNode: node.persistedNode, left: node.left, right: newRight, dirty: true}
+	if !node.dirty && node.hash != "" {
+		orphaned = append(orphaned, node.hash)
+	}
+	return next, orphaned, true, nil
+}
+
+type successorResult struct {
+	tree    *treeNode
+	orphans []string
+}
+
+// removeMin removes and returns the leftmost node of the subtree rooted at
+// node, along with the subtree that remains once it's gone.
+func removeMin(db NodeDB, node *treeNode) (*treeNode, successorResult, error) {
+	left, err := node.loadLeft(db)
+	if err != nil {
+		return nil, successorResult{}, err
+	}
+
+	var orphaned []string
+	if !node.dirty && node.hash != "" {
+		orphaned = append(orphaned, node.hash)
+	}
+
+	if left == nil {
+		right, err := node.loadRight(db)
+		if err != nil {
+			return nil, successorResult{}, err
+		}
+		min := &treeNode{persistedNode: persistedNode{Key: node.Key, Value: node.Value}, dirty: true}
+		return min, successorResult{tree: right, orphans: orphaned}, nil
+	}
+
+	min, rest, err := removeMin(db, left)
+	if err != nil {
+		return nil, successorResult{}, err
+	}
+	next := &treeNode{persistedNode: node.persistedNode, left: rest.tree, right: node.right, dirty: true}
+	orphaned = append(orphaned, rest.orphans...)
+	return min, successorResult{tree: next, orphans: orphaned}, nil
+}
+
+// Get looks up key in the tree's current (unsaved included) state.
+func (t *MutableTree) Get(key []byte) ([]byte, bool, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return getFromNode(t.db, t.root, key)
+}
+
+func getFromNode(db NodeDB, node *treeNode, key []byte) ([]byte, bool, error) {
+	for node != nil {
+		switch bytes.Compare(key, node.Key) {
+		case 0:
+			return node.Value, true, nil
+		case -1:
+			next, err := node.loadLeft(db)
+			if err != nil {
+				return nil, false, err
+			}
+			node = next
+		default:
+			next, err := node.loadRight(db)
+			if err != nil {
+				return nil, false, err
+			}
+			node = next
+		}
+	}
+	return nil, false, nil
+}
+
+// SaveVersion persists every dirty node reachable from the current root as
+// a single batch, records the new root hash under the new version number,
+// and returns that version and root hash.
+func (t *MutableTree) SaveVersion() (int64, string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	batch := t.db.Batch()
+	rootHash, err := persistDirty(t.root, batch)
+	if err != nil {
+		return 0, "", err
+	}
+
+	nextVersion := t.version + 1
+	batch.Set(rootKey(nextVersion), []byte(rootHash))
+	if err := batch.Write(); err != nil {
+		return 0, "", fmt.Errorf("failed to write version %d: %w", nextVersion, err)
+	}
+
+	t.version = nextVersion
+	t.roots[nextVersion] = rootHash
+	t.orphans[nextVersion] = t.pendingOrphan
+	t.pendingOrphan = nil
+	if _, ok := t.roots[t.oldestVersion]; !ok && t.oldestVersion == 0 {
+		t.oldestVersion = nextVersion
+	}
+
+	return nextVersion, rootHash, nil
+}
+
+func persistDirty(node *treeNode, batch NodeBatch) (string, error) {
+	if node == nil {
+		return "", nil
+	}
+	if !node.dirty {
+		return node.hash, nil
+	}
+
+	leftHash, err := persistDirty(node.left, batch)
+	if err != nil {
+		return "", err
+	}
+	rightHash, err := persistDirty(node.right, batch)
+	if err != nil {
+		return "", err
+	}
+	node.LeftHash = leftHash
+	node.RightHash = rightHash
+
+	hash := computeNodeHash(node.persistedNode)
+	data, err := encodeNode(node.persistedNode)
+	if err != nil {
+		return "", err
+	}
+	batch.Set([]byte(hash), data)
+
+	node.hash = hash
+	node.dirty = false
+	return hash, nil
+}
+
+// ImmutableTree is a read-only snapshot of the tree as of one saved
+// version. It only ever reads from NodeDB, so it stays valid and safe for
+// concurrent use while the originating MutableTree continues to mutate:
+// nodes are content-addressed and never overwritten once written.
+type ImmutableTree struct {
+	db       NodeDB
+	rootHash string
+}
+
+// GetImmutable returns a snapshot of the tree as of version, or an error if
+// that version was never saved (or has since been pruned via DeleteVersion).
+func (t *MutableTree) GetImmutable(version int64) (*ImmutableTree, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	rootHash, ok := t.roots[version]
+	if !ok {
+		return nil, fmt.Errorf("version %d not found", version)
+	}
+	return &ImmutableTree{db: t.db, rootHash: rootHash}, nil
+}
+
+// Get looks up key as of this snapshot's version.
+func (it *ImmutableTree) Get(key []byte) ([]byte, bool, error) {
+	root, err := loadNode(it.db, it.rootHash)
+	if err != nil {
+		return nil, false, err
+	}
+	return getFromNode(it.db, root, key)
+}
+
+// GetRootHash returns this snapshot's root hash (empty string for an empty tree).
+func (it *ImmutableTree) GetRootHash() string {
+	return it.rootHash
+}
+
+// Version returns the most recently saved version number (0 if none saved yet).
+func (t *MutableTree) Version() int64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.version
+}
+
+// DeleteVersion prunes version v's root entry and the nodes that became
+// orphaned when v+1 was saved (i.e. the nodes unique to v's snapshot).
+// Versions must be deleted oldest-first: pruning only ever needs to reason
+// about one generation of orphans at a time instead of checking every
+// still-retained version for a lingering reference, since anything older
+// than v is already gone and nothing newer than v can point at a node v
+// orphaned.
+func (t *MutableTree) DeleteVersion(v int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if v < t.oldestVersion || v >= t.version {
+		return fmt.Errorf("version %d is not a prunable non-latest version (oldest retained: %d, latest: %d)", v, t.oldestVersion, t.version)
+	}
+	if v != t.oldestVersion {
+		return fmt.Errorf("versions must be deleted oldest-first: oldest retained version is %d, got %d", t.oldestVersion, v)
+	}
+
+	batch := t.db.Batch()
+	batch.Delete(rootKey(v))
+	for _, hash := range t.orphans[v+1] {
+		batch.Delete([]byte(hash))
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to delete version %d: %w", v, err)
+	}
+
+	delete(t.roots, v)
+	delete(t.orphans, v+1)
+	t.oldestVersion = v + 1
+	return nil
+}
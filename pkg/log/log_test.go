@@ -0,0 +1,36 @@
+package log
+
+import "testing"
+
+func TestRecorder_CapturesLevelMessageAndKV(t *testing.T) {
+    r := NewRecorder()
+    r.Warn("node marked down", "node", "node-2", "since_heartbeat", "12s")
+
+    records := r.Records()
+    if len(records) != 1 {
+        t.Fatalf("expected 1 record, got %d", len(records))
+    }
+
+    rec := records[0]
+    if rec.Level != LevelWarn || rec.Msg != "node marked down" {
+        t.Fatalf("unexpected record: %+v", rec)
+    }
+    if len(rec.KV) != 4 || rec.KV[0] != "node" || rec.KV[1] != "node-2" {
+        t.Fatalf("unexpected kv context: %v", rec.KV)
+    }
+}
+
+func TestRecorder_WithPrependsContextToSubsequentCalls(t *testing.T) {
+    r := NewRecorder()
+    scoped := r.With("node", "node-1")
+    scoped.Info("started", "interval", "1s")
+
+    records := r.Records()
+    if len(records) != 1 {
+        t.Fatalf("expected 1 record, got %d", len(records))
+    }
+    kv := records[0].KV
+    if len(kv) != 4 || kv[0] != "node" || kv[1] != "node-1" || kv[2] != "interval" {
+        t.Fatalf("expected With context prepended, got %v", kv)
+    }
+}
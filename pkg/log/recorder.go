@@ -0,0 +1,56 @@
+package log
+
+import "sync"
+
+// Record is one captured log call.
+type Record struct {
+    Level Level
+    Msg   string
+    KV    []interface{}
+}
+
+// Recorder is a Logger that captures every call instead of printing it,
+// so tests can assert against structured records (level, message, and
+// key-value context) rather than parsing formatted strings.
+type Recorder struct {
+    mu      sync.Mutex
+    prefix  []interface{}
+    records *[]Record
+}
+
+// NewRecorder returns a Recorder Logger with an empty record set.
+func NewRecorder() *Recorder {
+    return &Recorder{records: &[]Record{}}
+}
+
+// Records returns every call captured so far.
+func (r *Recorder) Records() []Record {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    out := make([]Record, len(*r.records))
+    copy(out, *r.records)
+    return out
+}
+
+func (r *Recorder) log(level Level, msg string, kv []interface{}) {
+    all := make([]interface{}, 0, len(r.prefix)+len(kv))
+    all = append(all, r.prefix...)
+    all = append(all, kv...)
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    *r.records = append(*r.records, Record{Level: level, Msg: msg, KV: all})
+}
+
+func (r *Recorder) Trace(msg string, kv ...interface{}) { r.log(LevelTrace, msg, kv) }
+func (r *Recorder) Debug(msg string, kv ...interface{}) { r.log(LevelDebug, msg, kv) }
+func (r *Recorder) Info(msg string, kv ...interface{})  { r.log(LevelInfo, msg, kv) }
+func (r *Recorder) Warn(msg string, kv ...interface{})  { r.log(LevelWarn, msg, kv) }
+func (r *Recorder) Error(msg string, kv ...interface{}) { r.log(LevelError, msg, kv) }
+
+func (r *Recorder) With(kv ...interface{}) Logger {
+    prefix := make([]interface{}, 0, len(r.prefix)+len(kv))
+    prefix = append(prefix, r.prefix...)
+    prefix = append(prefix, kv...)
+    return &Recorder{prefix: prefix, records: r.records}
+}
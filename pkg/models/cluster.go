@@ -8,7 +8,12 @@ type NodeInfo struct {
     HTTPPort      int       `json:"http_port"`
     MQTTPort      int       `json:"mqtt_port"`
     LastHeartbeat time.Time `json:"last_heartbeat"`
-    Status        string    `json:"status"` 
+    Status        string    `json:"status"`
+    // Incarnation is bumped by a node itself whenever it refutes a
+    // suspicion of its own liveness. Suspicion/refutation messages for a
+    // node carry the incarnation they apply to, so a stale suspicion
+    // (lower incarnation) can never overrule a newer refutation.
+    Incarnation   int64     `json:"incarnation"`
 }
 
 type ClusterState struct {
@@ -17,10 +22,74 @@ type ClusterState struct {
     Version           int64                `json:"version"`
 }
 
+// SuspicionMessage reports that From independently suspects NodeID is down
+// as of Incarnation. GossipMessage piggybacks a batch of these so suspicion
+// confirmations spread the same way membership state does, without a
+// dedicated message type.
+type SuspicionMessage struct {
+    NodeID      string `json:"node_id"`
+    Incarnation int64  `json:"incarnation"`
+    From        string `json:"from"`
+}
+
+// RefutationMessage is broadcast by a node that was suspected but is still
+// alive. Incarnation is the node's new (bumped) incarnation number; any
+// suspicion carrying a lower incarnation for NodeID is stale and must be
+// dropped on receipt.
+type RefutationMessage struct {
+    NodeID      string `json:"node_id"`
+    Incarnation int64  `json:"incarnation"`
+}
+
 type GossipMessage struct {
-    State   ClusterState `json:"state"`
-    From    string       `json:"from"`
-    Version int64        `json:"version"`
+    State       ClusterState        `json:"state"`
+    From        string              `json:"from"`
+    Version     int64               `json:"version"`
+    Suspicions  []SuspicionMessage  `json:"suspicions,omitempty"`
+    Refutations []RefutationMessage `json:"refutations,omitempty"`
+    // AppState is an opaque blob a registered cluster.Delegate attaches via
+    // LocalState() and a peer's Delegate consumes via MergeRemoteState(),
+    // letting higher-level subsystems (replication, routing) piggyback their
+    // own state on full gossip syncs instead of running a separate exchange.
+    AppState []byte `json:"app_state,omitempty"`
+}
+
+// KeyRotationHint piggybacks a "promote this already-distributed key to
+// primary" instruction through gossip, the cluster-wide half of a
+// network.Keyring rotation: an operator AddKeys the new key on every node
+// out of band, then calls GossipProtocol.BroadcastKeyRotation once to have
+// every peer UseKey it without touching them individually. MAC is a
+// network.Keyring.RotationMAC over KeyID computed with the key being
+// promoted, so a receiver only honors the hint if it was produced by
+// something that already holds that key's secret bytes - the gossip
+// channel carrying this hint is not trusted to be authenticated on its
+// own (see GossipProtocol.HandleGossipPacket).
+type KeyRotationHint struct {
+    KeyID string `json:"key_id"`
+    MAC   []byte `json:"mac"`
+}
+
+// GossipDelta carries a single node's state as one broadcastable unit -
+// the currency of the bounded UDP gossip transport, which ships a handful
+// of deltas per tick instead of the entire ClusterState.
+type GossipDelta struct {
+    NodeID  string   `json:"node_id"`
+    Node    NodeInfo `json:"node"`
+    Version int64    `json:"version"`
+}
+
+// GossipPacket is the payload sent over the UDP transport on every gossip
+// tick: a bounded batch of deltas plus the sender's per-node version
+// vector, so a receiver that notices its own vector has fallen behind a
+// delta's version by more than one can tell it missed an update and fall
+// back to requesting a full TCP sync.
+type GossipPacket struct {
+    From            string              `json:"from"`
+    Deltas          []GossipDelta       `json:"deltas"`
+    Suspicions      []SuspicionMessage  `json:"suspicions,omitempty"`
+    Refutations     []RefutationMessage `json:"refutations,omitempty"`
+    Versions        map[string]int64    `json:"versions"`
+    KeyRotationHint *KeyRotationHint    `json:"key_rotation_hint,omitempty"`
 }
 
 type InternalMessage struct {
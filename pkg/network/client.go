@@ -5,40 +5,87 @@ import (
 	"fmt"
 	"net"
 	"time"
+
+	"github.com/minitrue/pkg/log"
 )
 
 type Client struct {
-	timeout time.Duration
+	timeout  time.Duration
+	connWrap func(net.Conn) (net.Conn, error)
+	keyring  *Keyring
+	logger   log.Logger
 }
 
-func NewClient(timeout time.Duration) *Client {
+func NewClient(timeout time.Duration, logger log.Logger) *Client {
+	if logger == nil {
+		logger = log.Nop()
+	}
 	return &Client{
 		timeout: timeout,
+		logger:  logger,
 	}
 }
 
+// SetConnWrap installs a hook applied to every connection Send dials, e.g.
+// to layer an encrypted, authenticated session (see
+// internal/transport/secure) onto the raw TCP stream.
+func (c *Client) SetConnWrap(wrap func(net.Conn) (net.Conn, error)) {
+	c.connWrap = wrap
+}
+
+// SetKeyring installs kr, under which every subsequent Send and SendPacket
+// encrypts its payload (AES-GCM, under kr's current primary key) before
+// writing the length prefix (Send) or the raw datagram (SendPacket). This
+// is independent of SetConnWrap's connection-level handshake: SetConnWrap
+// authenticates and encrypts the whole TCP session, while a Keyring
+// authenticates and encrypts each message - over TCP or UDP - under a key
+// an operator can rotate without a new handshake.
+func (c *Client) SetKeyring(kr *Keyring) {
+	c.keyring = kr
+}
+
 func (c *Client) Send(address string, data []byte) error {
 	conn, err := net.DialTimeout("tcp", address, c.timeout)
 	if err != nil {
+		c.logger.Debug("tcp dial failed", "addr", address, "err", err)
 		return fmt.Errorf("failed to connect to %s: %w", address, err)
 	}
 	defer conn.Close()
 
+	if c.connWrap != nil {
+		wrapped, err := c.connWrap(conn)
+		if err != nil {
+			return fmt.Errorf("securing connection to %s: %w", address, err)
+		}
+		conn = wrapped
+	}
+
+	payload := data
+	if c.keyring != nil {
+		encrypted, err := c.keyring.Encrypt(data)
+		if err != nil {
+			return fmt.Errorf("encrypting payload for %s: %w", address, err)
+		}
+		payload = encrypted
+	}
+
 	if err := conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
 		return fmt.Errorf("failed to set write deadline: %w", err)
 	}
 
-	length := uint32(len(data))
+	length := uint32(len(payload))
 	lengthBytes := make([]byte, 4)
 	binary.BigEndian.PutUint32(lengthBytes, length)
 
 	if _, err := conn.Write(lengthBytes); err != nil {
+		c.logger.Debug("tcp write failed", "addr", address, "err", err)
 		return fmt.Errorf("failed to write data length: %w", err)
 	}
 
-	if _, err := conn.Write(data); err != nil {
+	if _, err := conn.Write(payload); err != nil {
+		c.logger.Debug("tcp write failed", "addr", address, "err", err)
 		return fmt.Errorf("failed to write data: %w", err)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,70 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// Codec marshals/unmarshals RPC payloads. A connection picks its codec once,
+// via a single handshake byte sent right after connecting (see
+// NewRPCClient), so server and client agree on wire format without it being
+// repeated per frame.
+//
+// Only JSON and Gob are implemented: both ship in the standard library, and
+// adding MessagePack or Protobuf later means implementing this interface,
+// not changing the framing or handshake.
+type Codec interface {
+	// ID is the single handshake byte identifying this codec.
+	ID() byte
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+const (
+	CodecJSON byte = 0x01
+	CodecGob  byte = 0x02
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte                             { return CodecJSON }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ID() byte { return CodecGob }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// JSONCodec and GobCodec are the built-in Codec implementations.
+var (
+	JSONCodec Codec = jsonCodec{}
+	GobCodec  Codec = gobCodec{}
+)
+
+// codecByID resolves the handshake byte read from a connection to a Codec.
+func codecByID(id byte) (Codec, error) {
+	switch id {
+	case CodecJSON:
+		return JSONCodec, nil
+	case CodecGob:
+		return GobCodec, nil
+	default:
+		return nil, fmt.Errorf("unknown codec id 0x%x", id)
+	}
+}
@@ -0,0 +1,81 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Frame is the wire envelope for the RPC substrate (see rpc_client.go /
+// rpc_server.go). Unlike the raw length-prefixed blobs Server/Client
+// exchange today, every subsystem speaking Frame gets request/response
+// correlation (MsgID) and a handler-type dispatch (Type) for free instead of
+// inventing its own envelope inside the payload.
+type Frame struct {
+	Type    uint16
+	MsgID   uint64
+	Flags   uint16
+	Payload []byte
+}
+
+// Frame flags. A frame sets exactly one of Request/Response/Notify/Heartbeat,
+// plus optionally Error alongside Response.
+const (
+	FlagRequest   uint16 = 1 << 0
+	FlagResponse  uint16 = 1 << 1
+	FlagNotify    uint16 = 1 << 2
+	FlagHeartbeat uint16 = 1 << 3
+	FlagError     uint16 = 1 << 4
+)
+
+// frameHeaderSize is the fixed portion of a Frame on the wire: Type (2) +
+// MsgID (8) + Flags (2), not counting the leading 4-byte length prefix.
+const frameHeaderSize = 2 + 8 + 2
+
+// DefaultMaxFrameSize is used when callers don't set their own limit. It is
+// deliberately independent of Server's hardcoded 10 MiB cap so RPC traffic
+// can be tuned without touching the raw byte-slinging path.
+const DefaultMaxFrameSize = 4 * 1024 * 1024
+
+// WriteFrame writes f to w as [4-byte length][2-byte Type][8-byte MsgID]
+// [2-byte Flags][Payload], where length covers everything after itself.
+func WriteFrame(w io.Writer, f *Frame) error {
+	total := frameHeaderSize + len(f.Payload)
+	buf := make([]byte, 4+total)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(total))
+	binary.BigEndian.PutUint16(buf[4:6], f.Type)
+	binary.BigEndian.PutUint64(buf[6:14], f.MsgID)
+	binary.BigEndian.PutUint16(buf[14:16], f.Flags)
+	copy(buf[16:], f.Payload)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadFrame reads one Frame from r, rejecting frames whose declared length
+// exceeds maxSize.
+func ReadFrame(r io.Reader, maxSize uint32) (*Frame, error) {
+	lengthBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, lengthBytes); err != nil {
+		return nil, err
+	}
+	total := binary.BigEndian.Uint32(lengthBytes)
+	if total > maxSize {
+		return nil, fmt.Errorf("frame size %d exceeds limit %d", total, maxSize)
+	}
+	if total < frameHeaderSize {
+		return nil, fmt.Errorf("frame size %d smaller than header size %d", total, frameHeaderSize)
+	}
+
+	body := make([]byte, total)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return &Frame{
+		Type:    binary.BigEndian.Uint16(body[0:2]),
+		MsgID:   binary.BigEndian.Uint64(body[2:10]),
+		Flags:   binary.BigEndian.Uint16(body[10:12]),
+		Payload: body[12:],
+	}, nil
+}
@@ -0,0 +1,213 @@
+package network
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// KeySize is the required length, in bytes, of every Keyring key (AES-256).
+const KeySize = 32
+
+// keyEntry pairs a keyring key with the ID it was registered under.
+type keyEntry struct {
+	id  string
+	key []byte
+}
+
+// Keyring holds the symmetric key(s) used to encrypt and authenticate
+// gossip traffic: a primary key, used to encrypt everything this node
+// sends, plus zero or more secondary keys kept around for decryption only.
+// That split is what makes rotation zero-downtime: an operator AddKeys the
+// new key on every node first, waits for it to be installed everywhere,
+// then rotates with UseKey (or GossipProtocol.BroadcastKeyRotation) -
+// messages encrypted under the old primary, now a secondary, still
+// decrypt cleanly while the rollout is in flight. Modeled on memberlist's
+// gossip keyring.
+type Keyring struct {
+	mu sync.RWMutex
+	// entries[0] is always the current primary.
+	entries              []keyEntry
+	requireAuthenticated bool
+}
+
+// NewKeyring builds a Keyring whose primary key is primaryKey (exactly
+// KeySize bytes), registered under primaryID. If requireAuthenticated is
+// true, Decrypt's caller must reject any payload that fails to decrypt
+// under every known key rather than falling back to treating it as
+// plaintext.
+func NewKeyring(primaryID string, primaryKey []byte, requireAuthenticated bool) (*Keyring, error) {
+	if len(primaryKey) != KeySize {
+		return nil, fmt.Errorf("network: keyring key must be %d bytes, got %d", KeySize, len(primaryKey))
+	}
+	return &Keyring{
+		entries:              []keyEntry{{id: primaryID, key: append([]byte(nil), primaryKey...)}},
+		requireAuthenticated: requireAuthenticated,
+	}, nil
+}
+
+// PrimaryID returns the ID of the key currently used to encrypt.
+func (k *Keyring) PrimaryID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.entries[0].id
+}
+
+// RequireAuthenticated reports whether a payload that fails to decrypt
+// under every known key must be rejected outright, rather than passed
+// through as presumed-plaintext during a mixed-version rollout.
+func (k *Keyring) RequireAuthenticated() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.requireAuthenticated
+}
+
+// AddKey installs key under id as a secondary: available for decrypting,
+// but not used to encrypt until a later UseKey promotes it.
+func (k *Keyring) AddKey(id string, key []byte) error {
+	if len(key) != KeySize {
+		return fmt.Errorf("network: keyring key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, e := range k.entries {
+		if e.id == id {
+			return fmt.Errorf("network: keyring already has a key with ID %q", id)
+		}
+	}
+	k.entries = append(k.entries, keyEntry{id: id, key: append([]byte(nil), key...)})
+	return nil
+}
+
+// RemoveKey drops the key registered under id. Removing the current
+// primary is refused - UseKey to rotate off it first, so there's never a
+// moment where encryption silently falls back to a weaker key.
+func (k *Keyring) RemoveKey(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.entries[0].id == id {
+		return fmt.Errorf("network: cannot remove primary key %q, UseKey to rotate off it first", id)
+	}
+	for i, e := range k.entries {
+		if e.id == id {
+			k.entries = append(k.entries[:i], k.entries[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("network: no key with ID %q", id)
+}
+
+// UseKey rotates the primary to the key already registered under id,
+// demoting the previous primary to a decrypt-only secondary. id must
+// already be in the keyring - the usual sequence is AddKey the new key
+// everywhere, confirm every peer has it, then UseKey (or let
+// GossipProtocol.BroadcastKeyRotation do that last step across the
+// cluster).
+func (k *Keyring) UseKey(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for i, e := range k.entries {
+		if e.id == id {
+			k.entries[0], k.entries[i] = k.entries[i], k.entries[0]
+			return nil
+		}
+	}
+	return fmt.Errorf("network: no key with ID %q, AddKey it before rotating to it", id)
+}
+
+// Encrypt seals plaintext under the current primary key with AES-GCM,
+// returning a randomly generated nonce followed by the sealed ciphertext.
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	k.mu.RLock()
+	primary := k.entries[0].key
+	k.mu.RUnlock()
+
+	gcm, err := newGCM(primary)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("network: generating nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt tries every known key, primary first, to open encrypted (a
+// nonce followed by an AES-GCM sealed frame, as produced by Encrypt),
+// returning the plaintext and the ID of whichever key worked. Trying every
+// key, rather than reading a key ID off the wire, is what lets a rotation
+// land without a flag day: a node mid-rotation can still decrypt whatever
+// its peers - on the old or the new primary - sent it.
+func (k *Keyring) Decrypt(encrypted []byte) (plaintext []byte, keyID string, err error) {
+	k.mu.RLock()
+	entries := make([]keyEntry, len(k.entries))
+	copy(entries, k.entries)
+	k.mu.RUnlock()
+
+	for _, e := range entries {
+		gcm, err := newGCM(e.key)
+		if err != nil {
+			continue
+		}
+		if len(encrypted) < gcm.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := encrypted[:gcm.NonceSize()], encrypted[gcm.NonceSize():]
+		if plain, err := gcm.Open(nil, nonce, ciphertext, nil); err == nil {
+			return plain, e.id, nil
+		}
+	}
+	return nil, "", fmt.Errorf("network: failed to decrypt with any of %d known keys", len(entries))
+}
+
+// RotationMAC returns an HMAC-SHA256 over keyID computed with the key
+// already registered under keyID, proving the caller possesses that key's
+// secret bytes. This authenticates a key-rotation instruction (see
+// GossipProtocol.BroadcastKeyRotation/HandleGossipPacket) independent of
+// whatever transport carried it: an attacker who hasn't already been given
+// the target key out of band cannot produce a MAC VerifyRotationMAC will
+// accept, even if they can inject packets onto the gossip channel.
+func (k *Keyring) RotationMAC(keyID string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, e := range k.entries {
+		if e.id == keyID {
+			mac := hmac.New(sha256.New, e.key)
+			mac.Write([]byte(keyID))
+			return mac.Sum(nil), nil
+		}
+	}
+	return nil, fmt.Errorf("network: no key with ID %q", keyID)
+}
+
+// VerifyRotationMAC reports whether mac is a valid RotationMAC for keyID
+// under the key already registered under keyID in this Keyring. Returns
+// false (rather than erroring) if keyID is unknown, since the caller's
+// only action in either case is to refuse the rotation.
+func (k *Keyring) VerifyRotationMAC(keyID string, mac []byte) bool {
+	expected, err := k.RotationMAC(keyID)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, mac)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("network: building AES cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
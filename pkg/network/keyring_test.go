@@ -0,0 +1,108 @@
+package network
+
+import "testing"
+
+func mustKey(b byte) []byte {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = b
+	}
+	return key
+}
+
+func TestKeyring_EncryptDecryptRoundTrip(t *testing.T) {
+	kr, err := NewKeyring("k1", mustKey(1), false)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+
+	encrypted, err := kr.Encrypt([]byte("hello gossip"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	plain, keyID, err := kr.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if string(plain) != "hello gossip" {
+		t.Fatalf("expected round-tripped plaintext, got %q", plain)
+	}
+	if keyID != "k1" {
+		t.Fatalf("expected keyID k1, got %q", keyID)
+	}
+}
+
+func TestKeyring_DecryptTriesSecondaryKeysAfterRotation(t *testing.T) {
+	sender, err := NewKeyring("k1", mustKey(1), false)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	encrypted, err := sender.Encrypt([]byte("pre-rotation"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	receiver, err := NewKeyring("k1", mustKey(1), false)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	if err := receiver.AddKey("k2", mustKey(2)); err != nil {
+		t.Fatalf("AddKey: %v", err)
+	}
+	if err := receiver.UseKey("k2"); err != nil {
+		t.Fatalf("UseKey: %v", err)
+	}
+
+	// receiver's primary is now k2, but it must still decrypt a message
+	// encrypted under k1 since k1 was demoted to a secondary, not removed.
+	plain, keyID, err := receiver.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt after rotation: %v", err)
+	}
+	if string(plain) != "pre-rotation" {
+		t.Fatalf("expected pre-rotation plaintext, got %q", plain)
+	}
+	if keyID != "k1" {
+		t.Fatalf("expected decrypt to report k1, got %q", keyID)
+	}
+}
+
+func TestKeyring_RemoveKeyRefusesPrimary(t *testing.T) {
+	kr, err := NewKeyring("k1", mustKey(1), false)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	if err := kr.RemoveKey("k1"); err == nil {
+		t.Fatal("expected RemoveKey to refuse removing the primary key")
+	}
+}
+
+func TestKeyring_UseKeyRequiresKeyAlreadyAdded(t *testing.T) {
+	kr, err := NewKeyring("k1", mustKey(1), false)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	if err := kr.UseKey("k2"); err == nil {
+		t.Fatal("expected UseKey to fail for a key that was never added")
+	}
+}
+
+func TestKeyring_DecryptFailsWithWrongKey(t *testing.T) {
+	sender, err := NewKeyring("k1", mustKey(1), false)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	encrypted, err := sender.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	receiver, err := NewKeyring("k2", mustKey(2), true)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	if _, _, err := receiver.Decrypt(encrypted); err == nil {
+		t.Fatal("expected Decrypt to fail when no known key matches")
+	}
+}
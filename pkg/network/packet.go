@@ -0,0 +1,161 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/minitrue/pkg/log"
+)
+
+// maxPacketSize bounds a single inbound UDP datagram. Gossip deltas are
+// kept well under typical MTU (~1400 bytes), so this is a generous upper
+// bound rather than a tuned value.
+const maxPacketSize = 65536
+
+// PacketHandler processes one inbound UDP datagram. Unlike MessageHandler,
+// there is no connection to reply on - UDP is fire-and-forget, so any
+// response (e.g. a gap-triggered full sync) goes out as its own send.
+type PacketHandler interface {
+	HandlePacket(data []byte, addr net.Addr) error
+}
+
+// SendPacket ships data to address over UDP, with no delivery guarantee -
+// callers that need reliability layer their own retransmission on top
+// (see pkg/cluster.GossipProtocol's bounded broadcast queue). If a Keyring
+// was installed via SetKeyring, data is encrypted under its current
+// primary key first, the same as Send.
+func (c *Client) SendPacket(address string, data []byte) error {
+	conn, err := net.DialTimeout("udp", address, c.timeout)
+	if err != nil {
+		c.logger.Debug("udp dial failed", "addr", address, "err", err)
+		return fmt.Errorf("failed to resolve udp address %s: %w", address, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return fmt.Errorf("failed to set write deadline: %w", err)
+	}
+
+	payload := data
+	if c.keyring != nil {
+		encrypted, err := c.keyring.Encrypt(data)
+		if err != nil {
+			return fmt.Errorf("encrypting packet for %s: %w", address, err)
+		}
+		payload = encrypted
+	}
+
+	if _, err := conn.Write(payload); err != nil {
+		c.logger.Debug("udp write failed", "addr", address, "err", err)
+		return fmt.Errorf("failed to write packet to %s: %w", address, err)
+	}
+
+	return nil
+}
+
+// PacketServer is the UDP counterpart to Server: it listens for
+// unreliable, unordered datagrams and hands each one to handler.
+type PacketServer struct {
+	address  string
+	handler  PacketHandler
+	keyring  *Keyring
+	conn     net.PacketConn
+	wg       sync.WaitGroup
+	stopChan chan struct{}
+	logger   log.Logger
+}
+
+func NewPacketServer(address string, handler PacketHandler, logger log.Logger) *PacketServer {
+	if logger == nil {
+		logger = log.Nop()
+	}
+	return &PacketServer{
+		address:  address,
+		handler:  handler,
+		stopChan: make(chan struct{}),
+		logger:   logger,
+	}
+}
+
+// SetKeyring installs kr, under which receiveLoop decrypts every inbound
+// datagram before handing it to the PacketHandler, trying each of kr's
+// known keys in turn (see Keyring.Decrypt) the same way Server.SetKeyring
+// does for TCP. If decryption fails under every known key, the datagram is
+// passed through unchanged unless kr.RequireAuthenticated() is true, in
+// which case it's dropped. Must be called before Start.
+func (ps *PacketServer) SetKeyring(kr *Keyring) {
+	ps.keyring = kr
+}
+
+func (ps *PacketServer) Start() error {
+	conn, err := net.ListenPacket("udp", ps.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", ps.address, err)
+	}
+	ps.conn = conn
+
+	ps.logger.Info("udp packet server listening", "addr", ps.address)
+
+	ps.wg.Add(1)
+	go ps.receiveLoop()
+
+	return nil
+}
+
+func (ps *PacketServer) Stop() error {
+	close(ps.stopChan)
+	if ps.conn != nil {
+		if err := ps.conn.Close(); err != nil {
+			return err
+		}
+	}
+	ps.wg.Wait()
+	return nil
+}
+
+func (ps *PacketServer) receiveLoop() {
+	defer ps.wg.Done()
+
+	buf := make([]byte, maxPacketSize)
+
+	for {
+		select {
+		case <-ps.stopChan:
+			return
+		default:
+			ps.conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, addr, err := ps.conn.ReadFrom(buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					continue
+				}
+				select {
+				case <-ps.stopChan:
+					return
+				default:
+					ps.logger.Warn("failed to read packet", "err", err)
+					continue
+				}
+			}
+
+			data := make([]byte, n)
+			copy(data, buf[:n])
+
+			if ps.keyring != nil {
+				if plain, keyID, err := ps.keyring.Decrypt(data); err == nil {
+					ps.logger.Debug("decrypted inbound packet", "peer", addr, "key_id", keyID)
+					data = plain
+				} else if ps.keyring.RequireAuthenticated() {
+					ps.logger.Warn("rejecting unauthenticated packet", "peer", addr, "err", err)
+					continue
+				}
+			}
+
+			if err := ps.handler.HandlePacket(data, addr); err != nil {
+				ps.logger.Warn("error handling packet", "peer", addr, "err", err)
+			}
+		}
+	}
+}
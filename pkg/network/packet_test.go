@@ -0,0 +1,128 @@
+package network
+
+import (
+    "net"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/minitrue/pkg/log"
+)
+
+type recordingPacketHandler struct {
+    mu  sync.Mutex
+    got [][]byte
+    wg  sync.WaitGroup
+}
+
+func (h *recordingPacketHandler) HandlePacket(data []byte, addr net.Addr) error {
+    h.mu.Lock()
+    h.got = append(h.got, append([]byte{}, data...))
+    h.mu.Unlock()
+    h.wg.Done()
+    return nil
+}
+
+func TestPacketServer_ReceivesSentPacket(t *testing.T) {
+    handler := &recordingPacketHandler{}
+    handler.wg.Add(1)
+
+    server := NewPacketServer("127.0.0.1:18935", handler, log.Nop())
+    if err := server.Start(); err != nil {
+        t.Fatalf("failed to start packet server: %v", err)
+    }
+    defer server.Stop()
+
+    client := NewClient(time.Second, log.Nop())
+    if err := client.SendPacket("127.0.0.1:18935", []byte("hello")); err != nil {
+        t.Fatalf("failed to send packet: %v", err)
+    }
+
+    done := make(chan struct{})
+    go func() {
+        handler.wg.Wait()
+        close(done)
+    }()
+
+    select {
+    case <-done:
+    case <-time.After(2 * time.Second):
+        t.Fatal("timed out waiting for packet to be received")
+    }
+
+    handler.mu.Lock()
+    defer handler.mu.Unlock()
+    if len(handler.got) != 1 || string(handler.got[0]) != "hello" {
+        t.Fatalf("expected to receive %q, got %v", "hello", handler.got)
+    }
+}
+
+func TestPacketServer_DecryptsUnderKeyring(t *testing.T) {
+    kr, err := NewKeyring("k1", mustKey(1), false)
+    if err != nil {
+        t.Fatalf("NewKeyring: %v", err)
+    }
+
+    handler := &recordingPacketHandler{}
+    handler.wg.Add(1)
+
+    server := NewPacketServer("127.0.0.1:18950", handler, log.Nop())
+    server.SetKeyring(kr)
+    if err := server.Start(); err != nil {
+        t.Fatalf("failed to start packet server: %v", err)
+    }
+    defer server.Stop()
+
+    client := NewClient(time.Second, log.Nop())
+    client.SetKeyring(kr)
+    if err := client.SendPacket("127.0.0.1:18950", []byte("encrypted delta")); err != nil {
+        t.Fatalf("failed to send packet: %v", err)
+    }
+
+    done := make(chan struct{})
+    go func() {
+        handler.wg.Wait()
+        close(done)
+    }()
+
+    select {
+    case <-done:
+    case <-time.After(2 * time.Second):
+        t.Fatal("timed out waiting for packet to be received")
+    }
+
+    handler.mu.Lock()
+    defer handler.mu.Unlock()
+    if len(handler.got) != 1 || string(handler.got[0]) != "encrypted delta" {
+        t.Fatalf("expected server to decrypt and receive %q, got %v", "encrypted delta", handler.got)
+    }
+}
+
+func TestPacketServer_RequireAuthenticatedDropsPlaintext(t *testing.T) {
+    kr, err := NewKeyring("k1", mustKey(1), true)
+    if err != nil {
+        t.Fatalf("NewKeyring: %v", err)
+    }
+
+    handler := &recordingPacketHandler{}
+
+    server := NewPacketServer("127.0.0.1:18951", handler, log.Nop())
+    server.SetKeyring(kr)
+    if err := server.Start(); err != nil {
+        t.Fatalf("failed to start packet server: %v", err)
+    }
+    defer server.Stop()
+
+    client := NewClient(time.Second, log.Nop())
+    if err := client.SendPacket("127.0.0.1:18951", []byte("plaintext, no keyring")); err != nil {
+        t.Fatalf("failed to send packet: %v", err)
+    }
+
+    time.Sleep(100 * time.Millisecond)
+
+    handler.mu.Lock()
+    defer handler.mu.Unlock()
+    if len(handler.got) != 0 {
+        t.Fatalf("expected RequireAuthenticated server to drop an unencrypted packet, handler got %v", handler.got)
+    }
+}
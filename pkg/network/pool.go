@@ -0,0 +1,417 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/minitrue/pkg/log"
+)
+
+// HealthSource reports whether an address should currently be considered
+// for a send. Pool consults it, if set, before dialing or handing out a
+// pooled connection, so a caller wired to a membership view (e.g.
+// pkg/cluster.GossipProtocol, which implements this interface) can keep
+// Pool from wasting a send or retry on a peer already known to be down or
+// suspect. Defined here rather than imported from pkg/cluster because
+// pkg/cluster already depends on pkg/network for its own transport.
+type HealthSource interface {
+	// Healthy reports whether address is currently safe to send to. A
+	// HealthSource with no opinion about address should return true.
+	Healthy(address string) bool
+}
+
+// OutcomeFunc is notified after every Send attempt with the target
+// address and the error it returned (nil on success), so a caller can
+// feed the result into its own failure detector - e.g.
+// GossipProtocol.recordSendOutcome folds it into the Lifeguard awareness
+// score and suspicion bookkeeping.
+type OutcomeFunc func(address string, err error)
+
+const (
+	defaultMaxIdlePerAddr = 4
+	defaultIdleTimeout    = 60 * time.Second
+
+	// baseCircuitCooldown/maxCircuitCooldown bound the exponential backoff
+	// applied to an address's circuit breaker: cooldown doubles with every
+	// consecutive failure, capped at maxCircuitCooldown, so a persistently
+	// flapping peer is retried less and less often instead of on every
+	// single send attempt.
+	baseCircuitCooldown = 1 * time.Second
+	maxCircuitCooldown  = 30 * time.Second
+)
+
+// pooledConn is one persistent connection plus when it was last returned
+// to the idle list, so the idle reaper can evict connections that have
+// sat unused past idleTimeout.
+type pooledConn struct {
+	conn     net.Conn
+	lastIdle time.Time
+}
+
+// circuitState tracks consecutive send failures for one address.
+type circuitState struct {
+	consecutiveFailures int
+	cooldownUntil       time.Time
+}
+
+// Pool maintains a bounded set of persistent, idle-evicted TCP connections
+// per remote address, skips addresses a HealthSource has flagged unhealthy
+// or whose circuit breaker is cooling down, and implements
+// retry-with-failover across a ranked list of candidate addresses via
+// SendToAny.
+type Pool struct {
+	dialTimeout    time.Duration
+	maxIdlePerAddr int
+	idleTimeout    time.Duration
+	connWrap       func(net.Conn) (net.Conn, error)
+	keyring        *Keyring
+	health         HealthSource
+	onOutcome      OutcomeFunc
+	logger         log.Logger
+
+	mu       sync.Mutex
+	idle     map[string][]*pooledConn
+	breakers map[string]*circuitState
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// PoolOption configures a Pool at construction time.
+type PoolOption func(*Pool)
+
+// WithHealthSource installs h, consulted by Send/SendToAny to skip
+// addresses h considers unhealthy before even attempting a dial.
+func WithHealthSource(h HealthSource) PoolOption {
+	return func(p *Pool) { p.health = h }
+}
+
+// WithOutcomeCallback installs fn, called after every Send attempt with
+// the address and its resulting error (nil on success).
+func WithOutcomeCallback(fn OutcomeFunc) PoolOption {
+	return func(p *Pool) { p.onOutcome = fn }
+}
+
+// WithConnWrap installs a hook applied to every freshly dialed connection
+// before it's handed out, e.g. to layer an encrypted, authenticated
+// session onto the raw TCP stream.
+func WithConnWrap(wrap func(net.Conn) (net.Conn, error)) PoolOption {
+	return func(p *Pool) { p.connWrap = wrap }
+}
+
+// WithKeyring installs kr, under which Send encrypts every payload (AES-GCM,
+// under kr's current primary key) before framing it, the same way
+// Client.SetKeyring does.
+func WithKeyring(kr *Keyring) PoolOption {
+	return func(p *Pool) { p.keyring = kr }
+}
+
+// WithMaxIdlePerAddr overrides how many idle connections Pool keeps open
+// per address (default 4).
+func WithMaxIdlePerAddr(n int) PoolOption {
+	return func(p *Pool) {
+		if n > 0 {
+			p.maxIdlePerAddr = n
+		}
+	}
+}
+
+// WithIdleTimeout overrides how long an idle connection may sit unused
+// before the reaper closes it (default 60s).
+func WithIdleTimeout(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		if d > 0 {
+			p.idleTimeout = d
+		}
+	}
+}
+
+// WithPoolLogger installs logger, used for dial/write failures. A nil
+// logger (the default) discards everything.
+func WithPoolLogger(logger log.Logger) PoolOption {
+	return func(p *Pool) {
+		if logger != nil {
+			p.logger = logger
+		}
+	}
+}
+
+// NewPool builds a Pool dialing with dialTimeout, applying opts. Call
+// Start to begin the idle-connection reaper, and Stop to shut it down and
+// close every pooled connection.
+func NewPool(dialTimeout time.Duration, opts ...PoolOption) *Pool {
+	p := &Pool{
+		dialTimeout:    dialTimeout,
+		maxIdlePerAddr: defaultMaxIdlePerAddr,
+		idleTimeout:    defaultIdleTimeout,
+		logger:         log.Nop(),
+		idle:           make(map[string][]*pooledConn),
+		breakers:       make(map[string]*circuitState),
+		stopChan:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// SetKeyring installs kr, under which every subsequent Send encrypts its
+// payload before framing it. Exists as a runtime setter (unlike connWrap,
+// which is construction-only via WithConnWrap) because callers such as
+// GossipProtocol build their Pool internally and only learn the Keyring to
+// use afterward, from GossipProtocol.SetKeyring.
+func (p *Pool) SetKeyring(kr *Keyring) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keyring = kr
+}
+
+// Start begins the background idle-connection reaper.
+func (p *Pool) Start() {
+	p.wg.Add(1)
+	go p.reapIdleLoop()
+}
+
+// Stop halts the idle reaper and closes every pooled connection.
+func (p *Pool) Stop() {
+	close(p.stopChan)
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conns := range p.idle {
+		for _, pc := range conns {
+			pc.conn.Close()
+		}
+	}
+	p.idle = make(map[string][]*pooledConn)
+}
+
+func (p *Pool) reapIdleLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.reapIdle()
+		case <-p.stopChan:
+			return
+		}
+	}
+}
+
+func (p *Pool) reapIdle() {
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for address, conns := range p.idle {
+		kept := conns[:0]
+		for _, pc := range conns {
+			if now.Sub(pc.lastIdle) > p.idleTimeout {
+				pc.conn.Close()
+				continue
+			}
+			kept = append(kept, pc)
+		}
+		if len(kept) == 0 {
+			delete(p.idle, address)
+		} else {
+			p.idle[address] = kept
+		}
+	}
+}
+
+// Send writes data, length-prefixed the same way Client.Send does, to
+// address over a pooled connection - reusing an idle one if available,
+// dialing a fresh one otherwise - and returns the connection to the idle
+// list on success. If a Keyring is installed via SetKeyring, data is
+// encrypted under its current primary key first. Skips the attempt
+// entirely (without dialing) if a HealthSource says address is unhealthy
+// or its circuit breaker is still cooling down from recent failures.
+func (p *Pool) Send(address string, data []byte) error {
+	if p.health != nil && !p.health.Healthy(address) {
+		err := fmt.Errorf("pool: %s is not healthy, skipping send", address)
+		p.fireOutcome(address, err)
+		return err
+	}
+	if !p.breakerAllows(address) {
+		err := fmt.Errorf("pool: %s circuit open, skipping send", address)
+		p.fireOutcome(address, err)
+		return err
+	}
+
+	conn, err := p.get(address)
+	if err != nil {
+		p.logger.Debug("pool dial failed", "addr", address, "err", err)
+		p.recordFailure(address)
+		p.fireOutcome(address, err)
+		return err
+	}
+
+	payload := data
+	if kr := p.currentKeyring(); kr != nil {
+		encrypted, err := kr.Encrypt(data)
+		if err != nil {
+			conn.Close()
+			p.fireOutcome(address, err)
+			return fmt.Errorf("encrypting payload for %s: %w", address, err)
+		}
+		payload = encrypted
+	}
+
+	if err := p.writeFramed(conn, payload); err != nil {
+		p.logger.Debug("pool write failed", "addr", address, "err", err)
+		conn.Close()
+		p.recordFailure(address)
+		p.fireOutcome(address, err)
+		return err
+	}
+
+	p.put(address, conn)
+	p.recordSuccess(address)
+	p.fireOutcome(address, nil)
+	return nil
+}
+
+// SendToAny attempts Send against each address in candidates, in the
+// order given, returning as soon as one succeeds. Callers typically pass
+// a ranked candidate list (e.g. the replicas for a shard, most-preferred
+// first) built from the same membership view as the Pool's HealthSource.
+// Returns the address that succeeded, or a combined error from every
+// attempt if all candidates are exhausted.
+func (p *Pool) SendToAny(candidates []string, data []byte) (string, error) {
+	if len(candidates) == 0 {
+		return "", errors.New("pool: no candidates provided")
+	}
+
+	var errs []error
+	for _, address := range candidates {
+		if err := p.Send(address, data); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", address, err))
+			continue
+		}
+		return address, nil
+	}
+	return "", fmt.Errorf("pool: all %d candidates failed: %w", len(errs), errors.Join(errs...))
+}
+
+func (p *Pool) currentKeyring() *Keyring {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.keyring
+}
+
+func (p *Pool) get(address string) (net.Conn, error) {
+	p.mu.Lock()
+	if conns := p.idle[address]; len(conns) > 0 {
+		pc := conns[len(conns)-1]
+		p.idle[address] = conns[:len(conns)-1]
+		p.mu.Unlock()
+		return pc.conn, nil
+	}
+	p.mu.Unlock()
+
+	conn, err := net.DialTimeout("tcp", address, p.dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+
+	if p.connWrap != nil {
+		wrapped, err := p.connWrap(conn)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("securing connection to %s: %w", address, err)
+		}
+		conn = wrapped
+	}
+
+	return conn, nil
+}
+
+func (p *Pool) put(address string, conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.idle[address]) >= p.maxIdlePerAddr {
+		conn.Close()
+		return
+	}
+	p.idle[address] = append(p.idle[address], &pooledConn{conn: conn, lastIdle: time.Now()})
+}
+
+func (p *Pool) writeFramed(conn net.Conn, data []byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(p.dialTimeout)); err != nil {
+		return fmt.Errorf("failed to set write deadline: %w", err)
+	}
+
+	length := uint32(len(data))
+	lengthBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBytes, length)
+
+	if _, err := conn.Write(lengthBytes); err != nil {
+		return fmt.Errorf("failed to write data length: %w", err)
+	}
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+	return nil
+}
+
+// breakerAllows reports whether address's circuit breaker has finished
+// cooling down (or was never tripped).
+func (p *Pool) breakerAllows(address string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, ok := p.breakers[address]
+	if !ok {
+		return true
+	}
+	return time.Now().After(b.cooldownUntil)
+}
+
+// recordFailure trips address's circuit breaker, doubling its cooldown
+// window with every consecutive failure up to maxCircuitCooldown.
+func (p *Pool) recordFailure(address string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, ok := p.breakers[address]
+	if !ok {
+		b = &circuitState{}
+		p.breakers[address] = b
+	}
+	b.consecutiveFailures++
+
+	shift := b.consecutiveFailures - 1
+	if shift > 5 {
+		shift = 5
+	}
+	cooldown := baseCircuitCooldown * time.Duration(uint64(1)<<uint(shift))
+	if cooldown > maxCircuitCooldown {
+		cooldown = maxCircuitCooldown
+	}
+	b.cooldownUntil = time.Now().Add(cooldown)
+}
+
+// recordSuccess clears address's circuit breaker.
+func (p *Pool) recordSuccess(address string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.breakers, address)
+}
+
+func (p *Pool) fireOutcome(address string, err error) {
+	if p.onOutcome == nil {
+		return
+	}
+	p.onOutcome(address, err)
+}
@@ -0,0 +1,221 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minitrue/pkg/log"
+)
+
+type recordingMessageHandler struct {
+	mu  sync.Mutex
+	got [][]byte
+}
+
+func (h *recordingMessageHandler) HandleMessage(data []byte, conn net.Conn) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cp := make([]byte, len(data))
+	copy(cp, data)
+	h.got = append(h.got, cp)
+	return nil
+}
+
+func (h *recordingMessageHandler) received() [][]byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([][]byte, len(h.got))
+	copy(out, h.got)
+	return out
+}
+
+func TestPool_SendRoundTrip(t *testing.T) {
+	handler := &recordingMessageHandler{}
+	server := NewServer("127.0.0.1:18940", handler, log.Nop())
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	pool := NewPool(time.Second, WithPoolLogger(log.Nop()))
+	pool.Start()
+	defer pool.Stop()
+
+	if err := pool.Send("127.0.0.1:18940", []byte("hello")); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(handler.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	got := handler.received()
+	if len(got) != 1 || string(got[0]) != "hello" {
+		t.Fatalf("expected server to receive \"hello\", got %v", got)
+	}
+}
+
+type fixedHealthSource map[string]bool
+
+func (h fixedHealthSource) Healthy(address string) bool { return h[address] }
+
+func TestPool_SkipsUnhealthyAddressWithoutDialing(t *testing.T) {
+	pool := NewPool(time.Second,
+		WithHealthSource(fixedHealthSource{"127.0.0.1:1": false}),
+	)
+	pool.Start()
+	defer pool.Stop()
+
+	err := pool.Send("127.0.0.1:1", []byte("x"))
+	if err == nil {
+		t.Fatal("expected send to an unhealthy address to fail")
+	}
+}
+
+func TestPool_CircuitBreakerSkipsDuringCooldown(t *testing.T) {
+	pool := NewPool(50 * time.Millisecond)
+	pool.Start()
+	defer pool.Stop()
+
+	const deadAddr = "127.0.0.1:18941" // nothing listening here
+	if err := pool.Send(deadAddr, []byte("x")); err == nil {
+		t.Fatal("expected first send to a dead address to fail")
+	}
+	if pool.breakerAllows(deadAddr) {
+		t.Fatal("expected circuit breaker to be tripped after a failure")
+	}
+
+	time.Sleep(baseCircuitCooldown + 50*time.Millisecond)
+	if !pool.breakerAllows(deadAddr) {
+		t.Fatal("expected circuit breaker to allow a retry after cooldown elapses")
+	}
+}
+
+func TestPool_SendToAnyFailsOverToNextCandidate(t *testing.T) {
+	handler := &recordingMessageHandler{}
+	server := NewServer("127.0.0.1:18942", handler, log.Nop())
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	pool := NewPool(100 * time.Millisecond)
+	pool.Start()
+	defer pool.Stop()
+
+	addr, err := pool.SendToAny([]string{"127.0.0.1:18943", "127.0.0.1:18942"}, []byte("hi"))
+	if err != nil {
+		t.Fatalf("expected SendToAny to fail over to the working candidate, got error: %v", err)
+	}
+	if addr != "127.0.0.1:18942" {
+		t.Fatalf("expected SendToAny to report the candidate that succeeded, got %s", addr)
+	}
+}
+
+func TestPool_SendToAnyExhaustsAllCandidates(t *testing.T) {
+	pool := NewPool(50 * time.Millisecond)
+	pool.Start()
+	defer pool.Stop()
+
+	_, err := pool.SendToAny([]string{"127.0.0.1:18944", "127.0.0.1:18945"}, []byte("hi"))
+	if err == nil {
+		t.Fatal("expected SendToAny to fail when every candidate is unreachable")
+	}
+}
+
+func TestPool_SendEncryptsUnderKeyringAndServerDecrypts(t *testing.T) {
+	serverKeyring, err := NewKeyring("k1", mustKey(1), false)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+
+	handler := &recordingMessageHandler{}
+	server := NewServer("127.0.0.1:18948", handler, log.Nop())
+	server.SetKeyring(serverKeyring)
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	clientKeyring, err := NewKeyring("k1", mustKey(1), false)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	pool := NewPool(time.Second, WithKeyring(clientKeyring))
+	pool.Start()
+	defer pool.Stop()
+
+	if err := pool.Send("127.0.0.1:18948", []byte("encrypted hello")); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(handler.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	got := handler.received()
+	if len(got) != 1 || string(got[0]) != "encrypted hello" {
+		t.Fatalf("expected server to decrypt and receive \"encrypted hello\", got %v", got)
+	}
+}
+
+func TestPool_ServerRequireAuthenticatedRejectsPlaintext(t *testing.T) {
+	serverKeyring, err := NewKeyring("k1", mustKey(1), true)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+
+	handler := &recordingMessageHandler{}
+	server := NewServer("127.0.0.1:18949", handler, log.Nop())
+	server.SetKeyring(serverKeyring)
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	pool := NewPool(time.Second)
+	pool.Start()
+	defer pool.Stop()
+
+	if err := pool.Send("127.0.0.1:18949", []byte("plaintext, no keyring")); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := handler.received(); len(got) != 0 {
+		t.Fatalf("expected RequireAuthenticated server to reject an unencrypted message, handler got %v", got)
+	}
+}
+
+func TestPool_OutcomeCallbackFiresOnSuccessAndFailure(t *testing.T) {
+	handler := &recordingMessageHandler{}
+	server := NewServer("127.0.0.1:18946", handler, log.Nop())
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	var mu sync.Mutex
+	outcomes := make(map[string]error)
+	pool := NewPool(100*time.Millisecond, WithOutcomeCallback(func(address string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		outcomes[address] = err
+	}))
+	pool.Start()
+	defer pool.Stop()
+
+	pool.Send("127.0.0.1:18946", []byte("ok"))
+	pool.Send("127.0.0.1:18947", []byte("fail"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err, ok := outcomes["127.0.0.1:18946"]; !ok || err != nil {
+		t.Fatalf("expected a nil-error outcome for the reachable address, got %v (ok=%v)", err, ok)
+	}
+	if err, ok := outcomes["127.0.0.1:18947"]; !ok || err == nil {
+		t.Fatalf("expected a non-nil-error outcome for the unreachable address, got %v (ok=%v)", err, ok)
+	}
+}
@@ -0,0 +1,223 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// heartbeatInterval is how often RPCClient sends a heartbeat frame to keep
+// the server's idle deadline from expiring on a quiet connection.
+const heartbeatInterval = 15 * time.Second
+
+// RPCClient is a single persistent connection to an RPCServer. It demuxes
+// inbound response frames by MsgID so Call can block the specific caller
+// waiting on that ID instead of the whole connection.
+type RPCClient struct {
+	conn         net.Conn
+	codec        Codec
+	maxFrameSize uint32
+
+	writeMu sync.Mutex
+	nextID  uint64
+
+	mu      sync.Mutex
+	pending map[uint64]chan *Frame
+	closed  bool
+
+	stopHeartbeat chan struct{}
+}
+
+// NewRPCClient dials address, sends the codec handshake byte, and starts
+// the background read loop and heartbeat ticker. maxFrameSize limits
+// inbound response frames; zero means DefaultMaxFrameSize.
+func NewRPCClient(address string, codec Codec, maxFrameSize uint32) (*RPCClient, error) {
+	return NewRPCClientWithConnWrap(address, codec, maxFrameSize, nil)
+}
+
+// NewRPCClientWithConnWrap is NewRPCClient plus connWrap, applied to the
+// dialed connection before the codec handshake - e.g. to layer the
+// internal/transport/secure handshake onto it the same way Client.SetConnWrap
+// does for the ad-hoc transport. connWrap may be nil.
+func NewRPCClientWithConnWrap(address string, codec Codec, maxFrameSize uint32, connWrap func(net.Conn) (net.Conn, error)) (*RPCClient, error) {
+	if maxFrameSize == 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+
+	if connWrap != nil {
+		wrapped, err := connWrap(conn)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("securing connection to %s: %w", address, err)
+		}
+		conn = wrapped
+	}
+
+	if _, err := conn.Write([]byte{codec.ID()}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send codec handshake: %w", err)
+	}
+
+	c := &RPCClient{
+		conn:          conn,
+		codec:         codec,
+		maxFrameSize:  maxFrameSize,
+		pending:       make(map[uint64]chan *Frame),
+		stopHeartbeat: make(chan struct{}),
+	}
+
+	go c.readLoop()
+	go c.heartbeatLoop()
+
+	return c, nil
+}
+
+func (c *RPCClient) readLoop() {
+	for {
+		frame, err := ReadFrame(c.conn, c.maxFrameSize)
+		if err != nil {
+			c.failAllPending(err)
+			return
+		}
+		if frame.Flags&FlagResponse == 0 {
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[frame.MsgID]
+		if ok {
+			delete(c.pending, frame.MsgID)
+		}
+		c.mu.Unlock()
+
+		if ok {
+			ch <- frame
+		}
+	}
+}
+
+func (c *RPCClient) failAllPending(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+func (c *RPCClient) heartbeatLoop() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.writeMu.Lock()
+			c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			_ = WriteFrame(c.conn, &Frame{Flags: FlagHeartbeat})
+			c.writeMu.Unlock()
+		case <-c.stopHeartbeat:
+			return
+		}
+	}
+}
+
+// Call sends req (marshaled with the client's codec) as a request of the
+// given msgType and blocks until a matching response arrives, ctx is
+// cancelled, or the connection fails. On success resp is populated by
+// unmarshaling the response payload.
+func (c *RPCClient) Call(ctx context.Context, msgType uint16, req, resp interface{}) error {
+	payload, err := c.codec.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	msgID := atomic.AddUint64(&c.nextID, 1)
+	ch := make(chan *Frame, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errors.New("rpc client is closed")
+	}
+	c.pending[msgID] = ch
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	writeErr := WriteFrame(c.conn, &Frame{Type: msgType, MsgID: msgID, Flags: FlagRequest, Payload: payload})
+	c.writeMu.Unlock()
+	if writeErr != nil {
+		c.mu.Lock()
+		delete(c.pending, msgID)
+		c.mu.Unlock()
+		return fmt.Errorf("failed to write request: %w", writeErr)
+	}
+
+	select {
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, msgID)
+		c.mu.Unlock()
+		return ctx.Err()
+
+	case frame, ok := <-ch:
+		if !ok {
+			return errors.New("rpc connection closed while waiting for response")
+		}
+		if frame.Flags&FlagError != 0 {
+			var msg string
+			if err := c.codec.Unmarshal(frame.Payload, &msg); err != nil {
+				return fmt.Errorf("remote handler failed (undecodable error): %w", err)
+			}
+			return errors.New(msg)
+		}
+		if resp != nil {
+			return c.codec.Unmarshal(frame.Payload, resp)
+		}
+		return nil
+	}
+}
+
+// Notify sends req as a fire-and-forget request of the given msgType: no
+// response is expected or waited for.
+func (c *RPCClient) Notify(msgType uint16, req interface{}) error {
+	payload, err := c.codec.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return WriteFrame(c.conn, &Frame{Type: msgType, Flags: FlagNotify, Payload: payload})
+}
+
+// Close stops the heartbeat loop and closes the underlying connection,
+// failing any call still waiting on a response.
+func (c *RPCClient) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	close(c.stopHeartbeat)
+	err := c.conn.Close()
+	if err != nil && errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return err
+}
@@ -0,0 +1,226 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+func errNoHandler(msgType uint16) error {
+	return fmt.Errorf("no handler registered for type %d", msgType)
+}
+
+// RPCHandler processes one request frame's payload (already codec-decoded
+// is left to the caller; handlers work on raw bytes so they can pick their
+// own request/response types per Type) and returns the response payload.
+type RPCHandler func(payload []byte) ([]byte, error)
+
+// RPCServer accepts connections speaking the Frame wire format: one
+// handshake byte selecting a Codec, then a stream of length-prefixed
+// Frames. It dispatches request/notify frames to handlers registered by
+// Type and replies to requests with a correlated response frame.
+type RPCServer struct {
+	address      string
+	maxFrameSize uint32
+	idleTimeout  time.Duration
+	connWrap     func(net.Conn) (net.Conn, error)
+
+	mu       sync.RWMutex
+	handlers map[uint16]RPCHandler
+
+	listener net.Listener
+	connWG   sync.WaitGroup // tracks open connections, for graceful Stop
+	callWG   sync.WaitGroup // tracks in-flight handler calls
+	stopChan chan struct{}
+}
+
+// NewRPCServer builds a server listening on address. maxFrameSize limits an
+// individual Frame's payload, independent of Server's own 10 MiB cap; zero
+// means DefaultMaxFrameSize.
+func NewRPCServer(address string, maxFrameSize uint32) *RPCServer {
+	if maxFrameSize == 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &RPCServer{
+		address:      address,
+		maxFrameSize: maxFrameSize,
+		idleTimeout:  60 * time.Second,
+		handlers:     make(map[uint16]RPCHandler),
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// SetConnWrap installs a hook applied to every accepted connection before
+// its codec handshake is read, e.g. to layer an encrypted, authenticated
+// session (see internal/transport/secure) onto the raw TCP stream, the same
+// role Server.SetConnWrap plays for the ad-hoc transport. Must be called
+// before Start.
+func (s *RPCServer) SetConnWrap(wrap func(net.Conn) (net.Conn, error)) {
+	s.connWrap = wrap
+}
+
+// RegisterHandler associates msgType with h. Registering the same type
+// twice replaces the previous handler.
+func (s *RPCServer) RegisterHandler(msgType uint16, h RPCHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[msgType] = h
+}
+
+func (s *RPCServer) handlerFor(msgType uint16) (RPCHandler, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h, ok := s.handlers[msgType]
+	return h, ok
+}
+
+func (s *RPCServer) Start() error {
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	log.Printf("[RPC] Server listening on %s", s.address)
+
+	s.connWG.Add(1)
+	go s.acceptLoop()
+
+	return nil
+}
+
+// Stop closes the listener, waits for every open connection to finish its
+// current read/write cycle, and waits for any handler call already in
+// flight to return before returning itself.
+func (s *RPCServer) Stop() error {
+	close(s.stopChan)
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.connWG.Wait()
+	s.callWG.Wait()
+	return err
+}
+
+func (s *RPCServer) acceptLoop() {
+	defer s.connWG.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.stopChan:
+				return
+			default:
+				log.Printf("[RPC] Failed to accept connection: %v", err)
+				continue
+			}
+		}
+
+		s.connWG.Add(1)
+		go s.handleConnection(conn)
+	}
+}
+
+func (s *RPCServer) handleConnection(conn net.Conn) {
+	defer s.connWG.Done()
+	defer conn.Close()
+
+	if s.connWrap != nil {
+		wrapped, err := s.connWrap(conn)
+		if err != nil {
+			log.Printf("[RPC] connection setup rejected from %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+		conn = wrapped
+	}
+
+	handshake := make([]byte, 1)
+	if _, err := io.ReadFull(conn, handshake); err != nil {
+		log.Printf("[RPC] Failed to read codec handshake from %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	codec, err := codecByID(handshake[0])
+	if err != nil {
+		log.Printf("[RPC] %v from %s", err, conn.RemoteAddr())
+		return
+	}
+
+	var writeMu sync.Mutex
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		default:
+		}
+
+		conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+
+		frame, err := ReadFrame(conn, s.maxFrameSize)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Printf("[RPC] Error reading frame from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		switch {
+		case frame.Flags&FlagHeartbeat != 0:
+			// Heartbeats just reset the idle deadline above; nothing else to do.
+			continue
+
+		case frame.Flags&FlagNotify != 0:
+			s.callWG.Add(1)
+			go func(f *Frame) {
+				defer s.callWG.Done()
+				if h, ok := s.handlerFor(f.Type); ok {
+					if _, err := h(f.Payload); err != nil {
+						log.Printf("[RPC] Notify handler for type %d failed: %v", f.Type, err)
+					}
+				} else {
+					log.Printf("[RPC] No handler registered for notify type %d", f.Type)
+				}
+			}(frame)
+
+		case frame.Flags&FlagRequest != 0:
+			s.callWG.Add(1)
+			go func(f *Frame) {
+				defer s.callWG.Done()
+				s.reply(conn, &writeMu, f, codec)
+			}(frame)
+		}
+	}
+}
+
+func (s *RPCServer) reply(conn net.Conn, writeMu *sync.Mutex, req *Frame, codec Codec) {
+	h, ok := s.handlerFor(req.Type)
+	if !ok {
+		s.writeResponse(conn, writeMu, req.MsgID, codec, nil, errNoHandler(req.Type))
+		return
+	}
+
+	respPayload, err := h(req.Payload)
+	s.writeResponse(conn, writeMu, req.MsgID, codec, respPayload, err)
+}
+
+func (s *RPCServer) writeResponse(conn net.Conn, writeMu *sync.Mutex, msgID uint64, codec Codec, payload []byte, handlerErr error) {
+	flags := FlagResponse
+	if handlerErr != nil {
+		flags |= FlagError
+		payload, _ = codec.Marshal(handlerErr.Error())
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := WriteFrame(conn, &Frame{MsgID: msgID, Flags: flags, Payload: payload}); err != nil {
+		log.Printf("[RPC] Failed to write response for msg %d: %v", msgID, err)
+	}
+}
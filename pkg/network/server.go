@@ -4,10 +4,11 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"sync"
 	"time"
+
+	"github.com/minitrue/pkg/log"
 )
 
 type MessageHandler interface {
@@ -17,19 +18,45 @@ type MessageHandler interface {
 type Server struct {
 	address  string
 	handler  MessageHandler
+	connWrap func(net.Conn) (net.Conn, error)
+	keyring  *Keyring
 	listener net.Listener
 	wg       sync.WaitGroup
 	stopChan chan struct{}
+	logger   log.Logger
 }
 
-func NewServer(address string, handler MessageHandler) *Server {
+func NewServer(address string, handler MessageHandler, logger log.Logger) *Server {
+	if logger == nil {
+		logger = log.Nop()
+	}
 	return &Server{
 		address:  address,
-		handler:   handler,
-		stopChan:  make(chan struct{}),
+		handler:  handler,
+		stopChan: make(chan struct{}),
+		logger:   logger,
 	}
 }
 
+// SetConnWrap installs a hook applied to every accepted connection before
+// any message is read from it, e.g. to layer an encrypted, authenticated
+// session (see internal/transport/secure) onto the raw TCP stream. Must be
+// called before Start.
+func (s *Server) SetConnWrap(wrap func(net.Conn) (net.Conn, error)) {
+	s.connWrap = wrap
+}
+
+// SetKeyring installs kr, under which handleConnection decrypts every
+// inbound message before handing it to the MessageHandler, trying each of
+// kr's known keys in turn (see Keyring.Decrypt) so a message encrypted
+// under an older primary still decrypts during a rotation. If decryption
+// fails under every known key, the message is passed through unchanged
+// unless kr.RequireAuthenticated() is true, in which case it's dropped.
+// Must be called before Start.
+func (s *Server) SetKeyring(kr *Keyring) {
+	s.keyring = kr
+}
+
 func (s *Server) Start() error {
 	listener, err := net.Listen("tcp", s.address)
 	if err != nil {
@@ -37,7 +64,7 @@ func (s *Server) Start() error {
 	}
 	s.listener = listener
 
-	log.Printf("[Network] TCP server listening on %s", s.address)
+	s.logger.Info("tcp server listening", "addr", s.address)
 
 	s.wg.Add(1)
 	go s.acceptLoop()
@@ -70,7 +97,7 @@ func (s *Server) acceptLoop() {
 				case <-s.stopChan:
 					return
 				default:
-					log.Printf("[Network] Failed to accept connection: %v", err)
+					s.logger.Warn("failed to accept connection", "err", err)
 					continue
 				}
 			}
@@ -85,6 +112,15 @@ func (s *Server) handleConnection(conn net.Conn) {
 	defer s.wg.Done()
 	defer conn.Close()
 
+	if s.connWrap != nil {
+		wrapped, err := s.connWrap(conn)
+		if err != nil {
+			s.logger.Warn("connection setup rejected", "peer", conn.RemoteAddr(), "err", err)
+			return
+		}
+		conn = wrapped
+	}
+
 	conn.SetReadDeadline(time.Now().Add(30 * time.Second))
 
 	for {
@@ -95,29 +131,39 @@ func (s *Server) handleConnection(conn net.Conn) {
 			lengthBytes := make([]byte, 4)
 			if _, err := io.ReadFull(conn, lengthBytes); err != nil {
 				if err != io.EOF {
-					log.Printf("[Network] Failed to read message length: %v", err)
+					s.logger.Warn("failed to read message length", "err", err)
 				}
 				return
 			}
 
 			length := binary.BigEndian.Uint32(lengthBytes)
-			if length > 10*1024*1024 { 
-				log.Printf("[Network] Message too large: %d bytes", length)
+			if length > 10*1024*1024 {
+				s.logger.Warn("message too large", "bytes", length)
 				return
 			}
 
 			data := make([]byte, length)
 			if _, err := io.ReadFull(conn, data); err != nil {
-				log.Printf("[Network] Failed to read message data: %v", err)
+				s.logger.Warn("failed to read message data", "err", err)
 				return
 			}
 
+			if s.keyring != nil {
+				if plain, keyID, err := s.keyring.Decrypt(data); err == nil {
+					s.logger.Debug("decrypted inbound message", "peer", conn.RemoteAddr(), "key_id", keyID)
+					data = plain
+				} else if s.keyring.RequireAuthenticated() {
+					s.logger.Warn("rejecting unauthenticated message", "peer", conn.RemoteAddr(), "err", err)
+					conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+					continue
+				}
+			}
+
 			if err := s.handler.HandleMessage(data, conn); err != nil {
-				log.Printf("[Network] Error handling message: %v", err)
+				s.logger.Warn("error handling message", "err", err)
 			}
 
 			conn.SetReadDeadline(time.Now().Add(30 * time.Second))
 		}
 	}
 }
-
@@ -0,0 +1,73 @@
+// Package queryql implements a small PromQL/Flux-inspired expression
+// language for querying metrics: metric selectors with label matchers and
+// an optional range ("[5m]"), aggregation/windowing function calls
+// ("rate()", "avg_over_time()", "topk()"), and arithmetic between series.
+// This file holds the AST; see lexer.go/parser.go for how source text
+// becomes one of these nodes, and internal/query/queryql.go for how a
+// Service evaluates them against cluster storage.
+package queryql
+
+import "time"
+
+// Expr is any node in a parsed query: a VectorSelector, a Call, a
+// BinaryExpr, or a NumberLiteral.
+type Expr interface {
+	exprNode()
+}
+
+// LabelMatcher is one "label=value" or "label=~alt1|alt2" constraint in a
+// selector's braces. Op is "=" for an exact match or "=~" for alternation
+// over a "|"-separated list of values - not a full regular expression,
+// since devices are always named explicitly in this system rather than
+// discovered by pattern (there is no device registry to match against).
+type LabelMatcher struct {
+	Label string
+	Op    string
+	Value string
+}
+
+// VectorSelector selects one or more series by metric name plus label
+// matchers, over an optional trailing range ("[5m]"). Range is zero when
+// the selector has none.
+type VectorSelector struct {
+	Metric   string
+	Matchers []LabelMatcher
+	Range    time.Duration
+}
+
+func (*VectorSelector) exprNode() {}
+
+// Call is a function applied to its arguments, e.g. "rate(expr)" or
+// "topk(3, expr)".
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+func (*Call) exprNode() {}
+
+// BinaryExpr is arithmetic between two sub-expressions, e.g. "a / b".
+type BinaryExpr struct {
+	Op  string
+	LHS Expr
+	RHS Expr
+}
+
+func (*BinaryExpr) exprNode() {}
+
+// NumberLiteral is a bare numeric constant, e.g. the "100" in "a * 100".
+type NumberLiteral struct {
+	Value float64
+}
+
+func (*NumberLiteral) exprNode() {}
+
+// MatcherValue returns the matcher for label, and whether one was present.
+func (v *VectorSelector) MatcherValue(label string) (LabelMatcher, bool) {
+	for _, m := range v.Matchers {
+		if m.Label == label {
+			return m, true
+		}
+	}
+	return LabelMatcher{}, false
+}
@@ -0,0 +1,147 @@
+package queryql
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokDuration
+	tokLBrace
+	tokRBrace
+	tokLBracket
+	tokRBracket
+	tokLParen
+	tokRParen
+	tokComma
+	tokOp
+	tokMatchOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lexer turns query text into a flat token stream; the parser below drives
+// it with peek()/next() rather than this package producing a channel or
+// callback, since the grammar is small enough for a straightforward
+// recursive-descent reader.
+type lexer struct {
+	input string
+	pos   int
+}
+
+func newLexer(input string) *lexer {
+	return &lexer{input: input}
+}
+
+func (l *lexer) lex() ([]token, error) {
+	var tokens []token
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.input) {
+			tokens = append(tokens, token{kind: tokEOF})
+			return tokens, nil
+		}
+
+		c := l.input[l.pos]
+		switch {
+		case c == '{':
+			tokens = append(tokens, token{kind: tokLBrace, text: "{"})
+			l.pos++
+		case c == '}':
+			tokens = append(tokens, token{kind: tokRBrace, text: "}"})
+			l.pos++
+		case c == '[':
+			start := l.pos + 1
+			end := strings.IndexByte(l.input[start:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("queryql: unterminated range at position %d", l.pos)
+			}
+			tokens = append(tokens, token{kind: tokDuration, text: l.input[start : start+end]})
+			l.pos = start + end + 1
+		case c == ']':
+			tokens = append(tokens, token{kind: tokRBracket, text: "]"})
+			l.pos++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "("})
+			l.pos++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")"})
+			l.pos++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ","})
+			l.pos++
+		case c == '=':
+			if l.pos+1 < len(l.input) && l.input[l.pos+1] == '~' {
+				tokens = append(tokens, token{kind: tokMatchOp, text: "=~"})
+				l.pos += 2
+			} else {
+				tokens = append(tokens, token{kind: tokMatchOp, text: "="})
+				l.pos++
+			}
+		case c == '+' || c == '-' || c == '*' || c == '/':
+			tokens = append(tokens, token{kind: tokOp, text: string(c)})
+			l.pos++
+		case c == '"':
+			s, err := l.lexString()
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: s})
+		case isDigit(c):
+			tokens = append(tokens, token{kind: tokNumber, text: l.lexNumber()})
+		case isIdentStart(c):
+			tokens = append(tokens, token{kind: tokIdent, text: l.lexIdent()})
+		default:
+			return nil, fmt.Errorf("queryql: unexpected character %q at position %d", c, l.pos)
+		}
+	}
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.input) && (l.input[l.pos] == ' ' || l.input[l.pos] == '\t' || l.input[l.pos] == '\n') {
+		l.pos++
+	}
+}
+
+func (l *lexer) lexString() (string, error) {
+	l.pos++ // opening quote
+	start := l.pos
+	for l.pos < len(l.input) && l.input[l.pos] != '"' {
+		l.pos++
+	}
+	if l.pos >= len(l.input) {
+		return "", fmt.Errorf("queryql: unterminated string starting at position %d", start)
+	}
+	s := l.input[start:l.pos]
+	l.pos++ // closing quote
+	return s, nil
+}
+
+func (l *lexer) lexNumber() string {
+	start := l.pos
+	for l.pos < len(l.input) && (isDigit(l.input[l.pos]) || l.input[l.pos] == '.') {
+		l.pos++
+	}
+	return l.input[start:l.pos]
+}
+
+func (l *lexer) lexIdent() string {
+	start := l.pos
+	for l.pos < len(l.input) && isIdentPart(l.input[l.pos]) {
+		l.pos++
+	}
+	return l.input[start:l.pos]
+}
+
+func isDigit(c byte) bool      { return c >= '0' && c <= '9' }
+func isIdentStart(c byte) bool { return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isIdentPart(c byte) bool  { return isIdentStart(c) || isDigit(c) }
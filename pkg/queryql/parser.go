@@ -0,0 +1,197 @@
+package queryql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parser is a straightforward recursive-descent parser over the token
+// stream, with the usual two-level precedence split (term, then +/-) since
+// the grammar has no need for anything richer yet.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses a queryql expression, such as:
+//
+//	cpu_usage{device="d1"}
+//	rate(cpu_usage{device="d1"}[5m])
+//	topk(3, cpu_usage{device=~"d1|d2|d3"})
+//	cpu_usage{device="d1"} / mem_usage{device="d1"} * 100
+func Parse(input string) (Expr, error) {
+	tokens, err := newLexer(input).lex()
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("queryql: unexpected trailing token %q", p.peek().text)
+	}
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if p.pos < len(p.tokens)-1 {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	lhs, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp {
+		op := p.next().text
+		rhs, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseTerm() (Expr, error) {
+	switch p.peek().kind {
+	case tokNumber:
+		return p.parseNumber()
+	case tokLParen:
+		p.next()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			return nil, fmt.Errorf("queryql: expected ')', got %q", p.peek().text)
+		}
+		p.next()
+		return expr, nil
+	case tokIdent:
+		if p.tokens[p.pos+1].kind == tokLParen {
+			return p.parseCall()
+		}
+		return p.parseSelector()
+	default:
+		return nil, fmt.Errorf("queryql: unexpected token %q", p.peek().text)
+	}
+}
+
+func (p *parser) parseNumber() (Expr, error) {
+	t := p.next()
+	v, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("queryql: invalid number %q: %w", t.text, err)
+	}
+	return &NumberLiteral{Value: v}, nil
+}
+
+func (p *parser) parseCall() (Expr, error) {
+	name := p.next().text // ident
+	p.next()              // '('
+
+	var args []Expr
+	if p.peek().kind != tokRParen {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if p.peek().kind != tokRParen {
+		return nil, fmt.Errorf("queryql: expected ')' to close %s(...)", name)
+	}
+	p.next()
+
+	return &Call{Func: name, Args: args}, nil
+}
+
+func (p *parser) parseSelector() (Expr, error) {
+	metric := p.next().text // ident
+
+	var matchers []LabelMatcher
+	if p.peek().kind == tokLBrace {
+		p.next()
+		for p.peek().kind != tokRBrace {
+			m, err := p.parseMatcher()
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, m)
+			if p.peek().kind == tokComma {
+				p.next()
+			}
+		}
+		p.next() // '}'
+	}
+
+	var rng time.Duration
+	if p.peek().kind == tokDuration {
+		d, err := parseDuration(p.next().text)
+		if err != nil {
+			return nil, err
+		}
+		rng = d
+	}
+
+	return &VectorSelector{Metric: metric, Matchers: matchers, Range: rng}, nil
+}
+
+func (p *parser) parseMatcher() (LabelMatcher, error) {
+	if p.peek().kind != tokIdent {
+		return LabelMatcher{}, fmt.Errorf("queryql: expected label name, got %q", p.peek().text)
+	}
+	label := p.next().text
+
+	if p.peek().kind != tokMatchOp {
+		return LabelMatcher{}, fmt.Errorf("queryql: expected '=' or '=~' after label %q", label)
+	}
+	op := p.next().text
+
+	if p.peek().kind != tokString {
+		return LabelMatcher{}, fmt.Errorf("queryql: expected quoted value for label %q", label)
+	}
+	value := p.next().text
+
+	return LabelMatcher{Label: label, Op: op, Value: value}, nil
+}
+
+// parseDuration accepts Go's own duration syntax ("5m", "30s", "1h"), the
+// format every range literal in this language uses.
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("queryql: invalid duration %q: %w", s, err)
+	}
+	return d, nil
+}
+
+// MatchedValues splits a "=~" matcher's alternation ("d1|d2|d3") into its
+// individual values. A plain "=" matcher's value is returned as the single
+// element of a one-item slice.
+func (m LabelMatcher) MatchedValues() []string {
+	if m.Op != "=~" {
+		return []string{m.Value}
+	}
+	return strings.Split(m.Value, "|")
+}
@@ -0,0 +1,103 @@
+package queryql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSelector(t *testing.T) {
+	expr, err := Parse(`cpu_usage{device="d1"}`)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	sel, ok := expr.(*VectorSelector)
+	if !ok {
+		t.Fatalf("expected *VectorSelector, got %T", expr)
+	}
+	if sel.Metric != "cpu_usage" {
+		t.Errorf("expected metric cpu_usage, got %q", sel.Metric)
+	}
+	m, ok := sel.MatcherValue("device")
+	if !ok || m.Value != "d1" || m.Op != "=" {
+		t.Errorf("expected device=\"d1\" matcher, got %+v (ok=%v)", m, ok)
+	}
+	if sel.Range != 0 {
+		t.Errorf("expected no range, got %v", sel.Range)
+	}
+}
+
+func TestParseSelectorWithRangeAndAlternation(t *testing.T) {
+	expr, err := Parse(`cpu_usage{device=~"d1|d2|d3"}[5m]`)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	sel, ok := expr.(*VectorSelector)
+	if !ok {
+		t.Fatalf("expected *VectorSelector, got %T", expr)
+	}
+	if sel.Range != 5*time.Minute {
+		t.Errorf("expected range of 5m, got %v", sel.Range)
+	}
+	m, _ := sel.MatcherValue("device")
+	values := m.MatchedValues()
+	if len(values) != 3 || values[0] != "d1" || values[2] != "d3" {
+		t.Errorf("expected [d1 d2 d3], got %v", values)
+	}
+}
+
+func TestParseCall(t *testing.T) {
+	expr, err := Parse(`rate(cpu_usage{device="d1"}[1m])`)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	call, ok := expr.(*Call)
+	if !ok {
+		t.Fatalf("expected *Call, got %T", expr)
+	}
+	if call.Func != "rate" || len(call.Args) != 1 {
+		t.Errorf("expected rate() with one argument, got %+v", call)
+	}
+}
+
+func TestParseTopK(t *testing.T) {
+	expr, err := Parse(`topk(3, cpu_usage{device=~"d1|d2"})`)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	call, ok := expr.(*Call)
+	if !ok || call.Func != "topk" || len(call.Args) != 2 {
+		t.Fatalf("expected topk() with two arguments, got %+v (ok=%v)", expr, ok)
+	}
+	if _, ok := call.Args[0].(*NumberLiteral); !ok {
+		t.Errorf("expected first argument to be a number literal, got %T", call.Args[0])
+	}
+}
+
+func TestParseBinaryExpr(t *testing.T) {
+	expr, err := Parse(`cpu_usage{device="d1"} / mem_usage{device="d1"} * 100`)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	bin, ok := expr.(*BinaryExpr)
+	if !ok {
+		t.Fatalf("expected *BinaryExpr, got %T", expr)
+	}
+	if bin.Op != "*" {
+		t.Errorf("expected outermost operator '*', got %q", bin.Op)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []string{
+		`cpu_usage{`,
+		`cpu_usage{device}`,
+		`rate(cpu_usage`,
+		`123abc`,
+		`cpu_usage[5x]`,
+	}
+	for _, c := range cases {
+		if _, err := Parse(c); err == nil {
+			t.Errorf("expected error parsing %q, got none", c)
+		}
+	}
+}
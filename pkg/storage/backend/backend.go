@@ -0,0 +1,38 @@
+// Package backend abstracts the blob storage StorageEngine and WAL segment
+// files are read from and written to, so a node can run against local disk,
+// S3-compatible object storage, or anything else that can satisfy Backend.
+package backend
+
+import (
+	"io"
+	"time"
+)
+
+// Info is the subset of metadata a Backend can report about a key without
+// reading its contents.
+type Info struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// Backend is a key/value blob store. Keys are slash-separated paths (e.g.
+// "ing1.parq", "ing1.parq.wal") relative to whatever root the Backend was
+// configured with.
+type Backend interface {
+	// OpenRead opens key for reading. Callers must Close it.
+	OpenRead(key string) (io.ReadSeekCloser, error)
+
+	// WriteAtomic replaces key's contents with data as a single atomic
+	// operation - a concurrent OpenRead never observes a partial write.
+	WriteAtomic(key string, data []byte) error
+
+	// Stat reports key's size and modification time.
+	Stat(key string) (Info, error)
+
+	// List returns every key with the given prefix, sorted.
+	List(prefix string) ([]string, error)
+
+	// Delete removes key. Deleting a key that doesn't exist is not an
+	// error.
+	Delete(key string) error
+}
@@ -0,0 +1,117 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 is a Backend backed by an S3-compatible object store. Keys are mapped
+// to object keys under Prefix (if set), so multiple nodes can share a
+// bucket without colliding.
+type S3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3 connects to an S3-compatible endpoint (AWS S3, MinIO, etc.) and
+// returns a Backend that stores segment files as objects in bucket under
+// prefix. prefix may be empty to use the bucket root.
+func NewS3(endpoint, accessKeyID, secretAccessKey, bucket, prefix string, useSSL bool) (*S3, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s3 backend: connecting to %s: %w", endpoint, err)
+	}
+
+	return &S3{client: client, bucket: bucket, prefix: strings.Trim(prefix, "/")}, nil
+}
+
+func (s *S3) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return path.Join(s.prefix, key)
+}
+
+func (s *S3) OpenRead(key string) (io.ReadSeekCloser, error) {
+	ctx := context.Background()
+	objectKey := s.objectKey(key)
+
+	if _, err := s.client.StatObject(ctx, s.bucket, objectKey, minio.StatObjectOptions{}); err != nil {
+		return nil, fmt.Errorf("s3 backend: stat %s: %w", key, err)
+	}
+
+	obj, err := s.client.GetObject(ctx, s.bucket, objectKey, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("s3 backend: opening %s: %w", key, err)
+	}
+	return obj, nil
+}
+
+// WriteAtomic does a single PutObject call - an S3-compatible store only
+// ever exposes an object's previous or fully-new contents, never a partial
+// write, so no separate temp-object-then-rename step is needed the way the
+// Disk backend needs one.
+func (s *S3) WriteAtomic(key string, data []byte) error {
+	objectKey := s.objectKey(key)
+	_, err := s.client.PutObject(context.Background(), s.bucket, objectKey,
+		bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+			ContentType: "application/octet-stream",
+		})
+	if err != nil {
+		return fmt.Errorf("s3 backend: writing %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3) Stat(key string) (Info, error) {
+	info, err := s.client.StatObject(context.Background(), s.bucket, s.objectKey(key), minio.StatObjectOptions{})
+	if err != nil {
+		return Info{}, fmt.Errorf("s3 backend: stat %s: %w", key, err)
+	}
+	return Info{Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+func (s *S3) List(prefix string) ([]string, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var keys []string
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.objectKey(prefix),
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("s3 backend: listing %q: %w", prefix, obj.Err)
+		}
+
+		key := obj.Key
+		if s.prefix != "" {
+			key = strings.TrimPrefix(strings.TrimPrefix(key, s.prefix), "/")
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *S3) Delete(key string) error {
+	err := s.client.RemoveObject(context.Background(), s.bucket, s.objectKey(key), minio.RemoveObjectOptions{})
+	if err != nil {
+		resp := minio.ToErrorResponse(err)
+		if resp.Code == "NoSuchKey" {
+			return nil
+		}
+		return fmt.Errorf("s3 backend: deleting %s: %w", key, err)
+	}
+	return nil
+}
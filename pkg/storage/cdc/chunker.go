@@ -0,0 +1,100 @@
+// Package cdc implements content-defined chunking over a content-addressed
+// chunk store, so byte-identical runs shared across overlapping segments -
+// replicas, snapshots, S3 backups of the same series at different points in
+// time - are stored once no matter which manifest references them.
+package cdc
+
+const (
+	// windowSize is the width of the rolling hash's sliding window, in
+	// bytes - wide enough that the hash reflects more than a couple of
+	// records, narrow enough that the window update stays O(1).
+	windowSize = 64
+
+	// defaultAvgBits is the default mask width: a cut point is declared
+	// whenever the low defaultAvgBits bits of the rolling hash are all
+	// zero, which happens on average every 1<<defaultAvgBits bytes - 8 KiB
+	// at 13 bits.
+	defaultAvgBits = 13
+
+	defaultMinSize = 2 * 1024
+	defaultMaxSize = 64 * 1024
+
+	// rollingPrime is the multiplier the rolling hash uses; it only needs
+	// to be odd so it's invertible mod 2^64 - the exact value doesn't
+	// affect correctness, just how well it scatters chunk boundaries.
+	rollingPrime uint64 = 1099511628211
+)
+
+// rollingPrimePowWindow is rollingPrime^windowSize mod 2^64, precomputed so
+// Chunker.Split can remove a byte leaving the window in one multiply
+// instead of repeatedly exponentiating.
+var rollingPrimePowWindow = func() uint64 {
+	p := uint64(1)
+	for i := 0; i < windowSize; i++ {
+		p *= rollingPrime
+	}
+	return p
+}()
+
+// Chunker splits a byte stream into content-defined chunks using a
+// Rabin-style rolling hash over a windowSize-byte window. Unlike fixed-size
+// chunking, a cut point depends only on local content, so inserting or
+// deleting bytes in one place shifts only the chunks immediately around the
+// edit - everything else in the stream still hashes to the same chunks,
+// which is what makes cross-segment dedup worth doing.
+type Chunker struct {
+	// MinSize and MaxSize bound every chunk but the last; MaxSize forces a
+	// cut even if the rolling hash never satisfies Mask, so a single
+	// pathological run of bytes can't produce an unbounded chunk.
+	MinSize int
+	MaxSize int
+
+	// AvgBits sets the target average chunk size to 1<<AvgBits bytes.
+	AvgBits uint
+}
+
+// NewChunker returns a Chunker with the package defaults: an ~8 KiB average
+// chunk size, a 2 KiB floor and a 64 KiB ceiling.
+func NewChunker() *Chunker {
+	return &Chunker{MinSize: defaultMinSize, MaxSize: defaultMaxSize, AvgBits: defaultAvgBits}
+}
+
+// Split divides data into content-defined chunks. The returned slices
+// reference data directly rather than copying it.
+func (c *Chunker) Split(data []byte) [][]byte {
+	mask := uint64(1)<<c.AvgBits - 1
+
+	var chunks [][]byte
+	var window [windowSize]byte
+	var h uint64
+	wpos, filled := 0, 0
+	start := 0
+
+	for i, b := range data {
+		var out byte
+		if filled == windowSize {
+			out = window[wpos]
+		}
+		window[wpos] = b
+		wpos = (wpos + 1) % windowSize
+		if filled < windowSize {
+			filled++
+		}
+
+		h = h*rollingPrime + uint64(b) - uint64(out)*rollingPrimePowWindow
+
+		size := i - start + 1
+		if size >= c.MinSize && (h&mask == 0 || size >= c.MaxSize) {
+			chunks = append(chunks, data[start:i+1])
+			start = i + 1
+			h, wpos, filled = 0, 0, 0
+			window = [windowSize]byte{}
+		}
+	}
+
+	if start < len(data) {
+		chunks = append(chunks, data[start:])
+	}
+
+	return chunks
+}
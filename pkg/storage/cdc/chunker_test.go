@@ -0,0 +1,72 @@
+package cdc
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestChunker_Reassembles(t *testing.T) {
+	data := make([]byte, 500*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	chunks := NewChunker().Split(data)
+	if len(chunks) == 0 {
+		t.Fatal("expected at least one chunk")
+	}
+
+	var reassembled []byte
+	for _, c := range chunks {
+		reassembled = append(reassembled, c...)
+	}
+	if !bytes.Equal(reassembled, data) {
+		t.Fatal("reassembled data does not match original")
+	}
+}
+
+func TestChunker_RespectsMaxSize(t *testing.T) {
+	// All-zero input never satisfies h&mask==0 in a way that differs from
+	// a constant window, so MaxSize is the only thing that can force a cut.
+	data := make([]byte, 10*1024)
+
+	c := NewChunker()
+	for _, chunk := range c.Split(data) {
+		if len(chunk) > c.MaxSize {
+			t.Errorf("chunk of size %d exceeds MaxSize %d", len(chunk), c.MaxSize)
+		}
+	}
+}
+
+func TestChunker_StableAcrossInsertion(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	data := make([]byte, 200*1024)
+	rng.Read(data)
+
+	c := NewChunker()
+	before := c.Split(data)
+
+	// Insert a few bytes in the middle; chunks well away from the edit
+	// should still come out identical, which is the whole point of
+	// content-defined (as opposed to fixed-size) chunking.
+	inserted := make([]byte, len(data)+16)
+	copy(inserted, data[:100*1024])
+	copy(inserted[100*1024:], bytes.Repeat([]byte{0xAB}, 16))
+	copy(inserted[100*1024+16:], data[100*1024:])
+
+	after := c.Split(inserted)
+
+	beforeSet := make(map[string]bool, len(before))
+	for _, chunk := range before {
+		beforeSet[string(chunk)] = true
+	}
+
+	matched := 0
+	for _, chunk := range after {
+		if beforeSet[string(chunk)] {
+			matched++
+		}
+	}
+	if matched == 0 {
+		t.Error("expected at least some chunks to survive an unrelated insertion unchanged")
+	}
+}
@@ -0,0 +1,63 @@
+package cdc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/minitrue/internal/models"
+)
+
+// Manifest is what a segment becomes under dedup: instead of the records
+// themselves, just the ordered list of chunk digests (see Store) needed to
+// reassemble them.
+type Manifest struct {
+	ChunkDigests []string `json:"chunk_digests"`
+}
+
+// Put serializes records, splits the result into content-defined chunks
+// with a default Chunker, stores each one in store, and returns the
+// resulting Manifest.
+func Put(store *Store, records []models.Record) (Manifest, error) {
+	raw, err := json.Marshal(records)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("cdc: marshaling records: %w", err)
+	}
+
+	chunks := NewChunker().Split(raw)
+	digests := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		digest, err := store.Put(chunk)
+		if err != nil {
+			return Manifest{}, err
+		}
+		digests[i] = digest
+	}
+
+	return Manifest{ChunkDigests: digests}, nil
+}
+
+// Get reassembles the records a Manifest was built from.
+func Get(store *Store, manifest Manifest) ([]models.Record, error) {
+	raw := make([]byte, 0)
+	for _, digest := range manifest.ChunkDigests {
+		chunk, err := store.Get(digest)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, chunk...)
+	}
+
+	var records []models.Record
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &records); err != nil {
+			return nil, fmt.Errorf("cdc: unmarshaling records: %w", err)
+		}
+	}
+	return records, nil
+}
+
+// Release drops this Manifest's reference to every chunk it used, deleting
+// any that become unreferenced as a result.
+func Release(store *Store, manifest Manifest) error {
+	return store.Release(manifest.ChunkDigests)
+}
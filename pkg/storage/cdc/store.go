@@ -0,0 +1,147 @@
+package cdc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+
+	"github.com/minitrue/pkg/storage/backend"
+)
+
+// refcountKey is where Store persists chunk reference counts - one JSON
+// object shared by every manifest that uses this Backend, so Release can
+// tell a chunk is unreferenced by any of them before reclaiming it.
+const refcountKey = "chunks/refcounts.json"
+
+// Store is a content-addressed chunk store layered over a Backend: a chunk
+// is written at most once, keyed by its own digest, no matter how many
+// manifests (see Manifest) end up referencing it.
+type Store struct {
+	be backend.Backend
+
+	mu   sync.Mutex
+	refs map[string]int
+}
+
+// NewStore opens a Store over be, loading whatever refcounts a previous
+// Store already persisted there.
+func NewStore(be backend.Backend) (*Store, error) {
+	s := &Store{be: be, refs: make(map[string]int)}
+	if err := s.loadRefs(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Digest returns the content key Store uses for data.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func chunkKey(digest string) string {
+	return "chunks/" + digest[:2] + "/" + digest
+}
+
+func (s *Store) loadRefs() error {
+	r, err := s.be.OpenRead(refcountKey)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("cdc: loading refcounts: %w", err)
+	}
+	defer r.Close()
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("cdc: loading refcounts: %w", err)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, &s.refs)
+}
+
+// saveRefsLocked persists s.refs. Callers must hold s.mu.
+func (s *Store) saveRefsLocked() error {
+	raw, err := json.Marshal(s.refs)
+	if err != nil {
+		return fmt.Errorf("cdc: marshaling refcounts: %w", err)
+	}
+	if err := s.be.WriteAtomic(refcountKey, raw); err != nil {
+		return fmt.Errorf("cdc: saving refcounts: %w", err)
+	}
+	return nil
+}
+
+// Put stores chunk if no prior Put already wrote its digest, bumps its
+// refcount, and returns the digest Get needs to retrieve it later.
+func (s *Store) Put(chunk []byte) (string, error) {
+	digest := Digest(chunk)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refs[digest] == 0 {
+		if err := s.be.WriteAtomic(chunkKey(digest), chunk); err != nil {
+			return "", fmt.Errorf("cdc: writing chunk %s: %w", digest, err)
+		}
+	}
+	s.refs[digest]++
+
+	if err := s.saveRefsLocked(); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
+
+// Get returns the chunk stored under digest.
+func (s *Store) Get(digest string) ([]byte, error) {
+	r, err := s.be.OpenRead(chunkKey(digest))
+	if err != nil {
+		return nil, fmt.Errorf("cdc: reading chunk %s: %w", digest, err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cdc: reading chunk %s: %w", digest, err)
+	}
+	return data, nil
+}
+
+// Release drops one reference to each digest, deleting any chunk whose
+// refcount reaches zero as a result - the GC pass UnifiedStorage.Delete
+// triggers when it removes a manifest, so chunks no other manifest still
+// points at don't accumulate forever.
+func (s *Store) Release(digests []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var toDelete []string
+	for _, d := range digests {
+		if s.refs[d] <= 1 {
+			delete(s.refs, d)
+			toDelete = append(toDelete, d)
+		} else {
+			s.refs[d]--
+		}
+	}
+
+	if err := s.saveRefsLocked(); err != nil {
+		return err
+	}
+
+	for _, d := range toDelete {
+		if err := s.be.Delete(chunkKey(d)); err != nil {
+			return fmt.Errorf("cdc: deleting unreferenced chunk %s: %w", d, err)
+		}
+	}
+	return nil
+}
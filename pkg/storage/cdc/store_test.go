@@ -0,0 +1,116 @@
+package cdc
+
+import (
+	"testing"
+
+	"github.com/minitrue/internal/models"
+	"github.com/minitrue/pkg/storage/backend"
+)
+
+func TestStore_PutGetDedups(t *testing.T) {
+	be := backend.NewDisk(t.TempDir())
+	store, err := NewStore(be)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	chunk := []byte("identical payload")
+	d1, err := store.Put(chunk)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	d2, err := store.Put(chunk)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if d1 != d2 {
+		t.Fatalf("expected identical chunks to get the same digest, got %s and %s", d1, d2)
+	}
+
+	got, err := store.Get(d1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(got) != string(chunk) {
+		t.Fatalf("Get returned %q, want %q", got, chunk)
+	}
+
+	keys, err := be.List("chunks/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	chunkFiles := 0
+	for _, k := range keys {
+		if k != "chunks/refcounts.json" {
+			chunkFiles++
+		}
+	}
+	if chunkFiles != 1 {
+		t.Fatalf("expected exactly one stored chunk file, found %d among %v", chunkFiles, keys)
+	}
+}
+
+func TestStore_ReleaseReclaimsUnreferenced(t *testing.T) {
+	be := backend.NewDisk(t.TempDir())
+	store, err := NewStore(be)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	chunk := []byte("some chunk")
+	digest, err := store.Put(chunk)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := store.Put(chunk); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if err := store.Release([]string{digest}); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, err := store.Get(digest); err != nil {
+		t.Fatalf("expected chunk to survive a single Release while still referenced once, got: %v", err)
+	}
+
+	if err := store.Release([]string{digest}); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, err := store.Get(digest); err == nil {
+		t.Fatal("expected chunk to be gone after its last reference was released")
+	}
+}
+
+func TestManifest_PutGetRoundTrips(t *testing.T) {
+	be := backend.NewDisk(t.TempDir())
+	store, err := NewStore(be)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	records := []models.Record{
+		{Timestamp: 1, Value: 1.5, DeviceID: "d1", MetricName: "temp"},
+		{Timestamp: 2, Value: 2.5, DeviceID: "d1", MetricName: "temp"},
+	}
+
+	manifest, err := Put(store, records)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if len(manifest.ChunkDigests) == 0 {
+		t.Fatal("expected at least one chunk digest in the manifest")
+	}
+
+	got, err := Get(store, manifest)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(got) != len(records) {
+		t.Fatalf("got %d records, want %d", len(got), len(records))
+	}
+	for i := range records {
+		if got[i] != records[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], records[i])
+		}
+	}
+}
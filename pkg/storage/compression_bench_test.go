@@ -0,0 +1,118 @@
+// Package storage holds cross-cutting storage benchmarks that don't belong
+// to internal/storage or pkg/storage/backend individually.
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/minitrue/internal/models"
+	istorage "github.com/minitrue/internal/storage"
+	"github.com/minitrue/pkg/storage/backend"
+)
+
+// benchRecords builds a representative cold-segment workload: numDevices
+// devices each reporting a handful of metrics at a steady interval, which is
+// the redundancy (repeated device_id/metric_name strings, regularly-spaced
+// timestamps) CompressionLevel is meant to squeeze out on top of Gorilla's
+// per-series encoding.
+func benchRecords(numDevices, pointsPerDevice int) []models.Record {
+	metrics := []string{"temperature", "humidity", "battery", "signal_strength"}
+	records := make([]models.Record, 0, numDevices*pointsPerDevice*len(metrics))
+	for d := 0; d < numDevices; d++ {
+		deviceID := fmt.Sprintf("device-%04d", d)
+		for _, metric := range metrics {
+			for p := 0; p < pointsPerDevice; p++ {
+				records = append(records, models.Record{
+					Timestamp:  int64(p * 60),
+					Value:      float64(p%100) + float64(d)*0.01,
+					DeviceID:   deviceID,
+					MetricName: metric,
+				})
+			}
+		}
+	}
+	return records
+}
+
+// BenchmarkCompressionLevels measures on-disk segment size and Read latency
+// at each CompressionLevel for the same workload, since the whole point of
+// the outer zstd pass is trading write-time CPU for smaller cold storage
+// without touching hot-path Gorilla encoding.
+func BenchmarkCompressionLevels(b *testing.B) {
+	records := benchRecords(200, 500)
+
+	levels := []istorage.CompressionLevel{
+		istorage.CompressionNone,
+		istorage.CompressionFast,
+		istorage.CompressionDefault,
+		istorage.CompressionBetter,
+	}
+
+	for _, level := range levels {
+		level := level
+		name := string(level)
+		if name == "" {
+			name = "none"
+		}
+
+		b.Run(name+"/write", func(b *testing.B) {
+			be := backend.NewDisk(b.TempDir())
+			engine := istorage.NewStorageEngine(be, "bench.seg")
+			engine.CompressionLevel = level
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := engine.Write(records); err != nil {
+					b.Fatalf("Write: %v", err)
+				}
+			}
+			b.StopTimer()
+
+			info, err := engine.Stat()
+			if err != nil {
+				b.Fatalf("Stat: %v", err)
+			}
+			b.ReportMetric(float64(info.Size), "bytes/segment")
+		})
+
+		b.Run(name+"/read", func(b *testing.B) {
+			be := backend.NewDisk(b.TempDir())
+			engine := istorage.NewStorageEngine(be, "bench.seg")
+			engine.CompressionLevel = level
+			if err := engine.Write(records); err != nil {
+				b.Fatalf("Write: %v", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.Read(); err != nil {
+					b.Fatalf("Read: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCompact measures the one-time cost of rewriting an existing
+// segment from CompressionNone to CompressionBetter, which is what
+// UnifiedStorage.Compact does to a cold segment in the background.
+func BenchmarkCompact(b *testing.B) {
+	records := benchRecords(200, 500)
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		be := backend.NewDisk(b.TempDir())
+		engine := istorage.NewStorageEngine(be, "bench.seg")
+		if err := engine.Write(records); err != nil {
+			b.Fatalf("Write: %v", err)
+		}
+		b.StartTimer()
+
+		engine.CompressionLevel = istorage.CompressionBetter
+		if err := engine.Write(records); err != nil {
+			b.Fatalf("recompress Write: %v", err)
+		}
+		engine.CompressionLevel = istorage.CompressionNone
+	}
+}